@@ -0,0 +1,86 @@
+// Command equipsim simulates a equipment, which accepts HSMS connections and replies to the
+// primary messages of the host according to a scenario file.
+//
+// Usage:
+//
+//	equipsim [-addr address] [-session id] [-s9] [-compatible] [-v] scenario.json
+//
+// The scenario file refers to a SML message library, whose path is relative to the scenario file.
+// The rules of the scenario match the received primary messages against the messages in the library,
+// and reply the secondary messages filled with the bound values. The events of the scenario, e.g.
+// S6F11 and S5F1, are sent periodically on each connection, or when triggered by the rules.
+// Refer to the simulator package for the details.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/simulator"
+)
+
+func main() {
+	var (
+		addr       = flag.String("addr", ":5000", "TCP address to listen on")
+		sessionID  = flag.Int("session", 0, "session id (device id) of the equipment")
+		s9         = flag.Bool("s9", false, "send stream 9 error messages")
+		compatible = flag.Bool("compatible", false, "parse the SML library in the compatible dialect")
+		verbose    = flag.Bool("v", false, "log the sent and received messages")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: equipsim [-addr address] [-session id] [-s9] [-compatible] [-v] scenario.json\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	file := flag.Arg(0)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		logger.Fatalf("error: %v", err)
+	}
+	scenario, err := simulator.ParseEquipmentScenario(data)
+	if err != nil {
+		logger.Fatalf("error: %s: %v", file, err)
+	}
+
+	parseOpts := []sml.Option{}
+	if *compatible {
+		parseOpts = append(parseOpts, sml.WithDialect(sml.DialectCompatible))
+	}
+	lib, warnings, err := simulator.LoadLibrary(file, scenario.Library, parseOpts...)
+	for _, w := range warnings {
+		logger.Printf("warning: %s", w)
+	}
+	if err != nil {
+		logger.Fatalf("error: %v", err)
+	}
+
+	eq, err := simulator.NewEquipment(lib, scenario, simulator.WithLogger(logger))
+	if err != nil {
+		logger.Fatalf("error: %s: %v", file, err)
+	}
+	connOpts := []connection.Option{connection.WithHandler(eq), connection.WithSessionID(*sessionID)}
+	if *s9 {
+		connOpts = append(connOpts, connection.WithS9Messages())
+	}
+	if *verbose {
+		connOpts = append(connOpts, connection.WithMiddleware(connection.Logging(logger)))
+	}
+
+	l, err := connection.Listen(*addr, connOpts...)
+	if err != nil {
+		logger.Fatalf("error: %v", err)
+	}
+	logger.Printf("listening on %s", l.Addr())
+	logger.Fatalf("error: %v", eq.Serve(l))
+}
@@ -0,0 +1,117 @@
+// Command hostsim simulates a host, which connects to a equipment and runs the steps of
+// a scenario file, e.g. to test a equipment in CI.
+//
+// Usage:
+//
+//	hostsim [-addr address] [-session id] [-junit file] [-compatible] [-v] scenario.json
+//
+// The scenario file refers to a SML message library, whose path is relative to the scenario file.
+// The steps send messages and expect their replies, wait for messages from the equipment, and assert
+// the bound values; the steps after a failed step are skipped. Refer to the simulator package for
+// the details.
+//
+// The result of each step is printed, and written in the JUnit XML format to the file, if specified.
+// The exit status is 1 if a step failed, or the connection cannot be established.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/simulator"
+)
+
+func main() {
+	var (
+		addr       = flag.String("addr", "localhost:5000", "TCP address of the equipment")
+		sessionID  = flag.Int("session", 0, "session id (device id) of the equipment")
+		junit      = flag.String("junit", "", "file to write the JUnit XML report")
+		compatible = flag.Bool("compatible", false, "parse the SML library in the compatible dialect")
+		verbose    = flag.Bool("v", false, "log the sent and received messages")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: hostsim [-addr address] [-session id] [-junit file] [-compatible] [-v] scenario.json\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	file := flag.Arg(0)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		logger.Fatalf("error: %v", err)
+	}
+	scenario, err := simulator.ParseHostScenario(data)
+	if err != nil {
+		logger.Fatalf("error: %s: %v", file, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+
+	parseOpts := []sml.Option{}
+	if *compatible {
+		parseOpts = append(parseOpts, sml.WithDialect(sml.DialectCompatible))
+	}
+	lib, warnings, err := simulator.LoadLibrary(file, scenario.Library, parseOpts...)
+	for _, w := range warnings {
+		logger.Printf("warning: %s", w)
+	}
+	if err != nil {
+		logger.Fatalf("error: %v", err)
+	}
+
+	simOpts := []simulator.Option{}
+	connOpts := []connection.Option{connection.WithSessionID(*sessionID)}
+	if *verbose {
+		simOpts = append(simOpts, simulator.WithLogger(logger))
+		connOpts = append(connOpts, connection.WithMiddleware(connection.Logging(logger)))
+	}
+	h, err := simulator.NewHost(lib, scenario, simOpts...)
+	if err != nil {
+		logger.Fatalf("error: %s: %v", file, err)
+	}
+
+	var report *simulator.Report
+	conn, err := connection.Dial(*addr, append(connOpts, connection.WithHandler(h))...)
+	if err != nil {
+		report = &simulator.Report{Name: scenario.Name, Results: []simulator.StepResult{{Name: "connect", Err: err}}}
+	} else {
+		report = h.Run(conn)
+		conn.Close()
+	}
+
+	fmt.Print(report)
+	if *junit != "" {
+		if err := writeJUnit(*junit, report); err != nil {
+			logger.Fatalf("error: %v", err)
+		}
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+// writeJUnit writes the report in the JUnit XML format to the file.
+func writeJUnit(file string, report *simulator.Report) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJUnit(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
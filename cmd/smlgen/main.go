@@ -0,0 +1,82 @@
+// Command smlgen generates typed Go code from SML message dictionaries.
+//
+// Usage:
+//
+//	smlgen [-package name] [-o file] [-compatible] file.sml...
+//
+// For each message in the SML files, smlgen generates a struct type for its variables,
+// a constructor that returns the message filled with the struct, and a decoder that
+// extracts the struct from a received message. Refer to the codegen package for the details.
+//
+// It can be used with go generate, e.g.
+//
+//	//go:generate go run github.com/wolimst/lib-secs2-hsms-go/cmd/smlgen -o messages_gen.go messages.sml
+//
+// The package name defaults to $GOPACKAGE, which is set by go generate.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/codegen"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+func main() {
+	var (
+		pkgName    = flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated code")
+		output     = flag.String("o", "", "output file; standard output if empty")
+		compatible = flag.Bool("compatible", false, "parse the SML files in the compatible dialect")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: smlgen [-package name] [-o file] [-compatible] file.sml...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *pkgName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := []sml.Option{}
+	if *compatible {
+		opts = append(opts, sml.WithDialect(sml.DialectCompatible))
+	}
+
+	messages := []*ast.DataMessage{}
+	failed := false
+	for _, file := range flag.Args() {
+		msgs, errs, warnings := sml.LoadFile(filepath.Dir(file), filepath.Base(file), opts...)
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "error: %s\n", e)
+			failed = true
+		}
+		messages = append(messages, msgs...)
+	}
+	if failed {
+		os.Exit(1)
+	}
+
+	src, err := codegen.Generate(*pkgName, messages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+// Command smllint checks SML message dictionaries with semantic rules, such as
+// the message structures defined in SEMI E5.
+//
+// Usage:
+//
+//	smllint [-enable rules] [-disable rules] [-compatible] file.sml...
+//	smllint -list
+//
+// The messages in all files are checked together, e.g. a primary message in a file can be
+// paired with the secondary message in another file. rules is a comma-separated list of
+// rule names; -list prints the rules. The optional rules are checked only when enabled
+// by -enable. Refer to the lint package for the details.
+//
+// The exit status is 1 if errors or issues are found.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/lint"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+func main() {
+	var (
+		enable     = flag.String("enable", "", "comma-separated rules to check; all rules except the optional rules if empty")
+		disable    = flag.String("disable", "", "comma-separated rules not to check")
+		compatible = flag.Bool("compatible", false, "parse the SML files in the compatible dialect")
+		list       = flag.Bool("list", false, "print the rules and exit")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: smllint [-enable rules] [-disable rules] [-compatible] file.sml...\n")
+		fmt.Fprintf(os.Stderr, "       smllint -list\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *list {
+		for _, rule := range lint.Rules() {
+			optional := ""
+			if rule.Optional {
+				optional = " (optional)"
+			}
+			fmt.Printf("%-10s %s%s\n", rule.Name, rule.Description, optional)
+		}
+		return
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	parseOpts := []sml.Option{}
+	if *compatible {
+		parseOpts = append(parseOpts, sml.WithDialect(sml.DialectCompatible))
+	}
+	lintOpts := []lint.Option{}
+	if *enable != "" {
+		lintOpts = append(lintOpts, lint.WithRules(strings.Split(*enable, ",")...))
+	}
+	if *disable != "" {
+		lintOpts = append(lintOpts, lint.WithoutRules(strings.Split(*disable, ",")...))
+	}
+
+	messages := []*ast.DataMessage{}
+	files := map[*ast.DataMessage]string{}
+	failed := false
+	for _, file := range flag.Args() {
+		msgs, errs, warnings := sml.LoadFile(filepath.Dir(file), filepath.Base(file), parseOpts...)
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "error: %s\n", e)
+			failed = true
+		}
+		for _, msg := range msgs {
+			files[msg] = file
+		}
+		messages = append(messages, msgs...)
+	}
+	if failed {
+		os.Exit(1)
+	}
+
+	issues, err := lint.Lint(messages, lintOpts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	for _, issue := range issues {
+		fmt.Printf("%s: %s\n", files[issue.Message], issue)
+	}
+	if len(issues) != 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,23 @@
+// Command smlls is a language server for SML files, which communicates with
+// the editor using the Language Server Protocol over stdin/stdout.
+//
+// Usage:
+//
+//	smlls
+//
+// Refer to the lsp package for the supported features.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/lsp"
+)
+
+func main() {
+	if err := lsp.NewServer().Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "smlls: %v\n", err)
+		os.Exit(1)
+	}
+}
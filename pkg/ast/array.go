@@ -0,0 +1,66 @@
+package ast
+
+import "fmt"
+
+// arrayVariable is the size range of a array variable, which is a variable filled in
+// with an array of data values, e.g. VIDS of <U4[..] VIDS>.
+//
+// A node with a array variable should consist of the single variable, so that the size range
+// can be written as the data item size in the string representation of the node.
+type arrayVariable struct {
+	minSize, maxSize int // size range of the array; maxSize == -1 means no limit
+
+	// Rep invariants
+	// - minSize >= 0, maxSize >= -1
+	// - minSize <= maxSize, when maxSize != -1
+}
+
+// checkArrayVariable panics if the array variable violates the rep invariants of the node, that
+// the node should consist of a single variable without a default value, if the array variable exists.
+func checkArrayVariable(a *arrayVariable, variables map[string]int, size int, defaults map[string]interface{}) {
+	if a == nil {
+		return
+	}
+	if a.minSize < 0 || a.maxSize < -1 || (a.maxSize != -1 && a.minSize > a.maxSize) {
+		panic("invalid array size range")
+	}
+	if size != 1 || len(variables) != 1 {
+		panic("array variable should be the only value in the node")
+	}
+	if len(defaults) != 0 {
+		panic("array variable cannot have a default value")
+	}
+}
+
+// sizeRange returns the size range of the array variable; ok is false if a is nil.
+func (a *arrayVariable) sizeRange() (min, max int, ok bool) {
+	if a == nil {
+		return 0, 0, false
+	}
+	return a.minSize, a.maxSize, true
+}
+
+// check returns an error if the size of the array filled into the variable is out of the size range.
+func (a *arrayVariable) check(name string, size int) error {
+	if size < a.minSize || (a.maxSize != -1 && a.maxSize < size) {
+		return fmt.Errorf("variable %q: array size %d out of range %s", name, size, a)
+	}
+	return nil
+}
+
+// String returns the size range in the form of the data item size, e.g. [..], [2..], [2..7].
+func (a *arrayVariable) String() string {
+	if a.minSize == 0 && a.maxSize == -1 {
+		return "[..]"
+	}
+	return sizeRangeString(a.minSize, a.maxSize)
+}
+
+// sizeString returns the data item size in the string representation of a node, which is
+// the size range of the array variable if exists, or the size of the node, e.g. [3].
+func sizeString(a *arrayVariable, size int) string {
+	if a != nil {
+		return a.String()
+	}
+	return fmt.Sprintf("[%d]", size)
+}
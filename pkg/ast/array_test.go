@@ -0,0 +1,149 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the array variables of UintNode, IntNode, FloatNode, BinaryNode and BooleanNode.
+//
+// Testing Strategy:
+//
+// Create nodes with array variables using WithArraySize(), fill in values or match items,
+// and test the string representation of the result, the matched values, or the error text.
+//
+// Partitions:
+//
+// - Node: UintNode, IntNode, FloatNode, BinaryNode, BooleanNode, ListNode with ellipsis
+// - Size range: no limit, [min..], [min..max], exact size
+// - Fill-in value: slice in range, slice out of range, scalar, rename, not filled
+// - Element: allowed, not allowed by the constraint
+// - Invalid array variable: multiple values, data value, default value, invalid size range
+
+func TestArray_FillVariables(t *testing.T) {
+	var tests = []struct {
+		description    string                 // Test case description
+		node           ItemNode               // Input node
+		values         map[string]interface{} // Input to FillVariables()
+		expectedString string                 // expected result from String(), empty if error
+		expectedError  string                 // expected error text, empty if no error
+	}{
+		{
+			description:    "UintNode, not filled",
+			node:           NewUintNode(4, "SVIDS").(*UintNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{},
+			expectedString: "<U4[..] SVIDS>",
+		},
+		{
+			description:    "UintNode, slice",
+			node:           NewUintNode(4, "SVIDS").(*UintNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{"SVIDS": []uint32{1, 2, 3}},
+			expectedString: "<U4[3] 1 2 3>",
+		},
+		{
+			description:    "UintNode, empty slice",
+			node:           NewUintNode(4, "SVIDS").(*UintNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{"SVIDS": []uint32{}},
+			expectedString: "<U4[0]>",
+		},
+		{
+			description:   "UintNode, slice out of range",
+			node:          NewUintNode(4, "SVIDS").(*UintNode).WithArraySize(1, -1),
+			values:        map[string]interface{}{"SVIDS": []uint32{}},
+			expectedError: `variable "SVIDS": array size 0 out of range [1..]`,
+		},
+		{
+			description:   "UintNode, element not allowed",
+			node:          NewUintNode(4, "SVIDS").(*UintNode).WithConstraint("SVIDS", NewConstraint(ValueRange{1, 10})).(*UintNode).WithArraySize(0, -1),
+			values:        map[string]interface{}{"SVIDS": []int{1, 11}},
+			expectedError: `variable "SVIDS": value 11 not allowed, expected {1..10}`,
+		},
+		{
+			description:    "IntNode, scalar",
+			node:           NewIntNode(2, "TEMPS").(*IntNode).WithArraySize(1, 3),
+			values:         map[string]interface{}{"TEMPS": -1},
+			expectedString: "<I2[1] -1>",
+		},
+		{
+			description:   "IntNode, slice out of range",
+			node:          NewIntNode(2, "TEMPS").(*IntNode).WithArraySize(1, 3),
+			values:        map[string]interface{}{"TEMPS": []interface{}{1, 2, 3, 4}},
+			expectedError: `variable "TEMPS": array size 4 out of range [1..3]`,
+		},
+		{
+			description:    "FloatNode, rename",
+			node:           NewFloatNode(8, "RATIOS").(*FloatNode).WithArraySize(2, 2),
+			values:         map[string]interface{}{"RATIOS": "VALUES"},
+			expectedString: "<F8[2] VALUES>",
+		},
+		{
+			description:    "BinaryNode, []byte",
+			node:           NewBinaryNode("DATA").(*BinaryNode).WithArraySize(0, 4),
+			values:         map[string]interface{}{"DATA": []byte{1, 2}},
+			expectedString: "<B[2] 0b1 0b10>",
+		},
+		{
+			description:    "BooleanNode, []bool",
+			node:           NewBooleanNode("FLAGS").(*BooleanNode).WithArraySize(2, -1),
+			values:         map[string]interface{}{"FLAGS": []bool{true, false}},
+			expectedString: "<BOOLEAN[2] T F>",
+		},
+		{
+			description:    "ListNode with ellipsis, renamed array variables",
+			node:           NewListNode(NewUintNode(4, "SVIDS").(*UintNode).WithArraySize(0, -1), "..."),
+			values:         map[string]interface{}{"...": 1, "SVIDS[1]": []int{1, 2}},
+			expectedString: "<L[2]\n  <U4[..] SVIDS[0]>\n  <U4[2] 1 2>\n>",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		node, err := test.node.FillVariables(test.values)
+		if test.expectedError != "" {
+			assert.EqualError(t, err, test.expectedError)
+			assert.Nil(t, node)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedString, fmt.Sprint(node))
+	}
+}
+
+func TestArray_Match(t *testing.T) {
+	template := NewListNode(
+		NewUintNode(4, "SVIDS").(*UintNode).WithArraySize(0, 3),
+		NewIntNode(1, "V1").(*IntNode).WithArraySize(0, -1),
+		NewFloatNode(4, "V2").(*FloatNode).WithArraySize(0, -1),
+		NewBinaryNode("V3").(*BinaryNode).WithArraySize(0, -1),
+		NewBooleanNode("V4").(*BooleanNode).WithArraySize(0, -1),
+	)
+
+	item := NewListNode(NewUintNode(4, 1, 2), NewIntNode(1), NewFloatNode(4, 0.5), NewBinaryNode(1, 255), NewBooleanNode(true))
+	values, err := Match(template, item)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"SVIDS": []uint64{1, 2}, "V1": []int64{}, "V2": []float64{0.5}, "V3": []byte{1, 255}, "V4": []bool{true},
+	}, values)
+
+	filled, err := template.FillVariables(values)
+	assert.NoError(t, err)
+	assert.Equal(t, item, filled)
+
+	_, err = Match(template, NewListNode(NewUintNode(4, 1, 2, 3, 4), NewIntNode(1), NewFloatNode(4), NewBinaryNode(), NewBooleanNode()))
+	assert.EqualError(t, err, `variable "SVIDS": array size 4 out of range [0..3]`)
+}
+
+func TestArray_Panics(t *testing.T) {
+	min, max, ok := NewUintNode(4, "V1").(*UintNode).WithArraySize(1, 2).(*UintNode).ArraySize()
+	assert.Equal(t, []interface{}{1, 2, true}, []interface{}{min, max, ok})
+	_, _, ok = NewUintNode(4, "V1").(*UintNode).ArraySize()
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { NewUintNode(4, "V1", "V2").(*UintNode).WithArraySize(0, -1) })
+	assert.Panics(t, func() { NewIntNode(4, 1).(*IntNode).WithArraySize(0, -1) })
+	assert.Panics(t, func() { NewFloatNode(4, "V1").(*FloatNode).WithDefault("V1", 1).(*FloatNode).WithArraySize(0, -1) })
+	assert.Panics(t, func() { NewBinaryNode("V1").(*BinaryNode).WithArraySize(0, -1).(*BinaryNode).WithDefault("V1", 1) })
+	assert.Panics(t, func() { NewBooleanNode("V1").(*BooleanNode).WithArraySize(2, 1) })
+	assert.Panics(t, func() { NewBooleanNode("V1").(*BooleanNode).WithArraySize(-1, 1) })
+}
@@ -26,12 +26,17 @@ type ASCIINode struct {
 	// - variable.name should adhere to the variable naming rule; refer to interface.go
 	// - variable.minLength >= 0, variable.maxLength >= -1
 	// - variable.minLength <= variable.maxLength, when variable.maxLength != -1
+	// - If variable.hasDefault == false, variable.defaultValue should be empty string
+	//   else, variable.defaultValue should consist of ASCII characters, and its length
+	//   should be in range of [variable.minLength, variable.maxLength]
 }
 
 type asciiNodeVariable struct {
-	name      string // variable name
-	minLength int    // minimum length of the string value to be filled; -1 means no limit
-	maxLength int    // maximum length of the string value to be filled; -1 means no limit
+	name         string // variable name
+	minLength    int    // minimum length of the string value to be filled; -1 means no limit
+	maxLength    int    // maximum length of the string value to be filled; -1 means no limit
+	defaultValue string // default value of the variable, used only when hasDefault == true
+	hasDefault   bool   // a flag that represents whether the variable has a default value
 }
 
 // Factory methods
@@ -59,7 +64,7 @@ func NewASCIINode(str string) ItemNode {
 // minLength <= maxLength, when maxLength != -1.
 func NewASCIINodeVariable(name string, minLength, maxLength int) ItemNode {
 	node := &ASCIINode{
-		variable: asciiNodeVariable{name, minLength, maxLength},
+		variable: asciiNodeVariable{name: name, minLength: minLength, maxLength: maxLength},
 		isValue:  false,
 	}
 	node.checkRep()
@@ -90,6 +95,13 @@ func (node *ASCIINode) FillInStringLength() (min int, max int) {
 	return node.variable.minLength, node.variable.maxLength
 }
 
+// Value returns the string value of the node.
+//
+// If the node have a variable, returns empty string.
+func (node *ASCIINode) Value() string {
+	return node.value
+}
+
 // Variables implements DataItemNode.Variables().
 func (node *ASCIINode) Variables() []string {
 	if node.isValue {
@@ -98,33 +110,70 @@ func (node *ASCIINode) Variables() []string {
 	return []string{node.variable.name}
 }
 
+// Default returns the default value of the variable, and whether the default value exists.
+// The default value has the type of string.
+func (node *ASCIINode) Default(name string) (interface{}, bool) {
+	if node.isValue || node.variable.name != name || !node.variable.hasDefault {
+		return nil, false
+	}
+	return node.variable.defaultValue, true
+}
+
+// WithDefault returns a new ASCIINode, with the default value set to the variable.
+// The variable should exist in the node, and the value should be a string that consists of
+// ASCII characters, in range of the fill-in string length.
+func (node *ASCIINode) WithDefault(name string, value interface{}) ItemNode {
+	if node.isValue || node.variable.name != name {
+		panic("variable not found")
+	}
+	str, ok := value.(string)
+	if !ok {
+		panic("default value should be a string")
+	}
+
+	variable := node.variable
+	variable.defaultValue, variable.hasDefault = str, true
+	result := &ASCIINode{variable: variable}
+	result.checkRep()
+	return result
+}
+
 // FillVariables implements ItemNode.FillVariables().
 //
-// The fill-in value must be acceptable by the NewASCIINode factory method, and
+// The fill-in value should be a string, a []byte, or a time.Time, which is formatted
+// with the time format of the options; refer to WithTimeFormat().
+// The converted string must be acceptable by the NewASCIINode factory method, and
 // it should be in range of the fill-in string length.
-func (node *ASCIINode) FillVariables(values map[string]interface{}) ItemNode {
+// If the variable doesn't exist in the input map, the default value is filled in, if exists.
+func (node *ASCIINode) FillVariables(values map[string]interface{}, opts ...FillOption) (ItemNode, error) {
 	if node.isValue {
-		return node
+		return node, nil
 	}
 
-	if _, ok := values[node.variable.name]; !ok {
-		return node
+	name := node.variable.name
+	if _, ok := values[name]; !ok {
+		if node.variable.hasDefault {
+			return NewASCIINode(node.variable.defaultValue), nil
+		}
+		return node, nil
 	}
 
-	value, ok := values[node.variable.name].(string)
-	if !ok {
-		panic("fill-in value has invalid type for ASCIINode")
+	value, err := convertASCII(values[name], newFillOptions(opts))
+	if err != nil {
+		return nil, fmt.Errorf("variable %q: %v", name, err)
 	}
 
-	if len(value) < node.variable.minLength {
-		panic("fill-in string length overflow")
+	if len(value) < node.variable.minLength ||
+		(node.variable.maxLength != -1 && node.variable.maxLength < len(value)) {
+		return nil, fmt.Errorf("variable %q: fill-in string length overflow, expected %s",
+			name, sizeRangeString(node.variable.minLength, node.variable.maxLength))
 	}
 
-	if node.variable.maxLength != -1 && node.variable.maxLength < len(value) {
-		panic("fill-in string length overflow")
+	result, err := tryNew(func() ItemNode { return NewASCIINode(value) })
+	if err != nil {
+		return nil, fmt.Errorf("variable %q: %v", name, err)
 	}
-
-	return NewASCIINode(value)
+	return result, nil
 }
 
 // ToBytes implements ItemNode.ToBytes()
@@ -146,19 +195,15 @@ func (node *ASCIINode) ToBytes() []byte {
 }
 
 // String returns the string representation of the node.
+//
+// Printable characters are enclosed in double quotes, with double quotes and
+// backslashes escaped by a backslash, e.g. "say \"hi\"". Non-printable characters
+// are represented as ASCII number codes in hexadecimal, e.g. 0x0A.
 func (node *ASCIINode) String() string {
 	if !node.isValue {
-		var lengthStr string
-		min, max := node.variable.minLength, node.variable.maxLength
-
-		if min == 0 && max == -1 {
-			// empty lengthStr
-		} else if min == max {
-			lengthStr = fmt.Sprintf("[%d]", max)
-		} else if max == -1 {
-			lengthStr = fmt.Sprintf("[%d..]", min)
-		} else {
-			lengthStr = fmt.Sprintf("[%d..%d]", min, max)
+		lengthStr := sizeRangeString(node.variable.minLength, node.variable.maxLength)
+		if node.variable.hasDefault {
+			return fmt.Sprintf("<A%s %s=%s>", lengthStr, node.variable.name, asciiLiteral(node.variable.defaultValue))
 		}
 		return fmt.Sprintf("<A%s %s>", lengthStr, node.variable.name)
 	}
@@ -167,9 +212,20 @@ func (node *ASCIINode) String() string {
 		return "<A[0]>"
 	}
 
+	return fmt.Sprintf(`<A %s>`, asciiLiteral(node.value))
+}
+
+// asciiLiteral returns the SML literal of the ASCII string, which is a sequence of
+// quoted strings and 0xNN codes of the non-printable control characters, e.g. "abc" 0x0A "def".
+// The empty string is represented as "".
+func asciiLiteral(s string) string {
+	if s == "" {
+		return `""`
+	}
+
 	var sb strings.Builder
 	printableState := false
-	for _, ch := range node.value {
+	for _, ch := range s {
 		if ch < 32 || ch == 127 {
 			// ch is a non-printable control character
 			// 32: space, which is the first printable character, 127: del
@@ -184,6 +240,9 @@ func (node *ASCIINode) String() string {
 				printableState = true
 				sb.WriteString(` "`) // Open double quote
 			}
+			if ch == '"' || ch == '\\' {
+				sb.WriteByte('\\') // Escape double quote and backslash
+			}
 			sb.WriteRune(ch)
 		}
 	}
@@ -192,14 +251,14 @@ func (node *ASCIINode) String() string {
 		sb.WriteString(`"`)
 	}
 
-	return fmt.Sprintf(`<A%s>`, sb.String())
+	return sb.String()[1:]
 }
 
 // Private methods
 
 func (node *ASCIINode) checkRep() {
 	if node.isValue {
-		if node.variable.name != "" || node.variable.minLength != 0 || node.variable.maxLength != 0 {
+		if node.variable != (asciiNodeVariable{}) {
 			panic("value and variable should not be used at the same time")
 		}
 
@@ -226,5 +285,19 @@ func (node *ASCIINode) checkRep() {
 				panic("invalid fill-in string length")
 			}
 		}
+
+		if node.variable.hasDefault {
+			length := len(node.variable.defaultValue)
+			if length < node.variable.minLength || (node.variable.maxLength != -1 && node.variable.maxLength < length) {
+				panic("default value length overflow")
+			}
+			for _, ch := range node.variable.defaultValue {
+				if ch > unicode.MaxASCII {
+					panic("encountered non-ASCII character")
+				}
+			}
+		} else if node.variable.defaultValue != "" {
+			panic("default value should not be used without the flag")
+		}
 	}
 }
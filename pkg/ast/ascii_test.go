@@ -19,6 +19,7 @@ import (
 // - Length of the string: 0, 1, ...
 // - Non-printable characters (LF, TAB, etc.) in string literal: true, false
 // - Position of the non-printable characters: head, middle, tail
+// - Characters to escape (double quote, backslash) in string literal: true, false
 //
 // When the node contains variable
 // - Fill-in string min length: 0, 1, ...
@@ -118,6 +119,16 @@ func TestASCIINode_NoVariable_ProducedByFactoryMethod(t *testing.T) {
 			expectedToBytes:         []byte{0x41, 6, 0x74, 0x65, 0x09, 0x7F, 0x78, 0x74},
 			expectedString:          `<A "te" 0x09 0x7F "xt">`,
 		},
+		{
+			description:             "Length: 8, Double quotes and backslash",
+			input:                   `say "\\"`,
+			expectedSize:            8,
+			expectedFillInStrLenMin: -2,
+			expectedFillInStrLenMax: -2,
+			expectedVariables:       []string{},
+			expectedToBytes:         []byte{0x41, 8, 0x73, 0x61, 0x79, 0x20, 0x22, 0x5C, 0x5C, 0x22},
+			expectedString:          `<A "say \"\\\\\"">`,
+		},
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
@@ -343,7 +354,8 @@ func TestASCIINode_Variable_ProducedByFillVariables(t *testing.T) {
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
 		node := NewASCIINodeVariable(test.input[0].(string), test.input[1].(int), test.input[2].(int))
-		node = node.FillVariables(test.inputFillInValues)
+		node, err := node.FillVariables(test.inputFillInValues)
+		assert.NoError(t, err)
 		min, max := node.(*ASCIINode).FillInStringLength()
 
 		assert.Equal(t, test.expectedSize, node.Size())
@@ -4,6 +4,7 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 	"unicode"
 )
 
@@ -189,6 +190,15 @@ func (node *DataMessage) Direction() string {
 	return node.direction
 }
 
+// Item returns the data item of the SECS-II message.
+// If the message doesn't have a data item, it will return nil.
+func (node *DataMessage) Item() ItemNode {
+	if _, ok := node.dataItem.(emptyItemNode); ok {
+		return nil
+	}
+	return node.dataItem
+}
+
 // SessionID returns the session id of the SECS-II message.
 // If the session id was not set, it will return -1.
 func (node *DataMessage) SessionID() int {
@@ -259,10 +269,16 @@ func (node *DataMessage) Variables() []string {
 // variables in this SECS-II message.
 //
 // The map input argument has variable name as its key, and fill-in value as its value.
-// Each fill-in value must be acceptable by the ItemNode's factory method.
-// If a variable in the ItemNode doesn't exist in the input map, the variable will remain unchanged.
-func (node *DataMessage) FillVariables(values map[string]interface{}) *DataMessage {
-	item := node.dataItem.FillVariables(values)
+// Each fill-in value is converted into the data value of the ItemNode, and the options
+// configure the conversion.
+// If a variable in the ItemNode doesn't exist in the input map, its default value is filled in,
+// if exists; otherwise, the variable will remain unchanged.
+// An error is returned when a fill-in value cannot be filled in; refer to ItemNode.FillVariables().
+func (node *DataMessage) FillVariables(values map[string]interface{}, opts ...FillOption) (*DataMessage, error) {
+	item, err := node.dataItem.FillVariables(values, opts...)
+	if err != nil {
+		return nil, err
+	}
 
 	message := &DataMessage{
 		name:        node.name,
@@ -275,7 +291,21 @@ func (node *DataMessage) FillVariables(values map[string]interface{}) *DataMessa
 		systemBytes: node.systemBytes,
 	}
 	message.checkRep()
-	return message
+	return message, nil
+}
+
+// FillVariablesStrict is like FillVariables, but it returns an error when any variable
+// remains unbound after the values and the default values are filled in.
+// The error text contains the names of the unbound variables, in the order of their appearance.
+func (node *DataMessage) FillVariablesStrict(values map[string]interface{}, opts ...FillOption) (*DataMessage, error) {
+	message, err := node.FillVariables(values, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if unbound := message.Variables(); len(unbound) != 0 {
+		return nil, fmt.Errorf("unbound variables: %s", strings.Join(unbound, ", "))
+	}
+	return message, nil
 }
 
 // Type returns HSMS message type.
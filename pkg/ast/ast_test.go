@@ -41,6 +41,7 @@ func TestMessageNode_ProducedByFactoryMethod_EmptyItem(t *testing.T) {
 	assert.Equal(t, -1, msg.SessionID())
 	assert.Equal(t, []byte{0, 0, 0, 0}, msg.SystemBytes())
 	assert.Equal(t, "S0F0 [W] H->E empty_message", msg.Header())
+	assert.Nil(t, msg.Item())
 	assert.Equal(t, []string{}, msg.Variables())
 	assert.Equal(t, []byte{}, msg.ToBytes())
 	assert.Equal(t, "S0F0 [W] H->E empty_message\n.", fmt.Sprint(msg))
@@ -95,6 +96,9 @@ func TestMessageNode_ProducedByFactoryMethod_NoHSMS(t *testing.T) {
 			test.inputDirection,
 			test.inputItemNode,
 		)
+		if test.inputItemNode.Size() != 0 {
+			assert.Equal(t, test.inputItemNode, msg.Item())
+		}
 		assert.Equal(t, test.expectedVariables, msg.Variables())
 		assert.Equal(t, test.expectedToBytes, msg.ToBytes())
 		assert.Equal(t, test.expectedString, fmt.Sprint(msg))
@@ -319,7 +323,8 @@ func TestMessageNode_ProducedByProducerMethods(t *testing.T) {
 			test.inputDirection,
 			test.inputItemNode,
 		)
-		msg = msg.FillVariables(test.inputFillInValues)
+		msg, err := msg.FillVariables(test.inputFillInValues)
+		assert.NoError(t, err)
 		msg = msg.SetSessionIDAndSystemBytes(test.inputSessionID, test.inputSystemBytes)
 		msg = msg.SetWaitBit(test.inputSetWaitBit)
 		assert.Equal(t, test.expectedVariables, msg.Variables())
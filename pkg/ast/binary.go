@@ -9,14 +9,18 @@ import (
 // BinaryNode is a immutable data type that represents a binary item in a SECS-II message.
 // Implements ItemNode.
 type BinaryNode struct {
-	values    []int          // Array of binary values between [0, 255], represented as integers
-	variables map[string]int // Variable name and its position in the data array
+	values    []int                  // Array of binary values between [0, 255], represented as integers
+	variables map[string]int         // Variable name and its position in the data array
+	defaults  map[string]interface{} // Variable name and its default value; nil if there's no default value
+	array     *arrayVariable         // Size range of the array variable; nil if the node doesn't have a array variable
 
 	// Rep invariants
 	// - Each values[i] should be in range of [0, 255]
 	// - If a variable exists in position i, values[i] will be zero-value (0) and should not be used.
 	// - variable name should adhere to the variable naming rule; refer to interface.go
 	// - variable positions should be unique, and be in range of [0, len(values))
+	// - defaults should be nil or non-empty, and its keys should be the variable names in the node
+	// - array should be nil, or the node should consist of a single variable without a default value
 }
 
 // Factory methods
@@ -58,7 +62,7 @@ func NewBinaryNode(values ...interface{}) ItemNode {
 		}
 	}
 
-	node := &BinaryNode{nodeValues, nodeVariables}
+	node := &BinaryNode{nodeValues, nodeVariables, nil, nil}
 	node.checkRep()
 	return node
 }
@@ -75,31 +79,85 @@ func (node *BinaryNode) Variables() []string {
 	return getVariableNames(node.variables)
 }
 
+// Values returns the data values and the variable names in the node, in their positions.
+// The result can be passed to the factory method, e.g. NewBinaryNode(node.Values()...).
+func (node *BinaryNode) Values() []interface{} {
+	result := make([]interface{}, 0, node.Size())
+	for _, v := range node.values {
+		result = append(result, v)
+	}
+	for name, pos := range node.variables {
+		result[pos] = name
+	}
+	return result
+}
+
+// Default returns the default value of the variable, and whether the default value exists.
+// The default value has the type of int.
+func (node *BinaryNode) Default(name string) (interface{}, bool) {
+	v, ok := node.defaults[name]
+	return v, ok
+}
+
+// WithDefault returns a new BinaryNode, with the default value set to the variable.
+// The variable should exist in the node, and the value should be acceptable by the factory method
+// as a data value.
+func (node *BinaryNode) WithDefault(name string, value interface{}) ItemNode {
+	result := &BinaryNode{node.values, node.variables, withDefault(node.defaults, node.variables, name, NewBinaryNode(value)), node.array}
+	result.checkRep()
+	return result
+}
+
+// ArraySize returns the size range of the array variable, where max == -1 means no limit.
+// ok is false if the node doesn't have a array variable.
+func (node *BinaryNode) ArraySize() (min, max int, ok bool) {
+	return node.array.sizeRange()
+}
+
+// WithArraySize returns a new BinaryNode, whose variable is a array variable with the size range,
+// e.g. <B[2..5] VAR>, which is filled in with an array of data values in range of the size range.
+// The node should consist of a single variable without a default value.
+//
+// min and max should meet following conditions.
+// min >= 0, max >= -1, where -1 means no limit.
+// min <= max, when max != -1.
+func (node *BinaryNode) WithArraySize(min, max int) ItemNode {
+	result := &BinaryNode{
+		node.values, node.variables, node.defaults,
+		&arrayVariable{min, max},
+	}
+	result.checkRep()
+	return result
+}
+
 // FillVariables implements ItemNode.FillVariables().
-func (node *BinaryNode) FillVariables(values map[string]interface{}) ItemNode {
+//
+// A fill-in value of any Go integer type in range of [0, 255], or a binary string
+// such as "0b1001" is converted. A slice or an array of integers, e.g. []byte,
+// is filled in as multiple data values in place of the variable.
+// Other string fill-in values rename the variable.
+func (node *BinaryNode) FillVariables(values map[string]interface{}, opts ...FillOption) (ItemNode, error) {
 	if len(node.variables) == 0 {
-		return node
+		return node, nil
 	}
 
-	nodeValues := make([]interface{}, 0, node.Size())
-	for _, v := range node.values {
-		nodeValues = append(nodeValues, v)
+	filled, err := fillValues(
+		node.Size(), func(i int) interface{} { return node.values[i] }, node.variables, nil,
+		node.defaults, node.array, values, func(v interface{}) ([]interface{}, error) { return convertBinaryValues(v) },
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	createNew := false
-	for name, pos := range node.variables {
-		if v, ok := values[name]; ok {
-			nodeValues[pos] = v
-			createNew = true
-		} else {
-			nodeValues[pos] = name
-		}
+	if !filled.createNew {
+		return node, nil
 	}
-
-	if !createNew {
-		return node
+	result, err := tryNew(func() ItemNode { return NewBinaryNode(filled.values...) })
+	if err != nil {
+		return nil, err
 	}
-	return NewBinaryNode(nodeValues...)
+	result.(*BinaryNode).defaults, result.(*BinaryNode).array = filled.defaults, filled.array
+	return result, nil
 }
 
 // ToBytes implements ItemNode.ToBytes()
@@ -134,9 +192,12 @@ func (node *BinaryNode) String() string {
 
 	for name, pos := range node.variables {
 		values[pos] = name
+		if d, ok := node.defaults[name]; ok {
+			values[pos] += "=" + "0b" + strconv.FormatInt(int64(d.(int)), 2)
+		}
 	}
 
-	return fmt.Sprintf("<B[%d] %v>", node.Size(), strings.Join(values, " "))
+	return fmt.Sprintf("<B%s %v>", sizeString(node.array, node.Size()), strings.Join(values, " "))
 }
 
 // Private methods
@@ -167,4 +228,7 @@ func (node *BinaryNode) checkRep() {
 			panic("variable position overflow")
 		}
 	}
+
+	checkDefaults(node.defaults, node.variables, nil)
+	checkArrayVariable(node.array, node.variables, node.Size(), node.defaults)
 }
@@ -138,7 +138,8 @@ func TestBinaryNode_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewBinaryNode(test.input...).FillVariables(test.fillInValues)
+		node, err := NewBinaryNode(test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -8,13 +8,17 @@ import (
 // BinaryNode is a immutable data type that represents a binary data item in a SECS-II message.
 // Implements ItemNode.
 type BooleanNode struct {
-	values    []bool         // Array of boolean values
-	variables map[string]int // Variable name and its position in the data array
+	values    []bool                 // Array of boolean values
+	variables map[string]int         // Variable name and its position in the data array
+	defaults  map[string]interface{} // Variable name and its default value; nil if there's no default value
+	array     *arrayVariable         // Size range of the array variable; nil if the node doesn't have a array variable
 
 	// Rep invariants
 	// - If a variable exists in position i, values[i] will be zero-value (false) and should not be used.
 	// - variable name should adhere to the variable naming rule; refer to interface.go
 	// - variable positions should be unique, and be in range of [0, len(values))
+	// - defaults should be nil or non-empty, and its keys should be the variable names in the node
+	// - array should be nil, or the node should consist of a single variable without a default value
 }
 
 // Factory methods
@@ -49,7 +53,7 @@ func NewBooleanNode(values ...interface{}) ItemNode {
 		}
 	}
 
-	node := &BooleanNode{nodeValues, nodeVariables}
+	node := &BooleanNode{nodeValues, nodeVariables, nil, nil}
 	node.checkRep()
 	return node
 }
@@ -66,31 +70,84 @@ func (node *BooleanNode) Variables() []string {
 	return getVariableNames(node.variables)
 }
 
+// Values returns the data values and the variable names in the node, in their positions.
+// The result can be passed to the factory method, e.g. NewBooleanNode(node.Values()...).
+func (node *BooleanNode) Values() []interface{} {
+	result := make([]interface{}, 0, node.Size())
+	for _, v := range node.values {
+		result = append(result, v)
+	}
+	for name, pos := range node.variables {
+		result[pos] = name
+	}
+	return result
+}
+
+// Default returns the default value of the variable, and whether the default value exists.
+// The default value has the type of bool.
+func (node *BooleanNode) Default(name string) (interface{}, bool) {
+	v, ok := node.defaults[name]
+	return v, ok
+}
+
+// WithDefault returns a new BooleanNode, with the default value set to the variable.
+// The variable should exist in the node, and the value should be acceptable by the factory method
+// as a data value.
+func (node *BooleanNode) WithDefault(name string, value interface{}) ItemNode {
+	result := &BooleanNode{node.values, node.variables, withDefault(node.defaults, node.variables, name, NewBooleanNode(value)), node.array}
+	result.checkRep()
+	return result
+}
+
+// ArraySize returns the size range of the array variable, where max == -1 means no limit.
+// ok is false if the node doesn't have a array variable.
+func (node *BooleanNode) ArraySize() (min, max int, ok bool) {
+	return node.array.sizeRange()
+}
+
+// WithArraySize returns a new BooleanNode, whose variable is a array variable with the size range,
+// e.g. <BOOLEAN[2..5] VAR>, which is filled in with an array of data values in range of the size range.
+// The node should consist of a single variable without a default value.
+//
+// min and max should meet following conditions.
+// min >= 0, max >= -1, where -1 means no limit.
+// min <= max, when max != -1.
+func (node *BooleanNode) WithArraySize(min, max int) ItemNode {
+	result := &BooleanNode{
+		node.values, node.variables, node.defaults,
+		&arrayVariable{min, max},
+	}
+	result.checkRep()
+	return result
+}
+
 // FillVariables implements ItemNode.FillVariables().
-func (node *BooleanNode) FillVariables(values map[string]interface{}) ItemNode {
+//
+// A fill-in value of bool type is converted, and a slice or an array of them, e.g. []bool,
+// is filled in as multiple data values in place of the variable.
+// A string fill-in value renames the variable.
+func (node *BooleanNode) FillVariables(values map[string]interface{}, opts ...FillOption) (ItemNode, error) {
 	if len(node.variables) == 0 {
-		return node
+		return node, nil
 	}
 
-	nodeValues := make([]interface{}, 0, node.Size())
-	for _, v := range node.values {
-		nodeValues = append(nodeValues, v)
+	filled, err := fillValues(
+		node.Size(), func(i int) interface{} { return node.values[i] }, node.variables, nil,
+		node.defaults, node.array, values, func(v interface{}) ([]interface{}, error) { return convertValues(v, convertBoolean) },
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	createNew := false
-	for name, pos := range node.variables {
-		if v, ok := values[name]; ok {
-			nodeValues[pos] = v
-			createNew = true
-		} else {
-			nodeValues[pos] = name
-		}
+	if !filled.createNew {
+		return node, nil
 	}
-
-	if !createNew {
-		return node
+	result, err := tryNew(func() ItemNode { return NewBooleanNode(filled.values...) })
+	if err != nil {
+		return nil, err
 	}
-	return NewBooleanNode(nodeValues...)
+	result.(*BooleanNode).defaults, result.(*BooleanNode).array = filled.defaults, filled.array
+	return result, nil
 }
 
 // ToBytes implements ItemNode.ToBytes()
@@ -132,9 +189,16 @@ func (node *BooleanNode) String() string {
 
 	for name, pos := range node.variables {
 		values[pos] = name
+		if d, ok := node.defaults[name]; ok {
+			if d.(bool) {
+				values[pos] += "=T"
+			} else {
+				values[pos] += "=F"
+			}
+		}
 	}
 
-	return fmt.Sprintf("<BOOLEAN[%d] %v>", node.Size(), strings.Join(values, " "))
+	return fmt.Sprintf("<BOOLEAN%s %v>", sizeString(node.array, node.Size()), strings.Join(values, " "))
 }
 
 // Private methods
@@ -159,4 +223,7 @@ func (node *BooleanNode) checkRep() {
 			panic("variable position overflow")
 		}
 	}
+
+	checkDefaults(node.defaults, node.variables, nil)
+	checkArrayVariable(node.array, node.variables, node.Size(), node.defaults)
 }
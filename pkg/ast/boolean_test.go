@@ -138,7 +138,8 @@ func TestBooleanNode_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewBooleanNode(test.input...).FillVariables(test.fillInValues)
+		node, err := NewBooleanNode(test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -0,0 +1,399 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Constraint is a immutable data type that represents the values allowed to be filled into
+// a variable of UintNode, IntNode, or FloatNode.
+//
+// It consists of value ranges, and a value is allowed when it is in any of the ranges.
+// An enumerated value is represented as a range with the same minimum and maximum.
+//
+// The string representation of a constraint is the value ranges enclosed in curly braces,
+// separated by a space, e.g. {1..100}, {0 1 2}, {..-1 10..}, which follows the variable name
+// in the string representation of the node, e.g. <U4 SVID{1..100}>.
+type Constraint struct {
+	ranges []ValueRange // value ranges, in the insertion order
+
+	// Rep invariants
+	// - len(ranges) > 0
+	// - Min and Max of each range should be nil, uint64, int64, or float64
+	// - Min and Max of each range should not be nil at the same time
+	// - Min <= Max, when both are not nil
+}
+
+// ValueRange represents a range of numeric values, from Min to Max inclusive.
+// Min and Max should be a number, or nil which means no limit.
+type ValueRange struct {
+	Min, Max interface{}
+}
+
+// Factory methods
+
+// NewConstraint creates a new Constraint that allows the values in any of the ranges.
+//
+// At least one range should be specified. In each range, at least one of Min and Max
+// should be specified, and Min should not be greater than Max.
+func NewConstraint(ranges ...ValueRange) *Constraint {
+	constraintRanges := make([]ValueRange, 0, len(ranges))
+	for _, r := range ranges {
+		var min, max interface{}
+		if r.Min != nil {
+			v, ok := toNumber(r.Min)
+			if !ok {
+				panic("input argument contains invalid type for Constraint")
+			}
+			min = v
+		}
+		if r.Max != nil {
+			v, ok := toNumber(r.Max)
+			if !ok {
+				panic("input argument contains invalid type for Constraint")
+			}
+			max = v
+		}
+		constraintRanges = append(constraintRanges, ValueRange{min, max})
+	}
+
+	c := &Constraint{constraintRanges}
+	c.checkRep()
+	return c
+}
+
+// Public methods
+
+// Ranges returns the value ranges of the constraint.
+// Min and Max of the ranges are either nil, uint64, int64, or float64.
+func (c *Constraint) Ranges() []ValueRange {
+	return append([]ValueRange{}, c.ranges...)
+}
+
+// Allows reports whether the value is allowed by the constraint.
+// The value should be a number; otherwise, it returns false.
+func (c *Constraint) Allows(value interface{}) bool {
+	v, ok := toNumber(value)
+	if !ok {
+		return false
+	}
+	for _, r := range c.ranges {
+		if (r.Min == nil || compareNumber(r.Min, v) <= 0) &&
+			(r.Max == nil || compareNumber(v, r.Max) <= 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the string representation of the constraint.
+func (c *Constraint) String() string {
+	ranges := make([]string, 0, len(c.ranges))
+	for _, r := range c.ranges {
+		if r.Min != nil && r.Max != nil && compareNumber(r.Min, r.Max) == 0 {
+			ranges = append(ranges, formatNumber(r.Min))
+			continue
+		}
+		var min, max string
+		if r.Min != nil {
+			min = formatNumber(r.Min)
+		}
+		if r.Max != nil {
+			max = formatNumber(r.Max)
+		}
+		ranges = append(ranges, min+".."+max)
+	}
+	return fmt.Sprintf("{%s}", strings.Join(ranges, " "))
+}
+
+// Private methods
+
+func (c *Constraint) checkRep() {
+	if len(c.ranges) == 0 {
+		panic("empty constraint")
+	}
+
+	for _, r := range c.ranges {
+		if r.Min == nil && r.Max == nil {
+			panic("value range without limit")
+		}
+		if r.Min != nil && r.Max != nil && compareNumber(r.Min, r.Max) > 0 {
+			panic("invalid value range")
+		}
+	}
+}
+
+// Helper functions
+
+// checkConstraint returns an error if the value filled into the variable isn't allowed
+// by the constraint. The constraint can be nil, which allows any value.
+func checkConstraint(name string, value interface{}, c *Constraint) error {
+	if c != nil && !c.Allows(value) {
+		return fmt.Errorf("variable %q: value %v not allowed, expected %v", name, value, c)
+	}
+	return nil
+}
+
+// withConstraint returns a copy of the constraints with the constraint c of the variable.
+// It panics if the variable doesn't exist in the variables.
+func withConstraint(constraints map[string]*Constraint, variables map[string]int, name string, c *Constraint) map[string]*Constraint {
+	if _, ok := variables[name]; !ok {
+		panic("variable not found")
+	}
+	if c == nil {
+		panic("nil constraint")
+	}
+	result := map[string]*Constraint{name: c}
+	for k, v := range constraints {
+		if k != name {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// filledValues is the result of fillValues.
+type filledValues struct {
+	values      []interface{}          // data values and variable names, the input of the factory method
+	constraints map[string]*Constraint // constraints of the new node; nil if there's no constraint
+	defaults    map[string]interface{} // default values of the new node; nil if there's no default value
+	array       *arrayVariable         // array variable of the new node; nil if the array variable is filled in
+	createNew   bool                   // true if any variable is filled in or renamed
+}
+
+// fillValues fills the values into the variables, and returns the data values to
+// create a new node with the factory method, with the constraints and the default values
+// of the new node. The data values and the variables in the data array are converted to
+// the input of the factory, using value(i).
+//
+// A variable not in the values is filled with its default value, if exists.
+// A fill-in value converted into a single variable name renames the variable,
+// keeping its constraint, default value, and array size range.
+// The number of the data values filled into a array variable should be in its size range,
+// and a variable other than the array variable should be filled with a single data value.
+//
+// convert should convert a fill-in value into the data values that replace the variable,
+// as the input of the factory method; refer to convertValues().
+// An error is returned when a fill-in value cannot be converted or filled in.
+func fillValues(
+	size int, value func(i int) interface{}, variables map[string]int, constraints map[string]*Constraint,
+	defaults map[string]interface{}, array *arrayVariable, values map[string]interface{},
+	convert func(v interface{}) ([]interface{}, error),
+) (*filledValues, error) {
+	slots := make([][]interface{}, 0, size)
+	for i := 0; i < size; i++ {
+		slots = append(slots, []interface{}{value(i)})
+	}
+
+	result := &filledValues{}
+	keep := func(oldName, newName string) {
+		result.array = array
+		if c, ok := constraints[oldName]; ok {
+			if result.constraints == nil {
+				result.constraints = map[string]*Constraint{}
+			}
+			result.constraints[newName] = c
+		}
+		if d, ok := defaults[oldName]; ok {
+			if result.defaults == nil {
+				result.defaults = map[string]interface{}{}
+			}
+			result.defaults[newName] = d
+		}
+	}
+
+	for name, pos := range variables {
+		v, ok := values[name]
+		if !ok {
+			if d, ok := defaults[name]; ok {
+				slots[pos] = []interface{}{d}
+				result.createNew = true
+			} else {
+				slots[pos] = []interface{}{name}
+				keep(name, name)
+			}
+			continue
+		}
+
+		converted, err := convert(v)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q: %v", name, err)
+		}
+		if newName, ok := renamedTo(converted); ok {
+			if !isValidVarName(newName) {
+				return nil, fmt.Errorf("variable %q: invalid variable name %q", name, newName)
+			}
+			keep(name, newName)
+		} else {
+			if array != nil {
+				if err := array.check(name, len(converted)); err != nil {
+					return nil, err
+				}
+			} else if len(converted) != 1 {
+				return nil, fmt.Errorf("variable %q: cannot fill %d values into a non-array variable", name, len(converted))
+			}
+			for _, c := range converted {
+				if err := checkConstraint(name, c, constraints[name]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		slots[pos] = converted
+		result.createNew = true
+	}
+
+	result.values = make([]interface{}, 0, size)
+	for _, slot := range slots {
+		result.values = append(result.values, slot...)
+	}
+	return result, nil
+}
+
+// renamedTo returns the new variable name, if the converted fill-in value is a variable name.
+func renamedTo(converted []interface{}) (string, bool) {
+	if len(converted) != 1 {
+		return "", false
+	}
+	name, ok := converted[0].(string)
+	return name, ok
+}
+
+// tryNew calls the factory function, and returns the panic of the factory function as an error.
+func tryNew(factory func() ItemNode) (node ItemNode, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			node, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	return factory(), nil
+}
+
+// toNumber converts the value to uint64 if it's an unsigned integer, int64 if it's
+// a signed integer, or float64 if it's a float. The second return value is false,
+// if the value is not a number.
+func toNumber(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case float32:
+		return float64(v), !math.IsNaN(float64(v))
+	case float64:
+		return v, !math.IsNaN(v)
+	}
+	return nil, false
+}
+
+// compareNumber compares the numbers converted by toNumber, and returns
+// -1 if a < b, 0 if a == b, and 1 if a > b.
+// Integers are compared exactly, and compared as float64 with a float.
+func compareNumber(a, b interface{}) int {
+	af, aIsFloat := a.(float64)
+	bf, bIsFloat := b.(float64)
+	if aIsFloat || bIsFloat {
+		if !aIsFloat {
+			af = toFloat(a)
+		}
+		if !bIsFloat {
+			bf = toFloat(b)
+		}
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+
+	// Compare the signs of the integers first
+	ai, aIsInt := a.(int64)
+	bi, bIsInt := b.(int64)
+	switch {
+	case aIsInt && ai < 0 && !(bIsInt && bi < 0):
+		return -1
+	case bIsInt && bi < 0 && !(aIsInt && ai < 0):
+		return 1
+	case aIsInt && bIsInt:
+		return compareInt64(ai, bi)
+	}
+	return compareUint64(toUint(a), toUint(b))
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// toFloat converts the integer converted by toNumber to float64.
+func toFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return float64(v.(uint64))
+}
+
+// toUint converts the non-negative integer converted by toNumber to uint64.
+func toUint(v interface{}) uint64 {
+	if i, ok := v.(int64); ok {
+		return uint64(i)
+	}
+	return v.(uint64)
+}
+
+// formatNumber returns the string representation of the number converted by toNumber.
+func formatNumber(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// sizeRangeString returns the string representation of a size range, e.g. [2], [2..], [2..7],
+// which is used in the string representation of ASCIINode and ListNode.
+// It returns empty string if the range is [0..], which means no limit.
+func sizeRangeString(min, max int) string {
+	switch {
+	case min == 0 && max == -1:
+		return ""
+	case min == max:
+		return fmt.Sprintf("[%d]", max)
+	case max == -1:
+		return fmt.Sprintf("[%d..]", min)
+	}
+	return fmt.Sprintf("[%d..%d]", min, max)
+}
@@ -0,0 +1,277 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the constraints of variables, and the size ranges of ListNodes.
+//
+// Testing Strategy:
+//
+// Create constraints using the factory method, and test the result of Allows() and String().
+// Create nodes with constraints and size ranges, fill in values, and test the result or the error text.
+//
+// Partitions:
+//
+// - Constraint: range, enumerated values, open range, multiple ranges, invalid range
+// - Value type: unsigned integer, negative integer, float, non-number
+// - Node: UintNode, IntNode, FloatNode, ListNode with/without ellipsis
+// - Fill-in value: allowed, not allowed, invalid type, rename by ellipsis
+// - Ellipsis count: int, other integer types, negative, out of range, over list size limit or size range
+// - List size: in range, out of range, ellipsis not filled
+
+func TestConstraint(t *testing.T) {
+	var tests = []struct {
+		description    string        // Test case description
+		input          []ValueRange  // Input to the factory method
+		allowed        []interface{} // Values expected to be allowed
+		notAllowed     []interface{} // Values expected not to be allowed
+		expectedString string        // expected result from String()
+	}{
+		{
+			description:    "Range",
+			input:          []ValueRange{{1, 100}},
+			allowed:        []interface{}{1, uint8(50), int64(100), 1.5},
+			notAllowed:     []interface{}{0, -1, 101, 100.5, "1", nil},
+			expectedString: "{1..100}",
+		},
+		{
+			description:    "Enumerated values",
+			input:          []ValueRange{{0, 0}, {1, 1}, {uint64(2), uint64(2)}},
+			allowed:        []interface{}{0, uint(1), 2.0},
+			notAllowed:     []interface{}{3, 0.5, -1},
+			expectedString: "{0 1 2}",
+		},
+		{
+			description:    "Open ranges",
+			input:          []ValueRange{{nil, -1}, {10, nil}},
+			allowed:        []interface{}{math.MinInt64, -1, 10, uint64(math.MaxUint64), math.Inf(1)},
+			notAllowed:     []interface{}{0, 9, 9.99, math.NaN()},
+			expectedString: "{..-1 10..}",
+		},
+		{
+			description:    "Float range",
+			input:          []ValueRange{{-0.5, 0.5}},
+			allowed:        []interface{}{-0.5, 0, float32(0.25), 0.5},
+			notAllowed:     []interface{}{-1, 1, 0.51},
+			expectedString: "{-0.5..0.5}",
+		},
+		{
+			description:    "Large unsigned integers",
+			input:          []ValueRange{{uint64(math.MaxUint64 - 1), uint64(math.MaxUint64)}},
+			allowed:        []interface{}{uint64(math.MaxUint64)},
+			notAllowed:     []interface{}{uint64(math.MaxUint64 - 2), math.MaxInt64, -1},
+			expectedString: "{18446744073709551614..18446744073709551615}",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		c := NewConstraint(test.input...)
+		for _, v := range test.allowed {
+			assert.True(t, c.Allows(v), "%v", v)
+		}
+		for _, v := range test.notAllowed {
+			assert.False(t, c.Allows(v), "%v", v)
+		}
+		assert.Equal(t, test.expectedString, c.String())
+	}
+
+	assert.Equal(t, []ValueRange{{int64(1), uint64(2)}, {nil, 1.5}}, NewConstraint(ValueRange{1, uint(2)}, ValueRange{nil, 1.5}).Ranges())
+	assert.Panics(t, func() { NewConstraint() })
+	assert.Panics(t, func() { NewConstraint(ValueRange{nil, nil}) })
+	assert.Panics(t, func() { NewConstraint(ValueRange{2, 1}) })
+	assert.Panics(t, func() { NewConstraint(ValueRange{"1", 2}) })
+}
+
+func TestConstraint_FillVariables(t *testing.T) {
+	var (
+		svid  = NewConstraint(ValueRange{1, 100})
+		ack   = NewConstraint(ValueRange{0, 0}, ValueRange{1, 1})
+		ratio = NewConstraint(ValueRange{0, 1})
+	)
+
+	var tests = []struct {
+		description    string                 // Test case description
+		node           ItemNode               // Input node
+		values         map[string]interface{} // Input to FillVariables()
+		expectedString string                 // expected result from String(), empty if error
+		expectedError  string                 // expected error text, empty if no error
+	}{
+		{
+			description:    "UintNode, not filled",
+			node:           NewUintNode(4, "SVID", 1).(*UintNode).WithConstraint("SVID", svid),
+			values:         map[string]interface{}{},
+			expectedString: "<U4[2] SVID{1..100} 1>",
+		},
+		{
+			description:    "UintNode, allowed",
+			node:           NewUintNode(4, "SVID", "V").(*UintNode).WithConstraint("SVID", svid),
+			values:         map[string]interface{}{"SVID": 100},
+			expectedString: "<U4[2] 100 V>",
+		},
+		{
+			description:   "UintNode, not allowed",
+			node:          NewUintNode(4, "SVID").(*UintNode).WithConstraint("SVID", svid),
+			values:        map[string]interface{}{"SVID": uint32(101)},
+			expectedError: `variable "SVID": value 101 not allowed, expected {1..100}`,
+		},
+		{
+			description:   "UintNode, value overflow",
+			node:          NewUintNode(1, "SVID"),
+			values:        map[string]interface{}{"SVID": 256},
+			expectedError: `variable "SVID": value 256 out of range for U1`,
+		},
+		{
+			description:   "IntNode, not allowed",
+			node:          NewIntNode(1, "ACK").(*IntNode).WithConstraint("ACK", ack),
+			values:        map[string]interface{}{"ACK": -1},
+			expectedError: `variable "ACK": value -1 not allowed, expected {0 1}`,
+		},
+		{
+			description:    "FloatNode, allowed",
+			node:           NewFloatNode(8, "RATIO").(*FloatNode).WithConstraint("RATIO", ratio),
+			values:         map[string]interface{}{"RATIO": 0.5},
+			expectedString: "<F8[1] 0.5>",
+		},
+		{
+			description:   "FloatNode, invalid type",
+			node:          NewFloatNode(8, "RATIO").(*FloatNode).WithConstraint("RATIO", ratio),
+			values:        map[string]interface{}{"RATIO": true},
+			expectedError: `variable "RATIO": cannot fill value of type bool into F8`,
+		},
+		{
+			description:    "Renamed by ellipsis, constraint kept",
+			node:           NewListNode(NewUintNode(4, "SVID").(*UintNode).WithConstraint("SVID", svid), "..."),
+			values:         map[string]interface{}{"...": 1},
+			expectedString: "<L[2]\n  <U4[1] SVID[0]{1..100}>\n  <U4[1] SVID[1]{1..100}>\n>",
+		},
+		{
+			description:   "Renamed by ellipsis, not allowed",
+			node:          NewListNode(NewUintNode(4, "SVID").(*UintNode).WithConstraint("SVID", svid), "..."),
+			values:        map[string]interface{}{"...": 2, "SVID[1]": 0},
+			expectedError: `variable "SVID[1]": value 0 not allowed, expected {1..100}`,
+		},
+		{
+			description:    "List size range, ellipsis not filled",
+			node:           NewListNode(NewUintNode(4, "SVID"), "...").(*ListNode).WithSizeRange(1, 2),
+			values:         map[string]interface{}{"SVID": 1},
+			expectedString: "<L[1..2]\n  <U4[1] 1>\n  ...\n>",
+		},
+		{
+			description:    "List size range, in range",
+			node:           NewListNode(NewUintNode(4, "SVID"), "...").(*ListNode).WithSizeRange(1, 2),
+			values:         map[string]interface{}{"...": 1},
+			expectedString: "<L[2]\n  <U4[1] SVID[0]>\n  <U4[1] SVID[1]>\n>",
+		},
+		{
+			description:   "List size range, out of range",
+			node:          NewListNode(NewUintNode(4, "SVID"), "...").(*ListNode).WithSizeRange(1, 2),
+			values:        map[string]interface{}{"...": 2},
+			expectedError: "list size 3 out of range [1..2]",
+		},
+		{
+			description:   "Nested list size range, out of range",
+			node:          NewListNode(NewListNode(NewUintNode(4, "V"), "...").(*ListNode).WithSizeRange(2, -1)),
+			values:        map[string]interface{}{"...": 0},
+			expectedError: "list size 1 out of range [2..]",
+		},
+		{
+			description:   "Ellipsis, invalid type",
+			node:          NewListNode(NewUintNode(4, "SVID"), "..."),
+			values:        map[string]interface{}{"...": "2"},
+			expectedError: `variable "...": fill-in value has invalid type for ellipsis`,
+		},
+		{
+			description:   "Ellipsis, negative count",
+			node:          NewListNode(NewUintNode(4, "SVID"), "...").(*ListNode).WithSizeRange(1, 3),
+			values:        map[string]interface{}{"...[0]": -1},
+			expectedError: `variable "...[0]": ellipsis count -1 out of range`,
+		},
+		{
+			description:   "Ellipsis, count out of range",
+			node:          NewListNode(NewUintNode(4, "SVID"), "..."),
+			values:        map[string]interface{}{"...": uint64(math.MaxUint64)},
+			expectedError: `variable "...": ellipsis count 18446744073709551615 out of range`,
+		},
+		{
+			description:   "Ellipsis, count over MAX_BYTE_SIZE",
+			node:          NewListNode(NewUintNode(4, "SVID"), "..."),
+			values:        map[string]interface{}{"...": 1 << 40},
+			expectedError: `variable "...": ellipsis count 1099511627776 out of range`,
+		},
+		{
+			description:   "Ellipsis, list size limit exceeded",
+			node:          NewListNode(NewUintNode(4, "SVID"), NewUintNode(4, "CEID"), "..."),
+			values:        map[string]interface{}{"...": MAX_BYTE_SIZE / 2},
+			expectedError: `variable "...": ellipsis count 8388607 out of range, list size limit exceeded`,
+		},
+		{
+			description:   "Ellipsis, count over size range",
+			node:          NewListNode(NewUintNode(4, "SVID"), "...").(*ListNode).WithSizeRange(1, 100),
+			values:        map[string]interface{}{"...": MAX_BYTE_SIZE - 1},
+			expectedError: "list size 16777215 out of range [1..100]",
+		},
+		{
+			description:    "Ellipsis, count of other integer types",
+			node:           NewListNode(NewUintNode(4, 1), "..."),
+			values:         map[string]interface{}{"...": int64(1)},
+			expectedString: "<L[2]\n  <U4[1] 1>\n  <U4[1] 1>\n>",
+		},
+		{
+			description:   "Variable in list, invalid type",
+			node:          NewListNode("SV"),
+			values:        map[string]interface{}{"SV": 1},
+			expectedError: `variable "SV": fill-in value has invalid type for ListNode`,
+		},
+		{
+			description:   "ASCIINode, length out of range",
+			node:          NewListNode(NewASCIINodeVariable("MDLN", 0, 6)),
+			values:        map[string]interface{}{"MDLN": "SEMICONDUCTOR"},
+			expectedError: `variable "MDLN": fill-in string length overflow, expected [0..6]`,
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		node, err := test.node.FillVariables(test.values)
+		if test.expectedError != "" {
+			assert.EqualError(t, err, test.expectedError)
+			assert.Nil(t, node)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedString, fmt.Sprint(node))
+	}
+}
+
+func TestConstraint_Match(t *testing.T) {
+	template := NewListNode(
+		NewUintNode(4, "SVID").(*UintNode).WithConstraint("SVID", NewConstraint(ValueRange{1, 100})),
+		NewIntNode(2, "TEMP").(*IntNode).WithConstraint("TEMP", NewConstraint(ValueRange{-40, 125})),
+	)
+
+	values, err := Match(template, NewListNode(NewUintNode(4, 1), NewIntNode(2, -40)))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"SVID": uint64(1), "TEMP": int64(-40)}, values)
+
+	_, err = Match(template, NewListNode(NewUintNode(4, 1), NewIntNode(2, 126)))
+	assert.EqualError(t, err, `variable "TEMP": value 126 not allowed, expected {-40..125}`)
+}
+
+func TestConstraint_Panics(t *testing.T) {
+	c := NewConstraint(ValueRange{1, 2})
+	assert.Panics(t, func() { NewUintNode(4, "A").(*UintNode).WithConstraint("B", c) })
+	assert.Panics(t, func() { NewUintNode(4, "A").(*UintNode).WithConstraint("A", nil) })
+	assert.Panics(t, func() { NewListNode(NewUintNode(4, "A")).(*ListNode).WithSizeRange(2, 3) })
+	assert.Panics(t, func() { NewListNode(NewUintNode(4, "A"), "...").(*ListNode).WithSizeRange(2, 1) })
+
+	node := NewIntNode(4, "A", "B").(*IntNode).WithConstraint("A", c).(*IntNode).WithConstraint("B", c)
+	assert.Equal(t, c, node.(*IntNode).Constraint("A"))
+	assert.Nil(t, NewIntNode(4, "A").(*IntNode).Constraint("A"))
+
+	min, max := NewListNode().(*ListNode).SizeRange()
+	assert.Equal(t, []int{0, -1}, []int{min, max})
+}
@@ -0,0 +1,272 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FillOption configures the conversion of the fill-in values of FillVariables().
+type FillOption func(*fillOptions)
+
+// fillOptions is the configuration of the conversion of the fill-in values.
+type fillOptions struct {
+	timeFormat string // layout of time.Time.Format(); empty string means the SEMI E5 16-byte format
+}
+
+// WithTimeFormat returns a FillOption that converts a time.Time fill-in value into
+// a ASCIINode with the layout, as defined in time.Time.Format(), e.g. "2006-01-02T15:04:05Z07:00".
+//
+// Without this option, a time.Time is converted into the 16-byte time format of SEMI E5,
+// YYYYMMDDhhmmsscc, where cc is the centisecond.
+func WithTimeFormat(layout string) FillOption {
+	return func(o *fillOptions) {
+		o.timeFormat = layout
+	}
+}
+
+// newFillOptions returns the configuration of the options.
+func newFillOptions(opts []FillOption) *fillOptions {
+	o := &fillOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// formatTime converts the time into a string with the time format of the options.
+func (o *fillOptions) formatTime(t time.Time) string {
+	if o.timeFormat != "" {
+		return t.Format(o.timeFormat)
+	}
+	return t.Format("20060102150405") + fmt.Sprintf("%02d", t.Nanosecond()/int(10*time.Millisecond))
+}
+
+// Helper functions
+
+// converter converts a single fill-in value, which is not a slice, into a data value
+// acceptable by the factory method of a node. An error is returned when the value
+// cannot be converted.
+type converter func(value reflect.Value) (interface{}, error)
+
+// convertValues converts a fill-in value of a variable into the data values, which replace
+// the variable in the node. A slice or an array fill-in value is converted into multiple
+// data values, element by element, using convert. A string fill-in value is returned as is,
+// which renames the variable.
+func convertValues(value interface{}, convert converter) ([]interface{}, error) {
+	if name, ok := value.(string); ok {
+		return []interface{}{name}, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		v, err := convert(rv)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+
+	result := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Interface {
+			// element of []interface{}
+			elem = elem.Elem()
+		}
+		v, err := convert(elem)
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %v", i, err)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// uintConverter returns a converter for UintNode, which converts a value of
+// any Go integer kind into uint64, in range of the byte size.
+func uintConverter(byteSize int) converter {
+	max := uint64(math.MaxUint64) >> (64 - byteSize*8)
+	return func(rv reflect.Value) (interface{}, error) {
+		var v uint64
+		switch {
+		case isIntKind(rv):
+			if rv.Int() < 0 {
+				return nil, fmt.Errorf("value %d out of range for U%d", rv.Int(), byteSize)
+			}
+			v = uint64(rv.Int())
+		case isUintKind(rv):
+			v = rv.Uint()
+		default:
+			return nil, invalidTypeError(rv, fmt.Sprintf("U%d", byteSize))
+		}
+		if v > max {
+			return nil, fmt.Errorf("value %d out of range for U%d", v, byteSize)
+		}
+		return v, nil
+	}
+}
+
+// intConverter returns a converter for IntNode, which converts a value of
+// any Go integer kind into int64, in range of the byte size.
+func intConverter(byteSize int) converter {
+	max := int64(math.MaxInt64) >> (64 - byteSize*8)
+	min := -max - 1
+	return func(rv reflect.Value) (interface{}, error) {
+		var v int64
+		switch {
+		case isIntKind(rv):
+			v = rv.Int()
+		case isUintKind(rv):
+			if rv.Uint() > uint64(max) {
+				return nil, fmt.Errorf("value %d out of range for I%d", rv.Uint(), byteSize)
+			}
+			v = int64(rv.Uint())
+		default:
+			return nil, invalidTypeError(rv, fmt.Sprintf("I%d", byteSize))
+		}
+		if v < min || max < v {
+			return nil, fmt.Errorf("value %d out of range for I%d", v, byteSize)
+		}
+		return v, nil
+	}
+}
+
+// floatConverter returns a converter for FloatNode, which converts a value of
+// any Go integer or float kind into float64, in range of the byte size.
+func floatConverter(byteSize int) converter {
+	return func(rv reflect.Value) (interface{}, error) {
+		var v float64
+		switch {
+		case isIntKind(rv):
+			v = float64(rv.Int())
+		case isUintKind(rv):
+			v = float64(rv.Uint())
+		case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+			v = rv.Float()
+		default:
+			return nil, invalidTypeError(rv, fmt.Sprintf("F%d", byteSize))
+		}
+		if math.IsInf(v, 0) || math.IsNaN(v) || (byteSize == 4 && math.Abs(v) > math.MaxFloat32) {
+			return nil, fmt.Errorf("value %v out of range for F%d", v, byteSize)
+		}
+		return v, nil
+	}
+}
+
+// convertBinary is a converter for BinaryNode, which converts a value of
+// any Go integer kind in range of [0, 255] into int.
+func convertBinary(rv reflect.Value) (interface{}, error) {
+	var v uint64
+	switch {
+	case isIntKind(rv):
+		if rv.Int() < 0 {
+			return nil, fmt.Errorf("value %d out of range for B", rv.Int())
+		}
+		v = uint64(rv.Int())
+	case isUintKind(rv):
+		v = rv.Uint()
+	default:
+		return nil, invalidTypeError(rv, "B")
+	}
+	if v > 255 {
+		return nil, fmt.Errorf("value %d out of range for B", v)
+	}
+	return int(v), nil
+}
+
+// convertBinaryValues converts a fill-in value of a BinaryNode into the data values.
+// A string fill-in value is either a binary string such as "0b1001", or a variable name.
+// Other fill-in values are converted by convertValues() with convertBinary.
+func convertBinaryValues(value interface{}) ([]interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return convertValues(value, convertBinary)
+	}
+	if !strings.HasPrefix(s, "0b") {
+		return []interface{}{s}, nil
+	}
+	v, err := strconv.ParseUint(s[2:], 2, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binary string %q for B", s)
+	}
+	return []interface{}{int(v)}, nil
+}
+
+// convertBoolean is a converter for BooleanNode, which converts a value of bool kind into bool.
+func convertBoolean(rv reflect.Value) (interface{}, error) {
+	if rv.Kind() != reflect.Bool {
+		return nil, invalidTypeError(rv, "BOOLEAN")
+	}
+	return rv.Bool(), nil
+}
+
+// convertASCII converts a fill-in value of a ASCIINode into a string.
+// A value of string kind and a []byte are converted as is, and a time.Time is formatted
+// with the time format of the options.
+func convertASCII(value interface{}, o *fillOptions) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return o.formatTime(v), nil
+	case []byte:
+		return string(v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.String {
+		return "", invalidTypeError(rv, "A")
+	}
+	return rv.String(), nil
+}
+
+// convertEllipsis converts a fill-in value of a ellipsis, which is the number of
+// repetitions, into int. The value should be of any Go integer kind, non-negative
+// and less than MAX_BYTE_SIZE, as a list with more repetitions cannot be encoded.
+func convertEllipsis(value interface{}) (int, error) {
+	rv := reflect.ValueOf(value)
+	var v int64
+	switch {
+	case isIntKind(rv):
+		v = rv.Int()
+	case isUintKind(rv):
+		if rv.Uint() >= MAX_BYTE_SIZE {
+			return 0, fmt.Errorf("ellipsis count %d out of range", rv.Uint())
+		}
+		v = int64(rv.Uint())
+	default:
+		return 0, fmt.Errorf("fill-in value has invalid type for ellipsis")
+	}
+	if v < 0 || v >= MAX_BYTE_SIZE {
+		return 0, fmt.Errorf("ellipsis count %d out of range", v)
+	}
+	return int(v), nil
+}
+
+// isIntKind reports whether the value is a signed integer.
+func isIntKind(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+// isUintKind reports whether the value is an unsigned integer.
+func isUintKind(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// invalidTypeError returns an error that the value cannot be filled into the data item type.
+func invalidTypeError(rv reflect.Value, itemType string) error {
+	if !rv.IsValid() {
+		return fmt.Errorf("cannot fill nil into %s", itemType)
+	}
+	return fmt.Errorf("cannot fill value of type %s into %s", rv.Type(), itemType)
+}
@@ -0,0 +1,275 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the conversion of the fill-in values of FillVariables().
+//
+// Testing Strategy:
+//
+// Create nodes with variables, fill in values of various Go types with or without options,
+// and test the string representation of the result or the error text.
+//
+// Partitions:
+//
+// - Node: UintNode, IntNode, FloatNode, BinaryNode, BooleanNode, ASCIINode, ListNode
+// - Fill-in value type: integer kinds, float kinds, bool, string, []byte, time.Time, slice, array, nil, invalid type
+// - Fill-in value: in range, out of range, not allowed by the constraint, variable name, invalid variable name
+// - Slice length: 0, 1, >1
+// - Variable: array variable, non-array variable
+// - Time format: default, WithTimeFormat()
+
+func TestConvert_FillVariables(t *testing.T) {
+	clock := time.Date(2021, 3, 4, 5, 6, 7, 890000000, time.UTC)
+
+	var tests = []struct {
+		description    string                 // Test case description
+		node           ItemNode               // Input node
+		values         map[string]interface{} // Input to FillVariables()
+		opts           []FillOption           // Input to FillVariables()
+		expectedString string                 // expected result from String(), empty if error
+		expectedError  string                 // expected error text, empty if no error
+	}{
+		{
+			description:    "UintNode, integer kinds",
+			node:           NewUintNode(8, "V1", "V2", "V3"),
+			values:         map[string]interface{}{"V1": int8(1), "V2": uint16(2), "V3": uint64(math.MaxUint64)},
+			expectedString: "<U8[3] 1 2 18446744073709551615>",
+		},
+		{
+			description:   "UintNode, negative integer",
+			node:          NewUintNode(4, "V1"),
+			values:        map[string]interface{}{"V1": -1},
+			expectedError: `variable "V1": value -1 out of range for U4`,
+		},
+		{
+			description:   "UintNode, overflow",
+			node:          NewUintNode(2, "V1"),
+			values:        map[string]interface{}{"V1": 65536},
+			expectedError: `variable "V1": value 65536 out of range for U2`,
+		},
+		{
+			description:   "UintNode, float",
+			node:          NewUintNode(4, "V1"),
+			values:        map[string]interface{}{"V1": 1.0},
+			expectedError: `variable "V1": cannot fill value of type float64 into U4`,
+		},
+		{
+			description:   "UintNode, nil",
+			node:          NewUintNode(4, "V1"),
+			values:        map[string]interface{}{"V1": nil},
+			expectedError: `variable "V1": cannot fill nil into U4`,
+		},
+		{
+			description:    "UintNode, slice",
+			node:           NewUintNode(4, "VAR").(*UintNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{"VAR": []uint16{1, 2}},
+			expectedString: "<U4[2] 1 2>",
+		},
+		{
+			description:    "UintNode, array",
+			node:           NewUintNode(4, "VAR").(*UintNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{"VAR": [3]int{1, 2, 3}},
+			expectedString: "<U4[3] 1 2 3>",
+		},
+		{
+			description:    "UintNode, single element slice into a non-array variable",
+			node:           NewUintNode(4, "V1", "VAR"),
+			values:         map[string]interface{}{"VAR": []int{2}},
+			expectedString: "<U4[2] V1 2>",
+		},
+		{
+			description:   "UintNode, slice into a non-array variable",
+			node:          NewUintNode(4, "V1", "VAR", "V2"),
+			values:        map[string]interface{}{"VAR": []uint16{1, 2}},
+			expectedError: `variable "VAR": cannot fill 2 values into a non-array variable`,
+		},
+		{
+			description:   "UintNode, empty slice into a non-array variable",
+			node:          NewUintNode(4, "V1", "VAR"),
+			values:        map[string]interface{}{"VAR": []int{}},
+			expectedError: `variable "VAR": cannot fill 0 values into a non-array variable`,
+		},
+		{
+			description:    "UintNode, empty slice into a array variable",
+			node:           NewUintNode(4, "VAR").(*UintNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{"VAR": []int{}},
+			expectedString: "<U4[0]>",
+		},
+		{
+			description:   "UintNode, slice element out of range",
+			node:          NewUintNode(1, "VAR").(*UintNode).WithArraySize(0, -1),
+			values:        map[string]interface{}{"VAR": []int{1, 256}},
+			expectedError: `variable "VAR": index 1: value 256 out of range for U1`,
+		},
+		{
+			description:   "UintNode, slice element not allowed",
+			node:          NewUintNode(4, "VAR").(*UintNode).WithConstraint("VAR", NewConstraint(ValueRange{1, 100})).(*UintNode).WithArraySize(0, -1),
+			values:        map[string]interface{}{"VAR": []int{1, 200}},
+			expectedError: `variable "VAR": value 200 not allowed, expected {1..100}`,
+		},
+		{
+			description:    "UintNode, rename",
+			node:           NewUintNode(4, "V1"),
+			values:         map[string]interface{}{"V1": "V2"},
+			expectedString: "<U4[1] V2>",
+		},
+		{
+			description:   "UintNode, invalid variable name",
+			node:          NewUintNode(4, "V1"),
+			values:        map[string]interface{}{"V1": "1"},
+			expectedError: `variable "V1": invalid variable name "1"`,
+		},
+		{
+			description:    "IntNode, integer kinds in range",
+			node:           NewIntNode(1, "V1", "V2", "V3"),
+			values:         map[string]interface{}{"V1": -128, "V2": uint8(127), "V3": int64(0)},
+			expectedString: "<I1[3] -128 127 0>",
+		},
+		{
+			description:   "IntNode, underflow",
+			node:          NewIntNode(1, "V1"),
+			values:        map[string]interface{}{"V1": -129},
+			expectedError: `variable "V1": value -129 out of range for I1`,
+		},
+		{
+			description:   "IntNode, unsigned overflow",
+			node:          NewIntNode(8, "V1"),
+			values:        map[string]interface{}{"V1": uint64(math.MaxInt64 + 1)},
+			expectedError: `variable "V1": value 9223372036854775808 out of range for I8`,
+		},
+		{
+			description:    "IntNode, slice",
+			node:           NewIntNode(2, "VAR").(*IntNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{"VAR": []int32{-1, 1}},
+			expectedString: "<I2[2] -1 1>",
+		},
+		{
+			description:    "FloatNode, integer and float kinds",
+			node:           NewFloatNode(8, "V1", "V2", "V3"),
+			values:         map[string]interface{}{"V1": 1, "V2": uint(2), "V3": float32(0.5)},
+			expectedString: "<F8[3] 1 2 0.5>",
+		},
+		{
+			description:   "FloatNode, F4 overflow",
+			node:          NewFloatNode(4, "V1"),
+			values:        map[string]interface{}{"V1": 1e39},
+			expectedError: `variable "V1": value 1e+39 out of range for F4`,
+		},
+		{
+			description:   "FloatNode, infinity",
+			node:          NewFloatNode(8, "V1"),
+			values:        map[string]interface{}{"V1": math.Inf(1)},
+			expectedError: `variable "V1": value +Inf out of range for F8`,
+		},
+		{
+			description:    "BinaryNode, []byte",
+			node:           NewBinaryNode("VAR").(*BinaryNode).WithArraySize(0, -1),
+			values:         map[string]interface{}{"VAR": []byte{1, 255}},
+			expectedString: "<B[2] 0b1 0b11111111>",
+		},
+		{
+			description:    "BinaryNode, binary string and integer",
+			node:           NewBinaryNode("V1", "V2"),
+			values:         map[string]interface{}{"V1": "0b1001", "V2": uint8(2)},
+			expectedString: "<B[2] 0b1001 0b10>",
+		},
+		{
+			description:   "BinaryNode, invalid binary string",
+			node:          NewBinaryNode("V1"),
+			values:        map[string]interface{}{"V1": "0b100000000"},
+			expectedError: `variable "V1": invalid binary string "0b100000000" for B`,
+		},
+		{
+			description:   "BinaryNode, overflow",
+			node:          NewBinaryNode("V1"),
+			values:        map[string]interface{}{"V1": 256},
+			expectedError: `variable "V1": value 256 out of range for B`,
+		},
+		{
+			description:    "BinaryNode, rename",
+			node:           NewBinaryNode("V1"),
+			values:         map[string]interface{}{"V1": "V2"},
+			expectedString: "<B[1] V2>",
+		},
+		{
+			description:    "BooleanNode, bool and slice",
+			node:           NewBooleanNode("V1", "VAR"),
+			values:         map[string]interface{}{"V1": false, "VAR": []bool{true}},
+			expectedString: "<BOOLEAN[2] F T>",
+		},
+		{
+			description:   "BooleanNode, integer",
+			node:          NewBooleanNode("V1"),
+			values:        map[string]interface{}{"V1": 1},
+			expectedError: `variable "V1": cannot fill value of type int into BOOLEAN`,
+		},
+		{
+			description:    "ASCIINode, time.Time with default format",
+			node:           NewASCIINodeVariable("CLOCK", 0, -1),
+			values:         map[string]interface{}{"CLOCK": clock},
+			expectedString: `<A "2021030405060789">`,
+		},
+		{
+			description:    "ASCIINode, time.Time with time format",
+			node:           NewASCIINodeVariable("CLOCK", 0, -1),
+			values:         map[string]interface{}{"CLOCK": clock},
+			opts:           []FillOption{WithTimeFormat("2006-01-02T15:04:05Z07:00")},
+			expectedString: `<A "2021-03-04T05:06:07Z">`,
+		},
+		{
+			description:    "ASCIINode, []byte",
+			node:           NewASCIINodeVariable("MDLN", 0, -1),
+			values:         map[string]interface{}{"MDLN": []byte("model")},
+			expectedString: `<A "model">`,
+		},
+		{
+			description:   "ASCIINode, integer",
+			node:          NewASCIINodeVariable("MDLN", 0, -1),
+			values:        map[string]interface{}{"MDLN": 1},
+			expectedError: `variable "MDLN": cannot fill value of type int into A`,
+		},
+		{
+			description:   "ASCIINode, time.Time out of range of the string length",
+			node:          NewASCIINodeVariable("CLOCK", 0, 12),
+			values:        map[string]interface{}{"CLOCK": clock},
+			expectedError: `variable "CLOCK": fill-in string length overflow, expected [0..12]`,
+		},
+		{
+			description:    "ListNode, options passed to the child nodes",
+			node:           NewListNode(NewUintNode(4, "V1"), NewASCIINodeVariable("CLOCK", 0, -1)),
+			values:         map[string]interface{}{"V1": uint32(1), "CLOCK": clock},
+			opts:           []FillOption{WithTimeFormat("20060102150405")},
+			expectedString: "<L[2]\n  <U4[1] 1>\n  <A \"20210304050607\">\n>",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		node, err := test.node.FillVariables(test.values, test.opts...)
+		if test.expectedError != "" {
+			assert.EqualError(t, err, test.expectedError)
+			assert.Nil(t, node)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedString, fmt.Sprint(node))
+	}
+}
+
+func TestConvert_DataMessage(t *testing.T) {
+	clock := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	msg := NewDataMessage("", 2, 31, 1, "H->E", NewASCIINodeVariable("TIME", 0, -1))
+
+	filled, err := msg.FillVariablesStrict(map[string]interface{}{"TIME": clock}, WithTimeFormat("060102150405"))
+	assert.NoError(t, err)
+	assert.Equal(t, `<A "210304050607">`, fmt.Sprint(filled.Item()))
+
+	_, err = msg.FillVariables(map[string]interface{}{"TIME": 1.5})
+	assert.EqualError(t, err, `variable "TIME": cannot fill value of type float64 into A`)
+}
@@ -0,0 +1,40 @@
+package ast
+
+import "fmt"
+
+// withDefault returns a copy of the defaults with the default value of the variable,
+// which is the single data value of the node. It panics if the variable doesn't exist
+// in the variables, or the node doesn't consist of a single data value.
+func withDefault(defaults map[string]interface{}, variables map[string]int, name string, node ItemNode) map[string]interface{} {
+	if _, ok := variables[name]; !ok {
+		panic("variable not found")
+	}
+	if node.Size() != 1 || len(node.Variables()) != 0 {
+		panic("default value should be a data value")
+	}
+
+	result := map[string]interface{}{name: node.(interface{ Values() []interface{} }).Values()[0]}
+	for k, v := range defaults {
+		if k != name {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// checkDefaults panics if the defaults violate the rep invariants of the node, that
+// the defaults should be nil or non-empty, its keys should be the variable names in the node,
+// and the default values should be allowed by the constraints.
+func checkDefaults(defaults map[string]interface{}, variables map[string]int, constraints map[string]*Constraint) {
+	if defaults != nil && len(defaults) == 0 {
+		panic("empty defaults")
+	}
+	for name, v := range defaults {
+		if _, ok := variables[name]; !ok {
+			panic("default value of unknown variable")
+		}
+		if c, ok := constraints[name]; ok && !c.Allows(v) {
+			panic(fmt.Sprintf("default value %v not allowed, expected %v", v, c))
+		}
+	}
+}
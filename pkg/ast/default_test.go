@@ -0,0 +1,160 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the default values of variables.
+//
+// Testing Strategy:
+//
+// Create nodes with default values using WithDefault(), fill in values,
+// and test the string representation of the result or the error text.
+// Fill in a message strictly, and test the unbound variables in the error text.
+//
+// Partitions:
+//
+// - Node: UintNode, IntNode, FloatNode, BinaryNode, BooleanNode, ASCIINode, ListNode with/without ellipsis
+// - Variable: filled in, not filled in with/without default value, renamed by ellipsis
+// - Default value: valid, invalid type, overflow, not allowed by the constraint
+// - Strict fill: all variables bound, unbound variables
+
+func TestDefault_FillVariables(t *testing.T) {
+	var tests = []struct {
+		description      string                 // Test case description
+		node             ItemNode               // Input node
+		values           map[string]interface{} // Input to FillVariables()
+		expectedTemplate string                 // expected result from String() of the input node
+		expectedString   string                 // expected result from String() of the filled node
+	}{
+		{
+			description:      "UintNode, default value used",
+			node:             NewUintNode(4, "DATAID", "CEID").(*UintNode).WithDefault("DATAID", 0),
+			values:           map[string]interface{}{"CEID": 1},
+			expectedTemplate: "<U4[2] DATAID=0 CEID>",
+			expectedString:   "<U4[2] 0 1>",
+		},
+		{
+			description:      "UintNode, default value overridden",
+			node:             NewUintNode(4, "DATAID").(*UintNode).WithDefault("DATAID", 0),
+			values:           map[string]interface{}{"DATAID": 7},
+			expectedTemplate: "<U4[1] DATAID=0>",
+			expectedString:   "<U4[1] 7>",
+		},
+		{
+			description: "UintNode with constraint",
+			node: NewUintNode(4, "SVID").(*UintNode).
+				WithConstraint("SVID", NewConstraint(ValueRange{1, 100})).(*UintNode).WithDefault("SVID", 1),
+			values:           map[string]interface{}{},
+			expectedTemplate: "<U4[1] SVID{1..100}=1>",
+			expectedString:   "<U4[1] 1>",
+		},
+		{
+			description:      "IntNode",
+			node:             NewIntNode(2, "TEMP").(*IntNode).WithDefault("TEMP", -40),
+			values:           map[string]interface{}{},
+			expectedTemplate: "<I2[1] TEMP=-40>",
+			expectedString:   "<I2[1] -40>",
+		},
+		{
+			description:      "FloatNode",
+			node:             NewFloatNode(4, "RATIO").(*FloatNode).WithDefault("RATIO", 0.5),
+			values:           map[string]interface{}{},
+			expectedTemplate: "<F4[1] RATIO=0.5>",
+			expectedString:   "<F4[1] 0.5>",
+		},
+		{
+			description:      "BinaryNode",
+			node:             NewBinaryNode("ACK", "CODE").(*BinaryNode).WithDefault("ACK", "0b101"),
+			values:           map[string]interface{}{},
+			expectedTemplate: "<B[2] ACK=0b101 CODE>",
+			expectedString:   "<B[2] 0b101 CODE>",
+		},
+		{
+			description:      "BooleanNode",
+			node:             NewBooleanNode("ENABLED").(*BooleanNode).WithDefault("ENABLED", true),
+			values:           map[string]interface{}{},
+			expectedTemplate: "<BOOLEAN[1] ENABLED=T>",
+			expectedString:   "<BOOLEAN[1] T>",
+		},
+		{
+			description:      "ASCIINode",
+			node:             NewASCIINodeVariable("MDLN", 0, 20).(*ASCIINode).WithDefault("MDLN", "say \"hi\"\n"),
+			values:           map[string]interface{}{},
+			expectedTemplate: `<A[0..20] MDLN="say \"hi\"" 0x0A>`,
+			expectedString:   `<A "say \"hi\"" 0x0A>`,
+		},
+		{
+			description:      "ListNode with ellipsis, default value of renamed variables",
+			node:             NewListNode(NewUintNode(4, "SVID").(*UintNode).WithDefault("SVID", 1), NewASCIINodeVariable("TEXT", 0, -1).(*ASCIINode).WithDefault("TEXT", ""), "..."),
+			values:           map[string]interface{}{"...": 1, "SVID[1]": 2},
+			expectedTemplate: "<L\n  <U4[1] SVID=1>\n  <A TEXT=\"\">\n  ...\n>",
+			expectedString:   "<L[4]\n  <U4[1] 1>\n  <A[0]>\n  <U4[1] 2>\n  <A[0]>\n>",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		assert.Equal(t, test.expectedTemplate, fmt.Sprint(test.node))
+		node, err := test.node.FillVariables(test.values)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedString, fmt.Sprint(node))
+	}
+}
+
+func TestDefault_Accessors(t *testing.T) {
+	node := NewUintNode(4, "A", "B").(*UintNode).WithDefault("A", uint8(1))
+	v, ok := node.(*UintNode).Default("A")
+	assert.Equal(t, uint64(1), v)
+	assert.True(t, ok)
+	_, ok = node.(*UintNode).Default("B")
+	assert.False(t, ok)
+
+	v, ok = NewASCIINodeVariable("A", 0, -1).(*ASCIINode).WithDefault("A", "text").(*ASCIINode).Default("A")
+	assert.Equal(t, "text", v)
+	assert.True(t, ok)
+	_, ok = NewASCIINode("text").(*ASCIINode).Default("A")
+	assert.False(t, ok)
+
+	// Constraint and default value are kept when the other is set
+	c := NewConstraint(ValueRange{0, 10})
+	node = node.(*UintNode).WithConstraint("A", c)
+	v, _ = node.(*UintNode).Default("A")
+	assert.Equal(t, uint64(1), v)
+	assert.Equal(t, c, node.(*UintNode).Constraint("A"))
+}
+
+func TestDefault_Panics(t *testing.T) {
+	c := NewConstraint(ValueRange{1, 100})
+	assert.Panics(t, func() { NewUintNode(4, "A").(*UintNode).WithDefault("B", 1) })
+	assert.Panics(t, func() { NewUintNode(1, "A").(*UintNode).WithDefault("A", 256) })
+	assert.Panics(t, func() { NewUintNode(1, "A").(*UintNode).WithDefault("A", "B") })
+	assert.Panics(t, func() { NewIntNode(1, "A").(*IntNode).WithDefault("A", true) })
+	assert.Panics(t, func() { NewFloatNode(4, "A").(*FloatNode).WithConstraint("A", c).(*FloatNode).WithDefault("A", 0) })
+	assert.Panics(t, func() { NewIntNode(4, "A").(*IntNode).WithDefault("A", 0).(*IntNode).WithConstraint("A", c) })
+	assert.Panics(t, func() { NewBooleanNode("A").(*BooleanNode).WithDefault("A", 1) })
+	assert.Panics(t, func() { NewBinaryNode("A").(*BinaryNode).WithDefault("A", 256) })
+	assert.Panics(t, func() { NewASCIINodeVariable("A", 0, 2).(*ASCIINode).WithDefault("A", "abc") })
+	assert.Panics(t, func() { NewASCIINodeVariable("A", 0, 2).(*ASCIINode).WithDefault("A", 1) })
+	assert.Panics(t, func() { NewASCIINodeVariable("A", 0, 2).(*ASCIINode).WithDefault("B", "") })
+}
+
+func TestDefault_FillVariablesStrict(t *testing.T) {
+	msg := NewDataMessage("", 6, 11, 1, "H<-E", NewListNode(
+		NewUintNode(4, "DATAID").(*UintNode).WithDefault("DATAID", 0),
+		NewUintNode(4, "CEID"),
+		NewListNode(NewUintNode(4, "RPTID"), "..."),
+	))
+
+	_, err := msg.FillVariablesStrict(map[string]interface{}{"...": 1})
+	assert.EqualError(t, err, "unbound variables: CEID, RPTID[0], RPTID[1]")
+
+	filled, err := msg.FillVariablesStrict(map[string]interface{}{"CEID": 1, "...": 0, "RPTID": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "S6F11 W H<-E\n<L[3]\n  <U4[1] 0>\n  <U4[1] 1>\n  <L[1]\n    <U4[1] 2>\n  >\n>\n.", fmt.Sprint(filled))
+
+	_, err = msg.FillVariablesStrict(map[string]interface{}{"CEID": -1})
+	assert.Error(t, err)
+}
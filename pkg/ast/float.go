@@ -15,9 +15,12 @@ import (
 // String representation of the float values will use the golang's %g formatting.
 // Refer to the documentation of the fmt package (https://golang.org/pkg/fmt/).
 type FloatNode struct {
-	byteSize  int            // Byte size of the floats; should be either 4 or 8
-	values    []float64      // Array of floats
-	variables map[string]int // Variable name and its position in the data array
+	byteSize    int                    // Byte size of the floats; should be either 4 or 8
+	values      []float64              // Array of floats
+	variables   map[string]int         // Variable name and its position in the data array
+	constraints map[string]*Constraint // Variable name and its constraint; nil if there's no constraint
+	defaults    map[string]interface{} // Variable name and its default value; nil if there's no default value
+	array       *arrayVariable         // Size range of the array variable; nil if the node doesn't have a array variable
 
 	// Rep invariants
 	// - Each values[i] should be representable in bytes of byteSize
@@ -25,6 +28,10 @@ type FloatNode struct {
 	// - If a variable exists in position i, values[i] will be zero-value (0) and should not be used
 	// - variable name should adhere to the variable naming rule; refer to interface.go
 	// - variable positions should be unique, and be in range of [0, len(values))
+	// - constraints should be nil or non-empty, and its keys should be the variable names in the node
+	// - defaults should be nil or non-empty, and its keys should be the variable names in the node
+	// - default values should be allowed by the constraints of the variables
+	// - array should be nil, or the node should consist of a single variable without a default value
 }
 
 // Factory methods
@@ -81,7 +88,7 @@ func NewFloatNode(byteSize int, values ...interface{}) ItemNode {
 		}
 	}
 
-	node := &FloatNode{byteSize, nodeValues, nodeVariables}
+	node := &FloatNode{byteSize, nodeValues, nodeVariables, nil, nil, nil}
 	node.checkRep()
 	return node
 }
@@ -98,31 +105,112 @@ func (node *FloatNode) Variables() []string {
 	return getVariableNames(node.variables)
 }
 
+// ByteSize returns the byte size of each value in the node.
+func (node *FloatNode) ByteSize() int {
+	return node.byteSize
+}
+
+// Values returns the data values and the variable names in the node, in their positions.
+// The result can be passed to the factory method, e.g. NewFloatNode(node.ByteSize(), node.Values()...).
+func (node *FloatNode) Values() []interface{} {
+	result := make([]interface{}, 0, node.Size())
+	for _, v := range node.values {
+		result = append(result, v)
+	}
+	for name, pos := range node.variables {
+		result[pos] = name
+	}
+	return result
+}
+
+// Constraint returns the constraint of the variable, or nil if the variable
+// doesn't have a constraint or doesn't exist.
+func (node *FloatNode) Constraint(name string) *Constraint {
+	return node.constraints[name]
+}
+
+// WithConstraint returns a new FloatNode, with the constraint set to the variable.
+// The variable should exist in the node.
+func (node *FloatNode) WithConstraint(name string, c *Constraint) ItemNode {
+	result := &FloatNode{
+		node.byteSize, node.values, node.variables,
+		withConstraint(node.constraints, node.variables, name, c), node.defaults, node.array,
+	}
+	result.checkRep()
+	return result
+}
+
+// Default returns the default value of the variable, and whether the default value exists.
+// The default value has the type of float64.
+func (node *FloatNode) Default(name string) (interface{}, bool) {
+	v, ok := node.defaults[name]
+	return v, ok
+}
+
+// WithDefault returns a new FloatNode, with the default value set to the variable.
+// The variable should exist in the node, and the value should be acceptable by the factory method
+// as a data value, and allowed by the constraint of the variable, if exists.
+func (node *FloatNode) WithDefault(name string, value interface{}) ItemNode {
+	defaultValue := NewFloatNode(node.byteSize, value)
+	result := &FloatNode{
+		node.byteSize, node.values, node.variables, node.constraints,
+		withDefault(node.defaults, node.variables, name, defaultValue), node.array,
+	}
+	result.checkRep()
+	return result
+}
+
+// ArraySize returns the size range of the array variable, where max == -1 means no limit.
+// ok is false if the node doesn't have a array variable.
+func (node *FloatNode) ArraySize() (min, max int, ok bool) {
+	return node.array.sizeRange()
+}
+
+// WithArraySize returns a new FloatNode, whose variable is a array variable with the size range,
+// e.g. <F4[2..5] VAR>, which is filled in with an array of data values in range of the size range.
+// The node should consist of a single variable without a default value.
+//
+// min and max should meet following conditions.
+// min >= 0, max >= -1, where -1 means no limit.
+// min <= max, when max != -1.
+func (node *FloatNode) WithArraySize(min, max int) ItemNode {
+	result := &FloatNode{
+		node.byteSize, node.values, node.variables, node.constraints, node.defaults,
+		&arrayVariable{min, max},
+	}
+	result.checkRep()
+	return result
+}
+
 // FillVariables implements ItemNode.FillVariables().
-func (node *FloatNode) FillVariables(values map[string]interface{}) ItemNode {
+//
+// A fill-in value of any Go integer or float type is converted, when it's a finite number
+// in range of the byte size.
+// A slice or an array of them is filled in as multiple data values in place of the variable.
+// A string fill-in value renames the variable, and the constraint of the variable is kept.
+func (node *FloatNode) FillVariables(values map[string]interface{}, opts ...FillOption) (ItemNode, error) {
 	if len(node.variables) == 0 {
-		return node
+		return node, nil
 	}
 
-	nodeValues := make([]interface{}, 0, node.Size())
-	for _, v := range node.values {
-		nodeValues = append(nodeValues, v)
+	filled, err := fillValues(
+		node.Size(), func(i int) interface{} { return node.values[i] }, node.variables, node.constraints,
+		node.defaults, node.array, values, func(v interface{}) ([]interface{}, error) { return convertValues(v, floatConverter(node.byteSize)) },
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	createNew := false
-	for name, pos := range node.variables {
-		if v, ok := values[name]; ok {
-			nodeValues[pos] = v
-			createNew = true
-		} else {
-			nodeValues[pos] = name
-		}
+	if !filled.createNew {
+		return node, nil
 	}
-
-	if !createNew {
-		return node
+	result, err := tryNew(func() ItemNode { return NewFloatNode(node.byteSize, filled.values...) })
+	if err != nil {
+		return nil, err
 	}
-	return NewFloatNode(node.byteSize, nodeValues...)
+	filledNode := result.(*FloatNode)
+	filledNode.constraints, filledNode.defaults, filledNode.array = filled.constraints, filled.defaults, filled.array
+	return result, nil
 }
 
 // ToBytes implements ItemNode.ToBytes()
@@ -176,9 +264,15 @@ func (node *FloatNode) String() string {
 
 	for name, pos := range node.variables {
 		values[pos] = name
+		if c, ok := node.constraints[name]; ok {
+			values[pos] += c.String()
+		}
+		if d, ok := node.defaults[name]; ok {
+			values[pos] += "=" + strconv.FormatFloat(d.(float64), 'g', -1, node.byteSize*8)
+		}
 	}
 
-	return fmt.Sprintf("<F%d[%d] %v>", node.byteSize, node.Size(), strings.Join(values, " "))
+	return fmt.Sprintf("<F%d%s %v>", node.byteSize, sizeString(node.array, node.Size()), strings.Join(values, " "))
 }
 
 // Private methods
@@ -221,4 +315,16 @@ func (node *FloatNode) checkRep() {
 			panic("variable position overflow")
 		}
 	}
+
+	if node.constraints != nil && len(node.constraints) == 0 {
+		panic("empty constraints")
+	}
+	for name := range node.constraints {
+		if _, ok := node.variables[name]; !ok {
+			panic("constraint of unknown variable")
+		}
+	}
+
+	checkDefaults(node.defaults, node.variables, node.constraints)
+	checkArrayVariable(node.array, node.variables, node.Size(), node.defaults)
 }
@@ -155,7 +155,8 @@ func TestF4Node_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewFloatNode(4, test.input...).FillVariables(test.fillInValues)
+		node, err := NewFloatNode(4, test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -307,7 +308,8 @@ func TestF8Node_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewFloatNode(8, test.input...).FillVariables(test.fillInValues)
+		node, err := NewFloatNode(8, test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -248,6 +248,23 @@ func (msg *ControlMessage) Type() string {
 	}
 }
 
+// SessionID returns the session id of the control message, which is 0xFFFF
+// for linktest.req and linktest.rsp.
+func (msg *ControlMessage) SessionID() int {
+	return int(msg.header[0])<<8 | int(msg.header[1])
+}
+
+// Status returns the status byte of the control message, i.e. the select status of select.rsp,
+// the deselect status of deselect.rsp, and the reason code of reject.req.
+func (msg *ControlMessage) Status() byte {
+	return msg.header[3]
+}
+
+// SystemBytes returns the system bytes of the control message.
+func (msg *ControlMessage) SystemBytes() []byte {
+	return append([]byte{}, msg.header[6:10]...)
+}
+
 // ToBytes returns the HSMS byte representation of the control message.
 func (msg *ControlMessage) ToBytes() []byte {
 	result := make([]byte, 0, 14)
@@ -25,6 +25,13 @@ func TestHSMSControlMessage(t *testing.T) {
 	msg := NewHSMSControlMessage([]byte{1, 2, 0, 0, 0, 1, 0, 1, 2, 3})
 	assert.Equal(t, "select.req", msg.Type())
 	assert.Equal(t, []byte{0, 0, 0, 10, 1, 2, 0, 0, 0, 1, 0, 1, 2, 3}, msg.ToBytes())
+	assert.Equal(t, 0x0102, msg.(*ControlMessage).SessionID())
+	assert.Equal(t, []byte{0, 1, 2, 3}, msg.(*ControlMessage).SystemBytes())
+
+	rsp := NewHSMSMessageSelectRsp(msg, 3).(*ControlMessage)
+	assert.Equal(t, byte(3), rsp.Status())
+	assert.Equal(t, 0x0102, rsp.SessionID())
+	assert.Equal(t, 0xFFFF, NewHSMSMessageLinktestReq([]byte{0, 0, 0, 1}).(*ControlMessage).SessionID())
 }
 
 func TestHSMSControlMessage_SelectReqRsp(t *testing.T) {
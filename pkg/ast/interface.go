@@ -30,6 +30,20 @@ const MAX_BYTE_SIZE = 1<<24 - 1
 // when the ellipsis is filled in with 1 (1 repetition).
 // For more detailed information, refer to the documentation of ListNode.
 //
+// Variables in UintNode, IntNode, and FloatNode can have a constraint, which limits the values
+// to be filled in, and a ListNode with ellipsis can have a size range, which limits the size of
+// the list after the ellipsis is filled in. Refer to the documentation of Constraint and ListNode.
+//
+// A variable in UintNode, IntNode, FloatNode, BinaryNode, and BooleanNode can be a array variable,
+// which is filled in with an array of data values, when it's the only value in the node,
+// e.g. <U4[..] SVIDS>. The number of the data values is limited by the size range of the array
+// variable, which is written as the data item size in the string representation of the node.
+//
+// Variables in all ItemNodes except ListNode can have a default value, which is filled into
+// the variable when the variable doesn't exist in the input map of FillVariables().
+// The default value follows the variable name and its constraint in the string representation
+// of the node, e.g. <U4 DATAID=0>, <U4 SVID{1..100}=1>, <A MDLN="model">.
+//
 // There is a limit on the number of data values that a ItemNode can contain,
 // as specified in the SEMI Standard.
 // The limit is expressed as following equation; n * b <= 16,777,215 (3 bytes),
@@ -44,9 +58,13 @@ type ItemNode interface {
 
 	// FillVariables returns a new ItemNode with the specified values filled into the variables.
 	// The map input argument has variable name as its key, and fill-in value as its value.
-	// Each fill-in value must be acceptable by the ItemNode's factory method.
-	// If a variable in the ItemNode doesn't exist in the input map, the variable will remain unchanged.
-	FillVariables(map[string]interface{}) ItemNode
+	// Each fill-in value is converted into the data value of the ItemNode, following the
+	// conversion rules of the ItemNode, and it must be allowed by the constraint of the variable,
+	// if exists. The options configure the conversion; refer to FillOption.
+	// If a variable in the ItemNode doesn't exist in the input map, its default value is filled in,
+	// if exists; otherwise, the variable will remain unchanged.
+	// An error is returned when a fill-in value cannot be converted or filled in.
+	FillVariables(values map[string]interface{}, opts ...FillOption) (ItemNode, error)
 
 	// ToBytes returns the byte representation of the data item.
 	ToBytes() []byte
@@ -72,8 +90,8 @@ func (node emptyItemNode) Variables() []string {
 }
 
 // FillVariables implements ItemNode.FillVariables().
-func (node emptyItemNode) FillVariables(values map[string]interface{}) ItemNode {
-	return node
+func (node emptyItemNode) FillVariables(values map[string]interface{}, opts ...FillOption) (ItemNode, error) {
+	return node, nil
 }
 
 // ToBytes implements ItemNode.ToBytes()
@@ -88,16 +106,19 @@ func (node emptyItemNode) String() string {
 
 // Helper functions
 
+var (
+	reVarName  = regexp.MustCompile(`^[A-Za-z_]\w*(\[\d+\])*$`)
+	reEllipsis = regexp.MustCompile(`^\.{3}(\[\d+\])?$`)
+)
+
 // isValidVarName checks that the variable name is valid as specified in the interface document.
 func isValidVarName(name string) bool {
-	re := regexp.MustCompile(`^[A-Za-z_]\w*(\[\d+\])*$`)
-	return re.MatchString(name)
+	return reVarName.MatchString(name)
 }
 
 // isEllipsis checks whether a variable is ellipsis or not.
 func isEllipsis(name string) bool {
-	re := regexp.MustCompile(`^\.{3}(\[\d+\])?$`)
-	return re.MatchString(name)
+	return reEllipsis.MatchString(name)
 }
 
 // getVariableNames returns variable names sorted by their positions.
@@ -1,5 +1,11 @@
 package ast
 
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
 // Tests the implementations of ItemNode interface.
 // The implementations consist of nodes that represent SECS-II data types
 // which are ASCII, binary, boolean, float(4,8), int(1,2,4,8), uint(1,2,4,8).
@@ -8,6 +14,7 @@ package ast
 //
 // For each implementation, create a new instance using the factory method or FillVariables(),
 // and test the result of public observer methods Size(), Variables(), ToBytes(), and String().
+// Test that the result of Values() can recreate the same node with the factory method.
 //
 // Partitions:
 //
@@ -22,3 +29,61 @@ package ast
 //
 // * ASCII and List type are special types and the partitions might differ.
 //   Refer to ascii_test.go and list_test.go.
+
+func TestItemNode_Values(t *testing.T) {
+	var tests = []struct {
+		description    string        // Test case description
+		node           ItemNode      // Input node
+		expectedValues []interface{} // expected result from Values()
+		recreate       func([]interface{}) ItemNode
+	}{
+		{
+			description:    "Binary",
+			node:           NewBinaryNode(1, "var", "0b11"),
+			expectedValues: []interface{}{1, "var", 3},
+			recreate:       func(v []interface{}) ItemNode { return NewBinaryNode(v...) },
+		},
+		{
+			description:    "Boolean",
+			node:           NewBooleanNode("var", true),
+			expectedValues: []interface{}{"var", true},
+			recreate:       func(v []interface{}) ItemNode { return NewBooleanNode(v...) },
+		},
+		{
+			description:    "F4, no values",
+			node:           NewFloatNode(4),
+			expectedValues: []interface{}{},
+			recreate:       func(v []interface{}) ItemNode { return NewFloatNode(4, v...) },
+		},
+		{
+			description:    "I2",
+			node:           NewIntNode(2, -1, "var"),
+			expectedValues: []interface{}{int64(-1), "var"},
+			recreate:       func(v []interface{}) ItemNode { return NewIntNode(2, v...) },
+		},
+		{
+			description:    "U8",
+			node:           NewUintNode(8, "var", 1),
+			expectedValues: []interface{}{"var", uint64(1)},
+			recreate:       func(v []interface{}) ItemNode { return NewUintNode(8, v...) },
+		},
+		{
+			description:    "List with variable and ellipsis",
+			node:           NewListNode(NewASCIINode("text"), "var", "..."),
+			expectedValues: []interface{}{NewASCIINode("text"), "var", "..."},
+			recreate:       func(v []interface{}) ItemNode { return NewListNode(v...) },
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		values := test.node.(interface{ Values() []interface{} }).Values()
+		assert.Equal(t, test.expectedValues, values)
+		assert.Equal(t, test.node, test.recreate(values))
+	}
+
+	assert.Equal(t, 4, NewFloatNode(4).(*FloatNode).ByteSize())
+	assert.Equal(t, 2, NewIntNode(2).(*IntNode).ByteSize())
+	assert.Equal(t, 8, NewUintNode(8).(*UintNode).ByteSize())
+	assert.Equal(t, "text", NewASCIINode("text").(*ASCIINode).Value())
+	assert.Equal(t, "", NewASCIINodeVariable("var", 0, -1).(*ASCIINode).Value())
+}
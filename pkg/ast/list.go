@@ -31,11 +31,17 @@ import (
 // therefore, repeating nested ListNode multiple times.
 // Nested ellipsis can be named and identified also with the array-like notation, e.g. ...[0], ...[1].
 //
+// A ListNode can have a size range, which limits the size of the ListNode after its ellipsis is filled in,
+// e.g. <L[1..10] <U4 SVID> ...>. An error is returned when filling in the ellipsis results in a ListNode
+// with the size out of the range.
+//
 // The size of the ListNode in it's string representation, will be only specified when the size is deterministic,
-// which means there is no ellipsis and ItemNode variable.
+// which means there is no ellipsis and ItemNode variable. Otherwise, the size range is specified if exists.
 type ListNode struct {
 	values    []ItemNode     // Array of ItemNodes that this ListNode contains
 	variables map[string]int // Variable name and its position in the data array
+	minSize   int            // minimum size of the list after the ellipsis is filled in
+	maxSize   int            // maximum size of the list after the ellipsis is filled in; -1 means no limit
 
 	// Rep invariants
 	// - If a variable exists in position i, values[i] will be zero-value (emptyItemNode) and should not be used
@@ -44,6 +50,8 @@ type ListNode struct {
 	// - All variable names in a ListNode, including its child item nodes' variables, should be unique
 	// - Each ListNode can contain at most one ellipsis variable, counted *non-recursively*
 	// - Variable positions should be unique, and be in range of [0, len(values))
+	// - minSize >= 0, maxSize >= -1, and minSize <= maxSize when maxSize != -1
+	// - If the ListNode doesn't have ellipsis, its size should be in range of [minSize, maxSize]
 }
 
 // Factory methods
@@ -77,7 +85,7 @@ func NewListNode(values ...interface{}) ItemNode {
 		}
 	}
 
-	node := &ListNode{nodeValues, nodeVariables}
+	node := &ListNode{nodeValues, nodeVariables, 0, -1}
 	node.checkRep()
 	return node
 }
@@ -107,31 +115,87 @@ func (node *ListNode) Variables() []string {
 	return result
 }
 
+// Values returns the data values and the variable names in the node, in their positions.
+// The result can be passed to the factory method, e.g. NewListNode(node.Values()...).
+func (node *ListNode) Values() []interface{} {
+	result := make([]interface{}, 0, node.Size())
+	for _, v := range node.values {
+		result = append(result, v)
+	}
+	for name, pos := range node.variables {
+		result[pos] = name
+	}
+	return result
+}
+
+// SizeRange returns the minimum and the maximum size of the list after the ellipsis is filled in.
+//
+// Return value of -1 means no limit.
+func (node *ListNode) SizeRange() (min int, max int) {
+	return node.minSize, node.maxSize
+}
+
+// WithSizeRange returns a new ListNode with the size range, which limits the size of the list
+// after the ellipsis is filled in.
+//
+// min and max should meet following conditions.
+// min >= 0, max >= -1, where -1 means no limit.
+// min <= max, when max != -1.
+// If the ListNode doesn't have ellipsis, its size should be in range of [min, max].
+func (node *ListNode) WithSizeRange(min, max int) ItemNode {
+	result := &ListNode{node.values, node.variables, min, max}
+	result.checkRep()
+	return result
+}
+
 // FillVariables implements ItemNode.FillVariables().
-func (node *ListNode) FillVariables(values map[string]interface{}) ItemNode {
+//
+// The options are passed to the FillVariables() of the child nodes.
+// An error is returned when the size of a ListNode, after the ellipsis is filled in,
+// is out of its size range.
+func (node *ListNode) FillVariables(values map[string]interface{}, opts ...FillOption) (ItemNode, error) {
 	ellipsisValues, otherValues := node.splitValues(values)
+	for name, v := range ellipsisValues {
+		count, err := convertEllipsis(v)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q: %v", name, err)
+		}
+		ellipsisValues[name] = count
+	}
 
 	// Fill in ellipsis
 	ellipsisToFill, ellipsisRemaining := node.ellipsisAnalysis(ellipsisValues)
 	nodeEllipsisFilled := node
 	if ellipsisToFill > 0 {
-		nodeEllipsisFilled = node.fillEllipsis(ellipsisValues, newFillState(ellipsisRemaining)).(*ListNode)
+		state := newFillState(ellipsisRemaining)
+		filled := node.fillEllipsis(ellipsisValues, state)
+		if state.err != nil {
+			return nil, state.err
+		}
+		nodeEllipsisFilled = filled.(*ListNode)
 	}
 
 	// Fill in non-ellipsis variables with specified values
 	nodeValues := make([]interface{}, 0, nodeEllipsisFilled.Size())
 	for _, item := range nodeEllipsisFilled.values {
-		nodeValues = append(nodeValues, item.FillVariables(otherValues))
+		filled, err := item.FillVariables(otherValues, opts...)
+		if err != nil {
+			return nil, err
+		}
+		nodeValues = append(nodeValues, filled)
 	}
 	for name, pos := range nodeEllipsisFilled.variables {
 		if v, ok := otherValues[name]; ok {
+			if _, ok := v.(ItemNode); !ok {
+				return nil, fmt.Errorf("variable %q: fill-in value has invalid type for ListNode", name)
+			}
 			nodeValues[pos] = v
 		} else {
 			nodeValues[pos] = name
 		}
 	}
 
-	return NewListNode(nodeValues...)
+	return nodeEllipsisFilled.newFilled(nodeValues)
 }
 
 // ToBytes implements ItemNode.ToBytes()
@@ -198,6 +262,13 @@ func (node *ListNode) checkRep() {
 		}
 	}
 
+	if node.minSize < 0 || node.maxSize < -1 || (node.maxSize != -1 && node.minSize > node.maxSize) {
+		panic("invalid size range")
+	}
+	if !ellipsisExist && !node.inSizeRange() {
+		panic("list size out of range")
+	}
+
 	// Check duplicated variables including child item nodes
 	variables := node.Variables()
 	foundVarName := map[string]bool{}
@@ -243,6 +314,8 @@ func (node *ListNode) stringIndented(level int) string {
 	sizeStr := ""
 	if sizeDetermined {
 		sizeStr = fmt.Sprintf("[%d]", node.Size())
+	} else if node.hasEllipsis() {
+		sizeStr = sizeRangeString(node.minSize, node.maxSize)
 	}
 	return fmt.Sprintf("%v<L%v\n%v%v>", indentStr, sizeStr, sb.String(), indentStr)
 }
@@ -257,6 +330,53 @@ func (node *ListNode) variablesSwapKeyValue() map[int]string {
 	return result
 }
 
+// hasEllipsis reports whether the ListNode has ellipsis, counted *non-recursively*.
+func (node *ListNode) hasEllipsis() bool {
+	for name := range node.variables {
+		if isEllipsis(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// inSizeRange reports whether the size of the ListNode is in its size range.
+func (node *ListNode) inSizeRange() bool {
+	return node.minSize <= node.Size() && (node.maxSize == -1 || node.Size() <= node.maxSize)
+}
+
+// checkFilledSize returns an error if the size of the ListNode, after its ellipsis named name
+// at position pos is filled in with count repetitions, exceeds its maximum size or MAX_BYTE_SIZE.
+// It is checked before the items are repeated, so that a large count doesn't allocate the items.
+func (node *ListNode) checkFilledSize(name string, pos, count int) error {
+	rest := node.Size() - pos - 1 // number of items after the ellipsis
+	if count > (MAX_BYTE_SIZE-rest)/pos-1 {
+		return fmt.Errorf("variable %q: ellipsis count %d out of range, list size limit exceeded", name, count)
+	}
+	size := (count+1)*pos + rest
+	if node.maxSize != -1 && size > node.maxSize {
+		return fmt.Errorf("list size %d out of range %s", size, sizeRangeString(node.minSize, node.maxSize))
+	}
+	return nil
+}
+
+// newFilled returns a new ListNode with the values filled in, which has the same size range
+// with this ListNode. An error is returned when the new ListNode cannot be created,
+// or when the new ListNode doesn't have ellipsis and its size is out of the size range.
+func (node *ListNode) newFilled(values []interface{}) (ItemNode, error) {
+	result, err := tryNew(func() ItemNode { return NewListNode(values...) })
+	if err != nil {
+		return nil, err
+	}
+
+	list := result.(*ListNode)
+	list.minSize, list.maxSize = node.minSize, node.maxSize
+	if !list.hasEllipsis() && !list.inSizeRange() {
+		return nil, fmt.Errorf("list size %d out of range %s", list.Size(), sizeRangeString(node.minSize, node.maxSize))
+	}
+	return list, nil
+}
+
 // splitValues splits input map into two independent map, one with ellipsis key and one without.
 func (node *ListNode) splitValues(values map[string]interface{}) (ellipsisValues, otherValues map[string]interface{}) {
 	ellipsisValues = map[string]interface{}{}
@@ -302,7 +422,11 @@ func (node *ListNode) ellipsisAnalysis(values map[string]interface{}) (int, int)
 // fillEllipsis fills in ellipsis variables with specified number of repeated
 // item nodes in the ListNode. Ellipsis will be filled in appearing order on
 // the top ListNode's string representation.
+// The first error encountered is set to state.err.
 func (node *ListNode) fillEllipsis(values map[string]interface{}, state *fillState) ItemNode {
+	if state.err != nil {
+		return node
+	}
 
 	// Check whether this ListNode have a ellipsis to fill
 	var (
@@ -314,6 +438,11 @@ func (node *ListNode) fillEllipsis(values map[string]interface{}, state *fillSta
 			ellipsisPosition = pos
 			ellipsisValue = values[name].(int)
 			if ellipsisValue > 0 {
+				// Check the size before repeating the items
+				if err := node.checkFilledSize(name, pos, ellipsisValue); err != nil {
+					state.setError(err)
+					return node
+				}
 				state.growDimension()
 			}
 			break
@@ -350,10 +479,11 @@ func (node *ListNode) fillEllipsis(values map[string]interface{}, state *fillSta
 			if len(item.Variables()) == 0 {
 				nodeValues = append(nodeValues, item)
 			} else {
-				varName := state.getNewVariableName(item.Variables()[0])
-				minLength := itemTyped.variable.minLength
-				maxLength := itemTyped.variable.maxLength
-				nodeValues = append(nodeValues, NewASCIINodeVariable(varName, minLength, maxLength))
+				variable := itemTyped.variable
+				variable.name = state.getNewVariableName(variable.name)
+				renamed := &ASCIINode{variable: variable}
+				renamed.checkRep()
+				nodeValues = append(nodeValues, renamed)
 			}
 		case emptyItemNode:
 			varName := state.getNewVariableName(posVar[i])
@@ -367,11 +497,22 @@ func (node *ListNode) fillEllipsis(values map[string]interface{}, state *fillSta
 				for _, v := range variables {
 					fill[v] = state.getNewVariableName(v)
 				}
-				nodeValues = append(nodeValues, item.FillVariables(fill))
+				filled, err := item.FillVariables(fill)
+				if err != nil {
+					state.setError(err)
+					filled = NewEmptyItemNode()
+				}
+				nodeValues = append(nodeValues, filled)
 			}
 		}
 	}
-	return NewListNode(nodeValues...)
+
+	result, err := node.newFilled(nodeValues)
+	if err != nil {
+		state.setError(err)
+		return node
+	}
+	return result
 }
 
 // fillState is a mutable data type that contains state information for ListNode.fillEllipsis().
@@ -382,6 +523,7 @@ type fillState struct {
 	currentIndices   []int // current indices which describe suffix of next variable name
 	ellipsisCount    int   // Number of encountered ellipsis that is not filled in
 	multipleEllipsis bool  // true if there will be multiple remaining ellipsis after fillEllipsis() call
+	err              error // the first error encountered during fillEllipsis() call
 }
 
 func newFillState(remainingEllipsisCount int) *fillState {
@@ -389,7 +531,13 @@ func newFillState(remainingEllipsisCount int) *fillState {
 	if remainingEllipsisCount > 1 {
 		multipleEllipsis = true
 	}
-	return &fillState{0, []int{}, 0, multipleEllipsis}
+	return &fillState{0, []int{}, 0, multipleEllipsis, nil}
+}
+
+func (state *fillState) setError(err error) {
+	if state.err == nil {
+		state.err = err
+	}
 }
 
 func (state *fillState) growDimension() {
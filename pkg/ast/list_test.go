@@ -596,7 +596,8 @@ func TestListNode_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewListNode(test.input...).FillVariables(test.inputFillInValues)
+		node, err := NewListNode(test.input...).FillVariables(test.inputFillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -0,0 +1,182 @@
+package ast
+
+import "fmt"
+
+// Match matches the item node against the template, which is a item node that contains variables,
+// and returns the values of the variables in the template, that are found in the item node.
+//
+// The item node should have the same structure as the template; the same data item types,
+// byte sizes, sizes and data values, except at the positions of the variables in the template.
+// An ASCIINode variable matches a string in range of its fill-in string length,
+// a variable with a constraint matches a value allowed by the constraint,
+// a array variable matches data values of any number in range of its size range, and
+// a variable in a ListNode matches any item node.
+// The item node should not contain variables, and the template should not contain ellipsis.
+//
+// The value of a variable has the type of uint64 in UintNode, int64 in IntNode, float64 in FloatNode,
+// int in BinaryNode, bool in BooleanNode, string in ASCIINode, and ItemNode in ListNode.
+// The value of a array variable has the type of []uint64, []int64, []float64, []byte, or []bool,
+// respectively. Filling the values into the template results in the item node.
+//
+// An error is returned when the item node doesn't match the template.
+func Match(template, item ItemNode) (map[string]interface{}, error) {
+	if len(item.Variables()) != 0 {
+		return nil, fmt.Errorf("item contains variables")
+	}
+
+	values := map[string]interface{}{}
+	if err := match(template, item, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// match matches the item node against the template, and adds the values of the variables
+// in the template to values.
+func match(template, item ItemNode, values map[string]interface{}) error {
+	if itemType(template) != itemType(item) {
+		return fmt.Errorf("expected %s item, found %s item", itemType(template), itemType(item))
+	}
+
+	var templateValues, itemValues []interface{}
+	switch template := template.(type) {
+	case *ASCIINode:
+		value := item.(*ASCIINode).Value()
+		if template.isValue {
+			if template.value != value {
+				return fmt.Errorf("expected %q, found %q", template.value, value)
+			}
+			return nil
+		}
+		min, max := template.FillInStringLength()
+		if len(value) < min || (max != -1 && max < len(value)) {
+			return fmt.Errorf("string length out of range: %q", value)
+		}
+		values[template.variable.name] = value
+		return nil
+	case *ListNode:
+		templateValues, itemValues = template.Values(), item.(*ListNode).Values()
+	case *BinaryNode:
+		templateValues, itemValues = template.Values(), item.(*BinaryNode).Values()
+	case *BooleanNode:
+		templateValues, itemValues = template.Values(), item.(*BooleanNode).Values()
+	case *FloatNode:
+		templateValues, itemValues = template.Values(), item.(*FloatNode).Values()
+	case *IntNode:
+		templateValues, itemValues = template.Values(), item.(*IntNode).Values()
+	case *UintNode:
+		templateValues, itemValues = template.Values(), item.(*UintNode).Values()
+	default:
+		return nil
+	}
+
+	if array := arrayOf(template); array != nil {
+		name := template.Variables()[0]
+		if err := array.check(name, len(itemValues)); err != nil {
+			return err
+		}
+		for _, v := range itemValues {
+			if err := checkConstraint(name, v, constraintOf(template, name)); err != nil {
+				return err
+			}
+		}
+		values[name] = arrayValue(item)
+		return nil
+	}
+
+	if len(templateValues) != len(itemValues) {
+		return fmt.Errorf("expected %s[%d] item, found %s[%d] item",
+			itemType(template), len(templateValues), itemType(item), len(itemValues))
+	}
+
+	for i, v := range templateValues {
+		if name, ok := v.(string); ok {
+			if isEllipsis(name) {
+				return fmt.Errorf("ellipsis is not supported")
+			}
+			if err := checkConstraint(name, itemValues[i], constraintOf(template, name)); err != nil {
+				return err
+			}
+			values[name] = itemValues[i]
+		} else if node, ok := v.(ItemNode); ok {
+			if err := match(node, itemValues[i].(ItemNode), values); err != nil {
+				return err
+			}
+		} else if v != itemValues[i] {
+			return fmt.Errorf("expected %v in %s item, found %v", v, itemType(template), itemValues[i])
+		}
+	}
+	return nil
+}
+
+// constraintOf returns the constraint of the variable in the node, or nil if not exists.
+func constraintOf(node ItemNode, name string) *Constraint {
+	switch node := node.(type) {
+	case *FloatNode:
+		return node.Constraint(name)
+	case *IntNode:
+		return node.Constraint(name)
+	case *UintNode:
+		return node.Constraint(name)
+	}
+	return nil
+}
+
+// arrayOf returns the array variable of the node, or nil if not exists.
+func arrayOf(node ItemNode) *arrayVariable {
+	switch node := node.(type) {
+	case *BinaryNode:
+		return node.array
+	case *BooleanNode:
+		return node.array
+	case *FloatNode:
+		return node.array
+	case *IntNode:
+		return node.array
+	case *UintNode:
+		return node.array
+	}
+	return nil
+}
+
+// arrayValue returns the data values of the node without variables, as the value of a array variable.
+func arrayValue(node ItemNode) interface{} {
+	switch node := node.(type) {
+	case *BinaryNode:
+		result := make([]byte, 0, len(node.values))
+		for _, v := range node.values {
+			result = append(result, byte(v))
+		}
+		return result
+	case *BooleanNode:
+		return append([]bool{}, node.values...)
+	case *FloatNode:
+		return append([]float64{}, node.values...)
+	case *IntNode:
+		return append([]int64{}, node.values...)
+	case *UintNode:
+		return append([]uint64{}, node.values...)
+	}
+	return nil
+}
+
+// itemType returns the data item type of the item node, as written in SML, e.g. "L", "U4".
+func itemType(node ItemNode) string {
+	switch node := node.(type) {
+	case *ASCIINode:
+		return "A"
+	case *ListNode:
+		return "L"
+	case *BinaryNode:
+		return "B"
+	case *BooleanNode:
+		return "BOOLEAN"
+	case *FloatNode:
+		return fmt.Sprintf("F%d", node.byteSize)
+	case *IntNode:
+		return fmt.Sprintf("I%d", node.byteSize)
+	case *UintNode:
+		return fmt.Sprintf("U%d", node.byteSize)
+	}
+	return "empty"
+}
@@ -0,0 +1,134 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests matching a item node against a template.
+//
+// Testing Strategy:
+//
+// Match item nodes against templates, and test the returned values or the error text.
+// For the matched cases, test that filling the values into the template results in the item node.
+//
+// Partitions:
+//
+// - Template: without variables, with variables in each node type, nested list, variable in list,
+//             ellipsis
+// - Item node: same structure, different type, byte size, size, value, ASCII length out of range,
+//              with variables
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		description    string                 // Test case description
+		template       ItemNode               // Input template
+		item           ItemNode               // Input item node
+		expectedValues map[string]interface{} // expected values
+		expectedError  string                 // expected error text, empty if no error
+	}{
+		{
+			description:    "Template without variables",
+			template:       NewListNode(NewASCIINode("text"), NewUintNode(1, 1)),
+			item:           NewListNode(NewASCIINode("text"), NewUintNode(1, 1)),
+			expectedValues: map[string]interface{}{},
+		},
+		{
+			description: "Variables in each node type",
+			template: NewListNode(
+				NewASCIINodeVariable("a", 0, -1),
+				NewBinaryNode("b", 1),
+				NewBooleanNode("bool"),
+				NewFloatNode(8, "f"),
+				NewIntNode(2, "i1", "i2"),
+				NewUintNode(4, "u"),
+			),
+			item: NewListNode(
+				NewASCIINode("text"),
+				NewBinaryNode(255, 1),
+				NewBooleanNode(true),
+				NewFloatNode(8, 0.5),
+				NewIntNode(2, -1, 1),
+				NewUintNode(4, 10),
+			),
+			expectedValues: map[string]interface{}{
+				"a":    "text",
+				"b":    255,
+				"bool": true,
+				"f":    0.5,
+				"i1":   int64(-1),
+				"i2":   int64(1),
+				"u":    uint64(10),
+			},
+		},
+		{
+			description:    "Nested list, variable in list",
+			template:       NewListNode(NewListNode(NewUintNode(1, "u"), "node")),
+			item:           NewListNode(NewListNode(NewUintNode(1, 1), NewListNode())),
+			expectedValues: map[string]interface{}{"u": uint64(1), "node": NewListNode()},
+		},
+		{
+			description:   "Different type",
+			template:      NewListNode(NewUintNode(1, "u")),
+			item:          NewListNode(NewIntNode(1, 1)),
+			expectedError: "expected U1 item, found I1 item",
+		},
+		{
+			description:   "Different byte size",
+			template:      NewUintNode(4, "u"),
+			item:          NewUintNode(2, 1),
+			expectedError: "expected U4 item, found U2 item",
+		},
+		{
+			description:   "Different size",
+			template:      NewListNode(NewASCIINode("text")),
+			item:          NewListNode(),
+			expectedError: "expected L[1] item, found L[0] item",
+		},
+		{
+			description:   "Different value",
+			template:      NewBinaryNode(0, "b"),
+			item:          NewBinaryNode(1, 1),
+			expectedError: "expected 0 in B item, found 1",
+		},
+		{
+			description:   "Different ASCII value",
+			template:      NewASCIINode("text"),
+			item:          NewASCIINode("txt"),
+			expectedError: `expected "text", found "txt"`,
+		},
+		{
+			description:   "ASCII length out of range",
+			template:      NewASCIINodeVariable("a", 0, 2),
+			item:          NewASCIINode("text"),
+			expectedError: `string length out of range: "text"`,
+		},
+		{
+			description:   "Ellipsis in template",
+			template:      NewListNode(NewUintNode(1, "u"), "..."),
+			item:          NewListNode(NewUintNode(1, 1), NewUintNode(1, 2)),
+			expectedError: "ellipsis is not supported",
+		},
+		{
+			description:   "Item with variables",
+			template:      NewUintNode(1, "u"),
+			item:          NewUintNode(1, "v"),
+			expectedError: "item contains variables",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		values, err := Match(test.template, test.item)
+		if test.expectedError != "" {
+			assert.EqualError(t, err, test.expectedError)
+			assert.Nil(t, values)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedValues, values)
+		filled, err := test.template.FillVariables(values)
+		assert.NoError(t, err)
+		assert.Equal(t, test.item, filled)
+	}
+}
@@ -133,7 +133,8 @@ func TestU1Node_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewUintNode(1, test.input...).FillVariables(test.fillInValues)
+		node, err := NewUintNode(1, test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -262,7 +263,8 @@ func TestU2Node_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewUintNode(2, test.input...).FillVariables(test.fillInValues)
+		node, err := NewUintNode(2, test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -391,7 +393,8 @@ func TestU4Node_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewUintNode(4, test.input...).FillVariables(test.fillInValues)
+		node, err := NewUintNode(4, test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
@@ -530,7 +533,8 @@ func TestU8Node_ProducedByFillVariables(t *testing.T) {
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
-		node := NewUintNode(8, test.input...).FillVariables(test.fillInValues)
+		node, err := NewUintNode(8, test.input...).FillVariables(test.fillInValues)
+		assert.NoError(t, err)
 		assert.Equal(t, test.expectedSize, node.Size())
 		assert.Equal(t, test.expectedVariables, node.Variables())
 		assert.Equal(t, test.expectedToBytes, node.ToBytes())
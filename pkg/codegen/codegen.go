@@ -0,0 +1,666 @@
+// Package codegen generates Go code from SML message templates.
+//
+// For each message, the generated code consists of a struct type that has
+// a field for each variable in the message, a constructor that fills the fields
+// into the message, and a decoder that extracts the fields from a received message.
+// A list with ellipsis in the message becomes a slice field, which has a struct type
+// for the repeated items, or the type of the variable when there is only one variable.
+//
+// Constraints of the variables and size ranges of the lists are kept in the templates,
+// so that the constructor and the decoder return an error when a value is not allowed.
+// Default values of the variables are also kept; a variable with a default value becomes
+// a pointer field, which is filled in with the default value when it's nil.
+//
+// The generated code uses the helper type of this package, List.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Generate returns the Go source code of the package pkgName, that contains
+// the generated code of the messages.
+//
+// The type name of a message is its name, converted to an exported Go identifier,
+// e.g. EventReport for EventReport and Event_report. When a message doesn't have a name,
+// its stream function code, e.g. S6F11, is used.
+// The field names are the variable names converted in the same way.
+//
+// An error is returned when the type names or the field names are not valid or duplicated.
+func Generate(pkgName string, messages []*ast.DataMessage) ([]byte, error) {
+	if !token.IsIdentifier(pkgName) {
+		return nil, fmt.Errorf("invalid package name %q", pkgName)
+	}
+
+	g := &generator{typeNames: map[string]bool{}}
+	for _, msg := range messages {
+		if err := g.message(msg); err != nil {
+			return nil, fmt.Errorf("%s: %v", msg.Header(), err)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by smlgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n", pkgName)
+	if len(messages) > 0 {
+		fmt.Fprintf(&buf, "\nimport (\n\t\"fmt\"\n\n")
+		fmt.Fprintf(&buf, "\t\"github.com/wolimst/lib-secs2-hsms-go/pkg/ast\"\n")
+		if g.usesHelpers {
+			fmt.Fprintf(&buf, "\t\"github.com/wolimst/lib-secs2-hsms-go/pkg/codegen\"\n")
+		}
+		fmt.Fprintf(&buf, ")\n")
+	}
+	buf.Write(g.buf.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+// generator writes the generated code of messages into buf.
+type generator struct {
+	buf         bytes.Buffer
+	typeNames   map[string]bool // type names in use
+	usesHelpers bool            // whether the code uses the helper type of this package
+}
+
+// scope is a struct type of a message, or of the repeated items in a list.
+type scope struct {
+	typeName   string          // name of the struct type
+	doc        string          // description of the struct type
+	element    bool            // whether the scope is the repeated items of a list
+	fields     []field         // fields for the variables
+	lists      []list          // fields for the lists with ellipsis, inner lists first
+	fieldNames map[string]bool // field names in use
+}
+
+// field is a field of a struct type, for a variable in a message.
+type field struct {
+	name     string // field name
+	variable string // variable name
+	kind     string // one of "A", "B", "BOOLEAN", "F", "I", "U", "L"
+	goType   string // Go type of the field, or of the pointer field if optional
+	optional bool   // whether the variable has a default value, which is used when the field is nil
+}
+
+// list is a slice field of a struct type, for a list with ellipsis in a message.
+// The variable name of the list is the field name.
+type list struct {
+	name     string // field name
+	template string // name of the template variable of the list
+	elem     *scope // struct type of the repeated items
+	elemType string // Go type of the slice elements
+}
+
+// message writes the code of the message.
+func (g *generator) message(msg *ast.DataMessage) error {
+	name := msg.Name()
+	if name == "" {
+		name = fmt.Sprintf("S%dF%d", msg.StreamCode(), msg.FunctionCode())
+	}
+	typeName, err := g.newTypeName(name)
+	if err != nil {
+		return err
+	}
+
+	sc := newScope(typeName, fmt.Sprintf("the message %q", msg.Header()))
+	template := ""
+	if msg.Item() != nil {
+		if template, err = g.template(msg.Item(), sc); err != nil {
+			return err
+		}
+	}
+	g.scope(sc)
+
+	templateVar := unexported(typeName) + "Template"
+	header := msg.Header()
+	waitBit := map[string]int{"false": 0, "true": 1, "optional": 2}[msg.WaitBit()]
+	placeholder := strings.HasPrefix(template, `"`)
+
+	w := &g.buf
+	if template != "" && !placeholder {
+		fmt.Fprintf(w, "\nvar %s = %s\n", templateVar, template)
+	}
+
+	fmt.Fprintf(w, "\n// New%s returns the message %q filled with v.\n", typeName, header)
+	fmt.Fprintf(w, "// An error is returned when a value in v is not allowed in the message.\n")
+	fmt.Fprintf(w, "func New%s(v %s) (*ast.DataMessage, error) {\n", typeName, typeName)
+	switch {
+	case template == "":
+		fmt.Fprintf(w, "item := ast.NewEmptyItemNode()\n")
+	case sc.isEmpty():
+		fmt.Fprintf(w, "item := %s\n", templateVar)
+	default:
+		fmt.Fprintf(w, "values, err := v.values()\n")
+		fmt.Fprintf(w, "if err != nil {\nreturn nil, err\n}\n")
+		if placeholder {
+			fmt.Fprintf(w, "item := values[%s].(ast.ItemNode)\n", template)
+		} else {
+			fmt.Fprintf(w, "item, err := %s.FillVariables(values)\n", templateVar)
+			fmt.Fprintf(w, "if err != nil {\nreturn nil, err\n}\n")
+		}
+	}
+	fmt.Fprintf(w, "return ast.NewDataMessage(%q, %d, %d, %d, %q, item), nil\n}\n",
+		msg.Name(), msg.StreamCode(), msg.FunctionCode(), waitBit, msg.Direction())
+
+	fmt.Fprintf(w, "\n// Decode%s returns the data of the message %q in msg.\n", typeName, header)
+	fmt.Fprintf(w, "// An error is returned when msg doesn't match the message.\n")
+	fmt.Fprintf(w, "func Decode%s(msg *ast.DataMessage) (%s, error) {\n", typeName, typeName)
+	fmt.Fprintf(w, "var v %s\n", typeName)
+	fmt.Fprintf(w, "if msg.StreamCode() != %d || msg.FunctionCode() != %d {\n", msg.StreamCode(), msg.FunctionCode())
+	fmt.Fprintf(w, "return v, fmt.Errorf(\"expected S%dF%d, found S%%dF%%d\", msg.StreamCode(), msg.FunctionCode())\n}\n",
+		msg.StreamCode(), msg.FunctionCode())
+	switch {
+	case template == "":
+		fmt.Fprintf(w, "if msg.Item() != nil {\nreturn v, fmt.Errorf(\"unexpected data item\")\n}\n")
+		fmt.Fprintf(w, "return v, nil\n}\n")
+		return nil
+	case sc.isEmpty():
+		fmt.Fprintf(w, "if msg.Item() == nil {\nreturn v, fmt.Errorf(\"missing data item\")\n}\n")
+		fmt.Fprintf(w, "_, err := ast.Match(%s, msg.Item())\n", templateVar)
+		fmt.Fprintf(w, "return v, err\n}\n")
+		return nil
+	case placeholder:
+		fmt.Fprintf(w, "values := map[string]interface{}{%s: msg.Item()}\n", template)
+	default:
+		fmt.Fprintf(w, "if msg.Item() == nil {\nreturn v, fmt.Errorf(\"missing data item\")\n}\n")
+		fmt.Fprintf(w, "values, err := ast.Match(%s, msg.Item())\n", templateVar)
+		fmt.Fprintf(w, "if err != nil {\nreturn v, err\n}\n")
+	}
+	fmt.Fprintf(w, "return v, v.decode(values)\n}\n")
+	return nil
+}
+
+// template returns the Go expression that creates the template of the item node,
+// where lists with ellipsis are replaced with variables. The fields for the variables
+// are added to the scope sc.
+// If the item node itself is a list with ellipsis, returns the quoted variable name.
+func (g *generator) template(node ast.ItemNode, sc *scope) (string, error) {
+	switch node := node.(type) {
+	case *ast.ASCIINode:
+		if len(node.Variables()) == 0 {
+			return fmt.Sprintf("ast.NewASCIINode(%q)", node.Value()), nil
+		}
+		name := node.Variables()[0]
+		if err := sc.addField(name, "A", "string"); err != nil {
+			return "", err
+		}
+		min, max := node.FillInStringLength()
+		expr := fmt.Sprintf("ast.NewASCIINodeVariable(%q, %d, %d)", name, min, max)
+		return defaulted(expr, "ASCIINode", node.Variables(), node.Default, sc), nil
+	case *ast.ListNode:
+		return g.listTemplate(node, sc)
+	case *ast.BinaryNode:
+		expr, err := valuesTemplate("ast.NewBinaryNode(", node.Values(), sc, "B", arrayType("byte", "[]byte", node.ArraySize))
+		return arraySized(defaulted(expr, "BinaryNode", node.Variables(), node.Default, sc), "BinaryNode", node.ArraySize), err
+	case *ast.BooleanNode:
+		expr, err := valuesTemplate("ast.NewBooleanNode(", node.Values(), sc, "BOOLEAN", arrayType("bool", "[]bool", node.ArraySize))
+		return arraySized(defaulted(expr, "BooleanNode", node.Variables(), node.Default, sc), "BooleanNode", node.ArraySize), err
+	case *ast.FloatNode:
+		goType := arrayType(fmt.Sprintf("float%d", node.ByteSize()*8), fmt.Sprintf("[]float%d", node.ByteSize()*8), node.ArraySize)
+		expr, err := valuesTemplate(fmt.Sprintf("ast.NewFloatNode(%d, ", node.ByteSize()), node.Values(), sc, "F", goType)
+		expr = constrained(expr, "FloatNode", node.Variables(), node.Constraint)
+		return arraySized(defaulted(expr, "FloatNode", node.Variables(), node.Default, sc), "FloatNode", node.ArraySize), err
+	case *ast.IntNode:
+		goType := arrayType(fmt.Sprintf("int%d", node.ByteSize()*8), fmt.Sprintf("[]int%d", node.ByteSize()*8), node.ArraySize)
+		expr, err := valuesTemplate(fmt.Sprintf("ast.NewIntNode(%d, ", node.ByteSize()), node.Values(), sc, "I", goType)
+		expr = constrained(expr, "IntNode", node.Variables(), node.Constraint)
+		return arraySized(defaulted(expr, "IntNode", node.Variables(), node.Default, sc), "IntNode", node.ArraySize), err
+	case *ast.UintNode:
+		goType := arrayType(fmt.Sprintf("uint%d", node.ByteSize()*8), fmt.Sprintf("[]uint%d", node.ByteSize()*8), node.ArraySize)
+		expr, err := valuesTemplate(fmt.Sprintf("ast.NewUintNode(%d, ", node.ByteSize()), node.Values(), sc, "U", goType)
+		expr = constrained(expr, "UintNode", node.Variables(), node.Constraint)
+		return arraySized(defaulted(expr, "UintNode", node.Variables(), node.Default, sc), "UintNode", node.ArraySize), err
+	}
+	return "", fmt.Errorf("unsupported data item %v", node)
+}
+
+// listTemplate returns the Go expression that creates the template of the list.
+// A list with ellipsis is added to the scope sc as a slice field, and the quoted field name is returned.
+func (g *generator) listTemplate(node *ast.ListNode, sc *scope) (string, error) {
+	values := node.Values()
+	ellipsis := -1
+	for i, v := range values {
+		if name, ok := v.(string); ok && strings.HasPrefix(name, "...") {
+			ellipsis = i
+		}
+	}
+
+	if ellipsis == -1 {
+		items, err := g.listItems(values, sc)
+		if err != nil {
+			return "", err
+		}
+		return "ast.NewListNode(" + items + ")", nil
+	}
+
+	// The field is named after the first variable in the repeated items, e.g. SVIDList
+	name := goName(firstVariable(values[:ellipsis]))
+	l := list{name: sc.newFieldName(name + "List")}
+	if name == "" {
+		name = "List"
+	}
+	typeName, err := g.newTypeName(sc.typeName + name)
+	if err != nil {
+		return "", err
+	}
+	l.elem = newScope(typeName, fmt.Sprintf("the repeated items of %s.%s", sc.typeName, l.name))
+	l.elem.element = true
+	l.template = unexported(sc.typeName) + l.name
+
+	item, err := g.listItems(values[:ellipsis], l.elem)
+	if err != nil {
+		return "", err
+	}
+	tail, err := g.listItems(values[ellipsis+1:], sc)
+	if err != nil {
+		return "", err
+	}
+	min, max := node.SizeRange()
+	fmt.Fprintf(&g.buf, "\nvar %s = codegen.List{\nItem: ast.NewListNode(%s),\nTail: ast.NewListNode(%s),\nMinSize: %d,\nMaxSize: %d,\n}\n",
+		l.template, item, tail, min, max)
+
+	g.scope(l.elem)
+	l.elemType = l.elem.typeName
+	if l.elem.isSingle() {
+		l.elemType = l.elem.fields[0].goType
+	}
+	sc.lists = append(sc.lists, l)
+	g.usesHelpers = true
+	return strconv.Quote(l.name), nil
+}
+
+// listItems returns the Go expressions that create the templates of the list items,
+// joined with commas.
+func (g *generator) listItems(values []interface{}, sc *scope) (string, error) {
+	items := []string{}
+	for _, v := range values {
+		if name, ok := v.(string); ok {
+			if err := sc.addField(name, "L", "ast.ItemNode"); err != nil {
+				return "", err
+			}
+			items = append(items, strconv.Quote(name))
+			continue
+		}
+		item, err := g.template(v.(ast.ItemNode), sc)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ", "), nil
+}
+
+// scope writes the struct type of the scope sc, and its methods to convert
+// the struct from/into the values of the variables.
+// Nothing is written if sc is the repeated items of a list, that has only one variable.
+func (g *generator) scope(sc *scope) {
+	if sc.isSingle() {
+		return
+	}
+
+	w := &g.buf
+	fmt.Fprintf(w, "\n// %s is the data of %s.\n", sc.typeName, sc.doc)
+	fmt.Fprintf(w, "type %s struct {\n", sc.typeName)
+	for _, f := range sc.fields {
+		if f.optional {
+			fmt.Fprintf(w, "%s *%s\n", f.name, f.goType)
+		} else {
+			fmt.Fprintf(w, "%s %s\n", f.name, f.goType)
+		}
+	}
+	for _, l := range sc.lists {
+		fmt.Fprintf(w, "%s []%s\n", l.name, l.elemType)
+	}
+	fmt.Fprintf(w, "}\n")
+	if sc.isEmpty() {
+		return
+	}
+
+	fmt.Fprintf(w, "\nfunc (v %s) values() (map[string]interface{}, error) {\n", sc.typeName)
+	fmt.Fprintf(w, "values := map[string]interface{}{\n")
+	for _, f := range sc.fields {
+		if !f.optional {
+			fmt.Fprintf(w, "%q: %s,\n", f.variable, f.fillValue("v."+f.name))
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+	for _, f := range sc.fields {
+		if f.optional {
+			fmt.Fprintf(w, "if v.%s != nil {\nvalues[%q] = %s\n}\n", f.name, f.variable, f.fillValue("*v."+f.name))
+		}
+	}
+	for i, l := range sc.lists {
+		assign := "="
+		if i == 0 {
+			assign = ":="
+		}
+		fmt.Fprintf(w, "list, err %s %s.Build(values, len(v.%s), func(i int) (map[string]interface{}, error) {\n",
+			assign, l.template, l.name)
+		if l.elemType == l.elem.typeName {
+			fmt.Fprintf(w, "return v.%s[i].values()\n", l.name)
+		} else {
+			f := l.elem.fields[0]
+			fmt.Fprintf(w, "return map[string]interface{}{%q: %s}, nil\n", f.variable, f.fillValue("v."+l.name+"[i]"))
+		}
+		fmt.Fprintf(w, "})\n")
+		fmt.Fprintf(w, "if err != nil {\nreturn nil, err\n}\n")
+		fmt.Fprintf(w, "values[%q] = list\n", l.name)
+	}
+	fmt.Fprintf(w, "return values, nil\n}\n")
+
+	fmt.Fprintf(w, "\nfunc (v *%s) decode(values map[string]interface{}) error {\n", sc.typeName)
+	for i := len(sc.lists) - 1; i >= 0; i-- {
+		l := sc.lists[i]
+		assign := "="
+		if i == len(sc.lists)-1 {
+			assign = ":="
+		}
+		fmt.Fprintf(w, "elems, err %s %s.Match(values[%q], values)\n", assign, l.template, l.name)
+		fmt.Fprintf(w, "if err != nil {\nreturn err\n}\n")
+		fmt.Fprintf(w, "v.%s = make([]%s, len(elems))\n", l.name, l.elemType)
+		fmt.Fprintf(w, "for i, e := range elems {\n")
+		if l.elemType == l.elem.typeName {
+			fmt.Fprintf(w, "if err := v.%s[i].decode(e); err != nil {\nreturn err\n}\n", l.name)
+		} else {
+			f := l.elem.fields[0]
+			fmt.Fprintf(w, "v.%s[i] = %s\n", l.name, f.decodeValue("e"))
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+	for _, f := range sc.fields {
+		if f.optional {
+			fmt.Fprintf(w, "v.%s = new(%s)\n*v.%s = %s\n", f.name, f.goType, f.name, f.decodeValue("values"))
+		} else {
+			fmt.Fprintf(w, "v.%s = %s\n", f.name, f.decodeValue("values"))
+		}
+	}
+	fmt.Fprintf(w, "return nil\n}\n")
+}
+
+// newTypeName returns the type name converted from name, and marks it in use.
+func (g *generator) newTypeName(name string) (string, error) {
+	typeName := goName(name)
+	if !token.IsIdentifier(typeName) || !token.IsExported(typeName) {
+		return "", fmt.Errorf("invalid type name %q", typeName)
+	}
+	if g.typeNames[typeName] {
+		return "", fmt.Errorf("duplicated type name %q", typeName)
+	}
+	g.typeNames[typeName] = true
+	return typeName, nil
+}
+
+// newScope returns a new scope of the struct type typeName.
+func newScope(typeName, doc string) *scope {
+	return &scope{
+		typeName:   typeName,
+		doc:        doc,
+		fields:     []field{},
+		lists:      []list{},
+		fieldNames: map[string]bool{},
+	}
+}
+
+// isSingle reports whether the scope is the repeated items of a list that has only one variable,
+// without a default value. The slice field of the list has the type of the variable, instead of the struct type.
+func (sc *scope) isSingle() bool {
+	return sc.element && len(sc.fields) == 1 && len(sc.lists) == 0 && !sc.fields[0].optional
+}
+
+// isEmpty reports whether the scope is a message without variables.
+// The struct type doesn't have methods, since there's nothing to fill or decode.
+func (sc *scope) isEmpty() bool {
+	return !sc.element && len(sc.fields) == 0 && len(sc.lists) == 0
+}
+
+// addField adds a field for the variable to the scope.
+func (sc *scope) addField(variable, kind, goType string) error {
+	name := goName(variable)
+	if !token.IsIdentifier(name) || !token.IsExported(name) {
+		return fmt.Errorf("invalid field name %q", name)
+	}
+	if sc.fieldNames[name] {
+		return fmt.Errorf("duplicated field name %q", name)
+	}
+	sc.fieldNames[name] = true
+	sc.fields = append(sc.fields, field{name, variable, kind, goType, false})
+	return nil
+}
+
+// setOptional marks the field for the variable optional, which has a default value.
+func (sc *scope) setOptional(variable string) {
+	for i := range sc.fields {
+		if sc.fields[i].variable == variable {
+			sc.fields[i].optional = true
+		}
+	}
+}
+
+// newFieldName returns a unique field name in the scope based on name, and marks it in use.
+func (sc *scope) newFieldName(name string) string {
+	result := name
+	for i := 2; sc.fieldNames[result]; i++ {
+		result = fmt.Sprintf("%s%d", name, i)
+	}
+	sc.fieldNames[result] = true
+	return result
+}
+
+// fillValue returns the Go expression that converts the field value expr
+// to the fill-in value of the variable.
+func (f field) fillValue(expr string) string {
+	if f.kind == "B" && f.goType == "byte" {
+		return fmt.Sprintf("int(%s)", expr)
+	}
+	return expr
+}
+
+// decodeValue returns the Go expression that converts the value of the variable
+// in the values map expr, which is a result of ast.Match, to the field value.
+func (f field) decodeValue(expr string) string {
+	value := fmt.Sprintf("%s[%q]", expr, f.variable)
+	if strings.HasPrefix(f.goType, "[]") {
+		// array variable, whose value is a slice of the 64-bit type for the numbers
+		switch f.kind {
+		case "F":
+			return convertSlice(value, "[]float64", f.goType)
+		case "I":
+			return convertSlice(value, "[]int64", f.goType)
+		case "U":
+			return convertSlice(value, "[]uint64", f.goType)
+		}
+		return fmt.Sprintf("%s.(%s)", value, f.goType)
+	}
+	switch f.kind {
+	case "B":
+		return fmt.Sprintf("byte(%s.(int))", value)
+	case "F":
+		return convert(value, "float64", f.goType)
+	case "I":
+		return convert(value, "int64", f.goType)
+	case "U":
+		return convert(value, "uint64", f.goType)
+	}
+	return fmt.Sprintf("%s.(%s)", value, f.goType)
+}
+
+// convert returns the Go expression that asserts the type of the value to typ,
+// and converts it to goType.
+func convert(value, typ, goType string) string {
+	if typ == goType {
+		return fmt.Sprintf("%s.(%s)", value, typ)
+	}
+	return fmt.Sprintf("%s(%s.(%s))", goType, value, typ)
+}
+
+// convertSlice returns the Go expression that asserts the type of the value to the slice
+// type typ, and converts it to the slice type goType element by element.
+func convertSlice(value, typ, goType string) string {
+	if typ == goType {
+		return fmt.Sprintf("%s.(%s)", value, typ)
+	}
+	return fmt.Sprintf("func(a %s) %s {\nr := make(%s, len(a))\nfor i, e := range a {\nr[i] = %s(e)\n}\nreturn r\n}(%s.(%s))",
+		typ, goType, goType, goType[2:], value, typ)
+}
+
+// valuesTemplate returns the Go expression that creates the template of a item node
+// other than ASCII and list, by calling the factory method fn with the values.
+// The fields for the variables are added to the scope sc.
+func valuesTemplate(fn string, values []interface{}, sc *scope, kind, goType string) (string, error) {
+	args := []string{}
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			if err := sc.addField(v, kind, goType); err != nil {
+				return "", err
+			}
+			args = append(args, strconv.Quote(v))
+		case float64:
+			args = append(args, strconv.FormatFloat(v, 'g', -1, 64))
+		case int64:
+			if v < math.MinInt32 || math.MaxInt32 < v {
+				args = append(args, fmt.Sprintf("int64(%d)", v))
+			} else {
+				args = append(args, fmt.Sprint(v))
+			}
+		case uint64:
+			if math.MaxInt32 < v {
+				args = append(args, fmt.Sprintf("uint64(%d)", v))
+			} else {
+				args = append(args, fmt.Sprint(v))
+			}
+		default:
+			args = append(args, fmt.Sprint(v))
+		}
+	}
+	return fn + strings.Join(args, ", ") + ")", nil
+}
+
+// constrained returns the Go expression that sets the constraints of the variables
+// to the template expr, which creates a node of the type typ, e.g. "UintNode".
+func constrained(expr, typ string, variables []string, constraint func(name string) *ast.Constraint) string {
+	for _, name := range variables {
+		c := constraint(name)
+		if c == nil {
+			continue
+		}
+		ranges := []string{}
+		for _, r := range c.Ranges() {
+			ranges = append(ranges, fmt.Sprintf("ast.ValueRange{Min: %s, Max: %s}", numberTemplate(r.Min), numberTemplate(r.Max)))
+		}
+		expr = fmt.Sprintf("%s.(*ast.%s).WithConstraint(%q, ast.NewConstraint(%s))", expr, typ, name, strings.Join(ranges, ", "))
+	}
+	return expr
+}
+
+// defaulted returns the Go expression that sets the default values of the variables
+// to the template expr, which creates a node of the type typ, e.g. "UintNode".
+// The fields for the variables with default values are marked optional in the scope sc.
+func defaulted(expr, typ string, variables []string, defaultValue func(name string) (interface{}, bool), sc *scope) string {
+	for _, name := range variables {
+		v, ok := defaultValue(name)
+		if !ok {
+			continue
+		}
+		sc.setOptional(name)
+		expr = fmt.Sprintf("%s.(*ast.%s).WithDefault(%q, %s)", expr, typ, name, defaultTemplate(v))
+	}
+	return expr
+}
+
+// arraySized returns the Go expression that sets the array size range of the array variable
+// to the template expr, which creates a node of the type typ, e.g. "UintNode", if the node has
+// a array variable; otherwise, expr is returned as is.
+func arraySized(expr, typ string, arraySize func() (min, max int, ok bool)) string {
+	if min, max, ok := arraySize(); ok {
+		return fmt.Sprintf("%s.(*ast.%s).WithArraySize(%d, %d)", expr, typ, min, max)
+	}
+	return expr
+}
+
+// arrayType returns the Go type of the field for a variable, which is goType for a scalar variable,
+// and arrayGoType, the type of the value from ast.Match, for a array variable.
+func arrayType(goType, arrayGoType string, arraySize func() (min, max int, ok bool)) string {
+	if _, _, ok := arraySize(); ok {
+		return arrayGoType
+	}
+	return goType
+}
+
+// numberTemplate returns the Go expression of the number in a value range, which is
+// either nil, uint64, int64, or float64.
+func numberTemplate(v interface{}) string {
+	switch v := v.(type) {
+	case uint64:
+		return fmt.Sprintf("uint64(%d)", v)
+	case int64:
+		return fmt.Sprintf("int64(%d)", v)
+	case float64:
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return "nil"
+}
+
+// defaultTemplate returns the Go expression of the default value of a variable, which is
+// either string, int, bool, uint64, int64, or float64.
+func defaultTemplate(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case int, bool:
+		return fmt.Sprint(v)
+	}
+	return numberTemplate(v)
+}
+
+// firstVariable returns the first variable name in the list values, searching recursively,
+// or empty string if not found.
+func firstVariable(values []interface{}) string {
+	for _, v := range values {
+		if list, ok := v.(*ast.ListNode); ok {
+			if name := firstVariable(list.Values()); name != "" {
+				return name
+			}
+		} else if item, ok := v.(ast.ItemNode); ok && len(item.Variables()) > 0 {
+			return item.Variables()[0]
+		} else if name, ok := v.(string); ok && !strings.HasPrefix(name, "...") {
+			return name
+		}
+	}
+	return ""
+}
+
+// goName converts the name to an exported Go identifier, by removing the underscores
+// and the brackets, and capitalizing the letters after them, e.g. var_name[0] to VarName0.
+func goName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, ch := range name {
+		switch {
+		case ch == '_' || ch == '[' || ch == ']':
+			upper = true
+		case upper:
+			sb.WriteRune(unicode.ToUpper(ch))
+			upper = false
+		default:
+			sb.WriteRune(ch)
+		}
+	}
+	return sb.String()
+}
+
+// unexported returns the name with the first letter in lower case.
+func unexported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
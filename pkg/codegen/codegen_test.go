@@ -0,0 +1,223 @@
+package codegen
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+// Tests the code generation.
+//
+// The behavior of the generated code is tested in the example package,
+// which contains the code generated from example/messages.sml.
+//
+// Testing Strategy:
+//
+// Generate code from the example SML file, and test that it's equal to the generated file
+// in the example package. Generate code from SML input, and test the generated code
+// by the declarations it contains, and errors by their texts.
+//
+// Partitions:
+//
+// - Messages: none, example messages
+// - Message name: empty, valid, invalid, duplicated
+// - Variable name: with underbars and brackets, duplicated after conversion
+// - Array variable: U, I, F, B
+// - Variable with default value: U, A, B in the repeated items of a list
+// - List with ellipsis: with one variable, with multiple variables, without variables,
+//                       with items after the ellipsis, with duplicated field name
+
+func TestGenerate_Example(t *testing.T) {
+	messages, errs, _ := sml.LoadFile("example", "messages.sml")
+	assert.Empty(t, errs)
+
+	src, err := Generate("example", messages)
+	assert.NoError(t, err)
+
+	expected, err := ioutil.ReadFile("example/messages_gen.go")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(src), "run go generate in the example package")
+}
+
+func TestGenerate(t *testing.T) {
+	var tests = []struct {
+		description string   // Test case description
+		input       string   // Input SML
+		expected    []string // expected declarations in the generated code
+	}{
+		{
+			description: "No messages",
+			input:       "",
+			expected:    []string{"// Code generated by smlgen. DO NOT EDIT.\n\npackage test\n"},
+		},
+		{
+			description: "Message without name",
+			input:       "S1F1 W H->E .",
+			expected:    []string{"type S1F1 struct", "func NewS1F1(v S1F1)", "func DecodeS1F1("},
+		},
+		{
+			description: "Variable names with underbars and brackets",
+			input:       "S1F2 H<-E Online_data <L <A model_name> <A version[0]>> .",
+			expected: []string{
+				"type OnlineData struct {\n\tModelName string\n\tVersion0  string\n}",
+				`"model_name": v.ModelName,`,
+			},
+		},
+		{
+			description: "List with ellipsis without variables, and items after the ellipsis",
+			input:       `S1F1 W H->E Msg <L <L <A "item"> ... <U1 N>> <L <U1 V> ... <U1 W>>> .`,
+			expected: []string{
+				"type Msg struct {\n\tN     uint8\n\tW     uint8\n\tList  []MsgList\n\tVList []uint8\n}",
+				"type MsgList struct {\n}",
+				"var msgVList = codegen.List{\n\tItem:    ast.NewListNode(ast.NewUintNode(1, \"V\")),\n\tTail:    ast.NewListNode(ast.NewUintNode(1, \"W\")),\n\tMinSize: 0,\n\tMaxSize: -1,\n}",
+			},
+		},
+		{
+			description: "Variables with default values",
+			input:       `S1F1 W H->E Msg <L <U4 V1=5> <A V2="text"> <L <B V3=0x01> ...>> .`,
+			expected: []string{
+				"type Msg struct {\n\tV1     *uint32\n\tV2     *string\n\tV3List []MsgV3\n}",
+				"type MsgV3 struct {\n\tV3 *byte\n}",
+				`ast.NewUintNode(4, "V1").(*ast.UintNode).WithDefault("V1", uint64(5))`,
+				`ast.NewASCIINodeVariable("V2", 0, -1).(*ast.ASCIINode).WithDefault("V2", "text")`,
+				"if v.V1 != nil {\n\t\tvalues[\"V1\"] = *v.V1\n\t}",
+				"v.V1 = new(uint32)\n\t*v.V1 = uint32(values[\"V1\"].(uint64))",
+			},
+		},
+		{
+			description: "Array variables",
+			input:       `S1F1 W H->E Msg <L <U2[..] V1> <I1[1..] V2> <F8[..] V3> <B[..] V4>> .`,
+			expected: []string{
+				"type Msg struct {\n\tV1 []uint16\n\tV2 []int8\n\tV3 []float64\n\tV4 []byte\n}",
+				"v.V1 = func(a []uint64) []uint16 {\n\t\tr := make([]uint16, len(a))\n\t\tfor i, e := range a {\n\t\t\tr[i] = uint16(e)\n\t\t}\n\t\treturn r\n\t}(values[\"V1\"].([]uint64))",
+				"v.V2 = func(a []int64) []int8 {",
+				`v.V3 = values["V3"].([]float64)`,
+				`v.V4 = values["V4"].([]byte)`,
+			},
+		},
+		{
+			description: "Field name of list with ellipsis duplicated",
+			input:       `S1F1 W H->E Msg <L <A VList> <L <U1 V> ...>> .`,
+			expected:    []string{"type Msg struct {\n\tVList  string\n\tVList2 []uint8\n}"},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		messages, errs, _ := sml.Parse(test.input)
+		assert.Empty(t, errs)
+
+		src, err := Generate("test", messages)
+		assert.NoError(t, err)
+		for _, expected := range test.expected {
+			assert.Contains(t, string(src), expected)
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	var tests = []struct {
+		description   string // Test case description
+		input         string // Input SML
+		pkgName       string // Input package name
+		expectedError string // expected error text
+	}{
+		{
+			description:   "Invalid package name",
+			input:         "S1F1 W H->E .",
+			pkgName:       "my-package",
+			expectedError: `invalid package name "my-package"`,
+		},
+		{
+			description:   "Invalid message name",
+			input:         "S1F1 W H->E メッセージ .",
+			pkgName:       "test",
+			expectedError: `S1F1 W H->E メッセージ: invalid type name "メッセージ"`,
+		},
+		{
+			description:   "Duplicated message name",
+			input:         "S1F1 W H->E Msg . S1F3 W H->E msg .",
+			pkgName:       "test",
+			expectedError: `S1F3 W H->E msg: duplicated type name "Msg"`,
+		},
+		{
+			description:   "Duplicated field name",
+			input:         "S1F1 W H->E Msg <L <A var_name> <A VarName>> .",
+			pkgName:       "test",
+			expectedError: `S1F1 W H->E Msg: duplicated field name "VarName"`,
+		},
+		{
+			description:   "Duplicated type name of repeated items",
+			input:         "S1F1 W H->E Msg <L <L <A V> ...>> . S1F3 W H->E MsgV .",
+			pkgName:       "test",
+			expectedError: `S1F3 W H->E MsgV: duplicated type name "MsgV"`,
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		messages, errs, _ := sml.Parse(test.input)
+		assert.Empty(t, errs)
+
+		src, err := Generate(test.pkgName, messages)
+		assert.EqualError(t, err, test.expectedError)
+		assert.Nil(t, src)
+	}
+}
+
+func TestList(t *testing.T) {
+	l := List{
+		Item:    ast.NewListNode(ast.NewASCIINodeVariable("name", 0, -1), ast.NewUintNode(1, "value")),
+		Tail:    ast.NewListNode(ast.NewBooleanNode("flag")),
+		MinSize: 1,
+		MaxSize: 5,
+	}
+	elems := []map[string]interface{}{
+		{"name": "a", "value": uint64(1)},
+		{"name": "b", "value": uint64(2)},
+	}
+
+	list, err := l.Build(map[string]interface{}{"flag": true}, len(elems), func(i int) (map[string]interface{}, error) {
+		return elems[i], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `<L[5] <A "a"> <U1[1] 1> <A "b"> <U1[1] 2> <BOOLEAN[1] T> >`,
+		strings.Join(strings.Fields(list.(*ast.ListNode).String()), " "))
+
+	values := map[string]interface{}{}
+	result, err := l.Match(list, values)
+	assert.NoError(t, err)
+	assert.Equal(t, elems, result)
+	assert.Equal(t, map[string]interface{}{"flag": true}, values)
+
+	list, err = l.Build(map[string]interface{}{"flag": false}, 0, nil)
+	assert.NoError(t, err)
+	result, err = l.Match(list, values)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{}, result)
+	assert.Equal(t, map[string]interface{}{"flag": false}, values)
+
+	_, err = l.Build(map[string]interface{}{"flag": false}, 3, func(i int) (map[string]interface{}, error) {
+		return elems[0], nil
+	})
+	assert.EqualError(t, err, "list size 7 out of range [1..5]")
+
+	_, err = l.Build(map[string]interface{}{"flag": false}, 1, func(i int) (map[string]interface{}, error) {
+		return map[string]interface{}{"name": "a", "value": 256}, nil
+	})
+	assert.EqualError(t, err, `variable "value": value 256 out of range for U1`)
+
+	_, err = l.Match(ast.NewListNode(ast.NewASCIINode("a"), ast.NewBooleanNode(true)), values)
+	assert.EqualError(t, err, "unexpected list size 2")
+
+	_, err = l.Match(ast.NewListNode(ast.NewASCIINode("a"), ast.NewUintNode(2, 1), ast.NewBooleanNode(true)), values)
+	assert.EqualError(t, err, "expected U1 item, found U2 item")
+
+	_, err = l.Match(ast.NewListNode(), values)
+	assert.EqualError(t, err, "list size 0 out of range [1..5]")
+
+	_, err = l.Match(nil, values)
+	assert.EqualError(t, err, "expected L item, found <nil>")
+}
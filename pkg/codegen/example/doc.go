@@ -0,0 +1,5 @@
+// Package example is an example of the code generated by smlgen,
+// from the SML message dictionary messages.sml.
+package example
+
+//go:generate go run ../../../cmd/smlgen -o messages_gen.go messages.sml
@@ -0,0 +1,273 @@
+package example
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests the generated code.
+//
+// Testing Strategy:
+//
+// Create messages with the generated constructors, and test their string representation.
+// Decode the messages with the generated decoders, and test that the results are equal to
+// the input structs. Decode messages that don't match, and test the error texts.
+//
+// Partitions:
+//
+// - Message: without data item, without variables, with variables of each data item type
+// - List with ellipsis: as the data item, nested, 0, 1, ... repetitions,
+//                       with one variable, with multiple variables, with ItemNode variable
+// - Decoded message: matched, different stream function code, data item type, value, list size
+// - Array variable: 1, >1 values
+// - Variable with default value: field set, field nil
+// - Constraint: value not allowed, list size out of range, array size out of range,
+//               in the constructor and the decoder
+
+func TestGenerated_RoundTrip(t *testing.T) {
+	var tests = []struct {
+		description    string                                      // Test case description
+		input          interface{}                                 // Input struct
+		create         func(interface{}) (*ast.DataMessage, error) // Constructor
+		decode         func(*ast.DataMessage) (interface{}, error) // Decoder
+		expectedString string                                      // expected string representation
+	}{
+		{
+			description: "Message without data item",
+			input:       AreYouThere{},
+			create:      func(v interface{}) (*ast.DataMessage, error) { return NewAreYouThere(v.(AreYouThere)) },
+			decode: func(msg *ast.DataMessage) (interface{}, error) {
+				return DecodeAreYouThere(msg)
+			},
+			expectedString: "S1F1 W H->E AreYouThere\n.",
+		},
+		{
+			description: "Variables of each data item type",
+			input:       AlarmReport{ALCD: 0x80, ALID: 1, ALTX: "alarm", ALED: true, VALUE: 0.5, TEMP: -10},
+			create:      func(v interface{}) (*ast.DataMessage, error) { return NewAlarmReport(v.(AlarmReport)) },
+			decode: func(msg *ast.DataMessage) (interface{}, error) {
+				return DecodeAlarmReport(msg)
+			},
+			expectedString: `S5F1 W H<-E AlarmReport
+<L[6]
+  <B[1] 0b10000000>
+  <U4[1] 1>
+  <A "alarm">
+  <BOOLEAN[1] T>
+  <F8[1] 0.5>
+  <I2[1] -10>
+>
+.`,
+		},
+		{
+			description: "Array variable",
+			input:       TraceInitialize{TRID: "T1", DSPER: stringPtr("000100"), TOTSMP: 100, SVIDS: []uint32{1, 2, 3}},
+			create:      func(v interface{}) (*ast.DataMessage, error) { return NewTraceInitialize(v.(TraceInitialize)) },
+			decode: func(msg *ast.DataMessage) (interface{}, error) {
+				return DecodeTraceInitialize(msg)
+			},
+			expectedString: `S2F23 W H->E TraceInitialize
+<L[4]
+  <A "T1">
+  <A "000100">
+  <U4[1] 100>
+  <U4[3] 1 2 3>
+>
+.`,
+		},
+		{
+			description: "List with ellipsis as the data item, 0 repetitions",
+			input:       SelectedEquipmentStatusRequest{SVIDList: []uint32{}},
+			create: func(v interface{}) (*ast.DataMessage, error) {
+				return NewSelectedEquipmentStatusRequest(v.(SelectedEquipmentStatusRequest))
+			},
+			decode: func(msg *ast.DataMessage) (interface{}, error) {
+				return DecodeSelectedEquipmentStatusRequest(msg)
+			},
+			expectedString: "S1F3 W H->E SelectedEquipmentStatusRequest\n<L[0]>\n.",
+		},
+		{
+			description: "List with ellipsis as the data item, 2 repetitions",
+			input:       SelectedEquipmentStatusRequest{SVIDList: []uint32{1, 2}},
+			create: func(v interface{}) (*ast.DataMessage, error) {
+				return NewSelectedEquipmentStatusRequest(v.(SelectedEquipmentStatusRequest))
+			},
+			decode: func(msg *ast.DataMessage) (interface{}, error) {
+				return DecodeSelectedEquipmentStatusRequest(msg)
+			},
+			expectedString: "S1F3 W H->E SelectedEquipmentStatusRequest\n<L[2]\n  <U4[1] 1>\n  <U4[1] 2>\n>\n.",
+		},
+		{
+			description: "List with ellipsis, multiple variables",
+			input: HostCommandAck{
+				HCACK:      3,
+				CPNAMEList: []HostCommandAckCPNAME{{"LOTID", 1}, {"PPID", 2}},
+			},
+			create: func(v interface{}) (*ast.DataMessage, error) { return NewHostCommandAck(v.(HostCommandAck)) },
+			decode: func(msg *ast.DataMessage) (interface{}, error) {
+				return DecodeHostCommandAck(msg)
+			},
+			expectedString: `S2F42 H<-E HostCommandAck
+<L[2]
+  <B[1] 0b11>
+  <L[2]
+    <L[2]
+      <A "LOTID">
+      <B[1] 0b1>
+    >
+    <L[2]
+      <A "PPID">
+      <B[1] 0b10>
+    >
+  >
+>
+.`,
+		},
+		{
+			description: "Nested lists with ellipsis, ItemNode variables",
+			input: EventReport{
+				DATAID: 1,
+				CEID:   100,
+				RPTIDList: []EventReportRPTID{
+					{RPTID: 10, VList: []ast.ItemNode{ast.NewASCIINode("text"), ast.NewUintNode(1, 1)}},
+					{RPTID: 20, VList: []ast.ItemNode{}},
+				},
+			},
+			create: func(v interface{}) (*ast.DataMessage, error) { return NewEventReport(v.(EventReport)) },
+			decode: func(msg *ast.DataMessage) (interface{}, error) {
+				return DecodeEventReport(msg)
+			},
+			expectedString: `S6F11 W H<-E EventReport
+<L[3]
+  <U4[1] 1>
+  <U4[1] 100>
+  <L[2]
+    <L[2]
+      <U4[1] 10>
+      <L[2]
+        <A "text">
+        <U1[1] 1>
+      >
+    >
+    <L[2]
+      <U4[1] 20>
+      <L[0]>
+    >
+  >
+>
+.`,
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		msg, err := test.create(test.input)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedString, fmt.Sprint(msg))
+
+		v, err := test.decode(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, test.input, v)
+	}
+}
+
+func TestGenerated_DecodeErrors(t *testing.T) {
+	var tests = []struct {
+		description   string           // Test case description
+		input         *ast.DataMessage // Input message
+		expectedError string           // expected error text
+	}{
+		{
+			description:   "Different stream function code",
+			input:         ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()),
+			expectedError: "expected S6F12, found S1F1",
+		},
+		{
+			description:   "Missing data item",
+			input:         ast.NewDataMessage("", 6, 12, 0, "H->E", ast.NewEmptyItemNode()),
+			expectedError: "missing data item",
+		},
+		{
+			description:   "Different data item type",
+			input:         ast.NewDataMessage("", 6, 12, 0, "H->E", ast.NewUintNode(1, 0)),
+			expectedError: "expected B item, found U1 item",
+		},
+		{
+			description:   "Different data item size",
+			input:         ast.NewDataMessage("", 6, 12, 0, "H->E", ast.NewBinaryNode(0, 1)),
+			expectedError: "expected B[1] item, found B[2] item",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		_, err := DecodeEventReportAck(test.input)
+		assert.EqualError(t, err, test.expectedError)
+	}
+
+	msg := ast.NewDataMessage("", 2, 41, 1, "H->E", ast.NewListNode(
+		ast.NewASCIINode("START"),
+		ast.NewListNode(ast.NewListNode(ast.NewASCIINode("PPID"), ast.NewASCIINode("too long value for CPVAL"))),
+	))
+	_, err := DecodeHostCommand(msg)
+	assert.EqualError(t, err, `string length out of range: "too long value for CPVAL"`)
+
+	msg = ast.NewDataMessage("", 1, 4, 0, "H<-E", ast.NewASCIINode("SV"))
+	_, err = DecodeSelectedEquipmentStatusData(msg)
+	assert.EqualError(t, err, `expected L item, found <A "SV">`)
+}
+
+func TestGenerated_ConstraintErrors(t *testing.T) {
+	_, err := NewAlarmReport(AlarmReport{TEMP: 126})
+	assert.EqualError(t, err, `variable "TEMP": value 126 not allowed, expected {-40..125}`)
+
+	msg := ast.NewDataMessage("", 5, 1, 1, "H<-E", ast.NewListNode(
+		ast.NewBinaryNode(0), ast.NewUintNode(4, 1), ast.NewASCIINode(""),
+		ast.NewBooleanNode(false), ast.NewFloatNode(8, 0), ast.NewIntNode(2, -41),
+	))
+	_, err = DecodeAlarmReport(msg)
+	assert.EqualError(t, err, `variable "TEMP": value -41 not allowed, expected {-40..125}`)
+
+	cp := HostCommandCPNAME{CPNAME: "PPID", CPVAL: "RECIPE"}
+	_, err = NewHostCommand(HostCommand{RCMD: "START", CPNAMEList: []HostCommandCPNAME{cp, cp, cp}})
+	assert.EqualError(t, err, "list size 3 out of range [0..2]")
+
+	cpNode := ast.NewListNode(ast.NewASCIINode("PPID"), ast.NewASCIINode("RECIPE"))
+	msg = ast.NewDataMessage("", 2, 41, 1, "H->E", ast.NewListNode(
+		ast.NewASCIINode("START"), ast.NewListNode(cpNode, cpNode, cpNode),
+	))
+	_, err = DecodeHostCommand(msg)
+	assert.EqualError(t, err, "list size 3 out of range [0..2]")
+
+	_, err = NewTraceInitialize(TraceInitialize{TRID: "T1", SVIDS: []uint32{}})
+	assert.EqualError(t, err, `variable "SVIDS": array size 0 out of range [1..]`)
+
+	msg = ast.NewDataMessage("", 2, 23, 1, "H->E", ast.NewListNode(
+		ast.NewASCIINode("T1"), ast.NewASCIINode("000010"), ast.NewUintNode(4, 1), ast.NewUintNode(4),
+	))
+	_, err = DecodeTraceInitialize(msg)
+	assert.EqualError(t, err, `variable "SVIDS": array size 0 out of range [1..]`)
+}
+
+func TestGenerated_Default(t *testing.T) {
+	msg, err := NewTraceInitialize(TraceInitialize{TRID: "T1", TOTSMP: 100, SVIDS: []uint32{1}})
+	assert.NoError(t, err)
+	assert.Equal(t, `S2F23 W H->E TraceInitialize
+<L[4]
+  <A "T1">
+  <A "000010">
+  <U4[1] 100>
+  <U4[1] 1>
+>
+.`, fmt.Sprint(msg))
+
+	v, err := DecodeTraceInitialize(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, stringPtr("000010"), v.DSPER)
+}
+
+// stringPtr returns a pointer to the string s.
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,100 @@
+package codegen
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// List is the template of a list with ellipsis, which consists of repetitions of the items
+// in the Item template, followed by the items in the Tail template.
+//
+// The Item and Tail templates should be ListNodes. MinSize and MaxSize are the size range
+// of the list, where -1 means no limit. It is used by the generated code to build and
+// decode a list with ellipsis.
+type List struct {
+	Item, Tail       ast.ItemNode
+	MinSize, MaxSize int
+}
+
+// Build returns a list, which consists of n repetitions of the items in the Item template,
+// followed by the items in the Tail template.
+//
+// The i-th repetition is filled with the values returned by elem(i), and the tail is filled with values.
+// An error is returned when the values cannot be filled in, or the size of the list is out of the size range.
+func (l List) Build(values map[string]interface{}, n int, elem func(i int) (map[string]interface{}, error)) (ast.ItemNode, error) {
+	items := []interface{}{}
+	for i := 0; i < n; i++ {
+		v, err := elem(i)
+		if err != nil {
+			return nil, err
+		}
+		item, err := l.Item.FillVariables(v)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item.(*ast.ListNode).Values()...)
+	}
+	tail, err := l.Tail.FillVariables(values)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, tail.(*ast.ListNode).Values()...)
+
+	if err := l.checkSize(len(items)); err != nil {
+		return nil, err
+	}
+	return ast.NewListNode(items...), nil
+}
+
+// Match matches the list node against the repetitions of the items in the Item template,
+// followed by the items in the Tail template, and returns the values of the variables
+// in each repetition. The values of the variables in the tail template are added to values.
+//
+// An error is returned when the list node doesn't match, or its size is out of the size range.
+func (l List) Match(list interface{}, values map[string]interface{}) ([]map[string]interface{}, error) {
+	node, ok := list.(*ast.ListNode)
+	if !ok {
+		return nil, fmt.Errorf("expected L item, found %v", list)
+	}
+
+	items := node.Values()
+	if err := l.checkSize(len(items)); err != nil {
+		return nil, err
+	}
+	n := len(items) - l.Tail.Size()
+	if n < 0 || n%l.Item.Size() != 0 {
+		return nil, fmt.Errorf("unexpected list size %d", len(items))
+	}
+
+	result := []map[string]interface{}{}
+	for i := 0; i < n; i += l.Item.Size() {
+		v, err := ast.Match(l.Item, ast.NewListNode(items[i:i+l.Item.Size()]...))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+
+	v, err := ast.Match(l.Tail, ast.NewListNode(items[n:]...))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range v {
+		values[name] = value
+	}
+	return result, nil
+}
+
+// checkSize returns an error if the size is out of the size range.
+func (l List) checkSize(size int) error {
+	if size < l.MinSize || (l.MaxSize != -1 && l.MaxSize < size) {
+		max := ""
+		if l.MaxSize != -1 {
+			max = strconv.Itoa(l.MaxSize)
+		}
+		return fmt.Errorf("list size %d out of range [%d..%s]", size, l.MinSize, max)
+	}
+	return nil
+}
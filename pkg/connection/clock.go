@@ -0,0 +1,55 @@
+package connection
+
+import "time"
+
+// Clock is the source of the current time and the timers of a connection, e.g. for T3 timeout.
+// A fake clock can be used to control the time in tests; refer to the connectiontest package.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a new timer that sends the current time on its channel after the duration.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock, as time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer expires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from expiring. It returns false if the timer already expired or stopped.
+	Stop() bool
+}
+
+// NewSystemClock returns the Clock of the system time, which is the default clock of the connections.
+func NewSystemClock() Clock {
+	return realClock{}
+}
+
+// realClock is the Clock of the system time.
+type realClock struct{}
+
+// Now implements Clock.Now().
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer implements Clock.NewTimer().
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer is the Timer of the system time.
+type realTimer struct {
+	timer *time.Timer
+}
+
+// C implements Timer.C().
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+// Stop implements Timer.Stop().
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
@@ -0,0 +1,33 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the system clock
+//
+// Testing Strategy:
+//
+// Create timers with the system clock, and test their expiration.
+//
+// Partitions:
+//
+// - Timer: expired, stopped before the expiration
+
+func TestSystemClock(t *testing.T) {
+	c := NewSystemClock()
+	before := time.Now()
+	now := c.Now()
+	assert.False(t, now.Before(before))
+
+	timer := c.NewTimer(10 * time.Millisecond)
+	expired := <-timer.C()
+	assert.True(t, expired.Sub(now) >= 10*time.Millisecond)
+	assert.False(t, timer.Stop())
+
+	timer = c.NewTimer(time.Hour)
+	assert.True(t, timer.Stop())
+}
@@ -0,0 +1,681 @@
+// Package connection implements HSMS connections, which send and receive SECS-II messages
+// over TCP/IP as specified in SEMI E37, in the single session mode (HSMS-SS), or in the general
+// session mode (HSMS-GS) with the session entities registered by WithSession.
+//
+// A connection is established in the active mode with Dial or Active, which sends select.req,
+// or in the passive mode with Listen or Passive, which waits for select.req from the remote entity.
+// Data messages can be sent after the connection is selected, and a primary message with
+// the wait bit waits for its secondary message until T3 timeout.
+// Received primary messages are passed to the handler of the connection, e.g. a Router.
+// Sent and received messages can be processed by middlewares, e.g. for logging.
+//
+// Control transactions are handled by the connection; select.req, deselect.req and linktest.req
+// are responded, separate.req closes the connection, and data messages received in the
+// not selected state are rejected.
+package connection
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/hsms"
+)
+
+// Errors of the HSMS connection.
+var (
+	ErrClosed         = errors.New("connection closed")
+	ErrNotSelected    = errors.New("connection not selected")
+	ErrSeparated      = errors.New("separate.req received")
+	ErrAborted        = errors.New("transaction aborted")
+	ErrMessageTooLong = errors.New("message too long")
+	ErrT3Timeout      = errors.New("T3 timeout")
+	ErrT6Timeout      = errors.New("T6 timeout")
+	ErrT7Timeout      = errors.New("T7 timeout")
+)
+
+// controlSessionID is the session id of the control messages in HSMS-SS.
+const controlSessionID = 0xFFFF
+
+// Conn is a HSMS connection, which is safe for concurrent use by multiple goroutines.
+type Conn struct {
+	conn    net.Conn // underlying network connection
+	opts    *options // configuration of the connection
+	counter uint32   // last system bytes used, accessed atomically
+
+	sendPath    MessageFunc // send path wrapped by the middlewares
+	receivePath MessageFunc // receive path wrapped by the middlewares
+
+	writeMu sync.Mutex // guards writes to conn
+
+	handlers chan struct{} // semaphore of the goroutines handling the received primary messages; nil for no limit
+
+	sessions map[int]*Session // session entities of HSMS-GS by their session id; nil for HSMS-SS
+
+	mu            sync.Mutex                      // guards the fields below, and the state of the sessions
+	selected      bool                            // true if the connection (any session entity in HSMS-GS) is selected
+	closed        bool                            // true if the connection is closed
+	err           error                           // cause of the close; nil if not closed
+	transactions  map[uint32]chan ast.HSMSMessage // open transactions by their system bytes
+	linktestStats LinktestStats                   // statistics of the linktests
+	state         State                           // current state of the connection
+	lastActivity  time.Time                       // time of the last message sent or received
+	events        *eventQueue                     // state events to deliver to the subscribers
+	totalRTT      time.Duration                   // total round-trip time of the succeeded linktests
+	selectedCh    chan struct{}                   // closed when the connection is selected at the first time
+	done          chan struct{}                   // closed when the connection is closed
+}
+
+// Dial connects to the address in the active mode, and selects the connection.
+// Refer to Active for the details.
+func Dial(address string, opts ...Option) (*Conn, error) {
+	o := newOptions(opts)
+	for _, fn := range o.stateHandlers {
+		fn(StateEvent{StateConnecting, o.clock.Now(), nil})
+	}
+	c, err := net.Dial("tcp", address)
+	if err != nil {
+		for _, fn := range o.stateHandlers {
+			fn(StateEvent{StateClosed, o.clock.Now(), err})
+		}
+		return nil, err
+	}
+	return Active(c, opts...)
+}
+
+// Active creates a HSMS connection in the active mode on the network connection,
+// and sends select.req. It returns when select.rsp is received, or T6 timeout occurs.
+// In HSMS-GS, Active returns without sending select.req, and each session entity is selected
+// with Session.Select.
+//
+// The network connection is closed if the connection cannot be selected.
+func Active(c net.Conn, opts ...Option) (*Conn, error) {
+	conn := newConn(c, newOptions(opts))
+	if conn.sessions != nil {
+		return conn, nil
+	}
+
+	if err := conn.controlTransaction(ast.NewHSMSMessageSelectReq, controlSessionID); err != nil {
+		conn.closeWith(err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Passive creates a HSMS connection in the passive mode on the network connection,
+// and waits for select.req. It returns when select.req is received, or T7 timeout occurs.
+// In HSMS-GS, it returns when any session entity is selected.
+//
+// The network connection is closed if the connection is not selected.
+func Passive(c net.Conn, opts ...Option) (*Conn, error) {
+	conn := newConn(c, newOptions(opts))
+
+	timer := conn.opts.clock.NewTimer(conn.opts.t7)
+	defer timer.Stop()
+	select {
+	case <-conn.selectedCh:
+		return conn, nil
+	case <-conn.done:
+		return nil, conn.Err()
+	case <-timer.C():
+		conn.closeWith(ErrT7Timeout)
+		return nil, ErrT7Timeout
+	}
+}
+
+// newConn creates a HSMS connection, and starts receiving messages.
+func newConn(c net.Conn, opts *options) *Conn {
+	conn := &Conn{
+		conn:         c,
+		opts:         opts,
+		transactions: map[uint32]chan ast.HSMSMessage{},
+		state:        StateConnected,
+		lastActivity: opts.clock.Now(),
+		selectedCh:   make(chan struct{}),
+		done:         make(chan struct{}),
+		events:       newEventQueue(opts.stateHandlers),
+	}
+	conn.events.push(StateEvent{StateConnected, conn.lastActivity, nil})
+	for id, h := range opts.sessions {
+		if conn.sessions == nil {
+			conn.sessions = map[int]*Session{}
+		}
+		conn.sessions[id] = &Session{conn: conn, id: id, handler: h}
+	}
+	if opts.maxHandlers > 0 {
+		conn.handlers = make(chan struct{}, opts.maxHandlers)
+	}
+	conn.sendPath, conn.receivePath = chain(opts.middlewares, conn.writeMessage, conn.receive)
+	go conn.readLoop()
+	if opts.linktestInterval > 0 {
+		go conn.linktestLoop()
+	}
+	return conn
+}
+
+// Public methods
+
+// Send sends the data message, with the session id of the connection and new system bytes.
+// The wait bit of the message should not be optional, and the message should not contain variables.
+// In HSMS-GS, the session entity with the session id of the connection should be selected;
+// use Session.Send to send the data message with the session id of another session entity.
+//
+// If the wait bit is set, Send waits for the secondary message until T3 timeout, and returns it.
+// If the secondary message is SxF0, it is returned with ErrAborted.
+// Otherwise, Send returns nil message after sending the message.
+func (c *Conn) Send(msg *ast.DataMessage) (*ast.DataMessage, error) {
+	return c.send(c.opts.sessionID, msg)
+}
+
+// send sends the data message with the session id. Refer to Send.
+func (c *Conn) send(sessionID int, msg *ast.DataMessage) (*ast.DataMessage, error) {
+	if msg.WaitBit() == "optional" {
+		return nil, fmt.Errorf("wait bit of the message is optional")
+	}
+	if len(msg.Variables()) != 0 {
+		return nil, fmt.Errorf("message contains variables")
+	}
+	systemBytes := c.nextSystemBytes()
+	msg = msg.SetSessionIDAndSystemBytes(sessionID, systemBytes)
+	if msg.WaitBit() == "false" {
+		return nil, c.write(msg)
+	}
+
+	rsp, err := c.transact(msg, systemBytes, c.opts.t3, ErrT3Timeout)
+	if err == ErrT3Timeout && c.opts.s9 {
+		c.send(sessionID, NewS9MessageFor(S9TransactionTimeout, msg))
+	}
+	if err != nil {
+		return nil, err
+	}
+	reply := rsp.(*ast.DataMessage)
+	if reply.FunctionCode() == 0 {
+		return reply, ErrAborted
+	}
+	return reply, nil
+}
+
+// Reply sends the secondary message of the primary message, with the session id and
+// the system bytes of the primary message. Optional wait bit of the secondary message is
+// set to false. The primary message should have the wait bit.
+func (c *Conn) Reply(primary, reply *ast.DataMessage) error {
+	if primary.WaitBit() != "true" {
+		return fmt.Errorf("primary message doesn't expect a reply")
+	}
+	if len(reply.Variables()) != 0 {
+		return fmt.Errorf("message contains variables")
+	}
+	return c.write(reply.SetWaitBit(false).SetSessionIDAndSystemBytes(primary.SessionID(), primary.SystemBytes()))
+}
+
+// Close sends separate.req if the connection is selected, and closes the connection.
+// Open transactions fail with ErrClosed.
+func (c *Conn) Close() error {
+	selected := c.isSelected()
+	if !c.setClosed(ErrClosed) {
+		return nil
+	}
+	if selected {
+		c.sendPath(ast.NewHSMSMessageSeparateReq(controlSessionID, c.nextSystemBytes()))
+	}
+	return c.conn.Close()
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the cause of the close, e.g. ErrClosed, ErrSeparated or a network error,
+// or nil if the connection is not closed.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Subscribe registers the function to be called with the state events of the connection, and returns
+// a function that unregisters it. The function is called in a separate goroutine in order of the events,
+// until StateClosed. Use WithStateHandler to receive the events from the creation of the connection.
+func (c *Conn) Subscribe(fn func(StateEvent)) (unsubscribe func()) {
+	return c.events.add(fn)
+}
+
+// Snapshot returns the current status of the connection.
+func (c *Conn) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Snapshot{c.state, c.conn.RemoteAddr(), len(c.transactions), c.lastActivity}
+}
+
+// RemoteAddr returns the network address of the remote entity.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Private methods
+
+// nextSystemBytes returns new system bytes for a message sent by the connection.
+func (c *Conn) nextSystemBytes() []byte {
+	result := make([]byte, 4)
+	binary.BigEndian.PutUint32(result, atomic.AddUint32(&c.counter, 1))
+	return result
+}
+
+// isSelected returns true if the connection is in the selected state.
+func (c *Conn) isSelected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.selected
+}
+
+// isSessionSelected returns true if the session entity with the session id is in the selected state.
+// In HSMS-SS, it returns true if the connection is in the selected state.
+func (c *Conn) isSessionSelected(sessionID int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sessions == nil {
+		return c.selected
+	}
+	s, ok := c.sessions[sessionID]
+	return ok && s.selected
+}
+
+// setSelected changes the selected state of the session entity with the session id,
+// or the connection in HSMS-SS.
+func (c *Conn) setSelected(sessionID int, selected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	if c.sessions != nil {
+		s, ok := c.sessions[sessionID]
+		if !ok {
+			return
+		}
+		s.selected = selected
+		selected = false
+		for _, s := range c.sessions {
+			selected = selected || s.selected
+		}
+	}
+	switch {
+	case selected && !c.selected:
+		select {
+		case <-c.selectedCh:
+		default:
+			close(c.selectedCh)
+		}
+		c.setState(StateSelected, nil)
+	case !selected && c.selected:
+		c.setState(StateDeselected, nil)
+	}
+	c.selected = selected
+}
+
+// setState changes the state of the connection, and pushes the state event with the cause.
+// c.mu should be held.
+func (c *Conn) setState(state State, err error) {
+	c.state = state
+	c.events.push(StateEvent{state, c.opts.clock.Now(), err})
+}
+
+// closeWith closes the connection with the cause err, if it is not closed.
+func (c *Conn) closeWith(err error) {
+	if c.setClosed(err) {
+		c.conn.Close()
+	}
+}
+
+// setClosed changes the state of the connection to closed with the cause err, without closing
+// the network connection. It returns false if the connection is already closed.
+func (c *Conn) setClosed(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.closed, c.selected, c.err = true, false, err
+	for _, s := range c.sessions {
+		s.selected = false
+	}
+	if err == ErrSeparated {
+		c.setState(StateSeparated, err)
+	}
+	c.setState(StateClosed, err)
+	close(c.done)
+	return true
+}
+
+// write sends the HSMS message through the send path.
+// Data messages can be sent only in the selected state of their session entity.
+func (c *Conn) write(msg ast.HSMSMessage) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	if data, ok := msg.(*ast.DataMessage); ok && !c.isSessionSelected(data.SessionID()) {
+		return ErrNotSelected
+	}
+	return c.sendPath(msg)
+}
+
+// writeMessage writes the HSMS message to the network connection, which is the end of the send path.
+func (c *Conn) writeMessage(msg ast.HSMSMessage) error {
+	b := msg.ToBytes()
+	if len(b) == 0 {
+		return fmt.Errorf("message cannot be converted to HSMS format")
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.conn.Write(b); err != nil {
+		c.closeWith(err)
+		return err
+	}
+	c.touch()
+	return nil
+}
+
+// touch records the current time as the time of the last activity.
+func (c *Conn) touch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastActivity = c.opts.clock.Now()
+}
+
+// transact writes the request message with the system bytes, and waits for its response
+// until the timeout, which fails with timeoutErr.
+// A reject.req of the request fails the transaction.
+func (c *Conn) transact(msg ast.HSMSMessage, systemBytes []byte, timeout time.Duration, timeoutErr error) (ast.HSMSMessage, error) {
+	key := binary.BigEndian.Uint32(systemBytes)
+	ch := make(chan ast.HSMSMessage, 1)
+	c.mu.Lock()
+	c.transactions[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.transactions, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(msg); err != nil {
+		return nil, err
+	}
+
+	timer := c.opts.clock.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case rsp := <-ch:
+		if rsp.Type() == "reject.req" {
+			return nil, fmt.Errorf("%s rejected with reason %d", msg.Type(), rsp.(*ast.ControlMessage).Status())
+		}
+		return rsp, nil
+	case <-c.done:
+		return nil, c.Err()
+	case <-timer.C():
+		return nil, timeoutErr
+	}
+}
+
+// deliver passes the response message to the open transaction with the system bytes,
+// and returns false if the transaction is not open.
+func (c *Conn) deliver(systemBytes []byte, msg ast.HSMSMessage) bool {
+	key := binary.BigEndian.Uint32(systemBytes)
+	c.mu.Lock()
+	ch, ok := c.transactions[key]
+	delete(c.transactions, key)
+	c.mu.Unlock()
+	if ok {
+		ch <- msg
+	}
+	return ok
+}
+
+// readLoop receives messages until the connection is closed.
+func (c *Conn) readLoop() {
+	for {
+		b, err := c.readMessage()
+		if err != nil {
+			c.closeWith(err)
+			return
+		}
+		c.touch()
+		if b != nil {
+			c.receiveBytes(b)
+		}
+	}
+}
+
+// readMessage reads a HSMS message from the network connection, including the message length bytes.
+// The message should be received in T8 intercharacter timeout, after its first byte.
+//
+// A message longer than the maximum message length is discarded without being read into memory,
+// and nil is returned after S9F11 is sent, if the stream 9 error messages are enabled;
+// otherwise, ErrMessageTooLong is returned.
+func (c *Conn) readMessage() ([]byte, error) {
+	lengthBytes := make([]byte, 4)
+	c.conn.SetReadDeadline(time.Time{})
+	if _, err := io.ReadFull(c.conn, lengthBytes[:1]); err != nil {
+		return nil, err
+	}
+	r := &t8Reader{c.conn, c.opts.t8}
+	if _, err := io.ReadFull(r, lengthBytes[1:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(lengthBytes)
+	if length < 10 {
+		return nil, fmt.Errorf("invalid message length %d", length)
+	}
+	if uint64(length) > uint64(c.opts.maxMessageLength) {
+		if !c.opts.s9 {
+			return nil, fmt.Errorf("%w: length %d exceeds %d", ErrMessageTooLong, length, c.opts.maxMessageLength)
+		}
+		header := make([]byte, 10)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(length)-10); err != nil {
+			return nil, err
+		}
+		c.Send(NewS9Message(S9DataTooLong, header))
+		return nil, nil
+	}
+	b := make([]byte, 4+int(length))
+	copy(b, lengthBytes)
+	if _, err := io.ReadFull(r, b[4:]); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// receiveBytes handles the received HSMS message in bytes. A message with unsupported
+// PType or SType is rejected, and a malformed data message is discarded, with S9F7 if enabled.
+func (c *Conn) receiveBytes(b []byte) {
+	msg, ok := hsms.Parse(b)
+	if ok {
+		if data, isData := msg.(*ast.DataMessage); isData && data.FunctionCode()%2 == 1 {
+			c.handle(data)
+		} else {
+			c.receivePath(msg)
+		}
+		return
+	}
+
+	header := b[4:14]
+	sessionID, pType, sType, systemBytes := binary.BigEndian.Uint16(header[:2]), header[4], header[5], header[6:10]
+	switch {
+	case pType != 0:
+		c.write(ast.NewHSMSMessageRejectReq(sessionID, pType, sType, systemBytes, 2))
+	case sType != 0:
+		c.write(ast.NewHSMSMessageRejectReq(sessionID, pType, sType, systemBytes, 1))
+	case c.opts.s9:
+		c.Send(NewS9Message(S9IllegalData, header))
+	}
+}
+
+// handle passes the received primary message to the receive path in a new goroutine.
+// If the maximum number of the goroutines is reached, the message is aborted instead,
+// so that the other messages are still received; refer to WithMaxHandlers.
+func (c *Conn) handle(msg *ast.DataMessage) {
+	if c.handlers == nil {
+		go c.receivePath(msg)
+		return
+	}
+	select {
+	case c.handlers <- struct{}{}:
+	default:
+		abort(&replyWriter{c, msg}, msg)
+		return
+	}
+	go func() {
+		defer func() { <-c.handlers }()
+		c.receivePath(msg)
+	}()
+}
+
+// receive handles the received HSMS message, which is the end of the receive path.
+func (c *Conn) receive(msg ast.HSMSMessage) error {
+	switch msg := msg.(type) {
+	case *ast.DataMessage:
+		c.receiveData(msg)
+	case *ast.ControlMessage:
+		c.receiveControl(msg)
+	}
+	return nil
+}
+
+// receiveData handles the received data message. Secondary messages are passed to the open
+// transactions, or discarded if the transaction is not open, and primary messages are passed to
+// the handler, or aborted if the handler is not set. Refer to WithS9Messages for the stream 9
+// error messages sent instead.
+//
+// In HSMS-GS, data messages are rejected if their session entity is not registered or not selected,
+// and primary messages are passed to the handler of their session entity.
+func (c *Conn) receiveData(msg *ast.DataMessage) {
+	if !c.isSessionSelected(msg.SessionID()) {
+		c.write(ast.NewHSMSMessageRejectReq(uint16(msg.SessionID()), 0, 0, msg.SystemBytes(), 4))
+		return
+	}
+	if c.opts.s9 && c.sessions == nil && msg.SessionID() != c.opts.sessionID {
+		c.Send(NewS9MessageFor(S9UnrecognizedDeviceID, msg))
+		return
+	}
+	if msg.FunctionCode()%2 == 0 {
+		c.deliver(msg.SystemBytes(), msg)
+		return
+	}
+
+	h := c.opts.handler
+	if c.sessions != nil {
+		h = c.sessions[msg.SessionID()].handler
+	}
+	switch router, ok := h.(*Router); {
+	case h == nil && c.opts.s9:
+		h = HandlerFunc(unrecognizedStream)
+	case h == nil:
+		h = HandlerFunc(abort)
+	case ok && c.opts.s9:
+		h, _ = router.handler(msg, true)
+	}
+	h.ServeSECS(&replyWriter{c, msg}, msg)
+}
+
+// receiveControl handles the received control message.
+func (c *Conn) receiveControl(msg *ast.ControlMessage) {
+	switch msg.Type() {
+	case "select.req":
+		var status byte
+		switch {
+		case c.sessions != nil && c.sessions[msg.SessionID()] == nil:
+			status = selectStatusEntityUnknown
+		case c.isSessionSelected(msg.SessionID()):
+			status = selectStatusAlreadyActive
+		default:
+			c.setSelected(msg.SessionID(), true)
+		}
+		c.write(ast.NewHSMSMessageSelectRsp(msg, status))
+	case "deselect.req":
+		var status byte
+		if c.sessions != nil && !c.isSessionSelected(msg.SessionID()) {
+			status = deselectStatusNotEstablished
+		} else {
+			c.setSelected(msg.SessionID(), false)
+		}
+		c.write(ast.NewHSMSMessageDeselectRsp(msg, status))
+	case "linktest.req":
+		c.write(ast.NewHSMSMessageLinktestRsp(msg))
+	case "select.rsp":
+		if msg.Status() == 0 && c.hasTransaction(msg.SystemBytes()) {
+			c.setSelected(msg.SessionID(), true)
+		}
+		c.deliverControl(msg)
+	case "deselect.rsp":
+		if msg.Status() == 0 && c.hasTransaction(msg.SystemBytes()) {
+			c.setSelected(msg.SessionID(), false)
+		}
+		c.deliverControl(msg)
+	case "linktest.rsp", "reject.req":
+		c.deliverControl(msg)
+	case "separate.req":
+		c.closeWith(ErrSeparated)
+	}
+}
+
+// deliverControl passes the control response to the open transaction,
+// or rejects the response if the transaction is not open.
+func (c *Conn) deliverControl(msg *ast.ControlMessage) {
+	if c.deliver(msg.SystemBytes(), msg) || msg.Type() == "reject.req" {
+		return
+	}
+	sType := msg.ToBytes()[9]
+	c.write(ast.NewHSMSMessageRejectReq(uint16(msg.SessionID()), 0, sType, msg.SystemBytes(), 3))
+}
+
+// hasTransaction returns true if the transaction with the system bytes is open.
+func (c *Conn) hasTransaction(systemBytes []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.transactions[binary.BigEndian.Uint32(systemBytes)]
+	return ok
+}
+
+// t8Reader is a reader of the network connection, which fails if no data is received
+// in T8 intercharacter timeout.
+type t8Reader struct {
+	conn net.Conn
+	t8   time.Duration
+}
+
+// Read implements io.Reader.
+func (r *t8Reader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.t8))
+	return r.conn.Read(p)
+}
+
+// replyWriter is a ReplyWriter of a received primary message.
+type replyWriter struct {
+	conn    *Conn
+	primary *ast.DataMessage
+}
+
+// Reply implements ReplyWriter.Reply().
+func (w *replyWriter) Reply(msg *ast.DataMessage) error {
+	return w.conn.Reply(w.primary, msg)
+}
+
+// Send implements ReplyWriter.Send(). In HSMS-GS, the message is sent with the session id
+// of the received primary message.
+func (w *replyWriter) Send(msg *ast.DataMessage) (*ast.DataMessage, error) {
+	if w.conn.sessions != nil {
+		return w.conn.send(w.primary.SessionID(), msg)
+	}
+	return w.conn.Send(msg)
+}
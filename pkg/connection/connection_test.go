@@ -0,0 +1,326 @@
+package connection
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/hsms"
+)
+
+// Tests the HSMS connection
+//
+// Testing Strategy:
+//
+// Create a pair of connections in the active and passive mode on a in-memory network connection,
+// and test the messages sent and received by them. Control transactions are tested with
+// a raw network connection as the remote entity, which writes and reads HSMS messages in bytes.
+//
+// Partitions:
+//
+// - Mode: active, passive
+// - State: not selected, selected, closed
+// - Sent message: with wait bit, without wait bit, optional wait bit, contains variables
+// - Reply: secondary message, SxF0, no reply (T3 timeout)
+// - Received control message: select.req, deselect.req, linktest.req, separate.req,
+//   unexpected response, undefined SType, undefined PType
+// - Received data message: in selected state, in not selected state
+// - Select: accepted, rejected, no response (T6 timeout), no select.req (T7 timeout)
+// - Close: by the connection, by the remote entity, with open transactions
+// - Message length: in the maximum, exceeds the maximum with/without S9 messages
+// - Concurrent handlers: under the limit, at the limit
+
+// pair returns a pair of selected connections in the active and the passive mode.
+func pair(t *testing.T, activeOpts, passiveOpts []Option) (active, passive *Conn) {
+	c1, c2 := net.Pipe()
+	ch := make(chan *Conn)
+	go func() {
+		conn, err := Passive(c2, passiveOpts...)
+		assert.NoError(t, err)
+		ch <- conn
+	}()
+	active, err := Active(c1, activeOpts...)
+	assert.NoError(t, err)
+	passive = <-ch
+	return active, passive
+}
+
+// readRaw reads a HSMS message in bytes from the network connection.
+func readRaw(t *testing.T, c net.Conn) []byte {
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	length := make([]byte, 4)
+	_, err := io.ReadFull(c, length)
+	assert.NoError(t, err)
+	b := make([]byte, 4+binary.BigEndian.Uint32(length))
+	copy(b, length)
+	_, err = io.ReadFull(c, b[4:])
+	assert.NoError(t, err)
+	return b
+}
+
+// control returns a control message in bytes with the session id, status, PType, SType and system bytes.
+func control(sessionID uint16, status, pType, sType byte, systemBytes uint32) []byte {
+	b := []byte{0, 0, 0, 10, byte(sessionID >> 8), byte(sessionID), 0, status, pType, sType, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[10:], systemBytes)
+	return b
+}
+
+// echo is a handler that replies the primary message with its data item.
+var echo = HandlerFunc(func(w ReplyWriter, msg *ast.DataMessage) {
+	item := msg.Item()
+	if item == nil {
+		item = ast.NewEmptyItemNode()
+	}
+	w.Reply(ast.NewDataMessage("", msg.StreamCode(), msg.FunctionCode()+1, 0, "H<->E", item))
+})
+
+func TestConn_SendReply(t *testing.T) {
+	received := make(chan *ast.DataMessage, 1)
+	router := NewRouter()
+	router.Handle("S1F1", echo)
+	router.HandleFunc("S6F11", func(w ReplyWriter, msg *ast.DataMessage) {
+		received <- msg
+	})
+	active, passive := pair(t, []Option{WithSessionID(7)}, []Option{WithHandler(router)})
+	defer active.Close()
+	defer passive.Close()
+
+	// primary message with wait bit
+	reply, err := active.Send(ast.NewDataMessage("AreYouThere", 1, 1, 1, "H->E", ast.NewASCIINode("hello")))
+	assert.NoError(t, err)
+	assert.Equal(t, "S1F2 H<->E", reply.Header())
+	assert.Equal(t, ast.NewASCIINode("hello"), reply.Item())
+	assert.Equal(t, 7, reply.SessionID())
+	assert.Equal(t, []byte{0, 0, 0, 2}, reply.SystemBytes())
+
+	// primary message without wait bit
+	reply, err = active.Send(ast.NewDataMessage("", 6, 11, 0, "H<-E", ast.NewUintNode(4, 1)))
+	assert.NoError(t, err)
+	assert.Nil(t, reply)
+	select {
+	case msg := <-received:
+		assert.Equal(t, "S6F11 H<->E", msg.Header())
+		assert.Equal(t, 7, msg.SessionID())
+	case <-time.After(time.Second):
+		assert.Fail(t, "message not received")
+	}
+
+	// unhandled message
+	reply, err = passive.Send(ast.NewDataMessage("", 2, 13, 1, "H<-E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrAborted, err)
+	assert.Equal(t, "S2F0 H<->E", reply.Header())
+}
+
+func TestConn_SendErrors(t *testing.T) {
+	router := NewRouter()
+	router.HandleFunc("S1F1", func(w ReplyWriter, msg *ast.DataMessage) {})
+	active, passive := pair(t, []Option{WithT3(50 * time.Millisecond)}, []Option{WithHandler(router)})
+	defer passive.Close()
+
+	_, err := active.Send(ast.NewDataMessage("", 1, 1, 2, "H->E", ast.NewEmptyItemNode()))
+	assert.EqualError(t, err, "wait bit of the message is optional")
+	_, err = active.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewASCIINodeVariable("A", 0, -1)))
+	assert.EqualError(t, err, "message contains variables")
+	err = active.Reply(ast.NewDataMessage("", 1, 1, 0, "H->E", ast.NewEmptyItemNode()), ast.NewDataMessage("", 1, 2, 0, "H<-E", ast.NewEmptyItemNode()))
+	assert.EqualError(t, err, "primary message doesn't expect a reply")
+
+	// T3 timeout
+	_, err = active.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrT3Timeout, err)
+
+	// open transaction fails when the connection is closed
+	errCh := make(chan error)
+	go func() {
+		_, err := active.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+		errCh <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, active.Close())
+	assert.Equal(t, ErrClosed, <-errCh)
+	assert.Equal(t, ErrClosed, active.Err())
+
+	// remote entity is separated
+	select {
+	case <-passive.Done():
+		assert.Equal(t, ErrSeparated, passive.Err())
+	case <-time.After(time.Second):
+		assert.Fail(t, "connection not closed")
+	}
+	_, err = passive.Send(ast.NewDataMessage("", 1, 1, 0, "H<-E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrSeparated, err)
+}
+
+func TestConn_Passive(t *testing.T) {
+	c1, c2 := net.Pipe()
+	ch := make(chan *Conn)
+	go func() {
+		conn, err := Passive(c2)
+		assert.NoError(t, err)
+		ch <- conn
+	}()
+
+	// data message in not selected state
+	data := ast.NewHSMSDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode(), 1, []byte{0, 0, 0, 1})
+	c1.Write(data.ToBytes())
+	assert.Equal(t, control(1, 4, 0, 7, 1), readRaw(t, c1))
+
+	// select.req
+	c1.Write(control(0xFFFF, 0, 0, 1, 2))
+	assert.Equal(t, control(0xFFFF, 0, 0, 2, 2), readRaw(t, c1))
+	conn := <-ch
+	c1.Write(control(0xFFFF, 0, 0, 1, 3))
+	assert.Equal(t, control(0xFFFF, 1, 0, 2, 3), readRaw(t, c1))
+
+	// linktest.req
+	c1.Write(control(0xFFFF, 0, 0, 5, 4))
+	assert.Equal(t, control(0xFFFF, 0, 0, 6, 4), readRaw(t, c1))
+
+	// unexpected response, undefined SType and PType
+	c1.Write(control(0xFFFF, 0, 0, 6, 5))
+	assert.Equal(t, []byte{0, 0, 0, 10, 0xFF, 0xFF, 6, 3, 0, 7, 0, 0, 0, 5}, readRaw(t, c1))
+	c1.Write(control(0xFFFF, 0, 0, 11, 6))
+	assert.Equal(t, []byte{0, 0, 0, 10, 0xFF, 0xFF, 11, 1, 0, 7, 0, 0, 0, 6}, readRaw(t, c1))
+	c1.Write(control(0xFFFF, 0, 1, 1, 7))
+	assert.Equal(t, []byte{0, 0, 0, 10, 0xFF, 0xFF, 1, 2, 0, 7, 0, 0, 0, 7}, readRaw(t, c1))
+
+	// deselect.req
+	c1.Write(control(0xFFFF, 0, 0, 3, 8))
+	assert.Equal(t, control(0xFFFF, 0, 0, 4, 8), readRaw(t, c1))
+	c1.Write(data.ToBytes())
+	assert.Equal(t, control(1, 4, 0, 7, 1), readRaw(t, c1))
+	_, err := conn.Send(ast.NewDataMessage("", 1, 1, 0, "H<-E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrNotSelected, err)
+
+	// separate.req
+	c1.Write(control(0xFFFF, 0, 0, 9, 9))
+	<-conn.Done()
+	assert.Equal(t, ErrSeparated, conn.Err())
+}
+
+func TestConn_Timeout(t *testing.T) {
+	// T7 timeout
+	c1, c2 := net.Pipe()
+	conn, err := Passive(c2, WithT7(50*time.Millisecond))
+	assert.Nil(t, conn)
+	assert.Equal(t, ErrT7Timeout, err)
+	_, err = c1.Write([]byte{0})
+	assert.Error(t, err)
+
+	// T6 timeout
+	c1, c2 = net.Pipe()
+	go readRaw(t, c1)
+	conn, err = Active(c2, WithT6(50*time.Millisecond))
+	assert.Nil(t, conn)
+	assert.Equal(t, ErrT6Timeout, err)
+
+	// T8 timeout
+	c1, c2 = net.Pipe()
+	go func() {
+		c1.Write(control(0xFFFF, 0, 0, 1, 1)[:6])
+	}()
+	conn = newConn(c2, newOptions([]Option{WithT8(50 * time.Millisecond)}))
+	<-conn.Done()
+	assert.Error(t, conn.Err())
+}
+
+func TestConn_Active(t *testing.T) {
+	// select.req rejected
+	c1, c2 := net.Pipe()
+	go func() {
+		c1.Write(control(0xFFFF, 1, 0, 2, binary.BigEndian.Uint32(readRaw(t, c1)[10:])))
+	}()
+	conn, err := Active(c2)
+	assert.Nil(t, conn)
+	assert.EqualError(t, err, "select.req rejected with status 1")
+
+	// select.req rejected by reject.req
+	c1, c2 = net.Pipe()
+	go func() {
+		systemBytes := binary.BigEndian.Uint32(readRaw(t, c1)[10:])
+		c1.Write([]byte{0, 0, 0, 10, 0xFF, 0xFF, 1, 4, 0, 7, byte(systemBytes >> 24), byte(systemBytes >> 16), byte(systemBytes >> 8), byte(systemBytes)})
+	}()
+	conn, err = Active(c2)
+	assert.Nil(t, conn)
+	assert.EqualError(t, err, "select.req rejected with reason 4")
+}
+
+func TestConn_MaxMessageLength(t *testing.T) {
+	// message length exceeds the default maximum
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	go c1.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0x81, 1, 0, 0, 0, 0, 0, 1})
+	conn := newConn(c2, newOptions(nil))
+	select {
+	case <-conn.Done():
+	case <-time.After(time.Second):
+		assert.Fail(t, "connection not closed")
+	}
+	assert.ErrorIs(t, conn.Err(), ErrMessageTooLong)
+	assert.EqualError(t, conn.Err(), "message too long: length 4294967295 exceeds 16777216")
+
+	// message discarded with S9F11, and the connection continues
+	c1, c2 = net.Pipe()
+	ch := make(chan *Conn)
+	go func() {
+		conn, err := Passive(c2, WithS9Messages(), WithMaxMessageLength(20))
+		assert.NoError(t, err)
+		ch <- conn
+	}()
+	c1.Write(control(0xFFFF, 0, 0, 1, 1))
+	readRaw(t, c1)
+	conn = <-ch
+	defer conn.closeWith(ErrClosed)
+
+	long := ast.NewHSMSDataMessage("", 1, 3, 1, "H->E", ast.NewASCIINode("0123456789"), 0, []byte{0, 0, 0, 2}).ToBytes()
+	assert.Len(t, long, 26)
+	c1.Write(long)
+	msg, ok := hsms.Parse(readRaw(t, c1))
+	assert.True(t, ok)
+	assert.Equal(t, "S9F11 H<->E", msg.(*ast.DataMessage).Header())
+	assert.Equal(t, long[4:14], msg.(*ast.DataMessage).Item().ToBytes()[2:])
+
+	c1.Write(control(0xFFFF, 0, 0, 5, 3))
+	assert.Equal(t, control(0xFFFF, 0, 0, 6, 3), readRaw(t, c1))
+}
+
+func TestConn_MaxHandlers(t *testing.T) {
+	results, release := make(chan error, 1), make(chan struct{})
+	defer close(release)
+	passiveHandler := HandlerFunc(func(w ReplyWriter, msg *ast.DataMessage) {
+		_, err := w.Send(ast.NewDataMessage("", 64, 11, 1, "H<-E", ast.NewEmptyItemNode()))
+		results <- err
+		<-release
+	})
+	activeHandler := HandlerFunc(func(w ReplyWriter, msg *ast.DataMessage) {
+		w.Reply(ast.NewDataMessage("", 64, 12, 0, "H->E", ast.NewEmptyItemNode()))
+	})
+	active, passive := pair(t,
+		[]Option{WithHandler(activeHandler)},
+		[]Option{WithHandler(passiveHandler), WithMaxHandlers(1), WithT3(time.Second), WithLinktest(20*time.Millisecond, 1)},
+	)
+	defer active.Close()
+	defer passive.Close()
+
+	// The handler holds the only slot, and its reply is received meanwhile
+	_, err := active.Send(ast.NewDataMessage("", 64, 1, 0, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	select {
+	case err := <-results:
+		assert.NoError(t, err)
+	case <-time.After(500 * time.Millisecond):
+		assert.Fail(t, "reply not received while the handlers are at the limit")
+	}
+
+	// Primary messages over the limit are aborted
+	_, err = active.Send(ast.NewDataMessage("", 64, 3, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrAborted, err)
+
+	// Linktests succeed while the handlers are at the limit
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, passive.Err())
+	assert.NotZero(t, passive.LinktestStats().Count)
+}
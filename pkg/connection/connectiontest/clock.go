@@ -0,0 +1,120 @@
+package connectiontest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+)
+
+// FakeClock is a connection.Clock whose time is advanced manually, so that the timers of connections,
+// e.g. T3 timeout, expire deterministically in tests. It is safe for concurrent use.
+//
+// Timers are created by the connections in their goroutines, so a test should wait for a timer
+// with BlockUntil before advancing the time to expire it, e.g.
+//
+//	go func() { _, err = host.Send(msg) }() // waits for the reply until T3 timeout
+//	clock.BlockUntil(1)
+//	clock.Advance(45 * time.Second)         // host.Send returns connection.ErrT3Timeout
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer  // active timers
+	changed chan struct{} // closed when a timer is created
+}
+
+// NewFakeClock creates a fake clock with the current time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// Now implements connection.Clock.Now().
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer implements connection.Clock.NewTimer(). The timer expires when the time is advanced
+// by the duration; a timer with a non-positive duration expires immediately.
+func (c *FakeClock) NewTimer(d time.Duration) connection.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return t
+}
+
+// Advance advances the time by the duration, and expires the timers whose deadline is reached,
+// in order of their deadlines.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	active := []*fakeTimer{}
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			active = append(active, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = active
+}
+
+// Timers returns the number of the active timers, which are neither expired nor stopped.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil waits until the number of the active timers is at least n.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		count, changed := len(c.timers), c.changed
+		c.mu.Unlock()
+		if count >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+// fakeTimer is a timer of the FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// C implements connection.Timer.C().
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop implements connection.Timer.Stop().
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package connectiontest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the fake clock
+//
+// Testing Strategy:
+//
+// Create timers with the fake clock, advance the time, and test the expired timers.
+//
+// Partitions:
+//
+// - Timer duration: non-positive, positive
+// - Advance: before the deadline, at the deadline, after the deadlines of several timers
+// - Stop: active timer, expired timer
+// - BlockUntil: timers already created, timer created later
+
+// expired returns the time sent by the timer channel, or the zero time if it is not expired.
+func expired(ch <-chan time.Time) time.Time {
+	select {
+	case now := <-ch:
+		return now
+	default:
+		return time.Time{}
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	assert.Equal(t, start, c.Now())
+
+	assert.Equal(t, start, expired(c.NewTimer(0).C()))
+	assert.Equal(t, 0, c.Timers())
+
+	t1 := c.NewTimer(time.Second)
+	t2 := c.NewTimer(2 * time.Second)
+	t3 := c.NewTimer(3 * time.Second)
+	assert.Equal(t, 3, c.Timers())
+
+	c.Advance(500 * time.Millisecond)
+	assert.True(t, expired(t1.C()).IsZero())
+	c.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), expired(t1.C()))
+	assert.Equal(t, 2, c.Timers())
+	assert.False(t, t1.Stop())
+
+	assert.True(t, t2.Stop())
+	c.Advance(5 * time.Second)
+	assert.True(t, expired(t2.C()).IsZero())
+	assert.Equal(t, start.Add(6*time.Second), expired(t3.C()))
+	assert.Equal(t, start.Add(6*time.Second), c.Now())
+	assert.Equal(t, 0, c.Timers())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.NewTimer(time.Second)
+	}()
+	c.BlockUntil(1)
+	assert.Equal(t, 1, c.Timers())
+}
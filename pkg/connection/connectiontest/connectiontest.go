@@ -0,0 +1,45 @@
+// Package connectiontest provides utilities for testing HSMS connections in a single process,
+// without TCP and the system clock, e.g. a equipment simulator and a host wired together.
+//
+// Pipe and Listener are in-memory network connections, which can be used by the connection
+// package; Connect creates a pair of selected HSMS connections on a Pipe. FakeClock is a clock
+// whose time is advanced manually, which can be set with connection.WithClock to control
+// the timers of the connections, e.g. T3 timeout.
+package connectiontest
+
+import "github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+
+// Connect creates a pair of HSMS connections on a in-memory network connection, the host in the
+// active mode and the equipment in the passive mode, and returns them after they are selected.
+// The remote addresses of the host and the equipment are "equipment" and "host".
+//
+// Connect is for HSMS-SS. In HSMS-GS, use Pipe with connection.Active and connection.Passive,
+// and select the session entities of the host, as the equipment waits for the selection.
+func Connect(hostOpts, equipmentOpts []connection.Option) (host, equipment *connection.Conn, err error) {
+	c1, c2 := Pipe("host", "equipment")
+	type result struct {
+		conn *connection.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		conn, err := connection.Passive(c2, equipmentOpts...)
+		ch <- result{conn, err}
+	}()
+
+	host, err = connection.Active(c1, hostOpts...)
+	r := <-ch
+	if err != nil || r.err != nil {
+		if host != nil {
+			host.Close()
+		}
+		if r.conn != nil {
+			r.conn.Close()
+		}
+		if err == nil {
+			err = r.err
+		}
+		return nil, nil, err
+	}
+	return host, r.conn, nil
+}
@@ -0,0 +1,116 @@
+package connectiontest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+)
+
+// Tests the HSMS connections on the in-memory network connections with the fake clock
+//
+// Testing Strategy:
+//
+// Connect a host and a equipment in the same process, and test the messages and the timeouts,
+// which are expired by advancing the fake clock.
+//
+// Partitions:
+//
+// - Connect: selected, not selected
+// - Reply: secondary message, no reply (T3 timeout)
+// - Passive connection: no select.req (T7 timeout)
+// - Supervisor: connected, reconnected after T5
+
+// echo is a handler that replies the primary message with its data item.
+var echo = connection.HandlerFunc(func(w connection.ReplyWriter, msg *ast.DataMessage) {
+	w.Reply(ast.NewDataMessage("", msg.StreamCode(), msg.FunctionCode()+1, 0, "H<->E", msg.Item()))
+})
+
+func TestConnect(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	router := connection.NewRouter()
+	router.Handle("S1F1", echo)
+	router.HandleFunc("S2F13", func(w connection.ReplyWriter, msg *ast.DataMessage) {})
+
+	host, equipment, err := Connect(
+		[]connection.Option{connection.WithClock(clock)},
+		[]connection.Option{connection.WithClock(clock), connection.WithHandler(router)},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer host.Close()
+	assert.Equal(t, "equipment", host.RemoteAddr().String())
+	assert.Equal(t, "host", equipment.RemoteAddr().String())
+
+	reply, err := host.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewASCIINode("hello")))
+	assert.NoError(t, err)
+	assert.Equal(t, ast.NewASCIINode("hello"), reply.Item())
+
+	ch := make(chan error)
+	go func() {
+		_, err := host.Send(ast.NewDataMessage("", 2, 13, 1, "H->E", ast.NewEmptyItemNode()))
+		ch <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(44 * time.Second)
+	select {
+	case err := <-ch:
+		t.Fatalf("unexpected reply before T3 timeout: %v", err)
+	default:
+	}
+	clock.Advance(time.Second)
+	assert.ErrorIs(t, <-ch, connection.ErrT3Timeout)
+	assert.Equal(t, clock.Now(), host.Snapshot().LastActivity.Add(45*time.Second))
+}
+
+func TestConnectNotSelected(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	c1, c2 := Pipe("host", "equipment")
+	defer c1.Close()
+
+	ch := make(chan error)
+	go func() {
+		_, err := connection.Passive(c2, connection.WithClock(clock))
+		ch <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	assert.ErrorIs(t, <-ch, connection.ErrT7Timeout)
+
+	// the host cannot be selected
+	_, _, err := Connect(nil, []connection.Option{connection.WithHandler(echo), connection.WithT7(0)})
+	assert.Error(t, err)
+}
+
+func TestSupervisor(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	l := NewListener("equipment")
+	equipments := connection.NewListener(l, connection.WithClock(clock), connection.WithHandler(echo))
+
+	s := connection.NewSupervisor("equipment", []connection.Option{connection.WithClock(clock)},
+		connection.WithDialer(l.Dial))
+	events := make(chan connection.StateEvent, 16)
+	s.Subscribe(func(e connection.StateEvent) { events <- e })
+	s.Start()
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		equipment, err := equipments.Accept()
+		assert.NoError(t, err)
+		assert.Equal(t, connection.StateConnecting, (<-events).State)
+		assert.Equal(t, connection.StateConnected, (<-events).State)
+		assert.Equal(t, connection.StateSelected, (<-events).State)
+
+		reply, err := s.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewASCIINode("hello")))
+		assert.NoError(t, err)
+		assert.Equal(t, ast.NewASCIINode("hello"), reply.Item())
+
+		equipment.Close()
+		assert.Equal(t, connection.StateDisconnected, (<-events).State)
+		clock.BlockUntil(1)
+		clock.Advance(10 * time.Second)
+	}
+}
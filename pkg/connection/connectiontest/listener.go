@@ -0,0 +1,70 @@
+package connectiontest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ErrRefused is returned by Listener.Dial if the listener is closed.
+var ErrRefused = errors.New("connection refused")
+
+// Listener is a in-memory net.Listener, which accepts the connections created by its Dial method.
+// It can be used with connection.NewListener in the equipment, and its Dial method with
+// connection.WithDialer in the host, to connect them in the same process.
+type Listener struct {
+	address string
+	conns   chan net.Conn // remote ends of the dialed connections
+	closed  chan struct{} // closed when the listener is closed
+	once    sync.Once     // closes the listener once
+
+	mu      sync.Mutex // guards clients
+	clients int        // number of the dialed connections
+}
+
+// NewListener creates a in-memory listener with the address.
+func NewListener(address string) *Listener {
+	return &Listener{address: address, conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// Dial connects to the listener, and returns the local end of the connection, whose remote end
+// is returned by Accept. The address is ignored, so that Dial can be used as the dialer of
+// connection.WithDialer. The local address of the n-th connection is "client-n".
+// ErrRefused is returned if the listener is closed.
+func (l *Listener) Dial(address string) (net.Conn, error) {
+	l.mu.Lock()
+	l.clients++
+	local, remote := Pipe(fmt.Sprintf("client-%d", l.clients), l.address)
+	l.mu.Unlock()
+
+	select {
+	case l.conns <- remote:
+		return local, nil
+	case <-l.closed:
+		return nil, ErrRefused
+	}
+}
+
+// Accept implements net.Listener.Accept(). It waits for the next connection dialed to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener.Close(). Connections already accepted are not closed.
+func (l *Listener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+// Addr implements net.Listener.Addr().
+func (l *Listener) Addr() net.Addr {
+	return addr(l.address)
+}
@@ -0,0 +1,52 @@
+package connectiontest
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the in-memory listener
+//
+// Testing Strategy:
+//
+// Dial the listener, and test the accepted connections and their addresses.
+//
+// Partitions:
+//
+// - Listener: open, closed
+// - Dial: first, second connection
+
+func TestListener(t *testing.T) {
+	l := NewListener("equipment")
+	assert.Equal(t, "equipment", l.Addr().String())
+
+	for _, local := range []string{"client-1", "client-2"} {
+		ch := make(chan net.Conn)
+		go func() {
+			c, err := l.Accept()
+			assert.NoError(t, err)
+			ch <- c
+		}()
+		c1, err := l.Dial("ignored")
+		assert.NoError(t, err)
+		c2 := <-ch
+		assert.Equal(t, local, c1.LocalAddr().String())
+		assert.Equal(t, "equipment", c1.RemoteAddr().String())
+		assert.Equal(t, local, c2.RemoteAddr().String())
+
+		c1.Write([]byte{1})
+		buf := make([]byte, 1)
+		_, err = c2.Read(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(1), buf[0])
+	}
+
+	assert.NoError(t, l.Close())
+	assert.NoError(t, l.Close())
+	_, err := l.Dial("ignored")
+	assert.ErrorIs(t, err, ErrRefused)
+	_, err = l.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
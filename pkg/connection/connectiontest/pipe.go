@@ -0,0 +1,167 @@
+package connectiontest
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Pipe creates a in-memory, full duplex network connection, with the two ends named by the addresses,
+// e.g. "host" and "equipment". Unlike net.Pipe, writes are buffered and never block, as in TCP,
+// so that both ends can write at the same time, e.g. responses of control messages.
+// Read deadlines are supported, and write deadlines are ignored.
+func Pipe(address1, address2 string) (net.Conn, net.Conn) {
+	b1, b2 := newBuffer(), newBuffer()
+	c1 := &pipeConn{in: b1, out: b2, local: addr(address1), remote: addr(address2), deadlineCh: make(chan struct{})}
+	c2 := &pipeConn{in: b2, out: b1, local: addr(address2), remote: addr(address1), deadlineCh: make(chan struct{})}
+	return c1, c2
+}
+
+// addr is the network address of a in-memory connection.
+type addr string
+
+// Network implements net.Addr.Network().
+func (a addr) Network() string {
+	return "memory"
+}
+
+// String implements net.Addr.String().
+func (a addr) String() string {
+	return string(a)
+}
+
+// buffer is the bytes written to a end of the pipe, and not read yet.
+type buffer struct {
+	mu     sync.Mutex
+	data   []byte
+	eof    bool          // true if the writer is closed
+	broken bool          // true if the reader is closed
+	notify chan struct{} // closed when the buffer is changed
+}
+
+// newBuffer creates a empty buffer.
+func newBuffer() *buffer {
+	return &buffer{notify: make(chan struct{})}
+}
+
+// changed wakes up the reader waiting for the buffer. b.mu must be held.
+func (b *buffer) changed() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// pipeConn is a end of the pipe.
+type pipeConn struct {
+	in, out       *buffer // buffers to read from and write to
+	local, remote addr
+
+	mu           sync.Mutex
+	readDeadline time.Time
+	deadlineCh   chan struct{} // closed when the read deadline is changed
+}
+
+// Read implements net.Conn.Read(). It returns io.EOF after the remote end is closed and
+// the buffered bytes are read, and os.ErrDeadlineExceeded after the read deadline.
+func (c *pipeConn) Read(p []byte) (int, error) {
+	for {
+		c.in.mu.Lock()
+		switch {
+		case c.in.broken:
+			c.in.mu.Unlock()
+			return 0, io.ErrClosedPipe
+		case len(c.in.data) > 0:
+			n := copy(p, c.in.data)
+			c.in.data = c.in.data[n:]
+			c.in.mu.Unlock()
+			return n, nil
+		case c.in.eof:
+			c.in.mu.Unlock()
+			return 0, io.EOF
+		}
+		notify := c.in.notify
+		c.in.mu.Unlock()
+
+		c.mu.Lock()
+		deadline, deadlineCh := c.readDeadline, c.deadlineCh
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		select {
+		case <-notify:
+		case <-deadlineCh:
+		case <-timeout:
+			return 0, os.ErrDeadlineExceeded
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Write implements net.Conn.Write(). The bytes are buffered until the remote end reads them.
+func (c *pipeConn) Write(p []byte) (int, error) {
+	c.out.mu.Lock()
+	defer c.out.mu.Unlock()
+	if c.out.eof || c.out.broken {
+		return 0, io.ErrClosedPipe
+	}
+	c.out.data = append(c.out.data, p...)
+	c.out.changed()
+	return len(p), nil
+}
+
+// Close implements net.Conn.Close(). The remote end reads the buffered bytes, and then io.EOF.
+func (c *pipeConn) Close() error {
+	c.out.mu.Lock()
+	c.out.eof = true
+	c.out.changed()
+	c.out.mu.Unlock()
+
+	c.in.mu.Lock()
+	c.in.broken = true
+	c.in.data = nil
+	c.in.changed()
+	c.in.mu.Unlock()
+	return nil
+}
+
+// LocalAddr implements net.Conn.LocalAddr().
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr implements net.Conn.RemoteAddr().
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline implements net.Conn.SetDeadline().
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline implements net.Conn.SetReadDeadline().
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	close(c.deadlineCh)
+	c.deadlineCh = make(chan struct{})
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.SetWriteDeadline(). Writes never block, so it does nothing.
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
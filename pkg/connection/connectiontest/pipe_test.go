@@ -0,0 +1,85 @@
+package connectiontest
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the in-memory network connection
+//
+// Testing Strategy:
+//
+// Write and read bytes on the ends of a pipe, and test the bytes, the errors and the addresses.
+//
+// Partitions:
+//
+// - Write: before read, concurrent with the write of the remote end, after close
+// - Read: buffered bytes, waits for bytes, read deadline exceeded, after close of the local end,
+//   after close of the remote end
+// - Addresses: local, remote
+
+func TestPipe(t *testing.T) {
+	c1, c2 := Pipe("host", "equipment")
+	assert.Equal(t, "host", c1.LocalAddr().String())
+	assert.Equal(t, "equipment", c1.RemoteAddr().String())
+	assert.Equal(t, "memory", c1.RemoteAddr().Network())
+	assert.Equal(t, "equipment", c2.LocalAddr().String())
+	assert.Equal(t, "host", c2.RemoteAddr().String())
+
+	// writes don't block, in both directions
+	n, err := c1.Write([]byte{1, 2, 3})
+	assert.Equal(t, 3, n)
+	assert.NoError(t, err)
+	_, err = c2.Write([]byte{4})
+	assert.NoError(t, err)
+
+	buf := make([]byte, 2)
+	n, err = c2.Read(buf)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, buf)
+	n, err = c2.Read(buf)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(3), buf[0])
+	n, err = c1.Read(buf)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(4), buf[0])
+
+	// read waits for bytes
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c1.Write([]byte{5})
+	}()
+	n, err = c2.Read(buf)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(5), buf[0])
+
+	// read deadline
+	c2.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err = c2.Read(buf)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	c2.SetReadDeadline(time.Time{})
+
+	// close
+	c1.Write([]byte{6})
+	assert.NoError(t, c1.Close())
+	_, err = c1.Write([]byte{7})
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	_, err = c1.Read(buf)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	n, err = c2.Read(buf)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(6), buf[0])
+	_, err = c2.Read(buf)
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = c2.Write([]byte{8})
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
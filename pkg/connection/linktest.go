@@ -0,0 +1,78 @@
+package connection
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// ErrLinktestFailed is the cause of the close of a connection, whose periodic linktests failed.
+var ErrLinktestFailed = errors.New("linktest failed")
+
+// LinktestStats is the statistics of the linktests of a connection, for health reporting.
+type LinktestStats struct {
+	Count    int           // number of succeeded linktests
+	Failures int           // number of consecutive failed linktests
+	LastTime time.Time     // time of the last succeeded linktest; zero if none succeeded
+	LastRTT  time.Duration // round-trip time of the last succeeded linktest
+	MinRTT   time.Duration // minimum round-trip time of the succeeded linktests
+	MaxRTT   time.Duration // maximum round-trip time of the succeeded linktests
+	AvgRTT   time.Duration // average round-trip time of the succeeded linktests
+}
+
+// Linktest sends linktest.req, and returns the round-trip time when linktest.rsp is received.
+// An error is returned if linktest.rsp is not received until T6 timeout.
+// The result is recorded in the linktest statistics.
+func (c *Conn) Linktest() (time.Duration, error) {
+	systemBytes := c.nextSystemBytes()
+	start := c.opts.clock.Now()
+	_, err := c.transact(ast.NewHSMSMessageLinktestReq(systemBytes), systemBytes, c.opts.t6, ErrT6Timeout)
+	now := c.opts.clock.Now()
+	rtt := now.Sub(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := &c.linktestStats
+	if err != nil {
+		stats.Failures++
+		return 0, err
+	}
+	stats.Count++
+	stats.Failures = 0
+	stats.LastTime, stats.LastRTT = now, rtt
+	if stats.Count == 1 || rtt < stats.MinRTT {
+		stats.MinRTT = rtt
+	}
+	if rtt > stats.MaxRTT {
+		stats.MaxRTT = rtt
+	}
+	c.totalRTT += rtt
+	stats.AvgRTT = c.totalRTT / time.Duration(stats.Count)
+	return rtt, nil
+}
+
+// LinktestStats returns the statistics of the linktests of the connection.
+func (c *Conn) LinktestStats() LinktestStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.linktestStats
+}
+
+// linktestLoop sends linktest.req periodically until the connection is closed, and closes the connection
+// with ErrLinktestFailed when the linktests failed consecutively the number of times in the options.
+func (c *Conn) linktestLoop() {
+	for {
+		timer := c.opts.clock.NewTimer(c.opts.linktestInterval)
+		select {
+		case <-timer.C():
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+		if _, err := c.Linktest(); err != nil && c.LinktestStats().Failures >= c.opts.linktestFailures {
+			c.closeWith(ErrLinktestFailed)
+			return
+		}
+	}
+}
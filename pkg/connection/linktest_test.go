@@ -0,0 +1,81 @@
+package connection
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the linktests
+//
+// Testing Strategy:
+//
+// Send linktests on a pair of connections, or to a raw network connection that doesn't respond,
+// and test the linktest statistics and the state of the connection.
+//
+// Partitions:
+//
+// - Linktest: sent by Linktest, sent periodically
+// - Response: received, not received (T6 timeout)
+// - Consecutive failures: less than the limit, the limit
+
+func TestLinktest(t *testing.T) {
+	active, passive := pair(t, []Option{WithLinktest(10*time.Millisecond, 2)}, nil)
+	defer active.Close()
+	defer passive.Close()
+
+	assert.Equal(t, LinktestStats{}, passive.LinktestStats())
+	rtt, err := passive.Linktest()
+	assert.NoError(t, err)
+	stats := passive.LinktestStats()
+	assert.Equal(t, 1, stats.Count)
+	assert.Equal(t, rtt, stats.LastRTT)
+	assert.Equal(t, rtt, stats.MinRTT)
+	assert.Equal(t, rtt, stats.MaxRTT)
+	assert.Equal(t, rtt, stats.AvgRTT)
+	assert.False(t, stats.LastTime.IsZero())
+
+	time.Sleep(100 * time.Millisecond)
+	stats = active.LinktestStats()
+	assert.GreaterOrEqual(t, stats.Count, 3)
+	assert.Equal(t, 0, stats.Failures)
+	assert.True(t, stats.MinRTT <= stats.AvgRTT && stats.AvgRTT <= stats.MaxRTT)
+	assert.True(t, stats.MinRTT <= stats.LastRTT && stats.LastRTT <= stats.MaxRTT)
+}
+
+func TestLinktest_Failures(t *testing.T) {
+	c1, c2 := net.Pipe()
+	ch := make(chan *Conn)
+	go func() {
+		conn, err := Passive(c2, WithT6(20*time.Millisecond), WithLinktest(10*time.Millisecond, 2))
+		assert.NoError(t, err)
+		ch <- conn
+	}()
+	c1.Write(control(0xFFFF, 0, 0, 1, 1))
+	readRaw(t, c1)
+	conn := <-ch
+
+	// reads linktest.req without responding
+	linktests := make(chan []byte, 10)
+	go func() {
+		for {
+			b := make([]byte, 14)
+			if _, err := c1.Read(b); err != nil {
+				return
+			}
+			linktests <- b
+		}
+	}()
+
+	select {
+	case <-conn.Done():
+		assert.Equal(t, ErrLinktestFailed, conn.Err())
+		assert.Equal(t, 2, conn.LinktestStats().Failures)
+		assert.Equal(t, 0, conn.LinktestStats().Count)
+		assert.Equal(t, []byte{0, 0, 0, 10, 0xFF, 0xFF, 0, 0, 0, 5}, (<-linktests)[:10])
+	case <-time.After(time.Second):
+		assert.Fail(t, "connection not closed")
+	}
+}
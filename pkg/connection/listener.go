@@ -0,0 +1,52 @@
+package connection
+
+import (
+	"net"
+)
+
+// Listener is a HSMS network listener, which accepts connections in the passive mode.
+type Listener struct {
+	listener net.Listener // underlying network listener
+	opts     []Option     // options of the accepted connections
+}
+
+// Listen listens on the TCP address for HSMS connections in the passive mode.
+// The options are applied to the accepted connections.
+func Listen(address string, opts ...Option) (*Listener, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{l, opts}, nil
+}
+
+// NewListener creates a HSMS listener that accepts connections from the network listener,
+// e.g. a in-memory listener of the connectiontest package. The options are applied to the accepted connections.
+func NewListener(l net.Listener, opts ...Option) *Listener {
+	return &Listener{l, opts}
+}
+
+// Accept waits for the next connection that is selected by the remote entity, and returns it.
+// Network connections that are not selected until T7 timeout are closed, and skipped.
+// Refer to Passive for the details.
+func (l *Listener) Accept() (*Conn, error) {
+	for {
+		c, err := l.listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if conn, err := Passive(c, l.opts...); err == nil {
+			return conn, nil
+		}
+	}
+}
+
+// Close closes the listener. Connections already accepted are not closed.
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
+
+// Addr returns the network address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests the HSMS listener
+//
+// Testing Strategy:
+//
+// Listen on a local TCP address, connect to it, and test the accepted connection.
+//
+// Partitions:
+//
+// - Remote entity: selects the connection, doesn't select the connection
+// - Listener: open, closed
+
+func TestListener(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", WithT7(50*time.Millisecond), WithHandler(echo))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// not selected connection is skipped
+	raw, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	defer raw.Close()
+
+	ch := make(chan *Conn)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		conn, err := Dial(l.Addr().String())
+		assert.NoError(t, err)
+		ch <- conn
+	}()
+	passive, err := l.Accept()
+	assert.NoError(t, err)
+	active := <-ch
+
+	assert.Equal(t, active.conn.LocalAddr().String(), passive.RemoteAddr().String())
+	reply, err := active.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	assert.Equal(t, "S1F2 H<->E", reply.Header())
+
+	// closed listener
+	assert.NoError(t, l.Close())
+	_, err = l.Accept()
+	assert.Error(t, err)
+	assert.NoError(t, active.Close())
+	<-passive.Done()
+	assert.Equal(t, ErrSeparated, passive.Err())
+}
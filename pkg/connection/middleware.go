@@ -0,0 +1,114 @@
+package connection
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// MessageFunc processes a HSMS message, i.e. *ast.DataMessage or *ast.ControlMessage,
+// sent or received by a connection.
+type MessageFunc func(msg ast.HSMSMessage) error
+
+// Middleware wraps the send and receive paths of the HSMS messages of a connection,
+// to apply cross-cutting behavior such as logging, metrics and validation.
+//
+// Send wraps the send path, which ends with writing the message to the network connection.
+// The error returned by the send path is returned to the sender, e.g. Conn.Send.
+// Receive wraps the receive path, which ends with handling the received message,
+// e.g. passing a primary message to the handler. A received message is discarded if a
+// middleware doesn't call the next function.
+//
+// A middleware can call the next function with a different message, or several times,
+// e.g. to redact or retry the message.
+type Middleware interface {
+	Send(next MessageFunc) MessageFunc
+	Receive(next MessageFunc) MessageFunc
+}
+
+// MiddlewareFuncs is a adapter to use functions as a Middleware.
+// A nil function doesn't wrap the path.
+type MiddlewareFuncs struct {
+	OnSend    func(next MessageFunc) MessageFunc
+	OnReceive func(next MessageFunc) MessageFunc
+}
+
+// Send implements Middleware.Send().
+func (m MiddlewareFuncs) Send(next MessageFunc) MessageFunc {
+	if m.OnSend == nil {
+		return next
+	}
+	return m.OnSend(next)
+}
+
+// Receive implements Middleware.Receive().
+func (m MiddlewareFuncs) Receive(next MessageFunc) MessageFunc {
+	if m.OnReceive == nil {
+		return next
+	}
+	return m.OnReceive(next)
+}
+
+// chain returns the send and receive paths that end with send and receive, wrapped by the middlewares.
+// The first middleware is the outermost, which processes sent and received messages first.
+func chain(middlewares []Middleware, send, receive MessageFunc) (MessageFunc, MessageFunc) {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		send = middlewares[i].Send(send)
+		receive = middlewares[i].Receive(receive)
+	}
+	return send, receive
+}
+
+// Logging returns a middleware that logs the header of the sent and received messages,
+// and the errors of the next functions, to the logger. The standard logger is used if logger is nil.
+//
+// The log lines are like "send S1F1 W H<->E (session id 0, system bytes 00000001)".
+func Logging(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	wrap := func(prefix string) func(next MessageFunc) MessageFunc {
+		return func(next MessageFunc) MessageFunc {
+			return func(msg ast.HSMSMessage) error {
+				err := next(msg)
+				if err != nil {
+					logger.Printf("%s %s: %v", prefix, describe(msg), err)
+				} else {
+					logger.Printf("%s %s", prefix, describe(msg))
+				}
+				return err
+			}
+		}
+	}
+	return MiddlewareFuncs{wrap("send"), wrap("receive")}
+}
+
+// Recovery returns a middleware that recovers panics of the next functions, including the handler
+// of the received primary messages, and returns them as errors, e.g. "panic: message".
+// Place Logging before Recovery to log the recovered panics.
+func Recovery() Middleware {
+	wrap := func(next MessageFunc) MessageFunc {
+		return func(msg ast.HSMSMessage) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return next(msg)
+		}
+	}
+	return MiddlewareFuncs{wrap, wrap}
+}
+
+// describe returns the message header or the control message type of the HSMS message,
+// with its session id and system bytes.
+func describe(msg ast.HSMSMessage) string {
+	switch msg := msg.(type) {
+	case *ast.DataMessage:
+		return fmt.Sprintf("%s (session id %d, system bytes %X)", msg.Header(), msg.SessionID(), msg.SystemBytes())
+	case *ast.ControlMessage:
+		return fmt.Sprintf("%s (session id %d, system bytes %X)", msg.Type(), msg.SessionID(), msg.SystemBytes())
+	}
+	return msg.Type()
+}
@@ -0,0 +1,184 @@
+package connection
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests the middlewares
+//
+// Testing Strategy:
+//
+// Create a pair of connections with middlewares, send messages, and test the messages
+// processed by the middlewares, and the messages sent and received by the connections.
+//
+// Partitions:
+//
+// - Number of middlewares: 0, 1, 2
+// - Path: send, receive; data message, control message
+// - Middleware: calls next, doesn't call next, modifies message, returns error, panics
+// - Built-in middleware: Logging, Recovery
+
+// recorder is a middleware that records the messages processed by it, with its name.
+type recorder struct {
+	mu      *sync.Mutex
+	name    string
+	records *[]string
+}
+
+func (r *recorder) wrap(path string) func(next MessageFunc) MessageFunc {
+	return func(next MessageFunc) MessageFunc {
+		return func(msg ast.HSMSMessage) error {
+			r.mu.Lock()
+			*r.records = append(*r.records, fmt.Sprintf("%s %s %s", r.name, path, msg.Type()))
+			r.mu.Unlock()
+			return next(msg)
+		}
+	}
+}
+
+func (r *recorder) Send(next MessageFunc) MessageFunc    { return r.wrap("send")(next) }
+func (r *recorder) Receive(next MessageFunc) MessageFunc { return r.wrap("receive")(next) }
+
+func TestMiddleware_Order(t *testing.T) {
+	records := []string{}
+	mu := &sync.Mutex{}
+	first, second := &recorder{mu, "first", &records}, &recorder{mu, "second", &records}
+
+	router := NewRouter()
+	router.Handle("S1F1", echo)
+	active, passive := pair(t, []Option{WithMiddleware(first), WithMiddleware(second)}, []Option{WithHandler(router)})
+	defer passive.Close()
+
+	_, err := active.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	active.Close()
+
+	assert.Equal(t, []string{
+		"first send select.req",
+		"second send select.req",
+		"first receive select.rsp",
+		"second receive select.rsp",
+		"first send data message",
+		"second send data message",
+		"first receive data message",
+		"second receive data message",
+		"first send separate.req",
+		"second send separate.req",
+	}, records)
+}
+
+func TestMiddleware_Modify(t *testing.T) {
+	// redacts ASCII items of the sent messages
+	redact := MiddlewareFuncs{OnSend: func(next MessageFunc) MessageFunc {
+		return func(msg ast.HSMSMessage) error {
+			if data, ok := msg.(*ast.DataMessage); ok {
+				if _, ok := data.Item().(*ast.ASCIINode); ok {
+					msg = ast.NewHSMSDataMessage(data.Name(), data.StreamCode(), data.FunctionCode(), 0, data.Direction(),
+						ast.NewASCIINode("***"), data.SessionID(), data.SystemBytes())
+				}
+			}
+			return next(msg)
+		}
+	}}
+	// discards received messages of stream 2, and fails sent messages of stream 3
+	filter := MiddlewareFuncs{
+		OnSend: func(next MessageFunc) MessageFunc {
+			return func(msg ast.HSMSMessage) error {
+				if data, ok := msg.(*ast.DataMessage); ok && data.StreamCode() == 3 {
+					return errors.New("stream 3 is not allowed")
+				}
+				return next(msg)
+			}
+		},
+		OnReceive: func(next MessageFunc) MessageFunc {
+			return func(msg ast.HSMSMessage) error {
+				if data, ok := msg.(*ast.DataMessage); ok && data.StreamCode() == 2 {
+					return nil
+				}
+				return next(msg)
+			}
+		},
+	}
+
+	received := make(chan *ast.DataMessage, 2)
+	handler := HandlerFunc(func(w ReplyWriter, msg *ast.DataMessage) {
+		received <- msg
+	})
+	active, passive := pair(t, []Option{WithMiddleware(redact, filter)}, []Option{WithHandler(handler), WithMiddleware(filter)})
+	defer active.Close()
+	defer passive.Close()
+
+	_, err := active.Send(ast.NewDataMessage("", 3, 1, 0, "H->E", ast.NewEmptyItemNode()))
+	assert.EqualError(t, err, "stream 3 is not allowed")
+	_, err = active.Send(ast.NewDataMessage("", 2, 1, 0, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	_, err = active.Send(ast.NewDataMessage("", 1, 1, 0, "H->E", ast.NewASCIINode("password")))
+	assert.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "S1F1 H<->E", msg.Header())
+		assert.Equal(t, ast.NewASCIINode("***"), msg.Item())
+	case <-time.After(time.Second):
+		assert.Fail(t, "message not received")
+	}
+	assert.Empty(t, received)
+}
+
+func TestMiddleware_LoggingRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	logger := log.New(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}), "", 0)
+
+	router := NewRouter()
+	router.HandleFunc("S1F1", func(w ReplyWriter, msg *ast.DataMessage) {
+		panic("handler failed")
+	})
+	router.Handle("S1F3", echo)
+	active, passive := pair(t, nil, []Option{WithHandler(router), WithMiddleware(Logging(logger), Recovery())})
+	defer active.Close()
+	defer passive.Close()
+
+	_, err := active.Send(ast.NewDataMessage("", 1, 1, 0, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	reply, err := active.Send(ast.NewDataMessage("", 1, 3, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	assert.Equal(t, "S1F4 H<->E", reply.Header())
+
+	// the received message is logged after the handler returns
+	var lines []string
+	for deadline := time.Now().Add(time.Second); len(lines) < 5 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+		mu.Unlock()
+	}
+	assert.ElementsMatch(t, []string{
+		"send select.rsp (session id 65535, system bytes 00000001)",
+		"receive select.req (session id 65535, system bytes 00000001)",
+		"receive S1F1 H<->E (session id 0, system bytes 00000002): panic: handler failed",
+		"send S1F4 H<->E (session id 0, system bytes 00000003)",
+		"receive S1F3 W H<->E (session id 0, system bytes 00000003)",
+	}, lines)
+}
+
+// writerFunc is a adapter to use a function as a io.Writer.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
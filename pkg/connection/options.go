@@ -0,0 +1,201 @@
+package connection
+
+import "time"
+
+// Option is a option of the HSMS connection.
+type Option func(*options)
+
+// options is the configuration of the HSMS connection.
+type options struct {
+	sessionID int           // session id of the data messages
+	t3        time.Duration // reply timeout
+	t6        time.Duration // control transaction timeout
+	t7        time.Duration // not selected timeout
+	t8        time.Duration // network intercharacter timeout
+	clock     Clock         // source of the current time and the timers
+	handler   Handler       // handler of the received primary messages; nil to abort them
+	s9        bool          // true to send stream 9 error messages automatically
+
+	maxMessageLength int // maximum message length of the received messages, at least 10
+	maxHandlers      int // maximum number of the received primary messages handled concurrently; 0 for no limit
+
+	linktestInterval time.Duration // interval of the periodic linktests; 0 to disable them
+	linktestFailures int           // number of consecutive linktest failures to close the connection
+
+	stateHandlers []func(StateEvent) // subscribers of the state events from the creation of the connection
+
+	middlewares []Middleware    // middlewares of the send and receive paths, the outermost first
+	sessions    map[int]Handler // handlers of the session entities of HSMS-GS by their session id
+}
+
+// newOptions returns the options with the default values, applied with the opts.
+func newOptions(opts []Option) *options {
+	o := &options{
+		sessionID: 0,
+		t3:        45 * time.Second,
+		t6:        5 * time.Second,
+		t7:        10 * time.Second,
+		t8:        5 * time.Second,
+		clock:     realClock{},
+
+		maxMessageLength: 16 << 20,
+		maxHandlers:      64,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSessionID returns a option that sets the session id (device id) of the data messages,
+// which should be in range of [0, 65535). The default is 0.
+func WithSessionID(id int) Option {
+	return func(o *options) {
+		o.sessionID = id
+	}
+}
+
+// WithT3 returns a option that sets the reply timeout, which is the time to wait for the
+// secondary message after sending a primary message with the wait bit. The default is 45 seconds.
+func WithT3(d time.Duration) Option {
+	return func(o *options) {
+		o.t3 = d
+	}
+}
+
+// WithT6 returns a option that sets the control transaction timeout, which is the time to wait for
+// the response of a control message, e.g. select.rsp. The default is 5 seconds.
+func WithT6(d time.Duration) Option {
+	return func(o *options) {
+		o.t6 = d
+	}
+}
+
+// WithT7 returns a option that sets the not selected timeout, which is the time to wait for
+// select.req after the connection is established, in the passive mode. The default is 10 seconds.
+func WithT7(d time.Duration) Option {
+	return func(o *options) {
+		o.t7 = d
+	}
+}
+
+// WithT8 returns a option that sets the network intercharacter timeout, which is the maximum time
+// between successive bytes of a message. The default is 5 seconds.
+func WithT8(d time.Duration) Option {
+	return func(o *options) {
+		o.t8 = d
+	}
+}
+
+// WithClock returns a option that sets the clock of the timers, e.g. T3, and the times recorded by
+// the connection, e.g. in the state events. T8 is measured by the deadlines of the network connection,
+// regardless of the clock. The default is the system clock.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithHandler returns a option that sets the handler of the received primary messages,
+// e.g. a Router. If the handler is not set, SxF0 is replied to the received primary messages
+// with the wait bit, and the others are ignored.
+func WithHandler(h Handler) Option {
+	return func(o *options) {
+		o.handler = h
+	}
+}
+
+// WithLinktest returns a option that sends linktest.req periodically with the interval, from the time
+// the connection is established. A linktest fails if linktest.rsp is not received until T6 timeout,
+// and the connection is closed with ErrLinktestFailed after the number of consecutive failures,
+// which is at least 1. The periodic linktests are disabled by default.
+// The round-trip times are recorded in the linktest statistics; refer to Conn.LinktestStats.
+func WithLinktest(interval time.Duration, failures int) Option {
+	return func(o *options) {
+		if failures < 1 {
+			failures = 1
+		}
+		o.linktestInterval, o.linktestFailures = interval, failures
+	}
+}
+
+// WithStateHandler returns a option that subscribes the function to the state events of the connection,
+// from its creation; StateConnecting and StateClosed of a failed dial are passed to the function by Dial.
+// Refer to Conn.Subscribe.
+func WithStateHandler(fn func(StateEvent)) Option {
+	return func(o *options) {
+		o.stateHandlers = append(o.stateHandlers, fn)
+	}
+}
+
+// WithSession returns a option that registers a session entity with the session id and its handler,
+// which makes the connection HSMS-GS. The handler receives the primary messages with the session id,
+// instead of the handler set by WithHandler; the primary messages are aborted if it is nil.
+//
+// In HSMS-GS, each session entity is selected and deselected separately, e.g. with Session.Select,
+// and data messages of the session entities that are not registered or not selected are rejected.
+func WithSession(id int, handler Handler) Option {
+	return func(o *options) {
+		if o.sessions == nil {
+			o.sessions = map[int]Handler{}
+		}
+		o.sessions[id] = handler
+	}
+}
+
+// WithS9Messages returns a option that sends the stream 9 error messages automatically,
+// as the equipment is required to in SEMI E5:
+//
+//   - S9F1 for a received data message with a session id other than the session id of the
+//     connection, which is discarded, in HSMS-SS
+//   - S9F3 or S9F5 for a received primary message that is not handled by the Router of the
+//     connection, or S9F3 if the handler is not set, instead of replying SxF0
+//   - S9F7 for a received data message that cannot be decoded
+//   - S9F9 for a sent primary message whose reply is not received until T3 timeout
+//   - S9F11 for a received message longer than the maximum message length, which is discarded;
+//     refer to WithMaxMessageLength
+func WithS9Messages() Option {
+	return func(o *options) {
+		o.s9 = true
+	}
+}
+
+// WithMaxMessageLength returns a option that sets the maximum message length of the received messages,
+// which is the value of the message length bytes, i.e. the length of the header and the data item,
+// and at least 10. A longer message is not read into memory; it is discarded with S9F11 if the stream 9
+// error messages are enabled, or the connection is closed with ErrMessageTooLong otherwise.
+// The default is 16 MiB.
+func WithMaxMessageLength(n int) Option {
+	return func(o *options) {
+		if n < 10 {
+			n = 10
+		}
+		o.maxMessageLength = n
+	}
+}
+
+// WithMaxHandlers returns a option that sets the maximum number of the received primary messages
+// handled concurrently, each in its own goroutine with the receive path and the handler. When the limit
+// is reached, further primary messages are aborted with SxF0 if they have the wait bit, or discarded
+// otherwise, without being passed to the receive path, until a handler returns. Other messages,
+// e.g. the replies of the messages sent by the handlers, are still received.
+// n <= 0 means no limit. The default is 64.
+func WithMaxHandlers(n int) Option {
+	return func(o *options) {
+		if n < 0 {
+			n = 0
+		}
+		o.maxHandlers = n
+	}
+}
+
+// WithMiddleware returns a option that adds the middlewares to the send and receive paths of the
+// connection. The middlewares are applied in order; the first middleware processes the sent and
+// received messages first. Received primary data messages are processed in new goroutines,
+// which end with the handler, up to the limit of WithMaxHandlers; other received messages are
+// processed in order of receipt.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
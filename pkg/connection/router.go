@@ -0,0 +1,183 @@
+package connection
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Handler handles a primary message received by a HSMS connection.
+//
+// ServeSECS is called in a new goroutine for each received primary message. It should reply
+// to the message with w, if the message has the wait bit.
+type Handler interface {
+	ServeSECS(w ReplyWriter, msg *ast.DataMessage)
+}
+
+// HandlerFunc is a adapter to use a function as a Handler.
+type HandlerFunc func(w ReplyWriter, msg *ast.DataMessage)
+
+// ServeSECS implements Handler.ServeSECS().
+func (f HandlerFunc) ServeSECS(w ReplyWriter, msg *ast.DataMessage) {
+	f(w, msg)
+}
+
+// ReplyWriter is used by a Handler to reply to the received primary message.
+type ReplyWriter interface {
+	// Reply sends the secondary message of the received primary message, with the session id
+	// and the system bytes copied from the primary message. Refer to Conn.Reply.
+	Reply(msg *ast.DataMessage) error
+
+	// Send sends a new message on the connection that received the primary message.
+	// Refer to Conn.Send.
+	Send(msg *ast.DataMessage) (*ast.DataMessage, error)
+}
+
+// Router is a Handler that dispatches primary messages to the handlers registered
+// by their stream and function code.
+//
+// A handler is registered with a pattern "SxFy", where x and y are the stream and function code,
+// or the wildcard "*" that matches any code, e.g. "S1F13", "S6F*", "S*F*". The handler of the most
+// specific pattern is chosen; "SxFy" over "SxF*", "SxF*" over "S*Fy", and "S*Fy" over "S*F*".
+//
+// Primary messages that match no pattern are unhandled. By default, the router replies SxF0 abort
+// to the unhandled primary messages with the wait bit. With the WithS9Errors option, the router
+// sends S9F3 (unrecognized stream) if no pattern has the stream code, or S9F5 (unrecognized function)
+// otherwise, as the equipment is required to in SEMI E5.
+type Router struct {
+	s9Errors bool // true to send S9F3/S9F5 for unhandled messages, false to reply SxF0
+
+	mu     sync.RWMutex     // guards routes
+	routes map[[2]int]route // routes by the stream and function code of their patterns
+}
+
+// route is a handler registered to a pattern.
+type route struct {
+	stream, function int // stream and function code of the pattern; -1 for the wildcard
+	handler          Handler
+}
+
+// RouterOption is a option of the Router.
+type RouterOption func(*Router)
+
+// WithS9Errors returns a router option that sends S9F3 or S9F5 for unhandled primary messages,
+// instead of replying SxF0.
+func WithS9Errors() RouterOption {
+	return func(r *Router) {
+		r.s9Errors = true
+	}
+}
+
+// patternRegexp is the regular expression of the handler patterns.
+var patternRegexp = regexp.MustCompile(`^S(\d+|\*)F(\d+|\*)$`)
+
+// NewRouter creates a new router without handlers.
+func NewRouter(opts ...RouterOption) *Router {
+	r := &Router{routes: map[[2]int]route{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Handle registers the handler for the pattern, e.g. "S1F13", "S6F*".
+// Panics if the pattern is invalid or already registered, or the handler is nil.
+func (r *Router) Handle(pattern string, handler Handler) {
+	if handler == nil {
+		panic("nil handler")
+	}
+	stream, function, err := parsePattern(pattern)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := [2]int{stream, function}
+	if _, ok := r.routes[key]; ok {
+		panic(fmt.Sprintf("pattern %q already registered", pattern))
+	}
+	r.routes[key] = route{stream, function, handler}
+}
+
+// HandleFunc registers the handler function for the pattern. Refer to Handle.
+func (r *Router) HandleFunc(pattern string, handler func(w ReplyWriter, msg *ast.DataMessage)) {
+	r.Handle(pattern, HandlerFunc(handler))
+}
+
+// Handler returns the handler for the message, and whether a pattern matched the message.
+// If no pattern matched, the returned handler handles the message as unhandled.
+func (r *Router) Handler(msg *ast.DataMessage) (h Handler, ok bool) {
+	return r.handler(msg, r.s9Errors)
+}
+
+// handler returns the handler for the message, and whether a pattern matched the message.
+// If no pattern matched, the returned handler sends S9F3 or S9F5 if s9Errors is true,
+// or replies SxF0 otherwise.
+func (r *Router) handler(msg *ast.DataMessage, s9Errors bool) (h Handler, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stream, function := msg.StreamCode(), msg.FunctionCode()
+	for _, key := range [][2]int{{stream, function}, {stream, -1}, {-1, function}, {-1, -1}} {
+		if route, ok := r.routes[key]; ok {
+			return route.handler, true
+		}
+	}
+
+	if !s9Errors {
+		return HandlerFunc(abort), false
+	}
+	for _, route := range r.routes {
+		if route.stream == stream {
+			return HandlerFunc(unrecognizedFunction), false
+		}
+	}
+	return HandlerFunc(unrecognizedStream), false
+}
+
+// ServeSECS implements Handler.ServeSECS(), by dispatching the message to the handler
+// of the most specific pattern that matches the message.
+func (r *Router) ServeSECS(w ReplyWriter, msg *ast.DataMessage) {
+	h, _ := r.Handler(msg)
+	h.ServeSECS(w, msg)
+}
+
+// parsePattern returns the stream and function code of the pattern, where -1 means the wildcard.
+func parsePattern(pattern string) (stream, function int, err error) {
+	m := patternRegexp.FindStringSubmatch(pattern)
+	if m == nil {
+		return 0, 0, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	stream, function = -1, -1
+	if m[1] != "*" {
+		stream, _ = strconv.Atoi(m[1])
+	}
+	if m[2] != "*" {
+		function, _ = strconv.Atoi(m[2])
+	}
+	if stream > 127 || function > 255 {
+		return 0, 0, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	return stream, function, nil
+}
+
+// abort replies SxF0 to the message, if the message has the wait bit.
+func abort(w ReplyWriter, msg *ast.DataMessage) {
+	if msg.WaitBit() == "true" {
+		w.Reply(ast.NewDataMessage("", msg.StreamCode(), 0, 0, "H<->E", ast.NewEmptyItemNode()))
+	}
+}
+
+// unrecognizedStream sends S9F3 with the header of the message.
+func unrecognizedStream(w ReplyWriter, msg *ast.DataMessage) {
+	w.Send(NewS9MessageFor(S9UnrecognizedStream, msg))
+}
+
+// unrecognizedFunction sends S9F5 with the header of the message.
+func unrecognizedFunction(w ReplyWriter, msg *ast.DataMessage) {
+	w.Send(NewS9MessageFor(S9UnrecognizedFunction, msg))
+}
@@ -0,0 +1,137 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests the message handler router
+//
+// Testing Strategy:
+//
+// Register handlers to a router, dispatch received primary messages with a fake reply writer,
+// and test the handler that handled the message, or the messages written by the router.
+//
+// Partitions:
+//
+// - Pattern: SxFy, SxF*, S*Fy, S*F*, invalid, duplicated
+// - Handler: registered, nil
+// - Message: matches a pattern, matches several patterns, matches no pattern
+// - Unhandled message: with wait bit, without wait bit; stream registered, not registered
+// - Options: none, WithS9Errors
+
+// fakeWriter is a ReplyWriter that records the written messages.
+type fakeWriter struct {
+	replies []*ast.DataMessage
+	sent    []*ast.DataMessage
+}
+
+func (w *fakeWriter) Reply(msg *ast.DataMessage) error {
+	w.replies = append(w.replies, msg)
+	return nil
+}
+
+func (w *fakeWriter) Send(msg *ast.DataMessage) (*ast.DataMessage, error) {
+	w.sent = append(w.sent, msg)
+	return nil, nil
+}
+
+// received returns a data message as received by a connection.
+func received(stream, function int, waitBit bool) *ast.DataMessage {
+	w := 0
+	if waitBit {
+		w = 1
+	}
+	return ast.NewHSMSDataMessage("", stream, function, w, "H<->E", ast.NewEmptyItemNode(), 1, []byte{0, 0, 0, 7})
+}
+
+func TestRouter_Handler(t *testing.T) {
+	r := NewRouter()
+	handled := ""
+	for _, pattern := range []string{"S1F1", "S1F*", "S*F3", "S*F*", "S6F11"} {
+		pattern := pattern
+		r.HandleFunc(pattern, func(w ReplyWriter, msg *ast.DataMessage) {
+			handled = pattern
+		})
+	}
+
+	var tests = []struct {
+		stream, function int
+		expected         string
+	}{
+		{1, 1, "S1F1"},
+		{1, 3, "S1F*"},
+		{1, 13, "S1F*"},
+		{2, 3, "S*F3"},
+		{6, 11, "S6F11"},
+		{6, 13, "S*F*"},
+		{127, 255, "S*F*"},
+	}
+	for i, test := range tests {
+		msg := received(test.stream, test.function, true)
+		_, ok := r.Handler(msg)
+		assert.True(t, ok, "test %d", i)
+		handled = ""
+		r.ServeSECS(&fakeWriter{}, msg)
+		assert.Equal(t, test.expected, handled, "test %d", i)
+	}
+}
+
+func TestRouter_Unhandled(t *testing.T) {
+	nop := func(w ReplyWriter, msg *ast.DataMessage) {}
+
+	// Abort
+	r := NewRouter()
+	r.HandleFunc("S1F1", nop)
+	_, ok := r.Handler(received(1, 3, true))
+	assert.False(t, ok)
+
+	w := &fakeWriter{}
+	r.ServeSECS(w, received(1, 3, true))
+	assert.Empty(t, w.sent)
+	if assert.Len(t, w.replies, 1) {
+		assert.Equal(t, "S1F0 H<->E", w.replies[0].Header())
+		assert.Nil(t, w.replies[0].Item())
+	}
+
+	w = &fakeWriter{}
+	r.ServeSECS(w, received(1, 3, false))
+	assert.Empty(t, w.replies)
+	assert.Empty(t, w.sent)
+
+	// S9 errors
+	r = NewRouter(WithS9Errors())
+	r.HandleFunc("S1F1", nop)
+	r.HandleFunc("S*F13", nop)
+
+	w = &fakeWriter{}
+	r.ServeSECS(w, received(1, 3, true))
+	assert.Empty(t, w.replies)
+	if assert.Len(t, w.sent, 1) {
+		assert.Equal(t, "S9F5 H<-E UnrecognizedFunctionType", w.sent[0].Header())
+		assert.Equal(t, ast.NewBinaryNode(0, 1, 0x81, 3, 0, 0, 0, 0, 0, 7), w.sent[0].Item())
+	}
+
+	w = &fakeWriter{}
+	r.ServeSECS(w, received(2, 1, false))
+	assert.Empty(t, w.replies)
+	if assert.Len(t, w.sent, 1) {
+		assert.Equal(t, "S9F3 H<-E UnrecognizedStreamType", w.sent[0].Header())
+		assert.Equal(t, ast.NewBinaryNode(0, 1, 2, 1, 0, 0, 0, 0, 0, 7), w.sent[0].Item())
+	}
+}
+
+func TestRouter_Handle(t *testing.T) {
+	nop := func(w ReplyWriter, msg *ast.DataMessage) {}
+	r := NewRouter()
+	r.HandleFunc("S1F1", nop)
+
+	assert.PanicsWithValue(t, "nil handler", func() { r.Handle("S1F3", nil) })
+	assert.PanicsWithValue(t, `pattern "S1F1" already registered`, func() { r.HandleFunc("S1F1", nop) })
+	for _, pattern := range []string{"", "S1", "F1", "s1f1", "S1F1 ", "S*", "S1F**", "S128F1", "S1F256", "S-1F1"} {
+		assert.PanicsWithValue(t, "invalid pattern "+`"`+pattern+`"`, func() { r.HandleFunc(pattern, nop) }, pattern)
+	}
+	assert.NotPanics(t, func() { r.HandleFunc("S127F255", nop) })
+}
@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"fmt"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/e5"
+)
+
+// Function codes of the stream 9 error messages in SEMI E5, which carry the 10-byte header
+// of the message that caused the error.
+const (
+	S9UnrecognizedDeviceID = 1  // S9F1, device id of the received message is not recognized
+	S9UnrecognizedStream   = 3  // S9F3, stream code of the received message is not recognized
+	S9UnrecognizedFunction = 5  // S9F5, function code of the received message is not recognized
+	S9IllegalData          = 7  // S9F7, data item of the received message cannot be interpreted
+	S9TransactionTimeout   = 9  // S9F9, reply of the sent message is not received until T3 timeout
+	S9DataTooLong          = 11 // S9F11, received message is longer than the maximum message length
+)
+
+// s9Names is the message names of the stream 9 error messages in the e5 dictionary, by their function code.
+var s9Names = map[int]string{
+	S9UnrecognizedDeviceID: "UnrecognizedDeviceID",
+	S9UnrecognizedStream:   "UnrecognizedStreamType",
+	S9UnrecognizedFunction: "UnrecognizedFunctionType",
+	S9IllegalData:          "IllegalData",
+	S9TransactionTimeout:   "TransactionTimerTimeout",
+	S9DataTooLong:          "DataTooLong",
+}
+
+// NewS9Message returns the stream 9 error message with the function code, which carries the
+// 10-byte message header, e.g. NewS9Message(S9IllegalData, header) returns S9F7 with MHEAD.
+// The message is created from the e5 dictionary.
+//
+// Panics if the function code is not one of the S9 constants, or the header is not 10 bytes.
+func NewS9Message(function int, header []byte) *ast.DataMessage {
+	name, ok := s9Names[function]
+	if !ok {
+		panic(fmt.Sprintf("invalid stream 9 function code %d", function))
+	}
+	if len(header) != 10 {
+		panic(fmt.Sprintf("invalid message header length %d", len(header)))
+	}
+	variable := "MHEAD"
+	if function == S9TransactionTimeout {
+		variable = "SHEAD"
+	}
+	msg, err := e5.Instantiate(name, map[string]interface{}{variable: header})
+	if err != nil {
+		panic(err.Error())
+	}
+	return msg
+}
+
+// NewS9MessageFor returns the stream 9 error message with the function code, which carries the
+// header of the data message. Refer to NewS9Message and MessageHeader.
+func NewS9MessageFor(function int, msg *ast.DataMessage) *ast.DataMessage {
+	return NewS9Message(function, MessageHeader(msg))
+}
+
+// MessageHeader returns the 10-byte HSMS message header of the data message, i.e. session id,
+// wait bit, stream code, function code, PType, SType and system bytes.
+//
+// Panics if the message cannot be converted to HSMS format, e.g. the session id is not set.
+func MessageHeader(msg *ast.DataMessage) []byte {
+	b := msg.ToBytes()
+	if len(b) == 0 {
+		panic("message cannot be converted to HSMS format")
+	}
+	return b[4:14]
+}
@@ -0,0 +1,145 @@
+package connection
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/hsms"
+)
+
+// Tests the stream 9 error messages
+//
+// Testing Strategy:
+//
+// Create stream 9 error messages with the helpers, and test their header and data item.
+// Test the stream 9 error messages sent automatically by a connection with WithS9Messages,
+// using a raw network connection as the remote entity.
+//
+// Partitions:
+//
+// - Function code: 1, 3, 5, 7, 9, 11, invalid
+// - Header: 10 bytes, other length; from bytes, from a data message, data message without session id
+// - Condition: unrecognized device id, unrecognized stream, unrecognized function, illegal data,
+//   T3 timeout; handler: nil, Router, other handler
+
+var header = []byte{0, 1, 0x81, 3, 0, 0, 0, 0, 0, 7}
+
+func TestNewS9Message(t *testing.T) {
+	var tests = []struct {
+		function       int
+		expectedHeader string
+	}{
+		{S9UnrecognizedDeviceID, "S9F1 H<-E UnrecognizedDeviceID"},
+		{S9UnrecognizedStream, "S9F3 H<-E UnrecognizedStreamType"},
+		{S9UnrecognizedFunction, "S9F5 H<-E UnrecognizedFunctionType"},
+		{S9IllegalData, "S9F7 H<-E IllegalData"},
+		{S9TransactionTimeout, "S9F9 H<-E TransactionTimerTimeout"},
+		{S9DataTooLong, "S9F11 H<-E DataTooLong"},
+	}
+	for _, test := range tests {
+		msg := NewS9Message(test.function, header)
+		assert.Equal(t, test.expectedHeader, msg.Header())
+		assert.Equal(t, "false", msg.WaitBit())
+		assert.Equal(t, []byte{33, 10, 0, 1, 0x81, 3, 0, 0, 0, 0, 0, 7}, msg.Item().ToBytes())
+	}
+
+	data := ast.NewHSMSDataMessage("", 1, 3, 1, "H->E", ast.NewEmptyItemNode(), 1, []byte{0, 0, 0, 7})
+	assert.Equal(t, header, MessageHeader(data))
+	assert.Equal(t, NewS9Message(S9UnrecognizedFunction, header), NewS9MessageFor(S9UnrecognizedFunction, data))
+
+	assert.PanicsWithValue(t, "invalid stream 9 function code 13", func() { NewS9Message(13, header) })
+	assert.PanicsWithValue(t, "invalid message header length 9", func() { NewS9Message(S9IllegalData, header[:9]) })
+	assert.PanicsWithValue(t, "message cannot be converted to HSMS format", func() {
+		MessageHeader(ast.NewDataMessage("", 1, 3, 1, "H->E", ast.NewEmptyItemNode()))
+	})
+}
+
+func TestConn_S9Messages(t *testing.T) {
+	// readS9 reads a message from the raw connection, and returns its header and MHEAD
+	readS9 := func(c net.Conn) (string, []byte) {
+		msg, ok := hsms.Parse(readRaw(t, c))
+		if !assert.True(t, ok) {
+			return "", nil
+		}
+		data := msg.(*ast.DataMessage)
+		return data.Header(), data.Item().ToBytes()[2:]
+	}
+
+	var tests = []struct {
+		handler        Handler
+		input          []byte
+		expectedHeader string
+		expectedMHEAD  []byte
+	}{
+		{
+			handler:        nil,
+			input:          ast.NewHSMSDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode(), 2, []byte{0, 0, 0, 1}).ToBytes(),
+			expectedHeader: "S9F1 H<->E",
+			expectedMHEAD:  []byte{0, 2, 0x81, 1, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			handler:        nil,
+			input:          ast.NewHSMSDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode(), 0, []byte{0, 0, 0, 1}).ToBytes(),
+			expectedHeader: "S9F3 H<->E",
+			expectedMHEAD:  []byte{0, 0, 0x81, 1, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			handler:        NewRouter(),
+			input:          ast.NewHSMSDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode(), 0, []byte{0, 0, 0, 1}).ToBytes(),
+			expectedHeader: "S9F3 H<->E",
+			expectedMHEAD:  []byte{0, 0, 0x81, 1, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			handler:        func() Handler { r := NewRouter(); r.Handle("S1F3", echo); return r }(),
+			input:          ast.NewHSMSDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode(), 0, []byte{0, 0, 0, 1}).ToBytes(),
+			expectedHeader: "S9F5 H<->E",
+			expectedMHEAD:  []byte{0, 0, 0x81, 1, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			handler:        nil,
+			input:          []byte{0, 0, 0, 12, 0, 0, 0x81, 1, 0, 0, 0, 0, 0, 1, 0x41, 5},
+			expectedHeader: "S9F7 H<->E",
+			expectedMHEAD:  []byte{0, 0, 0x81, 1, 0, 0, 0, 0, 0, 1},
+		},
+	}
+	for i, test := range tests {
+		c1, c2 := net.Pipe()
+		ch := make(chan *Conn)
+		go func() {
+			conn, err := Passive(c2, WithS9Messages(), WithHandler(test.handler))
+			assert.NoError(t, err)
+			ch <- conn
+		}()
+		c1.Write(control(0xFFFF, 0, 0, 1, 1))
+		readRaw(t, c1)
+		conn := <-ch
+
+		c1.Write(test.input)
+		header, mhead := readS9(c1)
+		assert.Equal(t, test.expectedHeader, header, "test %d", i)
+		assert.Equal(t, test.expectedMHEAD, mhead, "test %d", i)
+		conn.closeWith(ErrClosed)
+	}
+
+	// T3 timeout
+	received := make(chan *ast.DataMessage, 1)
+	router := NewRouter()
+	router.HandleFunc("S1F1", func(w ReplyWriter, msg *ast.DataMessage) {})
+	router.HandleFunc("S9F9", func(w ReplyWriter, msg *ast.DataMessage) {
+		received <- msg
+	})
+	active, passive := pair(t, []Option{WithS9Messages(), WithT3(50 * time.Millisecond)}, []Option{WithHandler(router)})
+	defer active.Close()
+	defer passive.Close()
+	_, err := active.Send(ast.NewDataMessage("", 1, 1, 1, "H<-E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrT3Timeout, err)
+	select {
+	case msg := <-received:
+		assert.Equal(t, []byte{33, 10, 0, 0, 0x81, 1, 0, 0, 0, 0, 0, 2}, msg.Item().ToBytes())
+	case <-time.After(time.Second):
+		assert.Fail(t, "S9F9 not received")
+	}
+}
@@ -0,0 +1,92 @@
+package connection
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Select status codes of select.rsp in HSMS-GS, in addition to the status codes of HSMS-SS.
+const (
+	selectStatusAlreadyActive = 1 // communication already active
+	selectStatusEntityUnknown = 4 // session entity is not registered
+)
+
+// Deselect status codes of deselect.rsp.
+const deselectStatusNotEstablished = 1 // communication not established
+
+// Session is a session entity of a HSMS-GS connection, which is selected and deselected
+// separately from the other session entities on the connection.
+// Data messages of the session entity have its session id.
+type Session struct {
+	conn    *Conn
+	id      int     // session id of the session entity
+	handler Handler // handler of the received primary messages; nil to abort them
+
+	selected bool // true if the session entity is selected, guarded by conn.mu
+}
+
+// ID returns the session id of the session entity.
+func (s *Session) ID() int {
+	return s.id
+}
+
+// Selected returns true if the session entity is in the selected state.
+func (s *Session) Selected() bool {
+	return s.conn.isSessionSelected(s.id)
+}
+
+// Select sends select.req with the session id, and returns when select.rsp is received,
+// or T6 timeout occurs. An error is returned if the session entity is not selected.
+func (s *Session) Select() error {
+	return s.conn.controlTransaction(ast.NewHSMSMessageSelectReq, s.id)
+}
+
+// Deselect sends deselect.req with the session id, and returns when deselect.rsp is received,
+// or T6 timeout occurs. An error is returned if the session entity is not deselected.
+func (s *Session) Deselect() error {
+	return s.conn.controlTransaction(ast.NewHSMSMessageDeselectReq, s.id)
+}
+
+// Send sends the data message with the session id of the session entity. Refer to Conn.Send.
+func (s *Session) Send(msg *ast.DataMessage) (*ast.DataMessage, error) {
+	return s.conn.send(s.id, msg)
+}
+
+// Reply sends the secondary message of the primary message. Refer to Conn.Reply.
+func (s *Session) Reply(primary, reply *ast.DataMessage) error {
+	return s.conn.Reply(primary, reply)
+}
+
+// Session returns the session entity with the session id, or nil if the session entity is not
+// registered with WithSession.
+func (c *Conn) Session(id int) *Session {
+	return c.sessions[id]
+}
+
+// Sessions returns the session entities registered with WithSession, in the order of their session id.
+// It returns empty slice for a HSMS-SS connection.
+func (c *Conn) Sessions() []*Session {
+	result := make([]*Session, 0, len(c.sessions))
+	for _, s := range c.sessions {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
+
+// controlTransaction sends select.req or deselect.req created by newRequest with the session id,
+// and waits for the response until T6 timeout. Nonzero status of the response is returned as a error.
+func (c *Conn) controlTransaction(newRequest func(uint16, []byte) ast.HSMSMessage, sessionID int) error {
+	systemBytes := c.nextSystemBytes()
+	req := newRequest(uint16(sessionID), systemBytes)
+	rsp, err := c.transact(req, systemBytes, c.opts.t6, ErrT6Timeout)
+	if err != nil {
+		return err
+	}
+	if status := rsp.(*ast.ControlMessage).Status(); status != 0 {
+		return fmt.Errorf("%s rejected with status %d", req.Type(), status)
+	}
+	return nil
+}
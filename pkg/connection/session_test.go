@@ -0,0 +1,126 @@
+package connection
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests the HSMS-GS session entities
+//
+// Testing Strategy:
+//
+// Create a pair of HSMS-GS connections, select and deselect the session entities, send messages,
+// and test the state of the session entities and the handlers that received the messages.
+// Control transactions are tested with a raw network connection as the remote entity.
+//
+// Partitions:
+//
+// - Session entity: registered, not registered; selected, not selected
+// - Control message: select.req, deselect.req, select.rsp, deselect.rsp
+// - Data message: primary, secondary; session entity selected, not selected, not registered
+
+func TestSession(t *testing.T) {
+	received := make(chan int, 2)
+	handler := func(id int) Handler {
+		return HandlerFunc(func(w ReplyWriter, msg *ast.DataMessage) {
+			received <- id
+			echo.ServeSECS(w, msg)
+		})
+	}
+
+	c1, c2 := net.Pipe()
+	ch := make(chan *Conn)
+	go func() {
+		conn, err := Passive(c2, WithSession(1, handler(1)), WithSession(2, handler(2)))
+		assert.NoError(t, err)
+		ch <- conn
+	}()
+	active, err := Active(c1, WithSession(1, nil), WithSession(2, nil), WithSession(3, nil))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer active.Close()
+	assert.False(t, active.isSelected())
+
+	ids := []int{}
+	for _, s := range active.Sessions() {
+		ids = append(ids, s.ID())
+	}
+	assert.Equal(t, []int{1, 2, 3}, ids)
+	assert.Nil(t, active.Session(4))
+
+	// select
+	assert.NoError(t, active.Session(1).Select())
+	passive := <-ch
+	defer passive.Close()
+	assert.True(t, active.Session(1).Selected())
+	assert.True(t, passive.Session(1).Selected())
+	assert.False(t, active.Session(2).Selected())
+	assert.False(t, passive.Session(2).Selected())
+	assert.EqualError(t, active.Session(1).Select(), "select.req rejected with status 1")
+	assert.EqualError(t, active.Session(3).Select(), "select.req rejected with status 4")
+	assert.EqualError(t, active.Session(2).Deselect(), "deselect.req rejected with status 1")
+	assert.NoError(t, active.Session(2).Select())
+
+	// data messages are routed by the session id
+	for _, id := range []int{2, 1} {
+		reply, err := active.Session(id).Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewASCIINode("hi")))
+		assert.NoError(t, err)
+		assert.Equal(t, id, reply.SessionID())
+		assert.Equal(t, id, <-received)
+	}
+	_, err = active.Session(3).Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewASCIINode("hi")))
+	assert.Equal(t, ErrNotSelected, err)
+	_, err = active.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewASCIINode("hi")))
+	assert.Equal(t, ErrNotSelected, err)
+
+	// deselect
+	assert.NoError(t, active.Session(1).Deselect())
+	assert.False(t, active.Session(1).Selected())
+	assert.False(t, passive.Session(1).Selected())
+	assert.True(t, passive.Session(2).Selected())
+	_, err = passive.Session(1).Send(ast.NewDataMessage("", 1, 1, 0, "H<-E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrNotSelected, err)
+}
+
+func TestSession_Reject(t *testing.T) {
+	c1, c2 := net.Pipe()
+	ch := make(chan *Conn)
+	go func() {
+		conn, err := Passive(c2, WithSession(1, echo), WithSession(2, echo))
+		assert.NoError(t, err)
+		ch <- conn
+	}()
+
+	// not registered session entity
+	c1.Write(control(3, 0, 0, 1, 1))
+	assert.Equal(t, control(3, 4, 0, 2, 1), readRaw(t, c1))
+	c1.Write(control(1, 0, 0, 1, 2))
+	assert.Equal(t, control(1, 0, 0, 2, 2), readRaw(t, c1))
+	conn := <-ch
+	defer conn.Close()
+
+	// data message of selected, not selected, and not registered session entities
+	c1.Write(ast.NewHSMSDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode(), 1, []byte{0, 0, 0, 3}).ToBytes())
+	assert.Equal(t, ast.NewHSMSDataMessage("", 1, 2, 0, "H<->E", ast.NewEmptyItemNode(), 1, []byte{0, 0, 0, 3}).ToBytes(), readRaw(t, c1))
+	c1.Write(ast.NewHSMSDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode(), 2, []byte{0, 0, 0, 4}).ToBytes())
+	assert.Equal(t, control(2, 4, 0, 7, 4), readRaw(t, c1))
+	c1.Write(ast.NewHSMSDataMessage("", 1, 2, 0, "H->E", ast.NewEmptyItemNode(), 5, []byte{0, 0, 0, 5}).ToBytes())
+	assert.Equal(t, control(5, 4, 0, 7, 5), readRaw(t, c1))
+
+	// deselect.req
+	c1.Write(control(2, 0, 0, 3, 6))
+	assert.Equal(t, control(2, 1, 0, 4, 6), readRaw(t, c1))
+	c1.Write(control(1, 0, 0, 3, 7))
+	assert.Equal(t, control(1, 0, 0, 4, 7), readRaw(t, c1))
+	assert.False(t, conn.Session(1).Selected())
+	select {
+	case <-conn.Done():
+		assert.Fail(t, "connection closed")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
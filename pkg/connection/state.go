@@ -0,0 +1,158 @@
+package connection
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// State is a state of a HSMS connection.
+type State int
+
+// States of a HSMS connection.
+const (
+	StateConnecting   State = iota // network connection is being established
+	StateConnected                 // network connection is established, but not selected
+	StateSelected                  // connection is selected
+	StateDeselected                // connection is deselected, but not closed
+	StateSeparated                 // separate.req is received, and the connection is being closed
+	StateDisconnected              // network connection is lost, or cannot be established
+	StateClosed                    // connection is closed, and will not be connected again
+)
+
+// stateNames is the names of the states.
+var stateNames = map[State]string{
+	StateConnecting:   "connecting",
+	StateConnected:    "connected",
+	StateSelected:     "selected",
+	StateDeselected:   "deselected",
+	StateSeparated:    "separated",
+	StateDisconnected: "disconnected",
+	StateClosed:       "closed",
+}
+
+// String returns the name of the state, e.g. "selected".
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Snapshot is the status of a HSMS connection at a time.
+type Snapshot struct {
+	State        State     // current state
+	RemoteAddr   net.Addr  // network address of the remote entity
+	Transactions int       // number of the open transactions, which wait for their reply or response
+	LastActivity time.Time // time of the last message sent or received
+}
+
+// StateEvent is a change of the state of a HSMS connection.
+type StateEvent struct {
+	State State     // new state
+	Time  time.Time // time of the change
+	Err   error     // cause of the change, e.g. a network error; nil if there is no error
+}
+
+// String returns the string representation of the event, e.g. "disconnected: EOF".
+func (e StateEvent) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.State, e.Err)
+	}
+	return e.State.String()
+}
+
+// subscribers is the functions subscribed to the state events, which is safe for concurrent use.
+type subscribers struct {
+	mu     sync.Mutex
+	nextID int
+	ids    []int
+	fns    map[int]func(StateEvent)
+}
+
+// add subscribes the function to the state events, and returns a function that unsubscribes it.
+func (s *subscribers) add(fn func(StateEvent)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fns == nil {
+		s.fns = map[int]func(StateEvent){}
+	}
+	id := s.nextID
+	s.nextID++
+	s.ids = append(s.ids, id)
+	s.fns[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.fns, id)
+	}
+}
+
+// emit calls the subscribed functions with the state event, in the order of subscription.
+func (s *subscribers) emit(e StateEvent) {
+	s.mu.Lock()
+	fns := make([]func(StateEvent), 0, len(s.fns))
+	ids := s.ids[:0]
+	for _, id := range s.ids {
+		if fn, ok := s.fns[id]; ok {
+			fns = append(fns, fn)
+			ids = append(ids, id)
+		}
+	}
+	s.ids = ids
+	s.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(e)
+	}
+}
+
+// eventQueue delivers the state events to the subscribers in order, in a separate goroutine,
+// so that the subscribers can use the connection.
+type eventQueue struct {
+	subscribers
+
+	mu     sync.Mutex    // guards events
+	events []StateEvent  // events not delivered yet
+	signal chan struct{} // signals new events, with buffer size 1
+}
+
+// newEventQueue creates a event queue with the subscribers, and starts delivering the events.
+// The delivery ends with StateClosed.
+func newEventQueue(fns []func(StateEvent)) *eventQueue {
+	q := &eventQueue{signal: make(chan struct{}, 1)}
+	for _, fn := range fns {
+		q.add(fn)
+	}
+	go q.run()
+	return q
+}
+
+// push adds the state event to the queue.
+func (q *eventQueue) push(e StateEvent) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the events until StateClosed is delivered.
+func (q *eventQueue) run() {
+	for range q.signal {
+		q.mu.Lock()
+		events := q.events
+		q.events = nil
+		q.mu.Unlock()
+
+		for _, e := range events {
+			q.emit(e)
+			if e.State == StateClosed {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package connection
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests the state events and the snapshots of the connections
+//
+// Testing Strategy:
+//
+// Subscribe to the state events of connections, change their state by control messages,
+// and test the received state events and the snapshots.
+//
+// Partitions:
+//
+// - Subscription: WithStateHandler, Subscribe, unsubscribed
+// - Transition: connected, selected, deselected, separated, closed
+// - Cause of the close: Close, separate.req, T7 timeout, network error, dial error
+// - Snapshot: open transactions 0, >0; last activity: sent, received
+
+// collect returns a state handler that sends the events to the returned channel.
+func collect() (func(StateEvent), chan StateEvent) {
+	ch := make(chan StateEvent, 100)
+	return func(e StateEvent) { ch <- e }, ch
+}
+
+func TestState_Events(t *testing.T) {
+	activeHandler, activeEvents := collect()
+	passiveHandler, passiveEvents := collect()
+	active, passive := pair(t, []Option{WithStateHandler(activeHandler)}, []Option{WithStateHandler(passiveHandler)})
+
+	expectEvents(t, activeEvents, "connected", "selected")
+	expectEvents(t, passiveEvents, "connected", "selected")
+
+	// subscribe and unsubscribe
+	subscribed, subscribedEvents := collect()
+	unsubscribe := active.Subscribe(subscribed)
+
+	// deselect and select
+	systemBytes := active.nextSystemBytes()
+	_, err := active.transact(ast.NewHSMSMessageDeselectReq(controlSessionID, systemBytes), systemBytes, time.Second, ErrT6Timeout)
+	assert.NoError(t, err)
+	expectEvents(t, activeEvents, "deselected")
+	expectEvents(t, passiveEvents, "deselected")
+	expectEvents(t, subscribedEvents, "deselected")
+	unsubscribe()
+
+	systemBytes = passive.nextSystemBytes()
+	_, err = passive.transact(ast.NewHSMSMessageSelectReq(controlSessionID, systemBytes), systemBytes, time.Second, ErrT6Timeout)
+	assert.NoError(t, err)
+	expectEvents(t, activeEvents, "selected")
+	expectEvents(t, passiveEvents, "selected")
+
+	// separate
+	active.Close()
+	events := expectEvents(t, activeEvents, "closed: connection closed")
+	expectEvents(t, passiveEvents, "separated: separate.req received", "closed: separate.req received")
+	if len(events) == 1 {
+		assert.WithinDuration(t, time.Now(), events[0].Time, time.Second)
+	}
+	assert.Empty(t, subscribedEvents)
+}
+
+func TestState_Causes(t *testing.T) {
+	// T7 timeout
+	handler, events := collect()
+	_, c2 := net.Pipe()
+	_, err := Passive(c2, WithT7(10*time.Millisecond), WithStateHandler(handler))
+	assert.Equal(t, ErrT7Timeout, err)
+	expectEvents(t, events, "connected", "closed: T7 timeout")
+
+	// network error
+	handler, events = collect()
+	c1, c2 := net.Pipe()
+	conn := newConn(c2, newOptions([]Option{WithStateHandler(handler)}))
+	c1.Close()
+	<-conn.Done()
+	expectEvents(t, events, "connected", "closed: EOF")
+
+	// dial error
+	handler, events = collect()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := l.Addr().String()
+	l.Close()
+	_, err = Dial(address, WithStateHandler(handler))
+	assert.Error(t, err)
+	expectEvents(t, events, "connecting")
+	e := <-events
+	assert.Equal(t, StateClosed, e.State)
+	assert.Equal(t, err, e.Err)
+
+	assert.Equal(t, "State(100)", State(100).String())
+	assert.Equal(t, "disconnected: EOF", StateEvent{StateDisconnected, time.Now(), errors.New("EOF")}.String())
+}
+
+func TestState_Snapshot(t *testing.T) {
+	router := NewRouter()
+	block := make(chan struct{})
+	router.HandleFunc("S1F1", func(w ReplyWriter, msg *ast.DataMessage) {
+		<-block
+		echo.ServeSECS(w, msg)
+	})
+	active, passive := pair(t, nil, []Option{WithHandler(router)})
+	defer active.Close()
+	defer passive.Close()
+
+	snapshot := active.Snapshot()
+	assert.Equal(t, StateSelected, snapshot.State)
+	assert.Equal(t, 0, snapshot.Transactions)
+	assert.Equal(t, "pipe", snapshot.RemoteAddr.String())
+
+	start := time.Now()
+	go active.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+	time.Sleep(20 * time.Millisecond)
+	snapshot = active.Snapshot()
+	assert.Equal(t, 1, snapshot.Transactions)
+	assert.True(t, !snapshot.LastActivity.Before(start))
+
+	close(block)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 0, active.Snapshot().Transactions)
+	assert.True(t, active.Snapshot().LastActivity.After(snapshot.LastActivity))
+
+	active.Close()
+	assert.Equal(t, StateClosed, active.Snapshot().State)
+}
@@ -0,0 +1,327 @@
+package connection
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Errors of the supervisor queue.
+var (
+	ErrQueueFull = errors.New("send queue full")
+	ErrDropped   = errors.New("message dropped from send queue")
+)
+
+// DropPolicy decides which message is dropped when the send queue of a supervisor is full.
+type DropPolicy int
+
+// Drop policies of the send queue.
+const (
+	DropNewest DropPolicy = iota // the message being sent is not queued, and fails with ErrQueueFull
+	DropOldest                   // the oldest queued message is dropped, and fails with ErrDropped
+)
+
+// Supervisor keeps a HSMS connection in the active mode connected. When the connection is lost,
+// the supervisor dials the address again and sends select.req, until it is closed.
+//
+// Successive connect attempts are separated by T5, and by a exponential backoff with jitter
+// after consecutive failures. The supervisor emits a state event for each state change,
+// e.g. StateDisconnected with the cause, to the subscribers.
+//
+// Messages sent with the supervisor while disconnected fail with ErrNotSelected, or are queued
+// with the WithQueue option and sent in order after the connection is selected again.
+type Supervisor struct {
+	address  string   // address to dial
+	connOpts []Option // options of the connections
+
+	t5         time.Duration // connect separation timeout
+	maxDelay   time.Duration // maximum delay of the backoff
+	multiplier float64       // multiplier of the backoff delay for each consecutive failure
+	jitter     float64       // maximum fraction of the delay added or subtracted randomly
+	queueSize  int           // capacity of the send queue; 0 to disable the queue
+	dropPolicy DropPolicy    // drop policy of the send queue
+	dial       func(address string) (net.Conn, error)
+	clock      Clock // clock of the connections
+
+	subs subscribers // subscribers of the state events
+
+	mu      sync.Mutex    // guards the fields below
+	conn    *Conn         // current connection; nil if not selected
+	queue   []*queuedItem // messages waiting for the connection, the oldest first
+	started bool          // true if the supervisor is started
+	closed  bool          // true if the supervisor is closed
+	stop    chan struct{} // closed when the supervisor is closed
+	rand    *rand.Rand    // random source of the jitter
+}
+
+// queuedItem is a message in the send queue, with the channel of its send result.
+type queuedItem struct {
+	msg    *ast.DataMessage
+	result chan sendResult
+}
+
+// sendResult is the result of Send.
+type sendResult struct {
+	reply *ast.DataMessage
+	err   error
+}
+
+// SupervisorOption is a option of the Supervisor.
+type SupervisorOption func(*Supervisor)
+
+// WithT5 returns a supervisor option that sets the connect separation timeout, which is the minimum
+// time between successive connect attempts. The default is 10 seconds.
+func WithT5(d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.t5 = d
+	}
+}
+
+// WithBackoff returns a supervisor option that sets the exponential backoff of the connect attempts.
+// After n consecutive failures, the next attempt is delayed by T5 * multiplier^(n-1), up to max,
+// which is randomly changed by the jitter fraction, e.g. 0.1 for ±10%. The delay is at least T5.
+// The default is 2 minutes max, multiplier 2 and jitter 0.1.
+func WithBackoff(max time.Duration, multiplier, jitter float64) SupervisorOption {
+	return func(s *Supervisor) {
+		s.maxDelay, s.multiplier, s.jitter = max, multiplier, jitter
+	}
+}
+
+// WithQueue returns a supervisor option that queues the messages sent while disconnected, up to
+// the size. When the queue is full, a message is dropped by the policy. The queue is disabled by default.
+func WithQueue(size int, policy DropPolicy) SupervisorOption {
+	return func(s *Supervisor) {
+		s.queueSize, s.dropPolicy = size, policy
+	}
+}
+
+// WithDialer returns a supervisor option that sets the function to establish the network connections.
+// The default dials the TCP address.
+func WithDialer(dial func(address string) (net.Conn, error)) SupervisorOption {
+	return func(s *Supervisor) {
+		s.dial = dial
+	}
+}
+
+// NewSupervisor creates a supervisor of the connection to the address in the active mode.
+// The connection options are applied to each connection, and the clock of the connection options
+// is used for T5 and the backoff. Call Start to connect.
+func NewSupervisor(address string, connOpts []Option, opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		address:    address,
+		connOpts:   connOpts,
+		t5:         10 * time.Second,
+		maxDelay:   2 * time.Minute,
+		multiplier: 2,
+		jitter:     0.1,
+		dial: func(address string) (net.Conn, error) {
+			return net.Dial("tcp", address)
+		},
+		stop: make(chan struct{}),
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.clock = newOptions(connOpts).clock
+	return s
+}
+
+// Start starts connecting in a new goroutine. Start does nothing if the supervisor is already started.
+func (s *Supervisor) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+	go s.loop()
+}
+
+// Subscribe registers the function to be called with the state events of the supervisor, and returns
+// a function that unregisters it. The function is called in order of the events, and should not block.
+func (s *Supervisor) Subscribe(fn func(StateEvent)) (unsubscribe func()) {
+	return s.subs.add(fn)
+}
+
+// Conn returns the current connection, or nil if the supervisor is not connected.
+func (s *Supervisor) Conn() *Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+// Send sends the data message with the current connection. Refer to Conn.Send.
+//
+// If the supervisor is not connected, or messages are waiting in the queue, the message is queued
+// and Send waits until it is sent with the next connection, if the queue is enabled.
+// Otherwise, Send fails with ErrNotSelected.
+func (s *Supervisor) Send(msg *ast.DataMessage) (*ast.DataMessage, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if conn := s.conn; conn != nil && len(s.queue) == 0 {
+		s.mu.Unlock()
+		return conn.Send(msg)
+	}
+	if s.queueSize <= 0 {
+		s.mu.Unlock()
+		return nil, ErrNotSelected
+	}
+	if len(s.queue) >= s.queueSize {
+		if s.dropPolicy == DropNewest {
+			s.mu.Unlock()
+			return nil, ErrQueueFull
+		}
+		s.queue[0].result <- sendResult{nil, ErrDropped}
+		s.queue = s.queue[1:]
+	}
+	item := &queuedItem{msg, make(chan sendResult, 1)}
+	s.queue = append(s.queue, item)
+	s.mu.Unlock()
+
+	result := <-item.result
+	return result.reply, result.err
+}
+
+// Close stops connecting, and closes the current connection. Queued messages fail with ErrClosed.
+// A connect attempt in progress is closed when it is completed.
+func (s *Supervisor) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	for _, item := range s.queue {
+		item.result <- sendResult{nil, ErrClosed}
+	}
+	s.queue = nil
+	started := s.started
+	s.mu.Unlock()
+
+	if !started {
+		s.emit(StateClosed, ErrClosed)
+	}
+	return nil
+}
+
+// Done returns a channel that is closed when the supervisor is closed.
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.stop
+}
+
+// loop connects until the supervisor is closed.
+func (s *Supervisor) loop() {
+	failures := 0
+	for {
+		start := s.clock.Now()
+		conn, err := s.connect()
+		if err == nil {
+			failures = 0
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				conn.Close()
+				s.emit(StateClosed, ErrClosed)
+				return
+			}
+			s.conn = conn
+			s.mu.Unlock()
+			go s.flush(conn)
+
+			<-conn.Done()
+			err = conn.Err()
+			s.mu.Lock()
+			s.conn = nil
+			s.mu.Unlock()
+		} else {
+			failures++
+		}
+
+		select {
+		case <-s.stop:
+			s.emit(StateClosed, ErrClosed)
+			return
+		default:
+		}
+		s.emit(StateDisconnected, err)
+
+		timer := s.clock.NewTimer(s.delay(failures) - s.clock.Now().Sub(start))
+		select {
+		case <-timer.C():
+		case <-s.stop:
+			timer.Stop()
+			s.emit(StateClosed, ErrClosed)
+			return
+		}
+	}
+}
+
+// connect dials the address and selects the connection, emitting the state events.
+func (s *Supervisor) connect() (*Conn, error) {
+	s.emit(StateConnecting, nil)
+	c, err := s.dial(s.address)
+	if err != nil {
+		return nil, err
+	}
+	s.emit(StateConnected, nil)
+	conn, err := Active(c, s.connOpts...)
+	if err != nil {
+		return nil, err
+	}
+	s.emit(StateSelected, nil)
+	return conn, nil
+}
+
+// delay returns the minimum time from the start of a connect attempt to the next attempt,
+// after the number of consecutive failures.
+func (s *Supervisor) delay(failures int) time.Duration {
+	if failures == 0 {
+		return s.t5
+	}
+	d := float64(s.t5) * math.Pow(s.multiplier, float64(failures-1))
+	if max := float64(s.maxDelay); d > max {
+		d = max
+	}
+	s.mu.Lock()
+	d *= 1 + s.jitter*(2*s.rand.Float64()-1)
+	s.mu.Unlock()
+	if d < float64(s.t5) {
+		return s.t5
+	}
+	return time.Duration(d)
+}
+
+// flush sends the queued messages with the connection in order, while the connection is current.
+func (s *Supervisor) flush(conn *Conn) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 || s.conn != conn {
+			s.mu.Unlock()
+			return
+		}
+		item := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		reply, err := conn.Send(item.msg)
+		item.result <- sendResult{reply, err}
+	}
+}
+
+// emit emits the state event with the cause to the subscribers.
+func (s *Supervisor) emit(state State, err error) {
+	s.subs.emit(StateEvent{state, s.clock.Now(), err})
+}
@@ -0,0 +1,217 @@
+package connection
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests the supervisor of active connections
+//
+// Testing Strategy:
+//
+// Supervise connections to passive connections on in-memory network connections, created by
+// a fake dialer that can fail or block. Disconnect the connections, send messages, and test
+// the state events, the delays of the connect attempts, and the results of the sent messages.
+//
+// Partitions:
+//
+// - Connect attempt: succeeds, fails; consecutive failures: 0, 1, >1
+// - Disconnect: by the remote entity, by Close
+// - Send: connected; disconnected without queue, with queue; queue not full, full
+// - Drop policy: DropNewest, DropOldest
+// - Backoff: below max, above max; jitter: 0, >0
+
+// fakeDialer is a dialer of in-memory network connections to passive connections with the echo handler.
+type fakeDialer struct {
+	fail  int           // number of connect attempts to fail
+	gate  chan struct{} // if not nil, each connect attempt waits for a value
+	conns chan *Conn    // passive connections
+}
+
+func newFakeDialer(fail int) *fakeDialer {
+	return &fakeDialer{fail: fail, conns: make(chan *Conn, 10)}
+}
+
+func (d *fakeDialer) dial(address string) (net.Conn, error) {
+	if d.gate != nil {
+		<-d.gate
+	}
+	if d.fail > 0 {
+		d.fail--
+		return nil, errors.New("connection refused")
+	}
+	c1, c2 := net.Pipe()
+	go func() {
+		if conn, err := Passive(c2, WithHandler(echo)); err == nil {
+			d.conns <- conn
+		}
+	}()
+	return c1, nil
+}
+
+// expectEvents receives the state events from the channel, and tests their states and causes.
+func expectEvents(t *testing.T, events <-chan StateEvent, expected ...string) []StateEvent {
+	result := []StateEvent{}
+	for _, e := range expected {
+		select {
+		case event := <-events:
+			assert.Equal(t, e, event.String())
+			result = append(result, event)
+		case <-time.After(time.Second):
+			assert.Fail(t, "state event not received", e)
+			return result
+		}
+	}
+	return result
+}
+
+// queueLen returns the number of the queued messages of the supervisor.
+func queueLen(s *Supervisor) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+func TestSupervisor_Reconnect(t *testing.T) {
+	dialer := newFakeDialer(2)
+	s := NewSupervisor("equipment", nil, WithDialer(dialer.dial), WithT5(20*time.Millisecond), WithBackoff(time.Second, 3, 0))
+	events := make(chan StateEvent, 100)
+	s.Subscribe(func(e StateEvent) { events <- e })
+	s.Start()
+
+	// consecutive failures
+	result := expectEvents(t, events,
+		"connecting", "disconnected: connection refused",
+		"connecting", "disconnected: connection refused",
+		"connecting", "connected", "selected")
+	if len(result) == 7 {
+		assert.GreaterOrEqual(t, int64(result[2].Time.Sub(result[0].Time)), int64(20*time.Millisecond))
+		assert.GreaterOrEqual(t, int64(result[4].Time.Sub(result[2].Time)), int64(60*time.Millisecond))
+	}
+	assert.NotNil(t, s.Conn())
+
+	reply, err := s.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	assert.Equal(t, "S1F2 H<->E", reply.Header())
+
+	// disconnected by the remote entity
+	(<-dialer.conns).Close()
+	expectEvents(t, events, "disconnected: separate.req received", "connecting", "connected", "selected")
+
+	// closed
+	s.Close()
+	expectEvents(t, events, "closed: connection closed")
+	assert.Nil(t, s.Conn())
+	_, err = s.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.Equal(t, ErrClosed, err)
+	<-s.Done()
+	select {
+	case <-(<-dialer.conns).Done():
+	case <-time.After(time.Second):
+		assert.Fail(t, "connection not closed")
+	}
+}
+
+func TestSupervisor_Queue(t *testing.T) {
+	msg := func(function int) *ast.DataMessage {
+		return ast.NewDataMessage("", 1, function, 1, "H->E", ast.NewEmptyItemNode())
+	}
+	type result struct {
+		reply *ast.DataMessage
+		err   error
+	}
+	send := func(s *Supervisor, function int) chan result {
+		ch := make(chan result, 1)
+		n := queueLen(s)
+		go func() {
+			reply, err := s.Send(msg(function))
+			ch <- result{reply, err}
+		}()
+		for i := 0; i < 100 && queueLen(s) == n; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		return ch
+	}
+
+	for _, policy := range []DropPolicy{DropOldest, DropNewest} {
+		dialer := newFakeDialer(0)
+		dialer.gate = make(chan struct{})
+		s := NewSupervisor("equipment", nil, WithDialer(dialer.dial), WithT5(time.Millisecond), WithQueue(2, policy))
+		s.Start()
+
+		first, second, third := send(s, 1), send(s, 3), send(s, 5)
+		assert.Equal(t, 2, queueLen(s))
+		dialer.gate <- struct{}{}
+
+		switch policy {
+		case DropOldest:
+			assert.Equal(t, ErrDropped, (<-first).err)
+			r := <-second
+			assert.NoError(t, r.err)
+			assert.Equal(t, 4, r.reply.FunctionCode())
+			r = <-third
+			assert.NoError(t, r.err)
+			assert.Equal(t, 6, r.reply.FunctionCode())
+		case DropNewest:
+			assert.Equal(t, ErrQueueFull, (<-third).err)
+			r := <-first
+			assert.NoError(t, r.err)
+			assert.Equal(t, 2, r.reply.FunctionCode())
+			r = <-second
+			assert.NoError(t, r.err)
+			assert.Equal(t, 4, r.reply.FunctionCode())
+		}
+
+		// queued messages fail when closed
+		(<-dialer.conns).Close()
+		for i := 0; i < 100 && s.Conn() != nil; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		pending := send(s, 7)
+		s.Close()
+		assert.Equal(t, ErrClosed, (<-pending).err)
+		close(dialer.gate)
+	}
+
+	// without queue
+	dialer := newFakeDialer(0)
+	dialer.gate = make(chan struct{})
+	s := NewSupervisor("equipment", nil, WithDialer(dialer.dial))
+	s.Start()
+	_, err := s.Send(msg(1))
+	assert.Equal(t, ErrNotSelected, err)
+	close(dialer.gate)
+	s.Close()
+}
+
+func TestSupervisor_Delay(t *testing.T) {
+	s := NewSupervisor("equipment", nil, WithT5(time.Second), WithBackoff(10*time.Second, 2, 0))
+	var tests = []struct {
+		failures int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, s.delay(test.failures), "failures %d", test.failures)
+	}
+
+	s = NewSupervisor("equipment", nil, WithT5(time.Second), WithBackoff(10*time.Second, 2, 0.5))
+	for i := 0; i < 100; i++ {
+		d := s.delay(3)
+		assert.GreaterOrEqual(t, int64(d), int64(2*time.Second))
+		assert.LessOrEqual(t, int64(d), int64(6*time.Second))
+		assert.GreaterOrEqual(t, int64(s.delay(1)), int64(time.Second))
+	}
+}
@@ -0,0 +1,177 @@
+// Package e5 contains a built-in message dictionary of the standard messages in SEMI E5,
+// such as S1F13 EstablishCommunicationsRequest, so that the standard messages can be
+// instantiated and validated without writing SML.
+//
+// The dictionary contains the messages of streams 1, 2, 5, 6, 7, 9 and 10 commonly used
+// by equipment, e.g. S6F11 EventReportSend; streams 3 and 4, material status and control,
+// and the other messages in the streams, e.g. S2F45 DefineVariableLimitAttributes, are not included.
+//
+// The dictionary is written in SML, and loaded into a message library; refer to the library
+// package for looking up the messages, their secondary messages, and instantiating them.
+// Variables are named after the data items in SEMI E5, e.g. MDLN, and their sizes are limited
+// as in SEMI E5. A data item with several formats allowed, e.g. SVID, is a variable in a list,
+// which is filled in with a item node, e.g. map[string]interface{}{"SVID": ast.NewUintNode(4, 1)}.
+// Such a data item that is the only data item of a message has the most common format,
+// e.g. U4 for CEID of S6F15, and A for PPID of S7F5.
+//
+// Messages whose structure depends on the direction are defined once for each direction,
+// where the name of the message sent by the host has the suffix "Host",
+// e.g. EstablishCommunicationsRequestHost is S1F13 W H->E <L>.
+//
+// TIME and STIME are either 12 characters, YYMMDDhhmmss, or 16 characters, YYYYMMDDhhmmsscc.
+// Messages containing them are defined once for each length, where the name of the message
+// with 16 characters has the suffix "16", e.g. DateAndTimeData16 is S2F18 H<->E <A[16] TIME>.
+package e5
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/library"
+)
+
+// Version is the version of the dictionary, which is changed when the standard messages
+// in the dictionary are added or changed.
+const Version = "1.1.0"
+
+//go:embed e5.sml
+var source string
+
+// lib is the message library of the dictionary.
+var lib = load(source)
+
+// load creates the message library from the SML input. Panics if the input has errors.
+func load(input string) *library.MessageLibrary {
+	lib, errs, _ := library.ParseMessageLibrary(input)
+	if len(errs) != 0 {
+		panic("invalid E5 dictionary: " + strings.Join(errs, "; "))
+	}
+	return lib
+}
+
+// SML returns the dictionary in SML, which can be used as a base of a equipment specific dictionary.
+func SML() string {
+	return source
+}
+
+// Library returns the message library of the standard messages.
+func Library() *library.MessageLibrary {
+	return lib
+}
+
+// Instantiate returns a new standard message with the name, with the values filled into the variables.
+// Refer to library.MessageLibrary.Instantiate for the details.
+func Instantiate(name string, values map[string]interface{}) (*ast.DataMessage, error) {
+	return lib.Instantiate(name, values)
+}
+
+// Validate checks the message against the standard messages with the same stream code,
+// function code and compatible direction.
+//
+// The message should have the wait bit of the standard message, if it is not optional,
+// and its data item should match the data item of the standard message as in ast.Match;
+// a list with ellipsis in the standard message matches a list with the items before the ellipsis
+// repeated any number of times.
+//
+// An error is returned if the message is not a standard message, contains variables,
+// or doesn't match any of the standard messages.
+func Validate(msg *ast.DataMessage) error {
+	if len(msg.Variables()) != 0 {
+		return fmt.Errorf("message contains variables")
+	}
+	candidates := lib.Lookup(msg.StreamCode(), msg.FunctionCode(), msg.Direction())
+	if len(candidates) == 0 {
+		return fmt.Errorf("S%dF%d %s is not a standard message", msg.StreamCode(), msg.FunctionCode(), msg.Direction())
+	}
+
+	var firstErr error
+	for _, std := range candidates {
+		err := validate(std, msg)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s: %v", std.Header(), err)
+		}
+	}
+	return firstErr
+}
+
+// validate checks the message against the standard message.
+func validate(std, msg *ast.DataMessage) error {
+	if std.WaitBit() != "optional" && msg.WaitBit() != "optional" && std.WaitBit() != msg.WaitBit() {
+		return fmt.Errorf("expected wait bit %s, found %s", std.WaitBit(), msg.WaitBit())
+	}
+	switch {
+	case std.Item() == nil && msg.Item() == nil:
+		return nil
+	case std.Item() == nil:
+		return fmt.Errorf("unexpected data item")
+	case msg.Item() == nil:
+		return fmt.Errorf("missing data item")
+	}
+	return validateItem(std.Item(), msg.Item(), "")
+}
+
+// validateItem checks the item node against the template of the standard message.
+// path is the position of the item node in the message, e.g. "2.1".
+func validateItem(template, item ast.ItemNode, path string) error {
+	list, ok := template.(*ast.ListNode)
+	itemList, isList := item.(*ast.ListNode)
+	if !ok || !isList {
+		if _, err := ast.Match(template, item); err != nil {
+			return itemError(path, err)
+		}
+		return nil
+	}
+
+	templateValues, ellipsis := splitEllipsis(list.Values())
+	itemValues := itemList.Values()
+	n := len(templateValues)
+	switch {
+	case !ellipsis && n != len(itemValues):
+		return itemError(path, fmt.Errorf("expected L[%d] item, found L[%d] item", n, len(itemValues)))
+	case ellipsis && (n == 0 || len(itemValues)%n != 0):
+		return itemError(path, fmt.Errorf("expected L item with a multiple of %d items, found L[%d] item", n, len(itemValues)))
+	}
+
+	for i, v := range itemValues {
+		child, ok := templateValues[i%n].(ast.ItemNode)
+		if !ok {
+			continue
+		}
+		if err := validateItem(child, v.(ast.ItemNode), childPath(path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitEllipsis returns the values of a list before the ellipsis, and whether the ellipsis exists.
+func splitEllipsis(values []interface{}) ([]interface{}, bool) {
+	for i, v := range values {
+		if name, ok := v.(string); ok && strings.HasPrefix(name, "...") {
+			return values[:i], true
+		}
+	}
+	return values, false
+}
+
+// itemError returns the error with the position of the item node.
+func itemError(path string, err error) error {
+	if path == "" {
+		return err
+	}
+	return fmt.Errorf("item %s: %v", path, err)
+}
+
+// childPath returns the path of the i-th (zero-based) child in the list at the path.
+func childPath(path string, i int) string {
+	if path == "" {
+		return strconv.Itoa(i + 1)
+	}
+	return path + "." + strconv.Itoa(i+1)
+}
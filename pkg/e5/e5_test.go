@@ -0,0 +1,184 @@
+package e5
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+// Tests the E5 dictionary
+//
+// Testing Strategy:
+//
+// Look up the standard messages in the dictionary, instantiate them, and validate
+// messages parsed from SML against the dictionary.
+//
+// Partitions:
+//
+// - Message: primary with wait bit, primary with optional wait bit, secondary, header only
+// - Direction: H->E, H<-E, H<->E; structure depends on the direction or not
+// - Validate: matching message, not a standard message, message with variables, different wait bit,
+//             missing/unexpected data item, different data item type, size out of the limits,
+//             list with ellipsis, variable in list
+// - Dictionary: embedded, invalid input, streams of the messages
+
+func TestE5_Library(t *testing.T) {
+	assert.NotEmpty(t, Version)
+	assert.NotEmpty(t, SML())
+
+	lib := Library()
+	for _, msg := range lib.Messages() {
+		if msg.FunctionCode()%2 == 1 && msg.WaitBit() != "false" {
+			_, ok := lib.Secondary(msg.Name())
+			assert.True(t, ok, msg.Header())
+		}
+	}
+
+	msg, ok := lib.Message("EstablishCommunicationsRequest")
+	assert.True(t, ok)
+	assert.Equal(t, "S1F13 W H<-E EstablishCommunicationsRequest", msg.Header())
+	reply, ok := lib.Secondary("EstablishCommunicationsRequest")
+	assert.True(t, ok)
+	assert.Equal(t, "S1F14 H->E EstablishCommunicationsRequestAcknowledgeHost", reply.Header())
+	reply, ok = lib.Secondary("EstablishCommunicationsRequestHost")
+	assert.True(t, ok)
+	assert.Equal(t, "S1F14 H<-E EstablishCommunicationsRequestAcknowledge", reply.Header())
+
+	streams := map[int]bool{}
+	for _, msg := range lib.Messages() {
+		streams[msg.StreamCode()] = true
+	}
+	assert.Equal(t, map[int]bool{1: true, 2: true, 5: true, 6: true, 7: true, 9: true, 10: true}, streams)
+
+	assert.Panics(t, func() { load("S1F1 W H->E <L") })
+}
+
+func TestE5_Instantiate(t *testing.T) {
+	msg, err := Instantiate("EstablishCommunicationsRequest", map[string]interface{}{"MDLN": "model", "SOFTREV": "1.0.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "S1F13 W H<-E EstablishCommunicationsRequest\n<L[2]\n  <A \"model\">\n  <A \"1.0.0\">\n>\n.", msg.String())
+	assert.NoError(t, Validate(msg))
+
+	msg, err = Instantiate("SelectedEquipmentStatusRequest", map[string]interface{}{
+		"...[0]": 1, "SVID[0]": ast.NewUintNode(4, 1), "SVID[1]": ast.NewUintNode(4, 2),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "S1F3 W H->E SelectedEquipmentStatusRequest\n<L[2]\n  <U4[1] 1>\n  <U4[1] 2>\n>\n.", msg.String())
+
+	_, err = Instantiate("EstablishCommunicationsRequest", map[string]interface{}{"MDLN": "model-name-longer-than-20", "SOFTREV": ""})
+	assert.Error(t, err)
+	_, err = Instantiate("Unknown", nil)
+	assert.EqualError(t, err, `message "Unknown" not found`)
+}
+
+func TestE5_Validate(t *testing.T) {
+	var tests = []struct {
+		description   string // Test case description
+		input         string // Input message in SML
+		expectedError string // expected error text, empty if no error
+	}{
+		{
+			description: "header only",
+			input:       "S1F1 W H->E .",
+		},
+		{
+			description: "structure depends on the direction",
+			input:       `S1F14 H<-E <L <B 0> <L <A "model"> <A "1.0">>> .`,
+		},
+		{
+			description: "H<->E matches any of the standard messages",
+			input:       "S1F13 W H<->E <L> .",
+		},
+		{
+			description: "list with ellipsis and variables in list",
+			input:       `S6F11 W H<-E <L <U4 1> <U2 100> <L <L <U4 10> <L <A "V"> <F4 1.5>>> <L <U4 11> <L>>>> .`,
+		},
+		{
+			description: "optional wait bit",
+			input:       `S5F1 H<-E <L <B 0x81> <U4 1> <A "alarm">> .`,
+		},
+		{
+			description: "array variable and list with nested ellipsis",
+			input:       `S5F5 W H->E <U4 1 2 3> . S6F18 H<-E <L <U4 1> <U4 10> <L <L <U4 100> <L <L <U4 1000> <F4 1.5>>>>>> .`,
+		},
+		{
+			description:   "top-level data item in the most common format",
+			input:         `S6F15 W H->E <U2 10> .`,
+			expectedError: "S6F15 W H->E EventReportRequest: expected U4 item, found U2 item",
+		},
+		{
+			description:   "not a standard message",
+			input:         "S3F1 W H->E .",
+			expectedError: "S3F1 H->E is not a standard message",
+		},
+		{
+			description:   "not a standard message",
+			input:         "S64F1 W H->E .",
+			expectedError: "S64F1 H->E is not a standard message",
+		},
+		{
+			description:   "message with variables",
+			input:         "S1F13 W H<-E <L <A MDLN> <A SOFTREV>> .",
+			expectedError: "message contains variables",
+		},
+		{
+			description:   "different wait bit",
+			input:         "S1F13 H->E <L> .",
+			expectedError: "S1F13 W H->E EstablishCommunicationsRequestHost: expected wait bit true, found false",
+		},
+		{
+			description:   "unexpected data item",
+			input:         "S1F1 W H->E <L> .",
+			expectedError: "S1F1 W H<->E AreYouThere: unexpected data item",
+		},
+		{
+			description:   "missing data item",
+			input:         "S1F16 H<-E .",
+			expectedError: "S1F16 H<-E OffLineAcknowledge: missing data item",
+		},
+		{
+			description:   "different data item type",
+			input:         `S1F13 W H<-E <L <A "model"> <U4 1>> .`,
+			expectedError: "S1F13 W H<-E EstablishCommunicationsRequest: item 2: expected A item, found U4 item",
+		},
+		{
+			description:   "size out of the limits",
+			input:         `S2F18 H<-E <A "2021"> .`,
+			expectedError: `S2F18 H<->E DateAndTimeData: string length out of range: "2021"`,
+		},
+		{
+			description: "TIME of 12 and 16 characters",
+			input:       `S2F18 H<-E <A "211018123000"> . S2F18 H<-E <A "2021101812300000"> .`,
+		},
+		{
+			description:   "TIME of 14 characters",
+			input:         `S2F18 H<-E <A "20211018123000"> .`,
+			expectedError: `S2F18 H<->E DateAndTimeData: string length out of range: "20211018123000"`,
+		},
+		{
+			description:   "list size",
+			input:         `S1F14 H->E <L <B 0>> .`,
+			expectedError: "S1F14 H->E EstablishCommunicationsRequestAcknowledgeHost: expected L[2] item, found L[1] item",
+		},
+		{
+			description:   "list with ellipsis, not a multiple of the repeated items",
+			input:         `S2F15 W H->E <L <L <U4 1>>> .`,
+			expectedError: "S2F15 W H->E NewEquipmentConstantSend: item 1: expected L[2] item, found L[1] item",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		messages, errs, _ := sml.Parse(test.input)
+		assert.Empty(t, errs)
+		for _, msg := range messages {
+			err := Validate(msg)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedError)
+			}
+		}
+	}
+}
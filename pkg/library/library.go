@@ -0,0 +1,191 @@
+// Package library contains a message library, which indexes SECS-II messages
+// defined in SML, and instantiates them with values.
+package library
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+// MessageLibrary is a immutable data type that represents a set of SECS-II messages,
+// such as a message dictionary of a equipment interface.
+//
+// The messages are indexed by their names, and by their stream code, function code
+// and direction. Primary messages (odd function code) are paired with their
+// secondary messages (function code + 1) in the same stream.
+type MessageLibrary struct {
+	messages  []*ast.DataMessage                    // messages in the library, in insertion order
+	names     map[string]*ast.DataMessage           // message name and the message
+	codes     map[[2]int][]*ast.DataMessage         // stream and function code, and the messages in insertion order
+	secondary map[*ast.DataMessage]*ast.DataMessage // primary message and its secondary message
+	primary   map[*ast.DataMessage]*ast.DataMessage // secondary message and its primary message
+
+	// Rep invariants
+	// - Message names are unique, except for empty names
+	// - Each message in names and codes is also in messages
+	// - For each primary p and secondary s pair, p.StreamCode() == s.StreamCode(),
+	//   p.FunctionCode() is odd and s.FunctionCode() == p.FunctionCode()+1,
+	//   and their directions are compatible as in isReplyDirection()
+}
+
+// Factory methods
+
+// NewMessageLibrary creates a new message library from the messages.
+//
+// Messages without name can be looked up only by their stream and function code.
+// Messages with duplicated names are reported as errors, and only the first one
+// of them is added to the library.
+//
+// Each primary message is paired with the first secondary message, in the order
+// of the input, that has the same stream code, the function code + 1 and a
+// compatible direction, i.e. opposite direction or "H<->E".
+func NewMessageLibrary(messages []*ast.DataMessage) (lib *MessageLibrary, errors []string) {
+	lib = &MessageLibrary{
+		messages:  []*ast.DataMessage{},
+		names:     map[string]*ast.DataMessage{},
+		codes:     map[[2]int][]*ast.DataMessage{},
+		secondary: map[*ast.DataMessage]*ast.DataMessage{},
+		primary:   map[*ast.DataMessage]*ast.DataMessage{},
+	}
+	errors = []string{}
+
+	for _, msg := range messages {
+		if name := msg.Name(); name != "" {
+			if _, ok := lib.names[name]; ok {
+				errors = append(errors, fmt.Sprintf("duplicated message name %q", name))
+				continue
+			}
+			lib.names[name] = msg
+		}
+		code := [2]int{msg.StreamCode(), msg.FunctionCode()}
+		lib.codes[code] = append(lib.codes[code], msg)
+		lib.messages = append(lib.messages, msg)
+	}
+
+	for _, msg := range lib.messages {
+		if msg.FunctionCode()%2 == 0 || msg.FunctionCode() == 255 {
+			continue
+		}
+		for _, reply := range lib.codes[[2]int{msg.StreamCode(), msg.FunctionCode() + 1}] {
+			if isReplyDirection(msg.Direction(), reply.Direction()) {
+				lib.secondary[msg] = reply
+				if _, ok := lib.primary[reply]; !ok {
+					lib.primary[reply] = msg
+				}
+				break
+			}
+		}
+	}
+
+	return lib, errors
+}
+
+// ParseMessageLibrary parses the SML input string, and creates a new message library.
+//
+// Parsing errors and library errors are returned as errors, in that order.
+// Refer to sml.Parse and NewMessageLibrary for details.
+func ParseMessageLibrary(input string, opts ...sml.Option) (lib *MessageLibrary, errors, warnings []string) {
+	messages, errors, warnings := sml.Parse(input, opts...)
+	lib, libErrors := NewMessageLibrary(messages)
+	return lib, append(errors, libErrors...), warnings
+}
+
+// LoadMessageLibrary loads the SML file with the name in fsys, and creates a new message library.
+//
+// Loading errors and library errors are returned as errors, in that order.
+// Refer to sml.Load and NewMessageLibrary for details.
+func LoadMessageLibrary(fsys fs.FS, name string, opts ...sml.Option) (lib *MessageLibrary, errors, warnings []string) {
+	messages, errors, warnings := sml.Load(fsys, name, opts...)
+	lib, libErrors := NewMessageLibrary(messages)
+	return lib, append(errors, libErrors...), warnings
+}
+
+// Public methods
+
+// Messages returns the messages in the library, in insertion order.
+func (lib *MessageLibrary) Messages() []*ast.DataMessage {
+	return append([]*ast.DataMessage{}, lib.messages...)
+}
+
+// Message returns the message with the name.
+// The second return value is false if the message is not found.
+func (lib *MessageLibrary) Message(name string) (*ast.DataMessage, bool) {
+	msg, ok := lib.names[name]
+	return msg, ok
+}
+
+// Lookup returns the messages with the stream code, function code and compatible direction,
+// in insertion order.
+//
+// direction should be either "H->E", "H<-E", or "H<->E".
+// Messages with direction "H<->E" match any direction, and direction "H<->E" matches
+// messages with any direction.
+func (lib *MessageLibrary) Lookup(stream, function int, direction string) []*ast.DataMessage {
+	result := []*ast.DataMessage{}
+	for _, msg := range lib.codes[[2]int{stream, function}] {
+		if direction == "H<->E" || msg.Direction() == "H<->E" || msg.Direction() == direction {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// Secondary returns the secondary message of the primary message with the name.
+// The second return value is false if the primary message or its secondary message is not found.
+func (lib *MessageLibrary) Secondary(name string) (*ast.DataMessage, bool) {
+	msg, ok := lib.names[name]
+	if !ok {
+		return nil, false
+	}
+	reply, ok := lib.secondary[msg]
+	return reply, ok
+}
+
+// Primary returns the primary message of the secondary message with the name.
+// If multiple primary messages are paired with the secondary message, the first one is returned.
+// The second return value is false if the secondary message or its primary message is not found.
+func (lib *MessageLibrary) Primary(name string) (*ast.DataMessage, bool) {
+	msg, ok := lib.names[name]
+	if !ok {
+		return nil, false
+	}
+	request, ok := lib.primary[msg]
+	return request, ok
+}
+
+// Instantiate returns a new message with the values filled into the variables of
+// the message with the name, using ast.DataMessage.FillVariablesStrict.
+// The default values are filled into the variables not in the values.
+//
+// An error is returned if the message is not found, the values cannot be filled in,
+// or any variable remains in the message after filling in the values.
+func (lib *MessageLibrary) Instantiate(name string, values map[string]interface{}) (*ast.DataMessage, error) {
+	template, ok := lib.names[name]
+	if !ok {
+		return nil, fmt.Errorf("message %q not found", name)
+	}
+
+	msg, err := template.FillVariablesStrict(values)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fill variables of message %q: %v", name, err)
+	}
+	return msg, nil
+}
+
+// Helper functions
+
+// isReplyDirection reports whether a secondary message with the direction
+// reply can be a reply of a primary message with the direction request.
+func isReplyDirection(request, reply string) bool {
+	switch request {
+	case "H->E":
+		return reply == "H<-E" || reply == "H<->E"
+	case "H<-E":
+		return reply == "H->E" || reply == "H<->E"
+	default:
+		return true
+	}
+}
@@ -0,0 +1,189 @@
+package library
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests message library
+//
+// Testing Strategy:
+//
+// Create a message library from SML input, and test the lookup results by
+// the message names, and the instantiated messages by their string representation.
+//
+// Partitions:
+//
+// - Message name: unique, duplicated, empty
+// - Lookup direction: H->E, H<-E, H<->E; message direction: H->E, H<-E, H<->E
+// - Primary/secondary pair: exists, doesn't exist, multiple candidates
+// - Instantiate: message not found, all variables filled, unbound variables,
+//                invalid fill-in value
+
+const testLibrary = `
+S1F1 W H->E AreYouThereHost .
+S1F2 H<-E OnlineData <L <A MDLN> <A SOFTREV>> .
+S1F1 W H<-E AreYouThereEquipment .
+S1F2 H->E OnlineDataHost <L> .
+S1F13 W H<->E EstablishCommunicationsRequest <L <A MDLN> <A SOFTREV>> .
+S1F14 H<->E EstablishCommunicationsAcknowledge <L <B COMMACK> <L <A MDLN> <A SOFTREV>>> .
+S2F17 W H->E DateTimeRequest .
+S6F11 W H<-E EventReport <L <U4 DATAID> <U4 CEID> <L <L <U4 RPTID> <L <A V> ...>> ...>> .
+S6F12 H->E EventReportAcknowledge <B ACKC6> .
+S9F1 H<-E .
+`
+
+func newTestLibrary(t *testing.T) *MessageLibrary {
+	lib, errs, warnings := ParseMessageLibrary(testLibrary)
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+	return lib
+}
+
+// names returns the names of the messages, or "S?F?" for a message without name.
+func names(lib *MessageLibrary, stream, function int, direction string) []string {
+	result := []string{}
+	for _, msg := range lib.Lookup(stream, function, direction) {
+		if msg.Name() == "" {
+			result = append(result, fmt.Sprintf("S%dF%d", msg.StreamCode(), msg.FunctionCode()))
+		} else {
+			result = append(result, msg.Name())
+		}
+	}
+	return result
+}
+
+func TestMessageLibrary_Lookup(t *testing.T) {
+	lib := newTestLibrary(t)
+	assert.Len(t, lib.Messages(), 10)
+
+	msg, ok := lib.Message("DateTimeRequest")
+	assert.True(t, ok)
+	assert.Equal(t, "S2F17 W H->E DateTimeRequest", msg.Header())
+
+	_, ok = lib.Message("Unknown")
+	assert.False(t, ok)
+	_, ok = lib.Message("")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"AreYouThereHost"}, names(lib, 1, 1, "H->E"))
+	assert.Equal(t, []string{"AreYouThereEquipment"}, names(lib, 1, 1, "H<-E"))
+	assert.Equal(t, []string{"AreYouThereHost", "AreYouThereEquipment"}, names(lib, 1, 1, "H<->E"))
+	assert.Equal(t, []string{"EstablishCommunicationsRequest"}, names(lib, 1, 13, "H->E"))
+	assert.Equal(t, []string{"EstablishCommunicationsRequest"}, names(lib, 1, 13, "H<-E"))
+	assert.Equal(t, []string{"S9F1"}, names(lib, 9, 1, "H<-E"))
+	assert.Equal(t, []string{}, names(lib, 9, 1, "H->E"))
+	assert.Equal(t, []string{}, names(lib, 127, 255, "H<->E"))
+}
+
+func TestMessageLibrary_PrimarySecondary(t *testing.T) {
+	lib := newTestLibrary(t)
+
+	var tests = []struct {
+		primary   string
+		secondary string // empty if not paired
+	}{
+		{"AreYouThereHost", "OnlineData"},
+		{"AreYouThereEquipment", "OnlineDataHost"},
+		{"EstablishCommunicationsRequest", "EstablishCommunicationsAcknowledge"},
+		{"DateTimeRequest", ""},
+		{"EventReport", "EventReportAcknowledge"},
+	}
+	for _, test := range tests {
+		secondary, ok := lib.Secondary(test.primary)
+		if test.secondary == "" {
+			assert.False(t, ok, test.primary)
+			continue
+		}
+		if assert.True(t, ok, test.primary) {
+			assert.Equal(t, test.secondary, secondary.Name())
+		}
+		primary, ok := lib.Primary(test.secondary)
+		if assert.True(t, ok, test.secondary) {
+			assert.Equal(t, test.primary, primary.Name())
+		}
+	}
+
+	_, ok := lib.Secondary("Unknown")
+	assert.False(t, ok)
+	_, ok = lib.Primary("DateTimeRequest")
+	assert.False(t, ok)
+}
+
+func TestMessageLibrary_Instantiate(t *testing.T) {
+	lib := newTestLibrary(t)
+
+	msg, err := lib.Instantiate("EventReport", map[string]interface{}{
+		"DATAID": 1, "CEID": 100, "...[0]": 0, "...[1]": 1,
+		"RPTID[0]": 10, "V[0]": "a", "RPTID[1]": 20, "V[1]": "b",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `S6F11 W H<-E EventReport
+<L[3]
+  <U4[1] 1>
+  <U4[1] 100>
+  <L[2]
+    <L[2]
+      <U4[1] 10>
+      <L[1]
+        <A "a">
+      >
+    >
+    <L[2]
+      <U4[1] 20>
+      <L[1]
+        <A "b">
+      >
+    >
+  >
+>
+.`, fmt.Sprint(msg))
+
+	msg, err = lib.Instantiate("DateTimeRequest", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "S2F17 W H->E DateTimeRequest\n.", fmt.Sprint(msg))
+
+	_, err = lib.Instantiate("Unknown", nil)
+	assert.EqualError(t, err, `message "Unknown" not found`)
+
+	_, err = lib.Instantiate("OnlineData", map[string]interface{}{"MDLN": "model"})
+	assert.EqualError(t, err, `cannot fill variables of message "OnlineData": unbound variables: SOFTREV`)
+
+	_, err = lib.Instantiate("EventReportAcknowledge", map[string]interface{}{"ACKC6": "zero"})
+	assert.Error(t, err)
+}
+
+func TestMessageLibrary_Errors(t *testing.T) {
+	lib, errs, _ := ParseMessageLibrary("S1F1 W H->E Msg . S1F3 W H->E Msg . S1F5 W H->E Other .")
+	assert.Equal(t, []string{`duplicated message name "Msg"`}, errs)
+	assert.Len(t, lib.Messages(), 2)
+	msg, _ := lib.Message("Msg")
+	assert.Equal(t, 1, msg.FunctionCode())
+
+	lib, errs, _ = ParseMessageLibrary("S1F1 W H->E <X> .")
+	assert.Len(t, errs, 1)
+	assert.Empty(t, lib.Messages())
+}
+
+func TestLoadMessageLibrary(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.sml":    {Data: []byte(`#include "s1.sml" S1F2 H<-E OnlineData .`)},
+		"s1.sml":      {Data: []byte(`S1F1 W H->E AreYouThere .`)},
+		"invalid.sml": {Data: []byte(`#include "missing.sml"`)},
+	}
+
+	lib, errs, warnings := LoadMessageLibrary(fsys, "main.sml")
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+	secondary, ok := lib.Secondary("AreYouThere")
+	if assert.True(t, ok) {
+		assert.Equal(t, "OnlineData", secondary.Name())
+	}
+
+	lib, errs, _ = LoadMessageLibrary(fsys, "invalid.sml")
+	assert.Len(t, errs, 1)
+	assert.Empty(t, lib.Messages())
+}
@@ -0,0 +1,367 @@
+// Package lint checks SECS-II messages defined in SML with semantic rules, which
+// the SML parser doesn't check, such as the message structures defined in SEMI E5.
+//
+// The linter reports issues for messages that are not paired with their primary or
+// secondary messages, for variables used inconsistently across messages, and for variables
+// of secondary messages that cannot be filled in with the values of the primary messages.
+// Messages that are defined in SEMI E5, e.g. S1F13, are also checked against the
+// standard messages in the e5 package, for their wait bit, direction, data item
+// structure and sizes.
+//
+// Each rule can be enabled or disabled with the options of Lint. The optional rules,
+// e.g. unused, are disabled by default, since they report issues in most dictionaries,
+// such as the acknowledge codes of secondary messages filled in by the application.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Issue is a problem in a message, found by a lint rule.
+type Issue struct {
+	Rule    string           // name of the rule that found the issue
+	Message *ast.DataMessage // message that has the issue
+	Text    string           // description of the issue
+}
+
+// String returns the string representation of the issue,
+// e.g. "S1F13 W H<-E EstablishComm: missing secondary message S1F14 (pairing)".
+func (issue Issue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", issue.Message.Header(), issue.Text, issue.Rule)
+}
+
+// Rule is a semantic rule of the linter.
+type Rule struct {
+	Name        string // name of the rule, used in the options and the issues
+	Description string // description of the rule
+	Optional    bool   // whether the rule is disabled by default, and enabled only by WithRules
+
+	check func(messages []*ast.DataMessage, report reportFunc)
+}
+
+// reportFunc reports an issue of the message, with the text formatted as in fmt.Sprintf.
+type reportFunc func(msg *ast.DataMessage, format string, args ...interface{})
+
+// rules is the rules of the linter, in the order of the check.
+var rules = []Rule{
+	{"pairing", "primary messages expecting a reply have secondary messages, and vice versa", false, checkPairing},
+	{"wait-bit", "wait bit is set if and only if the reply is expected", false, checkWaitBit},
+	{"direction", "standard messages have the direction defined in SEMI E5", false, checkDirection},
+	{"structure", "standard messages have the data item structure defined in SEMI E5", false, checkStructure},
+	{"size", "data item sizes of standard messages are in the limits of SEMI E5", false, checkSize},
+	{"variable", "variables with the same name have the same data item type", false, checkVariables},
+	{"unused", "variables of secondary messages are bound from the primary message or have default values", true, checkUnused},
+}
+
+// Rules returns the rules of the linter, in the order of the check.
+func Rules() []Rule {
+	return append([]Rule{}, rules...)
+}
+
+// Option is a option of Lint.
+type Option func(*linter)
+
+// WithRules returns a option that enables only the rules with the names,
+// including the optional rules.
+func WithRules(names ...string) Option {
+	return func(l *linter) {
+		l.only = append(l.only, names...)
+	}
+}
+
+// WithoutRules returns a option that disables the rules with the names.
+func WithoutRules(names ...string) Option {
+	return func(l *linter) {
+		l.disabled = append(l.disabled, names...)
+	}
+}
+
+// linter is the configuration of Lint.
+type linter struct {
+	only     []string // names of the enabled rules; all rules are enabled if empty
+	disabled []string // names of the disabled rules
+}
+
+// Lint checks the messages with the rules, and returns the issues found.
+//
+// The messages are checked together, e.g. a primary message is paired with the secondary message
+// in the messages. All rules except the optional rules are enabled by default, which can be
+// changed by the options.
+// The issues are sorted in the order of the messages, and then in the order of the rules.
+//
+// An error is returned if a option has a unknown rule name.
+func Lint(messages []*ast.DataMessage, opts ...Option) ([]Issue, error) {
+	l := &linter{}
+	for _, opt := range opts {
+		opt(l)
+	}
+	enabled, err := l.enabledRules()
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []Issue{}
+	for _, rule := range enabled {
+		name := rule.Name
+		rule.check(messages, func(msg *ast.DataMessage, format string, args ...interface{}) {
+			issues = append(issues, Issue{name, msg, fmt.Sprintf(format, args...)})
+		})
+	}
+
+	index := make(map[*ast.DataMessage]int, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		index[messages[i]] = i
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return index[issues[i].Message] < index[issues[j].Message]
+	})
+	return issues, nil
+}
+
+// enabledRules returns the rules enabled by the options.
+func (l *linter) enabledRules() ([]Rule, error) {
+	known := map[string]bool{}
+	for _, rule := range rules {
+		known[rule.Name] = true
+	}
+	only, disabled := map[string]bool{}, map[string]bool{}
+	for _, name := range l.only {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		only[name] = true
+	}
+	for _, name := range l.disabled {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		disabled[name] = true
+	}
+
+	result := []Rule{}
+	for _, rule := range rules {
+		if ((len(only) == 0 && !rule.Optional) || only[rule.Name]) && !disabled[rule.Name] {
+			result = append(result, rule)
+		}
+	}
+	return result, nil
+}
+
+// checkPairing reports primary messages expecting a reply without a secondary message,
+// and secondary messages without a primary message.
+// Function code 0, which aborts a transaction, is not paired.
+func checkPairing(messages []*ast.DataMessage, report reportFunc) {
+	for _, msg := range messages {
+		s, f := msg.StreamCode(), msg.FunctionCode()
+		switch {
+		case f%2 == 1 && msg.WaitBit() == "true":
+			if findReply(messages, msg) == nil {
+				report(msg, "missing secondary message S%dF%d", s, f+1)
+			}
+		case f%2 == 0 && f != 0:
+			if findRequest(messages, msg) == nil {
+				report(msg, "missing primary message S%dF%d", s, f-1)
+			}
+		}
+	}
+}
+
+// checkWaitBit reports primary messages whose wait bit differs from the standard message,
+// and primary messages without the wait bit that have a secondary message.
+func checkWaitBit(messages []*ast.DataMessage, report reportFunc) {
+	for _, msg := range messages {
+		if msg.FunctionCode()%2 == 0 || msg.WaitBit() == "optional" {
+			continue
+		}
+		switch standardWaitBit(msg) {
+		case "true":
+			if msg.WaitBit() == "false" {
+				report(msg, "wait bit should be set, SEMI E5 defines a reply")
+			}
+			continue
+		case "false":
+			if msg.WaitBit() == "true" {
+				report(msg, "wait bit should not be set, SEMI E5 defines no reply")
+			}
+			continue
+		}
+		if reply := findReply(messages, msg); msg.WaitBit() == "false" && reply != nil {
+			report(msg, "wait bit should be set, the secondary message is defined as %s", reply.Header())
+		}
+	}
+}
+
+// checkDirection reports standard messages whose direction is not defined in SEMI E5.
+func checkDirection(messages []*ast.DataMessage, report reportFunc) {
+	for _, msg := range messages {
+		defs := standardMessages(msg.StreamCode(), msg.FunctionCode())
+		if len(defs) == 0 {
+			continue
+		}
+		directions := map[string]bool{}
+		for _, def := range defs {
+			directions[def.Direction()] = true
+		}
+		if directions["H<->E"] || directions[msg.Direction()] ||
+			(msg.Direction() == "H<->E" && directions["H->E"] && directions["H<-E"]) {
+			continue
+		}
+		report(msg, "direction should be %s as in SEMI E5", defs[0].Direction())
+	}
+}
+
+// checkStructure reports data items of standard messages whose structure differs from SEMI E5.
+func checkStructure(messages []*ast.DataMessage, report reportFunc) {
+	for _, msg := range messages {
+		for _, d := range compareStandard(msg) {
+			if !d.size {
+				report(msg, "%s", d.text)
+			}
+		}
+	}
+}
+
+// checkSize reports data items of standard messages whose size is out of the limits of SEMI E5.
+func checkSize(messages []*ast.DataMessage, report reportFunc) {
+	for _, msg := range messages {
+		for _, d := range compareStandard(msg) {
+			if d.size {
+				report(msg, "%s", d.text)
+			}
+		}
+	}
+}
+
+// checkVariables reports variables whose data item type differs from the first variable
+// with the same name, and variables whose name differs only in case from another variable.
+// Variables in lists, which can be filled in with any data item, are not checked.
+func checkVariables(messages []*ast.DataMessage, report reportFunc) {
+	type usage struct {
+		name, itemType string
+		msg            *ast.DataMessage
+	}
+	seen := map[string]usage{} // lower-case variable name and its first usage
+
+	for _, msg := range messages {
+		reported := map[string]bool{}
+		walkVariables(msg.Item(), func(name, itemType string) {
+			key := strings.ToLower(name)
+			first, ok := seen[key]
+			if !ok {
+				seen[key] = usage{name, itemType, msg}
+				return
+			}
+			if reported[name] {
+				return
+			}
+			switch {
+			case first.name != name:
+				report(msg, "variable %q differs only in case from %q in %s", name, first.name, first.msg.Header())
+				reported[name] = true
+			case first.itemType != itemType:
+				report(msg, "variable %q is %s, but %s in %s", name, itemType, first.itemType, first.msg.Header())
+				reported[name] = true
+			}
+		})
+	}
+}
+
+// checkUnused reports variables of secondary messages, which are neither bound from the variables
+// of the primary message nor have default values, so that the values bound by the primary message
+// are not enough to reply the secondary message.
+// Variables in lists, and secondary messages without the primary message, are not checked.
+func checkUnused(messages []*ast.DataMessage, report reportFunc) {
+	for _, msg := range messages {
+		if msg.FunctionCode()%2 == 1 || msg.FunctionCode() == 0 {
+			continue
+		}
+		primary := findRequest(messages, msg)
+		if primary == nil {
+			continue
+		}
+
+		bound := map[string]bool{}
+		walkVariables(primary.Item(), func(name, itemType string) {
+			bound[name] = true
+		})
+		filled, err := msg.FillVariables(map[string]interface{}{})
+		if err != nil {
+			continue
+		}
+		unbound := map[string]bool{}
+		for _, name := range filled.Variables() {
+			unbound[name] = true
+		}
+
+		walkVariables(msg.Item(), func(name, itemType string) {
+			if !bound[name] && unbound[name] {
+				report(msg, "variable %q is not bound from %s, and has no default value", name, primary.Header())
+			}
+		})
+	}
+}
+
+// walkVariables calls fn with the name and the data item type of each variable in the item node,
+// in the order of appearance. The data item type of a array variable has the suffix " array".
+func walkVariables(node ast.ItemNode, fn func(name, itemType string)) {
+	if node == nil {
+		return
+	}
+	if list, ok := node.(*ast.ListNode); ok {
+		for _, v := range list.Values() {
+			if child, ok := v.(ast.ItemNode); ok {
+				walkVariables(child, fn)
+			}
+		}
+		return
+	}
+
+	itemType := typeName(node)
+	if _, _, ok := arraySize(node); ok {
+		itemType += " array"
+	}
+	for _, name := range node.Variables() {
+		fn(name, itemType)
+	}
+}
+
+// findReply returns the first secondary message of the primary message in the messages,
+// or nil if not found.
+func findReply(messages []*ast.DataMessage, primary *ast.DataMessage) *ast.DataMessage {
+	for _, msg := range messages {
+		if msg.StreamCode() == primary.StreamCode() && msg.FunctionCode() == primary.FunctionCode()+1 &&
+			isReplyDirection(primary.Direction(), msg.Direction()) {
+			return msg
+		}
+	}
+	return nil
+}
+
+// findRequest returns the first primary message of the secondary message in the messages,
+// or nil if not found.
+func findRequest(messages []*ast.DataMessage, secondary *ast.DataMessage) *ast.DataMessage {
+	for _, msg := range messages {
+		if msg.StreamCode() == secondary.StreamCode() && msg.FunctionCode() == secondary.FunctionCode()-1 &&
+			isReplyDirection(msg.Direction(), secondary.Direction()) {
+			return msg
+		}
+	}
+	return nil
+}
+
+// isReplyDirection returns true if a message with the reply direction can be the reply
+// of a message with the request direction.
+func isReplyDirection(request, reply string) bool {
+	switch request {
+	case "H->E":
+		return reply == "H<-E" || reply == "H<->E"
+	case "H<-E":
+		return reply == "H->E" || reply == "H<->E"
+	default:
+		return true
+	}
+}
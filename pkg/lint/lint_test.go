@@ -0,0 +1,245 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+// Tests the linter
+//
+// Testing Strategy:
+//
+// Parse SML input, lint the messages with or without options, and test the string
+// representation of the issues, or the error.
+//
+// Partitions:
+//
+// - Rule: pairing, wait-bit, direction, structure, size, variable, unused
+// - Message: standard message, non-standard message
+// - Primary message: with wait bit, without wait bit, optional wait bit; secondary message exists, doesn't exist
+// - Secondary message: primary message exists, doesn't exist, function code 0
+// - Direction: same as the standard, different, H<->E
+// - Data item: header only, list, list with ellipsis, ASCII, array variable, variable in list
+// - Variable: same type, different type, array variable, different case
+// - Variable of secondary message: bound from the primary message, default value, unbound, in list
+// - Options: none, WithRules, WithoutRules, unknown rule name, optional rule
+
+func lint(t *testing.T, input string, opts ...Option) []string {
+	messages, errs, _ := sml.Parse(input)
+	assert.Empty(t, errs)
+	issues, err := Lint(messages, opts...)
+	assert.NoError(t, err)
+
+	result := []string{}
+	for _, issue := range issues {
+		result = append(result, issue.String())
+	}
+	return result
+}
+
+func TestLint_Rules(t *testing.T) {
+	var tests = []struct {
+		description string   // Test case description
+		input       string   // Input SML
+		rule        string   // Rule to check
+		expected    []string // expected issues
+	}{
+		{
+			description: "pairing, paired messages",
+			input: `S1F1 W H->E Ping .
+			        S1F2 H<-E Pong <L> .
+			        S1F1 H<->E Probe .
+			        S9F0 H<->E Abort .`,
+			rule:     "pairing",
+			expected: []string{},
+		},
+		{
+			description: "pairing, missing secondary and primary messages",
+			input: `S1F1 W H->E Ping .
+			        S1F2 H->E Pong <L> .
+			        S64F1 [W] H<-E Custom .
+			        S64F4 H<->E CustomAck .`,
+			rule: "pairing",
+			expected: []string{
+				"S1F1 W H->E Ping: missing secondary message S1F2 (pairing)",
+				"S1F2 H->E Pong: missing primary message S1F1 (pairing)",
+				"S64F4 H<->E CustomAck: missing primary message S64F3 (pairing)",
+			},
+		},
+		{
+			description: "wait-bit, standard messages",
+			input: `S1F13 H->E EstablishComm <L> .
+			        S5F1 H<-E Alarm <L <B[1] ALCD> <U4 ALID> <A ALTX>> .
+			        S9F1 W H<-E UnrecognizedDevice <B[10] MHEAD> .
+			        S10F3 [W] H->E Terminal <L <B[1] TID> <A TEXT>> .`,
+			rule: "wait-bit",
+			expected: []string{
+				"S1F13 H->E EstablishComm: wait bit should be set, SEMI E5 defines a reply (wait-bit)",
+				"S9F1 W H<-E UnrecognizedDevice: wait bit should not be set, SEMI E5 defines no reply (wait-bit)",
+			},
+		},
+		{
+			description: "wait-bit, non-standard messages",
+			input: `S64F1 H->E Custom .
+			        S64F2 H<-E CustomAck .
+			        S64F3 H->E Notify .`,
+			rule:     "wait-bit",
+			expected: []string{"S64F1 H->E Custom: wait bit should be set, the secondary message is defined as S64F2 H<-E CustomAck (wait-bit)"},
+		},
+		{
+			description: "direction",
+			input: `S1F13 W H<->E EstablishComm <L> .
+			        S2F17 W H->E DateTimeRequest .
+			        S1F3 W H<-E StatusRequest <L> .
+			        S6F11 W H<->E EventReport <L <U4 DATAID> <U4 CEID> <L>> .
+			        S64F1 H<-E Custom .`,
+			rule: "direction",
+			expected: []string{
+				"S1F3 W H<-E StatusRequest: direction should be H->E as in SEMI E5 (direction)",
+				"S6F11 W H<->E EventReport: direction should be H<-E as in SEMI E5 (direction)",
+			},
+		},
+		{
+			description: "structure, matching standard messages",
+			input: `S1F13 W H->E EstablishCommHost <L> .
+			        S1F13 W H<-E EstablishComm <L <A MDLN> <A SOFTREV>> .
+			        S1F14 H<->E EstablishCommAck <L <B[1] COMMACK> <L <A MDLN> <A SOFTREV>>> .
+			        S1F3 W H->E StatusRequest <L <U4 SVID> ...> .
+			        S1F3 W H->E StatusRequestFixed <L <U4 1> <U4 2>> .
+			        S2F33 W H->E DefineReport <L <U4 DATAID> <L <L <U4 RPTID> <L VID ...>> ...>> .
+			        S2F17 W H->E DateTimeRequest .
+			        S6F11 W H<-E EventReport <L DATAID CEID <L <L RPTID REPORT> ...>> .`,
+			rule:     "structure",
+			expected: []string{},
+		},
+		{
+			description: "structure, different data items",
+			input: `S1F13 W H<-E EstablishComm <L <A MDLN> <U4 SOFTREV>> .
+			        S1F14 H->E EstablishCommAck <L <B[1] COMMACK> <L> <A EXTRA>> .
+			        S2F17 W H->E DateTimeRequest <L> .
+			        S2F18 H<-E DateTimeData .
+			        S1F12 H<-E NamelistReply <L <L <U4 SVID> <A SVNAME>> ...> .
+			        S2F15 W H->E NewConstants <L <L <U4 1> <U4 1>> <L <U4 2>>> .
+			        S5F3 W H->E EnableAlarm <L <B[1] ALED> <U4 ALID> ...> .`,
+			rule: "structure",
+			expected: []string{
+				"S1F13 W H<-E EstablishComm: data item 2 (SOFTREV): expected A, found U4 (structure)",
+				"S1F14 H->E EstablishCommAck: data item: expected L[2], found L[3] (structure)",
+				"S2F17 W H->E DateTimeRequest: unexpected data item, SEMI E5 defines the message as header only (structure)",
+				"S2F18 H<-E DateTimeData: missing data item, expected A (structure)",
+				"S1F12 H<-E NamelistReply: data item 1: expected L[3], found L[2] (structure)",
+				"S2F15 W H->E NewConstants: data item 2: expected L[2], found L[1] (structure)",
+				"S5F3 W H->E EnableAlarm: data item: expected L[2], found L with ellipsis (structure)",
+			},
+		},
+		{
+			description: "size",
+			input: `S1F13 W H<-E EstablishComm <L <A[..30] MDLN> <A "123456789012345678901">> .
+			        S2F18 H<-E DateTimeData <A "2021" > .
+			        S2F31 W H->E DateTimeSet <A[12] TIME> .
+			        S1F14 H->E EstablishCommAck <L <B[2] 0 1> <L>> .
+			        S9F1 H<-E UnrecognizedDevice <B[..5] MHEAD> .
+			        S1F14 H<-E EstablishCommAck <L <B COMMACK> <L <A[20] MDLN> <A SOFTREV>>> .
+			        S9F3 H<-E UnrecognizedStream <B[10] SHEAD> .
+			        S2F25 W H->E Loopback <B[3] 1 2 3> .`,
+			rule: "size",
+			expected: []string{
+				"S1F13 W H<-E EstablishComm: data item 1 (MDLN): length [0..30] out of the limits [0..20] (size)",
+				"S1F13 W H<-E EstablishComm: data item 2 (SOFTREV): length [21] out of the limits [0..20] (size)",
+				"S2F18 H<-E DateTimeData: data item (TIME): length [4] out of the limits [12] (size)",
+				"S1F14 H->E EstablishCommAck: data item 1 (COMMACK): size [2] out of the limits [1] (size)",
+				"S9F1 H<-E UnrecognizedDevice: data item (MHEAD): size [0..5] out of the limits [10] (size)",
+			},
+		},
+		{
+			description: "variable",
+			input: `S1F3 W H->E StatusRequest <L <U4 SVID> ...> .
+			        S1F4 H<-E StatusData <L SV ...> .
+			        S1F11 W H->E NamelistRequest <L <U2 SVID> ...> .
+			        S1F12 H<-E NamelistReply <L <L <U4 Svid> <A SVNAME> <A UNITS>> ...> .
+			        S2F13 W H->E ConstantRequest <L <U4 SV> <U4[..] ECIDS>> .
+			        S2F14 H<-E ConstantData <L <U4 ECIDS> <U4 SVID>> .`,
+			rule: "variable",
+			expected: []string{
+				`S1F11 W H->E NamelistRequest: variable "SVID" is U2, but U4 in S1F3 W H->E StatusRequest (variable)`,
+				`S1F12 H<-E NamelistReply: variable "Svid" differs only in case from "SVID" in S1F3 W H->E StatusRequest (variable)`,
+				`S2F14 H<-E ConstantData: variable "ECIDS" is U4, but U4 array in S2F13 W H->E ConstantRequest (variable)`,
+			},
+		},
+		{
+			description: "unused, bound variables and default values",
+			input: `S1F3 W H->E StatusRequest <L <U4 SVID> ...> .
+			        S1F4 H<-E StatusData <L <U4 SVID> <A SV="">> .
+			        S2F41 W H->E HostCommand <L <A RCMD> <U4[..] CPIDS>> .
+			        S2F42 H<-E HostCommandAck <L <B[1] HCACK=0> <L <A RCMD> <U4[..] CPIDS>>> .`,
+			rule:     "unused",
+			expected: []string{},
+		},
+		{
+			description: "unused, unbound variables",
+			input: `S1F13 W H->E EstablishComm <L> .
+			        S1F14 H<-E EstablishCommAck <L <B[1] COMMACK> <L <A MDLN> <A SOFTREV="1.0">>> .
+			        S1F4 H<-E StatusData <L <U4 SV>> .
+			        S2F17 W H->E DateTimeRequest .
+			        S2F18 H<-E DateTimeData <A TIME> .
+			        S64F2 H->E CustomAck <L SV ...> .
+			        S64F1 W H<-E Custom <U4 SV> .`,
+			rule: "unused",
+			expected: []string{
+				`S1F14 H<-E EstablishCommAck: variable "COMMACK" is not bound from S1F13 W H->E EstablishComm, and has no default value (unused)`,
+				`S1F14 H<-E EstablishCommAck: variable "MDLN" is not bound from S1F13 W H->E EstablishComm, and has no default value (unused)`,
+				`S2F18 H<-E DateTimeData: variable "TIME" is not bound from S2F17 W H->E DateTimeRequest, and has no default value (unused)`,
+			},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		assert.Equal(t, test.expected, lint(t, test.input, WithRules(test.rule)))
+	}
+}
+
+func TestLint_Options(t *testing.T) {
+	input := `S1F13 H<-E EstablishComm <L <A MDLN> <U4 REV>> .
+	          S1F14 H<-E EstablishCommAck <L <B[1] COMMACK> <L <A MDLN> <A SOFTREV>>> .`
+
+	assert.Equal(t, []string{
+		"S1F13 H<-E EstablishComm: wait bit should be set, SEMI E5 defines a reply (wait-bit)",
+		"S1F13 H<-E EstablishComm: data item 2 (SOFTREV): expected A, found U4 (structure)",
+		"S1F14 H<-E EstablishCommAck: missing primary message S1F13 (pairing)",
+	}, lint(t, input))
+
+	assert.Equal(t, []string{
+		"S1F13 H<-E EstablishComm: data item 2 (SOFTREV): expected A, found U4 (structure)",
+	}, lint(t, input, WithoutRules("pairing", "wait-bit")))
+
+	assert.Equal(t, []string{
+		"S1F14 H<-E EstablishCommAck: missing primary message S1F13 (pairing)",
+	}, lint(t, input, WithRules("pairing", "structure"), WithoutRules("structure")))
+
+	optional := `S1F13 W H->E EstablishComm <L> .
+	             S1F14 H<-E EstablishCommAck <L <B[1] COMMACK> <L <A MDLN> <A SOFTREV>>> .`
+	assert.Equal(t, []string{}, lint(t, optional))
+	assert.Equal(t, []string{
+		`S1F14 H<-E EstablishCommAck: variable "COMMACK" is not bound from S1F13 W H->E EstablishComm, and has no default value (unused)`,
+		`S1F14 H<-E EstablishCommAck: variable "MDLN" is not bound from S1F13 W H->E EstablishComm, and has no default value (unused)`,
+		`S1F14 H<-E EstablishCommAck: variable "SOFTREV" is not bound from S1F13 W H->E EstablishComm, and has no default value (unused)`,
+	}, lint(t, optional, WithRules("pairing", "unused")))
+
+	_, err := Lint(nil, WithRules("unknown"))
+	assert.EqualError(t, err, `unknown rule "unknown"`)
+	_, err = Lint(nil, WithoutRules("pairing", "unknown"))
+	assert.EqualError(t, err, `unknown rule "unknown"`)
+
+	names, optionalNames := []string{}, []string{}
+	for _, rule := range Rules() {
+		assert.NotEmpty(t, rule.Description)
+		names = append(names, rule.Name)
+		if rule.Optional {
+			optionalNames = append(optionalNames, rule.Name)
+		}
+	}
+	assert.Equal(t, []string{"unused"}, optionalNames)
+	assert.Equal(t, []string{"pairing", "wait-bit", "direction", "structure", "size", "variable", "unused"}, names)
+}
@@ -0,0 +1,235 @@
+package lint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/e5"
+)
+
+// standardMessages returns the standard messages with the stream and function code
+// in the E5 dictionary, one for each direction, or nil if the message is not defined in the standard.
+func standardMessages(stream, function int) []*ast.DataMessage {
+	if msgs := e5.Library().Lookup(stream, function, "H<->E"); len(msgs) != 0 {
+		return msgs
+	}
+	return nil
+}
+
+// standardWaitBit returns the wait bit of the standard primary message,
+// which is "true", "false" or "optional", or empty string if the message is not defined.
+func standardWaitBit(msg *ast.DataMessage) string {
+	defs := compatibleMessages(msg)
+	if len(defs) == 0 {
+		return ""
+	}
+	return defs[0].WaitBit()
+}
+
+// compatibleMessages returns the standard messages with the stream and function code of msg,
+// whose direction is compatible with msg, i.e. same direction or "H<->E" in either of them.
+func compatibleMessages(msg *ast.DataMessage) []*ast.DataMessage {
+	return e5.Library().Lookup(msg.StreamCode(), msg.FunctionCode(), msg.Direction())
+}
+
+// difference is a difference of a data item from the standard message.
+type difference struct {
+	size bool   // true if the difference is a size out of the limits, false if a structure difference
+	text string // description of the difference
+}
+
+// compareStandard compares the data item of msg with the compatible standard messages, and returns
+// the differences from the standard message that has the least structure differences,
+// and then the least size differences. Returns nil if msg is not a standard message.
+func compareStandard(msg *ast.DataMessage) []difference {
+	var result []difference
+	best, bestSize := -1, -1
+	for _, def := range compatibleMessages(msg) {
+		diffs := compareMessage(def.Item(), msg.Item())
+		count := 0
+		for _, d := range diffs {
+			if !d.size {
+				count++
+			}
+		}
+		if best == -1 || count < best || (count == best && len(diffs)-count < bestSize) {
+			result, best, bestSize = diffs, count, len(diffs)-count
+		}
+	}
+	return result
+}
+
+// compareMessage compares the data item of a message with the data item of the standard message,
+// where nil means the message is header only.
+func compareMessage(expected, actual ast.ItemNode) []difference {
+	switch {
+	case expected == nil && actual == nil:
+		return nil
+	case expected == nil:
+		return []difference{{false, "unexpected data item, SEMI E5 defines the message as header only"}}
+	case actual == nil:
+		return []difference{{false, fmt.Sprintf("missing data item, expected %s", typeName(expected))}}
+	}
+	diffs := []difference{}
+	compare(expected, actual, "", &diffs)
+	return diffs
+}
+
+// compare compares the data item with the data item of the standard message, and appends
+// the differences to diffs. path is the position of the data item in the message, e.g. "2.1".
+//
+// The size of a data item is out of the limits, if its maximum size exceeds the limits, or if
+// its maximum size is less than the minimum of the limits. A variable without the maximum size
+// is not reported, as the size is limited when the value is filled in.
+func compare(expected, actual ast.ItemNode, path string, diffs *[]difference) {
+	where := position(expected, path)
+	expectedType, actualType := typeName(expected), typeName(actual)
+	if expectedType != actualType {
+		*diffs = append(*diffs, difference{false, fmt.Sprintf("%s: expected %s, found %s", where, expectedType, actualType)})
+		return
+	}
+
+	if expectedType == "L" {
+		compareList(expected.(*ast.ListNode), actual.(*ast.ListNode), path, diffs)
+		return
+	}
+
+	min, max := sizeRange(expected)
+	actualMin, actualMax := sizeRange(actual)
+	if actualMax != -1 && ((max != -1 && actualMax > max) || actualMax < min) {
+		unit := "size"
+		if expectedType == "A" {
+			unit = "length"
+		}
+		*diffs = append(*diffs, difference{true, fmt.Sprintf("%s: %s %s out of the limits %s",
+			where, unit, rangeString(actualMin, actualMax), rangeString(min, max))})
+	}
+}
+
+// compareList compares the list with the list of the standard message. If the standard list has
+// ellipsis, the items before the ellipsis can be repeated in the list.
+// Variables in the lists match any data item.
+func compareList(expected, actual *ast.ListNode, path string, diffs *[]difference) {
+	where := position(expected, path)
+	expectedValues, expectedEllipsis := splitEllipsis(expected.Values())
+	actualValues, actualEllipsis := splitEllipsis(actual.Values())
+
+	if !expectedEllipsis {
+		if actualEllipsis {
+			*diffs = append(*diffs, difference{false, fmt.Sprintf("%s: expected L[%d], found L with ellipsis", where, len(expectedValues))})
+			return
+		}
+		if len(expectedValues) != len(actualValues) {
+			*diffs = append(*diffs, difference{false, fmt.Sprintf("%s: expected L[%d], found L[%d]", where, len(expectedValues), len(actualValues))})
+			return
+		}
+	} else {
+		n := len(expectedValues)
+		if actualEllipsis && len(actualValues) != n {
+			*diffs = append(*diffs, difference{false, fmt.Sprintf("%s: expected %d items before ellipsis, found %d", where, n, len(actualValues))})
+			return
+		}
+		if !actualEllipsis && len(actualValues)%n != 0 {
+			*diffs = append(*diffs, difference{false, fmt.Sprintf("%s: expected L with a multiple of %d items, found L[%d]", where, n, len(actualValues))})
+			return
+		}
+	}
+
+	for i, value := range actualValues {
+		expectedChild, ok := expectedValues[i%len(expectedValues)].(ast.ItemNode)
+		if !ok {
+			continue
+		}
+		if child, ok := value.(ast.ItemNode); ok {
+			compare(expectedChild, child, childPath(path, i), diffs)
+		}
+	}
+}
+
+// splitEllipsis returns the values of a list before the ellipsis, and whether the ellipsis exists.
+func splitEllipsis(values []interface{}) ([]interface{}, bool) {
+	for i, v := range values {
+		if name, ok := v.(string); ok && strings.HasPrefix(name, "...") {
+			return values[:i], true
+		}
+	}
+	return values, false
+}
+
+// childPath returns the path of the i-th (zero-based) child in the list at the path.
+func childPath(path string, i int) string {
+	if path == "" {
+		return strconv.Itoa(i + 1)
+	}
+	return path + "." + strconv.Itoa(i+1)
+}
+
+// position returns the description of the position of the data item, with the data item
+// name of the standard node, e.g. "data item 1.2 (MDLN)".
+func position(expected ast.ItemNode, path string) string {
+	result := "data item"
+	if path != "" {
+		result += " " + path
+	}
+	if _, ok := expected.(*ast.ListNode); !ok && len(expected.Variables()) == 1 {
+		result += " (" + expected.Variables()[0] + ")"
+	}
+	return result
+}
+
+// sizeRange returns the size range of the data item that is not a list; the length of the string for
+// ASCII, and the number of the data values for others, where max == -1 means no limit.
+func sizeRange(node ast.ItemNode) (min, max int) {
+	if node, ok := node.(*ast.ASCIINode); ok {
+		if min, max := node.FillInStringLength(); min != -2 {
+			return min, max
+		}
+		return len(node.Value()), len(node.Value())
+	}
+	if min, max, ok := arraySize(node); ok {
+		return min, max
+	}
+	return node.Size(), node.Size()
+}
+
+// arraySize returns the size range of the array variable of the node; ok is false if not exists.
+func arraySize(node ast.ItemNode) (min, max int, ok bool) {
+	if node, ok := node.(interface{ ArraySize() (int, int, bool) }); ok {
+		return node.ArraySize()
+	}
+	return 0, 0, false
+}
+
+// rangeString returns the string representation of the size range, e.g. [3], [2..], [2..7].
+func rangeString(min, max int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("[%d]", min)
+	case max == -1:
+		return fmt.Sprintf("[%d..]", min)
+	}
+	return fmt.Sprintf("[%d..%d]", min, max)
+}
+
+// typeName returns the data item type of the node, e.g. "L", "A", "U4".
+func typeName(node ast.ItemNode) string {
+	switch node := node.(type) {
+	case *ast.ASCIINode:
+		return "A"
+	case *ast.ListNode:
+		return "L"
+	case *ast.BinaryNode:
+		return "B"
+	case *ast.BooleanNode:
+		return "BOOLEAN"
+	case *ast.FloatNode:
+		return fmt.Sprintf("F%d", node.ByteSize())
+	case *ast.IntNode:
+		return fmt.Sprintf("I%d", node.ByteSize())
+	case *ast.UintNode:
+		return fmt.Sprintf("U%d", node.ByteSize())
+	}
+	return "empty"
+}
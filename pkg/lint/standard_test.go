@@ -0,0 +1,92 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/e5"
+)
+
+// Tests the standard messages of SEMI E5
+//
+// Testing Strategy:
+//
+// Lint the standard messages in the E5 dictionary with the rules; the standard messages
+// should be consistent with themselves. Also test the comparison of data items with
+// the standard messages directly.
+//
+// Partitions:
+//
+// - Standard messages: defined, not defined
+// - Data item: header only, ASCII, binary array variable, list with ellipsis
+// - Message direction: compatible with one standard message, with multiple standard messages
+
+func TestStandard_Messages(t *testing.T) {
+	issues, err := Lint(e5.Library().Messages())
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+
+	defs := standardMessages(1, 13)
+	assert.Len(t, defs, 2)
+	assert.Equal(t, "S1F13 W H->E EstablishCommunicationsRequestHost", defs[0].Header())
+	assert.Nil(t, standardMessages(64, 1))
+}
+
+func TestStandard_Compare(t *testing.T) {
+	var tests = []struct {
+		description string           // Test case description
+		msg         *ast.DataMessage // Input message
+		expected    []difference     // expected differences
+	}{
+		{
+			description: "not a standard message",
+			msg:         ast.NewDataMessage("", 64, 1, 0, "H->E", ast.NewEmptyItemNode()),
+			expected:    nil,
+		},
+		{
+			description: "header only",
+			msg:         ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()),
+			expected:    nil,
+		},
+		{
+			description: "ASCII in the limits",
+			msg:         ast.NewDataMessage("", 2, 18, 0, "H<-E", ast.NewASCIINodeVariable("TIME", 12, 12)),
+			expected:    []difference{},
+		},
+		{
+			description: "ASCII in the limits of the second standard message",
+			msg:         ast.NewDataMessage("", 2, 18, 0, "H<-E", ast.NewASCIINodeVariable("TIME", 16, 16)),
+			expected:    []difference{},
+		},
+		{
+			description: "ASCII length between the limits of the standard messages",
+			msg:         ast.NewDataMessage("", 2, 18, 0, "H<-E", ast.NewASCIINode("20211018123000")),
+			expected:    []difference{{true, "data item (TIME): length [14] out of the limits [12]"}},
+		},
+		{
+			description: "binary array variable in the limits",
+			msg:         ast.NewDataMessage("", 2, 25, 1, "H->E", ast.NewBinaryNode("ABS").(*ast.BinaryNode).WithArraySize(0, 100)),
+			expected:    []difference{},
+		},
+		{
+			description: "H<->E, compared with the closest standard message",
+			msg: ast.NewDataMessage("", 1, 13, 1, "H<->E", ast.NewListNode(
+				ast.NewASCIINode("MODEL"), ast.NewASCIINodeVariable("SOFTREV", 0, 30),
+			)),
+			expected: []difference{{true, "data item 2 (SOFTREV): length [0..30] out of the limits [0..20]"}},
+		},
+		{
+			description: "list with ellipsis",
+			msg: ast.NewDataMessage("", 1, 12, 0, "H<-E", ast.NewListNode(
+				ast.NewListNode(ast.NewUintNode(4, 1), ast.NewASCIINode("NAME"), ast.NewASCIINode("UNITS")),
+				ast.NewListNode(ast.NewUintNode(4, 2), ast.NewASCIINode("NAME"), ast.NewBinaryNode(1)),
+			)),
+			expected: []difference{{false, "data item 2.3 (UNITS): expected A, found B"}},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		assert.Equal(t, test.expected, compareStandard(test.msg))
+	}
+}
@@ -0,0 +1,311 @@
+package lsp
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is a SML document opened in the editor, and the result of scanning its text.
+//
+// The scanning recognizes comments, quoted strings, data items and message headers
+// in the default SML dialect, without parsing the text. It's tolerant to syntax errors,
+// which are reported by the parser as diagnostics.
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	messages    []messageSpan    // messages in the document, in appearing order
+	definitions []definitionSpan // define directives in the document
+	folds       []foldingRange   // multi-line data items
+	lineStates  []lineState      // scanning state at the start of each line
+	hasInclude  bool             // whether the document has include directives
+}
+
+// pos is a position in a document; col is a byte offset in the line.
+type pos struct {
+	line, col int
+}
+
+// messageSpan is the position of a message in a document.
+type messageSpan struct {
+	start, end pos    // from the stream function code to the message end character
+	name       string // message name; empty if not specified
+	nameStart  pos    // position of the message name
+}
+
+// definitionSpan is the position of a define directive in a document.
+type definitionSpan struct {
+	name      string
+	nameStart pos
+}
+
+// lineState is the scanning state at the start of a line.
+type lineState struct {
+	depth    int  // depth of the data items
+	inString bool // whether the line continues a quoted string
+}
+
+var (
+	reStreamFunction = regexp.MustCompile(`^[Ss]\d+[Ff]\d+\*?$`)
+	headerKeywords   = map[string]bool{"W": true, "[W]": true, "*": true, "H->E": true, "H<-E": true, "H<->E": true}
+)
+
+// newDocument returns a new document with the text, scanned.
+func newDocument(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+	for i, line := range doc.lines {
+		doc.lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	doc.scan()
+	return doc
+}
+
+// scan scans the text of the document.
+func (doc *document) scan() {
+	var (
+		state      lineState
+		quote      byte
+		openLines  []int        // lines of the unclosed '<'
+		msg        *messageSpan // message being scanned
+		inBody     bool         // whether msg's data item started
+		prevWord   string       // previous word at depth 0
+		prevStart  pos          // position of prevWord
+		lastPos    pos          // end of the last scanned character
+		definition bool         // whether the next word is a definition name
+	)
+
+	endMessage := func(end pos) {
+		if msg != nil {
+			msg.end = end
+			doc.messages = append(doc.messages, *msg)
+			msg = nil
+		}
+	}
+
+	for ln, line := range doc.lines {
+		doc.lineStates = append(doc.lineStates, state)
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			if state.inString {
+				if c == '\\' {
+					i += 1
+				} else if c == quote {
+					state.inString = false
+				}
+				lastPos = pos{ln, i + 1}
+				continue
+			}
+
+			switch {
+			case c == ' ' || c == '\t':
+				continue
+			case strings.HasPrefix(line[i:], "//"):
+				i = len(line)
+				continue
+			case c == '"' || c == '\'':
+				state.inString, quote = true, c
+			case c == '<':
+				if state.depth == 0 && msg != nil {
+					inBody = true
+				}
+				openLines = append(openLines, ln)
+				state.depth += 1
+			case c == '>':
+				if n := len(openLines); n > 0 {
+					if openLines[n-1] < ln {
+						doc.folds = append(doc.folds, foldingRange{openLines[n-1], ln})
+					}
+					openLines = openLines[:n-1]
+					state.depth -= 1
+				}
+			case c == '.' && state.depth == 0 && msg != nil:
+				endMessage(pos{ln, i + 1})
+				inBody = false
+			default:
+				j := i
+				if state.depth == 0 {
+					j += len(directionPrefix(line[i:]))
+				}
+				for j < len(line) && !isDelimiter(line[j], state.depth == 0 && msg != nil) {
+					j += 1
+				}
+				if j == i {
+					j = i + 1
+				}
+				word, start := line[i:j], pos{ln, i}
+				i = j - 1
+
+				if state.depth != 0 {
+					break
+				}
+				switch {
+				case definition:
+					doc.definitions = append(doc.definitions, definitionSpan{word, start})
+					definition = false
+				case word == "#define":
+					definition = true
+				case word == "#include":
+					doc.hasInclude = true
+				case reStreamFunction.MatchString(word):
+					endMessage(lastPos)
+					msg, inBody = &messageSpan{start: start}, false
+					if strings.HasSuffix(prevWord, ":") && len(prevWord) > 1 && prevStart.line == ln {
+						msg.start = prevStart
+						msg.name, msg.nameStart = strings.TrimSuffix(prevWord, ":"), prevStart
+					}
+				case msg != nil && !inBody && msg.name == "" && !headerKeywords[strings.ToUpper(word)]:
+					msg.name, msg.nameStart = word, start
+				}
+				prevWord, prevStart = word, start
+			}
+			lastPos = pos{ln, i + 1}
+		}
+
+		if state.inString && !strings.HasSuffix(line, "\\") {
+			// Unterminated string, which is reported by the parser
+			state.inString = false
+		}
+	}
+	endMessage(lastPos)
+}
+
+// directionPrefix returns the message direction at the start of s, or empty string if not found.
+func directionPrefix(s string) string {
+	for _, direction := range []string{"H<->E", "H<-E", "H->E"} {
+		if strings.HasPrefix(s, direction) {
+			return direction
+		}
+	}
+	return ""
+}
+
+// isDelimiter reports whether the character c ends a word.
+// The message end character '.' ends a word only in a message header.
+func isDelimiter(c byte, header bool) bool {
+	switch c {
+	case ' ', '\t', '<', '>', '"', '\'':
+		return true
+	case '.':
+		return header
+	}
+	return false
+}
+
+// messageAt returns the index of the message at the position p, or -1 if not found.
+func (doc *document) messageAt(p pos) int {
+	for i, msg := range doc.messages {
+		if !less(p, msg.start) && !less(msg.end, p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// less reports whether the position a is before b.
+func less(a, b pos) bool {
+	return a.line < b.line || (a.line == b.line && a.col < b.col)
+}
+
+// wordAt returns the word at the position p, which consists of letters, digits,
+// underbars, and a leading '$', and its start position.
+func (doc *document) wordAt(p pos) (string, pos) {
+	if p.line < 0 || p.line >= len(doc.lines) {
+		return "", p
+	}
+	line := doc.lines[p.line]
+	start, end := p.col, p.col
+	for start > 0 && isWordChar(line[start-1]) {
+		start -= 1
+	}
+	for end < len(line) && isWordChar(line[end]) {
+		end += 1
+	}
+	if start > 0 && line[start-1] == '$' {
+		start -= 1
+	}
+	return line[start:end], pos{p.line, start}
+}
+
+// isWordChar reports whether the character c can be a part of a name.
+func isWordChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// format returns the formatted text of the document, where the lines are
+// indented by 2 spaces per depth of the data items, and trailing whitespaces are removed.
+// Lines that continue a quoted string are not changed.
+func (doc *document) format() string {
+	lines := make([]string, len(doc.lines))
+	for i, line := range doc.lines {
+		state := doc.lineStates[i]
+		if state.inString {
+			lines[i] = line
+			continue
+		}
+
+		line = strings.TrimRightFunc(strings.TrimLeftFunc(line, unicode.IsSpace), unicode.IsSpace)
+		depth := state.depth
+		if strings.HasPrefix(line, ">") && depth > 0 {
+			depth -= 1
+		}
+		if line != "" {
+			line = strings.Repeat("  ", depth) + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toProtocol converts the position p to the position in the protocol,
+// where the character offset is in UTF-16 code units.
+func (doc *document) toProtocol(p pos) position {
+	if p.line >= len(doc.lines) {
+		return position{p.line, 0}
+	}
+	line := doc.lines[p.line]
+	if p.col > len(line) {
+		p.col = len(line)
+	}
+	return position{p.line, len(utf16.Encode([]rune(line[:p.col])))}
+}
+
+// fromProtocol converts the position in the protocol to a position in the document.
+func (doc *document) fromProtocol(p position) pos {
+	if p.Line < 0 || p.Line >= len(doc.lines) {
+		return pos{p.Line, 0}
+	}
+	line := doc.lines[p.Line]
+	col, units := 0, 0
+	for col < len(line) && units < p.Character {
+		r, size := utf8.DecodeRuneInString(line[col:])
+		units += len(utf16.Encode([]rune{r}))
+		col += size
+	}
+	return pos{p.Line, col}
+}
+
+// rangeOf returns the range in the protocol, from the position p to the end of the word at p.
+// The word ends before '<' or '>'. If there's no word at p, the range covers a character.
+func (doc *document) rangeOf(p pos) textRange {
+	end := p
+	if p.line < len(doc.lines) {
+		line := doc.lines[p.line]
+		for end.col < len(line) && !unicode.IsSpace(rune(line[end.col])) {
+			c := line[end.col]
+			if end.col > p.col && (c == '<' || c == '>') {
+				break
+			}
+			end.col += 1
+			if c == '>' {
+				break
+			}
+		}
+	}
+	if end == p {
+		end.col += 1
+	}
+	return textRange{doc.toProtocol(p), doc.toProtocol(end)}
+}
@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests scanning SML documents.
+//
+// Testing Strategy:
+//
+// Create documents from SML text, and test the scanned messages, definitions, folding ranges,
+// formatted text, and the conversion of positions.
+//
+// Partitions:
+//
+// - Message: without data item, with nested data items, without name, without message end,
+//            named header, directions containing '<' and '>'
+// - Text: comments, quoted strings containing '<', '>', '.', "//", string continued on the next line
+// - Definition: data item, value
+// - Position: ASCII, multibyte characters, characters outside the BMP
+
+const testDocument = `// Messages
+#define ACK <B 0>
+S1F1 W H->E AreYouThere .
+S1F2 H<-E OnlineData
+<L
+  <A "MDLN // not a comment">
+    <A '1.0.<0>'>
+>
+.
+S6F11 W H<-E
+  <L <U4 DATAID> <L
+  <A "multi-line\
+   string">
+  >>
+.
+Name: S1F3 W H->E
+`
+
+func TestDocument_Scan(t *testing.T) {
+	doc := newDocument("untitled:test", testDocument)
+
+	assert.Equal(t, []messageSpan{
+		{start: pos{2, 0}, end: pos{2, 25}, name: "AreYouThere", nameStart: pos{2, 12}},
+		{start: pos{3, 0}, end: pos{8, 1}, name: "OnlineData", nameStart: pos{3, 10}},
+		{start: pos{9, 0}, end: pos{14, 1}},
+		{start: pos{15, 0}, end: pos{15, 17}, name: "Name", nameStart: pos{15, 0}},
+	}, doc.messages)
+	assert.Equal(t, []definitionSpan{{"ACK", pos{1, 8}}}, doc.definitions)
+	assert.Equal(t, []foldingRange{{4, 7}, {11, 12}, {10, 13}, {10, 13}}, doc.folds)
+	assert.False(t, doc.hasInclude)
+	assert.True(t, newDocument("untitled:test", `#include "common.sml"`).hasInclude)
+}
+
+func TestDocument_Format(t *testing.T) {
+	doc := newDocument("untitled:test", testDocument)
+	assert.Equal(t, `// Messages
+#define ACK <B 0>
+S1F1 W H->E AreYouThere .
+S1F2 H<-E OnlineData
+<L
+  <A "MDLN // not a comment">
+  <A '1.0.<0>'>
+>
+.
+S6F11 W H<-E
+<L <U4 DATAID> <L
+    <A "multi-line\
+   string">
+  >>
+.
+Name: S1F3 W H->E
+`, doc.format())
+
+	formatted := newDocument("untitled:test", doc.format())
+	assert.Equal(t, formatted.text, formatted.format())
+}
+
+func TestDocument_Position(t *testing.T) {
+	doc := newDocument("untitled:test", "S1F1 W H->E メッセージ\n<A \"😀x\">\n.")
+
+	var tests = []struct {
+		pos      pos      // position in the document
+		protocol position // position in the protocol
+	}{
+		{pos{0, 0}, position{0, 0}},
+		{pos{0, 12}, position{0, 12}},
+		{pos{0, 15}, position{0, 13}},
+		{pos{1, 4}, position{1, 4}},
+		{pos{1, 8}, position{1, 6}},
+		{pos{1, 9}, position{1, 7}},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %v", i, test.pos)
+		assert.Equal(t, test.protocol, doc.toProtocol(test.pos))
+		assert.Equal(t, test.pos, doc.fromProtocol(test.protocol))
+	}
+
+	word, start := doc.wordAt(pos{0, 13})
+	assert.Equal(t, "", word)
+	assert.Equal(t, pos{0, 13}, start)
+	assert.Equal(t, []messageSpan{{start: pos{0, 0}, end: pos{2, 1}, name: "メッセージ", nameStart: pos{0, 12}}}, doc.messages)
+}
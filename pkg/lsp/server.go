@@ -0,0 +1,488 @@
+// Package lsp implements a language server for SML files, using the Language Server Protocol.
+//
+// The server communicates with the editor over a pair of streams, e.g. stdin/stdout,
+// and supports the following features on the SML documents opened in the editor.
+//
+//   - Diagnostics: parsing errors and warnings, published when a document is opened or saved
+//   - Hover: encoded byte size and HSMS bytes in hex of the message under the cursor
+//   - Go to definition: message names and references of named definitions, e.g. $NAME
+//   - Completion: data item types, message names, and names of definitions
+//   - Document formatting: indentation of the nested data items
+//   - Folding ranges: multi-line data items, e.g. nested lists
+//
+// The documents are written in the default SML dialect. Include directives are resolved
+// in the file system, using the contents of the opened documents over the files.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+// Server is a mutable data type that serves the Language Server Protocol for SML files.
+type Server struct {
+	documents map[string]*document // opened documents by their URIs
+	out       io.Writer            // output stream to the editor
+	shutdown  bool                 // whether shutdown request is received
+}
+
+// NewServer creates a new language server.
+func NewServer() *Server {
+	return &Server{documents: map[string]*document{}}
+}
+
+// Serve reads requests and notifications from r, and writes responses and notifications to w,
+// until the exit notification is received or r is closed.
+//
+// Returns nil when the exit notification is received after the shutdown request,
+// or an error otherwise.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.out = w
+	reader := bufio.NewReader(r)
+	for {
+		msg, err := readMessage(reader)
+		if err == io.EOF {
+			return fmt.Errorf("input closed before exit notification")
+		} else if err != nil && msg == nil {
+			return err
+		} else if err != nil {
+			s.reply(nil, nil, &responseError{codeParseError, err.Error()})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit notification before shutdown request")
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(msg)
+		if msg.ID != nil {
+			if err := s.reply(msg.ID, result, rerr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// reply writes the response of the request with the id.
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *responseError) error {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	if result == nil && rerr == nil {
+		result = json.RawMessage("null")
+	}
+	return writeMessage(s.out, &message{ID: id, Result: result, Error: rerr})
+}
+
+// notify writes the notification with the method and the params.
+func (s *Server) notify(method string, params interface{}) {
+	content, _ := json.Marshal(params)
+	writeMessage(s.out, &message{Method: method, Params: content})
+}
+
+// handle handles the request or the notification msg, and returns the result of the request.
+func (s *Server) handle(msg *message) (interface{}, *responseError) {
+	if s.shutdown && msg.ID != nil {
+		return nil, &responseError{codeInvalidRequest, "server is shut down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			s.documents[params.TextDocument.URI] = newDocument(params.TextDocument.URI, params.TextDocument.Text)
+			s.publishDiagnostics(params.TextDocument.URI)
+		}
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil && len(params.ContentChanges) > 0 {
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.documents[params.TextDocument.URI] = newDocument(params.TextDocument.URI, text)
+		}
+		return nil, nil
+	case "textDocument/didSave":
+		var params documentParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			s.publishDiagnostics(params.TextDocument.URI)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params documentParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{params.TextDocument.URI, []diagnostic{}})
+		}
+		return nil, nil
+	case "textDocument/hover":
+		return s.positionRequest(msg, s.hover)
+	case "textDocument/definition":
+		return s.positionRequest(msg, s.definition)
+	case "textDocument/completion":
+		return s.positionRequest(msg, s.completion)
+	case "textDocument/formatting":
+		return s.documentRequest(msg, s.formatting)
+	case "textDocument/foldingRange":
+		return s.documentRequest(msg, s.foldingRanges)
+	}
+
+	if msg.ID == nil {
+		// Notifications that are not supported, e.g. initialized, are ignored
+		return nil, nil
+	}
+	return nil, &responseError{codeMethodNotFound, fmt.Sprintf("method not supported: %s", msg.Method)}
+}
+
+// positionRequest handles the request with textDocument/position params using the handler.
+func (s *Server) positionRequest(msg *message, handler func(*document, pos) interface{}) (interface{}, *responseError) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, &responseError{codeInvalidParams, err.Error()}
+	}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{codeInvalidParams, fmt.Sprintf("document not opened: %s", params.TextDocument.URI)}
+	}
+	return handler(doc, doc.fromProtocol(params.Position)), nil
+}
+
+// documentRequest handles the request with textDocument params using the handler.
+func (s *Server) documentRequest(msg *message, handler func(*document) interface{}) (interface{}, *responseError) {
+	var params documentParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return nil, &responseError{codeInvalidParams, err.Error()}
+	}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{codeInvalidParams, fmt.Sprintf("document not opened: %s", params.TextDocument.URI)}
+	}
+	return handler(doc), nil
+}
+
+// initialize returns the result of the initialize request.
+func (s *Server) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    1, // Full
+				"save":      true,
+			},
+			"hoverProvider":      true,
+			"definitionProvider": true,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"<", "$"},
+			},
+			"documentFormattingProvider": true,
+			"foldingRangeProvider":       true,
+		},
+		"serverInfo": map[string]interface{}{"name": "smlls"},
+	}
+}
+
+// Diagnostics
+
+var reErrorPosition = regexp.MustCompile(`^Ln (\d+), Col (\d+): (.*)$`)
+
+// publishDiagnostics publishes the parsing errors and warnings of the document with the uri.
+func (s *Server) publishDiagnostics(uri string) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return
+	}
+
+	_, errs, warnings := s.parse(doc)
+	diagnostics := []diagnostic{}
+	for _, e := range errs {
+		diagnostics = append(diagnostics, s.diagnostic(doc, e, severityError))
+	}
+	for _, w := range warnings {
+		diagnostics = append(diagnostics, s.diagnostic(doc, w, severityWarning))
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diagnostics})
+}
+
+// diagnostic converts the parsing error or warning text to a diagnostic of the document.
+// Errors in the other files, e.g. included files, are placed at the start of the document.
+func (s *Server) diagnostic(doc *document, text string, severity int) diagnostic {
+	if name := fileName(doc.uri); name != "" {
+		text = strings.TrimPrefix(text, name+": ")
+	}
+
+	d := diagnostic{Severity: severity, Source: "sml", Message: text}
+	if m := reErrorPosition.FindStringSubmatch(text); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		col, _ := strconv.Atoi(m[2])
+		p := pos{line - 1, 0}
+		if p.line < len(doc.lines) {
+			// Column number of the parser is counted in runes
+			l := doc.lines[p.line]
+			for i := 1; i < col && p.col < len(l); i++ {
+				_, size := utf8.DecodeRuneInString(l[p.col:])
+				p.col += size
+			}
+		}
+		d.Range = doc.rangeOf(p)
+		d.Message = m[3]
+	}
+	return d
+}
+
+// parse parses the document. Include directives are resolved when the document is a file.
+func (s *Server) parse(doc *document) ([]*ast.DataMessage, []string, []string) {
+	path := filePath(doc.uri)
+	if !doc.hasInclude || path == "" {
+		return sml.Parse(doc.text)
+	}
+
+	dir := filepath.Dir(path)
+	overlay := overlayFS{base: os.DirFS(dir), files: map[string]string{}}
+	for uri, d := range s.documents {
+		if p := filePath(uri); p != "" {
+			if rel, err := filepath.Rel(dir, p); err == nil && fs.ValidPath(filepath.ToSlash(rel)) {
+				overlay.files[filepath.ToSlash(rel)] = d.text
+			}
+		}
+	}
+	return sml.Load(overlay, filepath.Base(path))
+}
+
+// filePath returns the file path of the file URI, or empty string if uri is not a file URI.
+func filePath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// fileName returns the base name of the file URI, or empty string if uri is not a file URI.
+func fileName(uri string) string {
+	if path := filePath(uri); path != "" {
+		return filepath.Base(path)
+	}
+	return ""
+}
+
+// Hover
+
+// hover returns the hover of the message at the position p, that shows the encoded byte size
+// and the HSMS bytes of the message.
+func (s *Server) hover(doc *document, p pos) interface{} {
+	i := doc.messageAt(p)
+	if i == -1 {
+		return nil
+	}
+
+	messages, errs, _ := s.parse(doc)
+	if len(errs) != 0 || len(messages) != len(doc.messages) {
+		return nil
+	}
+	span := doc.messages[i]
+	msg := messages[i]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**%s**\n\n", msg.Header())
+	if variables := msg.Variables(); len(variables) != 0 {
+		fmt.Fprintf(&sb, "The message contains variables: %s", strings.Join(variables, ", "))
+	} else {
+		if msg.WaitBit() == "optional" {
+			msg = msg.SetWaitBit(msg.FunctionCode()%2 == 1)
+		}
+		bytes := msg.SetSessionIDAndSystemBytes(0, []byte{0, 0, 0, 0}).ToBytes()
+		fmt.Fprintf(&sb, "Size: %d bytes (message length %d bytes)\n\n", len(bytes), len(bytes)-4)
+		fmt.Fprintf(&sb, "```\n%s\n```", hexDump(bytes))
+	}
+
+	return hover{
+		Contents: markupContent{"markdown", sb.String()},
+		Range:    textRange{doc.toProtocol(span.start), doc.toProtocol(span.end)},
+	}
+}
+
+// hexDump returns the bytes in hex, 16 bytes per line.
+func hexDump(bytes []byte) string {
+	var sb strings.Builder
+	for i, b := range bytes {
+		if i > 0 && i%16 == 0 {
+			sb.WriteString("\n")
+		} else if i > 0 {
+			sb.WriteString(" ")
+		}
+		fmt.Fprintf(&sb, "%02X", b)
+	}
+	return sb.String()
+}
+
+// Go to definition
+
+// definition returns the location of the message name or the definition,
+// whose name is at the position p.
+func (s *Server) definition(doc *document, p pos) interface{} {
+	word, _ := doc.wordAt(p)
+	if word == "" {
+		return nil
+	}
+
+	for _, d := range s.searchOrder(doc) {
+		if strings.HasPrefix(word, "$") {
+			for _, def := range d.definitions {
+				if "$"+def.name == word {
+					return location{d.uri, d.rangeOf(def.nameStart)}
+				}
+			}
+			continue
+		}
+		for _, msg := range d.messages {
+			if msg.name == word {
+				return location{d.uri, textRange{d.toProtocol(msg.nameStart), d.toProtocol(pos{msg.nameStart.line, msg.nameStart.col + len(word)})}}
+			}
+		}
+	}
+	return nil
+}
+
+// searchOrder returns the opened documents, with doc at first and the others sorted by their URIs.
+func (s *Server) searchOrder(doc *document) []*document {
+	result := []*document{doc}
+	uris := []string{}
+	for uri := range s.documents {
+		if uri != doc.uri {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		result = append(result, s.documents[uri])
+	}
+	return result
+}
+
+// Completion
+
+var dataItemTypes = []string{"L", "A", "B", "BOOLEAN", "F4", "F8", "I1", "I2", "I4", "I8", "U1", "U2", "U4", "U8"}
+
+// completion returns the completion items at the position p; data item types after '<',
+// names of the definitions after '$', and message names otherwise.
+func (s *Server) completion(doc *document, p pos) interface{} {
+	word, start := doc.wordAt(pos{p.line, p.col})
+	if start.col+len(word) > p.col {
+		word = word[:p.col-start.col]
+	}
+	line := ""
+	if p.line < len(doc.lines) {
+		line = doc.lines[p.line]
+	}
+
+	items := []completionItem{}
+	seen := map[string]bool{}
+	add := func(label string, kind int, detail string) {
+		if !seen[label] {
+			items = append(items, completionItem{label, kind, detail})
+			seen[label] = true
+		}
+	}
+
+	switch {
+	case start.col > 0 && line[start.col-1] == '<':
+		for _, typ := range dataItemTypes {
+			add(typ, completionKindKeyword, "data item type")
+		}
+	case strings.HasPrefix(word, "$"):
+		for _, d := range s.searchOrder(doc) {
+			for _, def := range d.definitions {
+				add("$"+def.name, completionKindConstant, "definition")
+			}
+		}
+	default:
+		for _, d := range s.searchOrder(doc) {
+			for _, msg := range d.messages {
+				if msg.name != "" {
+					add(msg.name, completionKindClass, "message")
+				}
+			}
+		}
+	}
+	return items
+}
+
+// Formatting and folding ranges
+
+// formatting returns the text edits to format the document.
+func (s *Server) formatting(doc *document) interface{} {
+	formatted := doc.format()
+	if formatted == doc.text {
+		return []textEdit{}
+	}
+	end := pos{len(doc.lines) - 1, len(doc.lines[len(doc.lines)-1])}
+	return []textEdit{{textRange{position{0, 0}, doc.toProtocol(end)}, formatted}}
+}
+
+// foldingRanges returns the folding ranges of the multi-line data items in the document,
+// sorted by their start lines. Data items with the same range are folded together.
+func (s *Server) foldingRanges(doc *document) interface{} {
+	result := []foldingRange{}
+	seen := map[foldingRange]bool{}
+	for _, fold := range doc.folds {
+		if !seen[fold] {
+			result = append(result, fold)
+			seen[fold] = true
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].StartLine < result[j].StartLine })
+	return result
+}
+
+// overlayFS is a file system that has the files with the contents over the base file system.
+type overlayFS struct {
+	base  fs.FS
+	files map[string]string // file contents by their names
+}
+
+// Open implements fs.FS.
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if text, ok := o.files[name]; ok {
+		return &memFile{strings.NewReader(text), name, int64(len(text))}, nil
+	}
+	return o.base.Open(name)
+}
+
+// memFile is a file in memory, that implements fs.File and fs.FileInfo.
+type memFile struct {
+	*strings.Reader
+	name string
+	size int64
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *memFile) Close() error               { return nil }
+func (f *memFile) Name() string               { return filepath.Base(f.name) }
+func (f *memFile) Size() int64                { return f.size }
+func (f *memFile) Mode() fs.FileMode          { return 0444 }
+func (f *memFile) ModTime() time.Time         { return time.Time{} }
+func (f *memFile) IsDir() bool                { return false }
+func (f *memFile) Sys() interface{}           { return nil }
@@ -0,0 +1,355 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the language server over in-memory streams.
+//
+// Testing Strategy:
+//
+// Run a server with a test client connected by pipes, send requests and notifications
+// as an editor would do, and test the responses and the published diagnostics.
+//
+// Partitions:
+//
+// - Lifecycle: initialize, shutdown, exit with/without shutdown, input closed, unknown method
+// - Diagnostics: on open, on save after change, without errors, errors and warnings,
+//                include directive resolved with opened documents, on close
+// - Hover: message without variables, with variables, outside of messages, parsing errors
+// - Definition: message name in the same document, in other document, definition reference, not found
+// - Completion: after '<', after '$', otherwise
+// - Formatting: formatted, not formatted
+// - Folding ranges: nested lists, same ranges
+
+// client is a test client of the language server.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan *message // messages from the server
+	done     chan error    // result of Serve
+	id       int           // id of the last request
+}
+
+// newClient starts a server, and returns a client connected to it.
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, in: clientOut, messages: make(chan *message, 100), done: make(chan error, 1)}
+
+	go func() {
+		c.done <- NewServer().Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	go func() {
+		reader := bufio.NewReader(clientIn)
+		for {
+			msg, err := readMessage(reader)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+// send sends a request with the id or a notification if id is nil.
+func (c *client) send(id interface{}, method string, params interface{}) {
+	content, _ := json.Marshal(params)
+	msg := &message{Method: method, Params: content}
+	if id != nil {
+		raw, _ := json.Marshal(id)
+		rawID := json.RawMessage(raw)
+		msg.ID = &rawID
+	}
+	assert.NoError(c.t, writeMessage(c.in, msg))
+}
+
+// receive returns the next message from the server.
+func (c *client) receive() *message {
+	select {
+	case msg := <-c.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timeout waiting for a message")
+	}
+	return nil
+}
+
+// request sends a request and returns the result as JSON, and the error of the response.
+func (c *client) request(method string, params interface{}) (string, *responseError) {
+	c.id += 1
+	c.send(c.id, method, params)
+	msg := c.receive()
+	assert.Equal(c.t, fmt.Sprint(c.id), string(*msg.ID))
+	if msg.Error != nil {
+		return "", msg.Error
+	}
+	result, _ := json.Marshal(msg.Result)
+	return string(result), nil
+}
+
+// diagnostics returns the params of the next publishDiagnostics notification.
+func (c *client) diagnostics() publishDiagnosticsParams {
+	msg := c.receive()
+	assert.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+	var params publishDiagnosticsParams
+	json.Unmarshal(msg.Params, &params)
+	return params
+}
+
+// open opens the document with the text, and returns the published diagnostics.
+func (c *client) open(uri, text string) publishDiagnosticsParams {
+	c.send(nil, "textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "sml", "version": 1, "text": text},
+	})
+	return c.diagnostics()
+}
+
+// close shuts down the server and waits for it to exit.
+func (c *client) close() {
+	_, err := c.request("shutdown", nil)
+	assert.Nil(c.t, err)
+	c.send(nil, "exit", nil)
+	assert.NoError(c.t, <-c.done)
+}
+
+// positionParams returns the params of a request at the position.
+func positionParams(uri string, line, character int) interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	c := newClient(t)
+	result, err := c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}})
+	assert.Nil(t, err)
+	assert.Contains(t, result, `"hoverProvider":true`)
+	assert.Contains(t, result, `"foldingRangeProvider":true`)
+	c.send(nil, "initialized", map[string]interface{}{})
+
+	_, err = c.request("workspace/symbol", map[string]interface{}{"query": ""})
+	assert.Equal(t, &responseError{codeMethodNotFound, "method not supported: workspace/symbol"}, err)
+
+	_, err = c.request("textDocument/hover", positionParams("untitled:none", 0, 0))
+	assert.Equal(t, &responseError{codeInvalidParams, "document not opened: untitled:none"}, err)
+
+	c.close()
+
+	c = newClient(t)
+	c.send(nil, "exit", nil)
+	assert.EqualError(t, <-c.done, "exit notification before shutdown request")
+
+	c = newClient(t)
+	c.in.Close()
+	assert.EqualError(t, <-c.done, "input closed before exit notification")
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	c := newClient(t)
+	uri := "untitled:test"
+
+	assert.Equal(t, publishDiagnosticsParams{uri, []diagnostic{}}, c.open(uri, "S1F1 W H->E\n."))
+
+	c.send(nil, "textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"text": "S1F1 W H->E\n<U1 256>\n.\nS1F2 H<-E <A \"テキスト\"> <U1 0> ."}},
+	})
+	c.send(nil, "textDocument/didSave", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}})
+	assert.Equal(t, publishDiagnosticsParams{uri, []diagnostic{
+		{textRange{position{1, 4}, position{1, 7}}, severityError, "sml", "U1 range overflow"},
+		{textRange{position{3, 13}, position{3, 19}}, severityError, "sml", "expected ASCII characters, found 'テ'"},
+		{textRange{position{3, 21}, position{3, 24}}, severityError, "sml", `expected message end character '.', found "<"`},
+	}}, c.diagnostics())
+
+	c.send(nil, "textDocument/didClose", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}})
+	assert.Equal(t, publishDiagnosticsParams{uri, []diagnostic{}}, c.diagnostics())
+	c.close()
+}
+
+func TestServer_DiagnosticsWithInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smlls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "common.sml"), []byte("#define ACK <B 0>\n"), 0644))
+
+	c := newClient(t)
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "main.sml"))}).String()
+	commonURI := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "common.sml"))}).String()
+
+	text := "#include \"common.sml\"\nS6F12 H->E $ACK .\nS1F1 W H->E $UNKNOWN ."
+	assert.Equal(t, publishDiagnosticsParams{uri, []diagnostic{
+		{textRange{position{2, 12}, position{2, 20}}, severityError, "sml", `undefined reference "$UNKNOWN"`},
+	}}, c.open(uri, text))
+
+	// The contents of the opened document are used over the file
+	c.open(commonURI, "#define ACK <B 0>\n#define UNKNOWN <B 1>\n")
+	c.send(nil, "textDocument/didSave", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}})
+	assert.Equal(t, publishDiagnosticsParams{uri, []diagnostic{}}, c.diagnostics())
+
+	result, _ := c.request("textDocument/hover", positionParams(uri, 1, 0))
+	assert.Contains(t, result, "Size: 17 bytes")
+	c.close()
+}
+
+func TestServer_Hover(t *testing.T) {
+	c := newClient(t)
+	uri := "untitled:test"
+	c.open(uri, "// comment\nS1F1 W H->E AreYouThere .\nS1F2 H<-E <L <A MDLN> <A SOFTREV>> .\nS1F3 [W] H->E <U4 1> .")
+
+	var tests = []struct {
+		description string // Test case description
+		line, char  int    // Input position
+		expected    string // expected result
+	}{
+		{
+			description: "Message without data item",
+			line:        1,
+			char:        3,
+			expected:    `{"contents":{"kind":"markdown","value":"**S1F1 W H->E AreYouThere**\n\nSize: 14 bytes (message length 10 bytes)\n\n` + "```" + `\n00 00 00 0A 00 00 81 01 00 00 00 00 00 00\n` + "```" + `"},"range":{"start":{"line":1,"character":0},"end":{"line":1,"character":25}}}`,
+		},
+		{
+			description: "Message with variables",
+			line:        2,
+			char:        20,
+			expected:    `{"contents":{"kind":"markdown","value":"**S1F2 H<-E**\n\nThe message contains variables: MDLN, SOFTREV"},"range":{"start":{"line":2,"character":0},"end":{"line":2,"character":36}}}`,
+		},
+		{
+			description: "Message with optional wait bit",
+			line:        3,
+			char:        0,
+			expected:    `{"contents":{"kind":"markdown","value":"**S1F3 [W] H->E**\n\nSize: 20 bytes (message length 16 bytes)\n\n` + "```" + `\n00 00 00 10 00 00 81 03 00 00 00 00 00 00 B1 04\n00 00 00 01\n` + "```" + `"},"range":{"start":{"line":3,"character":0},"end":{"line":3,"character":22}}}`,
+		},
+		{
+			description: "Outside of messages",
+			line:        0,
+			char:        3,
+			expected:    "null",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		result, err := c.request("textDocument/hover", positionParams(uri, test.line, test.char))
+		assert.Nil(t, err)
+		assert.JSONEq(t, test.expected, result)
+	}
+
+	c.open("untitled:error", "S1F1 W H->E <U1 256> .")
+	result, _ := c.request("textDocument/hover", positionParams("untitled:error", 0, 0))
+	assert.Equal(t, "null", result)
+	c.close()
+}
+
+func TestServer_Definition(t *testing.T) {
+	c := newClient(t)
+	c.open("untitled:a", "#define ACK <B 0>\nS1F1 W H->E AreYouThere .\nS6F12 H->E $ACK .")
+	c.open("untitled:b", "// Reply of AreYouThere\nS1F2 H<-E OnlineData .")
+
+	var tests = []struct {
+		description string // Test case description
+		uri         string // Input document
+		line, char  int    // Input position
+		expected    string // expected result
+	}{
+		{
+			description: "Message name in the same document",
+			uri:         "untitled:a",
+			line:        1,
+			char:        15,
+			expected:    `{"uri":"untitled:a","range":{"start":{"line":1,"character":12},"end":{"line":1,"character":23}}}`,
+		},
+		{
+			description: "Message name in other document",
+			uri:         "untitled:b",
+			line:        0,
+			char:        14,
+			expected:    `{"uri":"untitled:a","range":{"start":{"line":1,"character":12},"end":{"line":1,"character":23}}}`,
+		},
+		{
+			description: "Definition reference",
+			uri:         "untitled:a",
+			line:        2,
+			char:        13,
+			expected:    `{"uri":"untitled:a","range":{"start":{"line":0,"character":8},"end":{"line":0,"character":11}}}`,
+		},
+		{
+			description: "Not found",
+			uri:         "untitled:b",
+			line:        0,
+			char:        4,
+			expected:    "null",
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		result, err := c.request("textDocument/definition", positionParams(test.uri, test.line, test.char))
+		assert.Nil(t, err)
+		assert.JSONEq(t, test.expected, result)
+	}
+	c.close()
+}
+
+func TestServer_Completion(t *testing.T) {
+	c := newClient(t)
+	c.open("untitled:a", "#define ACK <B 0>\nS1F1 W H->E AreYouThere .\nS6F12 H->E <\nS6F12 H->E $A\n")
+	c.open("untitled:b", "S1F2 H<-E OnlineData .")
+
+	labels := func(result string) []string {
+		items := []completionItem{}
+		json.Unmarshal([]byte(result), &items)
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	result, _ := c.request("textDocument/completion", positionParams("untitled:a", 2, 12))
+	assert.Equal(t, dataItemTypes, labels(result))
+
+	result, _ = c.request("textDocument/completion", positionParams("untitled:a", 3, 13))
+	assert.Equal(t, []string{"$ACK"}, labels(result))
+
+	result, _ = c.request("textDocument/completion", positionParams("untitled:a", 4, 0))
+	assert.Equal(t, []string{"AreYouThere", "OnlineData"}, labels(result))
+	c.close()
+}
+
+func TestServer_FormattingAndFoldingRanges(t *testing.T) {
+	c := newClient(t)
+	uri := "untitled:test"
+	c.open(uri, "S1F2 H<-E\n<L\n<L\n<A \"MDLN\">\n  >  \n >\n.")
+	params := map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}
+
+	result, err := c.request("textDocument/formatting", params)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"range":{"start":{"line":0,"character":0},"end":{"line":6,"character":1}},"newText":"S1F2 H<-E\n<L\n  <L\n    <A \"MDLN\">\n  >\n>\n."}]`, result)
+
+	result, err = c.request("textDocument/foldingRange", params)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"startLine":1,"endLine":5},{"startLine":2,"endLine":4}]`, result)
+
+	c.open("untitled:formatted", "S1F1 W H->E\n<L\n  <A>\n>\n.")
+	result, _ = c.request("textDocument/formatting", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": "untitled:formatted"},
+	})
+	assert.Equal(t, "[]", result)
+	assert.False(t, strings.Contains(result, "newText"))
+	c.close()
+}
@@ -91,6 +91,20 @@ func TestParser_DataMessage(t *testing.T) {
 			expectedSystemBytes:  []byte{0, 0, 0, 2},
 			expectedString:       "S50F50 H<->E\n<B[0]>\n.",
 		},
+		{
+			description: `S9F7 <B[3] 0b0 0b1 0b11111111>`,
+			input: []byte{
+				0, 0, 0, 15, 0, 2, 9, 7, 0, 0, 0, 0, 0, 3,
+				33, 3, 0, 1, 255,
+			},
+			expectedType:         "data message",
+			expectedStreamCode:   9,
+			expectedFunctionCode: 7,
+			expectedWaitBit:      "false",
+			expectedSessionID:    2,
+			expectedSystemBytes:  []byte{0, 0, 0, 3},
+			expectedString:       "S9F7 H<->E\n<B[3] 0b0 0b1 0b11111111>\n.",
+		},
 		{
 			description: `S126F254 <BOOLEAN[2] T F>`,
 			input: []byte{
@@ -0,0 +1,78 @@
+package sml
+
+import "strings"
+
+// Dialect specifies variations of the SML syntax used by other SECS tools.
+//
+// The zero value, DialectDefault, is the SML syntax of this library.
+// Each field enables a variation for both parsing and printing. When parsing,
+// the syntax of this library is accepted as well as the enabled variations.
+// When printing with Format, the enabled variations are used.
+type Dialect struct {
+	// BoolType enables the data item type BOOL as an alias of BOOLEAN, e.g. <BOOL T>.
+	BoolType bool
+
+	// StringTypes enables the data item types J (JIS-8 string) and V (variant string).
+	// They are parsed as ASCII data items with a warning, since they are not
+	// supported by this library. It doesn't affect printing.
+	StringTypes bool
+
+	// StarWaitBit enables '*' as the wait bit, e.g. S1F1* or S1F1 *.
+	StarWaitBit bool
+
+	// NamedHeader enables the message name before the stream function code,
+	// followed by a colon, e.g. AreYouThere: S1F1 W.
+	NamedHeader bool
+
+	// OptionalMessageEnd enables omitting the message end character '.'.
+	// A message ends at the start of the next message, a directive, or EOF.
+	OptionalMessageEnd bool
+
+	// SingleQuote prints strings in single quotes, e.g. <A 'text'>.
+	// Single-quoted strings are always accepted by the parser.
+	SingleQuote bool
+}
+
+var (
+	// DialectDefault is the SML syntax of this library.
+	DialectDefault = Dialect{}
+
+	// DialectCompatible accepts all variations supported by Dialect when parsing.
+	// It can be used to parse SML files exported from other SECS tools.
+	DialectCompatible = Dialect{
+		BoolType:           true,
+		StringTypes:        true,
+		StarWaitBit:        true,
+		NamedHeader:        true,
+		OptionalMessageEnd: true,
+	}
+)
+
+// Option configures the parsing of Parse, Load, LoadFile and NewScanner.
+type Option func(*parser)
+
+// WithDialect returns an Option that parses the input in the dialect.
+func WithDialect(dialect Dialect) Option {
+	return func(p *parser) {
+		p.dialect = dialect
+		p.lexer.dialect = dialect
+	}
+}
+
+// isHeaderName reports whether the token t is a message name followed by a colon,
+// which precedes the stream function code in a dialect.
+func isHeaderName(t token) bool {
+	return t.typ == tokenTypeMessageName && len(t.val) > 1 && strings.HasSuffix(t.val, ":")
+}
+
+// isMessageStart reports whether the token t can start a message or a directive,
+// which ends the previous message when the message end character is optional.
+func isMessageStart(t token) bool {
+	switch t.typ {
+	case tokenTypeStreamFunction, tokenTypeDirective, tokenTypeEOF:
+		return true
+	case tokenTypeMessageName:
+		return isHeaderName(t)
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package sml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests SML dialects
+//
+// Testing Strategy:
+//
+// Parse input written in the dialects of other SECS tools with the WithDialect option,
+// and test the parsed messages by their string representation, and errors/warnings by their texts.
+// Parse the same input without the option, and test that it's rejected.
+//
+// Partitions:
+//
+// - Dialect: default, each variation, compatible
+// - Data item type: BOOL, J, V, also as variable names in the default dialect
+// - Wait bit: '*' after the stream function code with/without space, on reply message
+// - Message name: before the stream function code with colon, after the direction
+// - Message end: with/without '.', message with/without data item, followed by directive
+// - Scanner and Load with the option
+
+func TestParse_Dialects(t *testing.T) {
+	var tests = []struct {
+		description      string   // Test case description
+		input            string   // Input to the parser
+		dialect          Dialect  // dialect of the input
+		expectedString   []string // expected string representation of messages
+		expectedErrors   []string // expected error strings
+		expectedWarnings []string // expected warning strings
+	}{
+		{
+			description:      "BOOL data item type",
+			input:            "S1F1 W H->E <L <BOOL T F> <bool>> .",
+			dialect:          Dialect{BoolType: true},
+			expectedString:   []string{"S1F1 W H->E\n<L[2]\n  <BOOLEAN[2] T F>\n  <BOOLEAN[0]>\n>\n."},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:    "J and V data item types",
+			input:          `S1F1 W H->E <L <J "jis"> <V[3] "var">> .`,
+			dialect:        Dialect{StringTypes: true},
+			expectedString: []string{"S1F1 W H->E\n<L[2]\n  <A \"jis\">\n  <A \"var\">\n>\n."},
+			expectedErrors: []string{},
+			expectedWarnings: []string{
+				"Ln 1, Col 17: J data item is not supported, it will be parsed as A",
+				"Ln 1, Col 27: V data item is not supported, it will be parsed as A",
+			},
+		},
+		{
+			description:      "star wait bit",
+			input:            "S1F1* H->E . S1F3 * H->E . S1F5 W H->E .",
+			dialect:          Dialect{StarWaitBit: true},
+			expectedString:   []string{"S1F1 W H->E\n.", "S1F3 W H->E\n.", "S1F5 W H->E\n."},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "star wait bit on reply message",
+			input:            "S1F2 * H<-E .",
+			dialect:          Dialect{StarWaitBit: true},
+			expectedString:   []string{},
+			expectedErrors:   []string{"Ln 1, Col 6: wait bit cannot be true on reply message (function code is even)"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "named header",
+			input:            "AreYouThere: S1F1 W H->E .\nS1F2 H<-E OnlineData .",
+			dialect:          Dialect{NamedHeader: true},
+			expectedString:   []string{"S1F1 W H->E AreYouThere\n.", "S1F2 H<-E OnlineData\n."},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description: "optional message end",
+			input: `S1F1 W H->E
+S1F2 H<-E <L <A "MDLN"> <A "1.0.0">>
+#define ACK <B 0>
+S1F14 H<-E $ACK
+S1F15 W H->E .
+S1F16 H<-E <B 0>`,
+			dialect: Dialect{OptionalMessageEnd: true},
+			expectedString: []string{
+				"S1F1 W H->E\n.",
+				"S1F2 H<-E\n<L[2]\n  <A \"MDLN\">\n  <A \"1.0.0\">\n>\n.",
+				"S1F14 H<-E\n<B[1] 0b0>\n.",
+				"S1F15 W H->E\n.",
+				"S1F16 H<-E\n<B[1] 0b0>\n.",
+			},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description: "compatible dialect",
+			input: `AreYouThere: S1F1*
+OnlineData: S1F2
+<L <A 'MDLN'> <A '1.0.0'>>
+Report: S6F11 W H<-E <L <U4 1> <BOOL T>>
+Ack: S6F12 <B 0> .`,
+			dialect: DialectCompatible,
+			expectedString: []string{
+				"S1F1 W H<->E AreYouThere\n.",
+				"S1F2 H<->E OnlineData\n<L[2]\n  <A \"MDLN\">\n  <A \"1.0.0\">\n>\n.",
+				"S6F11 W H<-E Report\n<L[2]\n  <U4[1] 1>\n  <BOOLEAN[1] T>\n>\n.",
+				"S6F12 H<->E Ack\n<B[1] 0b0>\n.",
+			},
+			expectedErrors: []string{},
+			expectedWarnings: []string{
+				`Ln 2, Col 1: missing message direction, "H<->E" will be used`,
+				`Ln 3, Col 1: missing message direction, "H<->E" will be used`,
+				`Ln 5, Col 12: missing message direction, "H<->E" will be used`,
+			},
+		},
+		{
+			description:      "default dialect, BOOL as a variable",
+			input:            "S1F1 W H->E <L BOOL J V> .",
+			dialect:          DialectDefault,
+			expectedString:   []string{"S1F1 W H->E\n<L\n  BOOL\n  J\n  V\n>\n."},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "default dialect, missing message end",
+			input:            "S1F1 W H->E\nS1F3 W H->E .",
+			dialect:          DialectDefault,
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 2, Col 1: expected '<' or '.', found "S1F3"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "optional message end, unexpected token after data item",
+			input:            "S1F1 W H->E <L> Name",
+			dialect:          Dialect{OptionalMessageEnd: true},
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 17: expected message end character '.', found "Name"`},
+			expectedWarnings: []string{},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		msgs, errs, warnings := Parse(test.input, WithDialect(test.dialect))
+		strs := []string{}
+		for _, msg := range msgs {
+			strs = append(strs, fmt.Sprint(msg))
+		}
+		assert.Equal(t, test.expectedString, strs)
+		assert.Equal(t, test.expectedErrors, errs)
+		assert.Equal(t, test.expectedWarnings, warnings)
+	}
+}
+
+func TestParse_DialectNotEnabled(t *testing.T) {
+	inputs := []string{
+		"S1F1 W H->E <BOOL T> .",
+		"S1F1 W H->E <J \"jis\"> .",
+		"S1F1* H->E .",
+		"AreYouThere: S1F1 W H->E .",
+		"S1F1 W H->E <L>",
+	}
+	for _, input := range inputs {
+		_, errs, _ := Parse(input)
+		assert.NotEmpty(t, errs, input)
+	}
+}
+
+func TestScanner_Dialect(t *testing.T) {
+	input := "A: S1F1*\nB: S1F2 <L <BOOL T> <U1 256>>\nC: S1F3 W\n"
+	scanner := NewScanner(strings.NewReader(input), WithDialect(DialectCompatible))
+
+	results := []string{}
+	for scanner.Scan() {
+		if msg := scanner.Message(); msg != nil {
+			results = append(results, msg.Header())
+		} else {
+			results = append(results, strings.Join(scanner.Errors(), "; "))
+		}
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"S1F1 W H<->E A", "Ln 2, Col 25: U1 range overflow", "S1F3 W H<->E C"}, results)
+}
+
+func TestLoad_Dialect(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.sml":   {Data: []byte("#include \"s1.sml\"\nOnlineData: S1F2 H<-E <L <BOOL T>>")},
+		"s1.sml":     {Data: []byte("AreYouThere: S1F1* H->E")},
+		"strict.sml": {Data: []byte("S1F1 W H->E <BOOL T> .")},
+	}
+
+	msgs, errs, warnings := Load(fsys, "main.sml", WithDialect(DialectCompatible))
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "S1F1 W H->E AreYouThere", msgs[0].Header())
+		assert.Equal(t, "S1F2 H<-E OnlineData", msgs[1].Header())
+	}
+
+	_, errs, _ = Load(fsys, "strict.sml")
+	assert.Len(t, errs, 1)
+}
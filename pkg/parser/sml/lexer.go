@@ -1,7 +1,9 @@
 package sml
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
 	"unicode"
@@ -29,35 +31,57 @@ const (
 
 	// Message header
 	tokenTypeStreamFunction // 'S' [0-9]+ 'F' [0-9]+, case insensitive
-	tokenTypeWaitBit        // 'W', '[W]', case insensitive
+	tokenTypeWaitBit        // 'W', '[W]', case insensitive, or '*' in a dialect
 	tokenTypeDirection      // 'H->E', 'H<-E', 'H<->E', case insensitive
 	tokenTypeMessageName    // Series of characters except whitespaces and comment delimiter
 
+	// Directive, which can appear between messages
+	tokenTypeDirective      // '#include', '#define'
+	tokenTypeDefinitionName // [A-Za-z_] [A-Za-z0-9_]*, following '#define'
+
 	// Message text
 	tokenTypeLeftAngleBracket  // '<'
 	tokenTypeRightAngleBracket // '>'
-	tokenTypeDataItemType      // 'L', 'B', 'BOOLEAN', 'A', 'F4', 'F8', 'I1', 'I2', 'I4', 'I8', 'U1', 'U2', 'U4', 'U8', case insensitive
-	tokenTypeDataItemSize      // '[' [0-9]+ ('..' [0-9]+)? ']'
+	tokenTypeDataItemType      // 'L', 'B', 'BOOLEAN', 'A', 'F4', 'F8', 'I1', 'I2', 'I4', 'I8', 'U1', 'U2', 'U4', 'U8', case insensitive, or 'BOOL', 'J', 'V' in a dialect
+	tokenTypeDataItemSize      // '[' [0-9]+ ']' | '[' [0-9]* '..' [0-9]* ']'
 	tokenTypeNumber            // decimal, hexadecimal, octal, binary, floating-point number including scientific notation, case insensitive
 	tokenTypeBool              // 'T', 'F', case insensitive
 	tokenTypeVariable          // [A-Za-z_] [A-Za-z0-9_]* ('[' [0-9]+ ']')?
-	tokenTypeQuotedString      // string enclosed with double quotes, e.g. "quoted string"
+	tokenTypeValueConstraint   // '{' values or ranges separated by whitespaces '}', following a variable, e.g. {1..100}, {0 1 2}
+	tokenTypeEqualSign         // '=', following a variable or its value constraint, followed by the default value
+	tokenTypeQuotedString      // string enclosed with double or single quotes, e.g. "quoted string", 'quoted string'
 	tokenTypeEllipsis          // '...'
+	tokenTypeReference         // '$' [A-Za-z_] [A-Za-z0-9_]*, can also appear in the message header
+
+	// Parser internal
+	tokenTypeExpansionEnd // end of the tokens expanded from a reference, never emitted by the lexer
 )
 
 // lexer represents the state of the lexical scanner.
 type lexer struct {
-	input     string     // input string being lexed
-	lastState stateFn    // last lexing state function
-	state     stateFn    // next lexing state function to enter
-	pos       int        // current position in the input
-	start     int        // start position of a token being lexed in input string
-	width     int        // width of last rune read from input
-	tokens    chan token // the channel to report scanned tokens
+	input      string        // input string being lexed
+	reader     *bufio.Reader // reader to read more input from; nil if the input is fixed
+	readErr    error         // non-EOF error encountered while reading from the reader
+	lineOffset int           // number of lines discarded from the beginning of the input
+	linePos    int           // position in the input up to which the lines are counted
+	lineCount  int           // number of lines in input[:linePos]
+	lineCol    int           // number of runes before linePos in the line containing linePos
+	depth      int           // nesting depth of the angle brackets in the message text
+	definition bool          // true if the body of a #define directive is being lexed
+	dialect    Dialect       // SML dialect of the input
+	lastState  stateFn       // last lexing state function
+	state      stateFn       // next lexing state function to enter
+	pos        int           // current position in the input
+	start      int           // start position of a token being lexed in input string
+	width      int           // width of last rune read from input
+	tokens     chan token    // the channel to report scanned tokens
 }
 
 const eof rune = -1
 
+// readChunkSize is the minimum number of bytes that the lexer reads at once from a reader.
+const readChunkSize = 64 * 1024
+
 // lex creates a new scanner for the input string.
 func lex(input string) *lexer {
 	l := &lexer{
@@ -68,9 +92,72 @@ func lex(input string) *lexer {
 	return l
 }
 
+// lexReader creates a new scanner that reads the input from r.
+//
+// The input is read in chunks of whole lines, and the lexed input is discarded
+// before each read, so that only the token being lexed and the unread input are kept in memory.
+func lexReader(r io.Reader) *lexer {
+	l := &lexer{
+		reader: bufio.NewReaderSize(r, readChunkSize),
+		state:  lexMessageHeader,
+		tokens: make(chan token, 2),
+	}
+	return l
+}
+
+// fill reads more input from the reader, if exists, after discarding the lexed input.
+// The input is read until the end of a line, so that a token is never split
+// between two reads. At least as many bytes as the kept input are read, so that
+// the input is copied in amortized linear time even if a token spans many reads.
+// Returns false if no more input is available.
+func (l *lexer) fill() bool {
+	if l.reader == nil {
+		return false
+	}
+
+	l.discard()
+	size := readChunkSize
+	if len(l.input) > size {
+		size = len(l.input)
+	}
+	var sb strings.Builder
+	sb.Grow(len(l.input) + size)
+	sb.WriteString(l.input)
+	n := 0
+	for n < size {
+		line, err := l.reader.ReadString('\n')
+		sb.WriteString(line)
+		n += len(line)
+		if err != nil {
+			if err != io.EOF {
+				l.readErr = err
+			}
+			l.reader = nil
+			break
+		}
+	}
+
+	l.input = sb.String()
+	return n > 0
+}
+
+// discard drops the lexed input before the current start position, i.e. before the token
+// being lexed. It keeps the memory usage bounded when the input is read from a reader.
+func (l *lexer) discard() {
+	if l.start == 0 {
+		return
+	}
+	l.lineColumn() // update line count up to the start position
+	l.lineOffset += l.lineCount
+	l.lineCount = 0
+	l.input = l.input[l.start:]
+	l.pos -= l.start
+	l.start, l.linePos = 0, 0
+}
+
 // next returns the next rune in the input.
 func (l *lexer) next() (r rune) {
-	if l.pos >= len(l.input) {
+	if l.pos >= len(l.input) && !l.fill() {
 		l.width = 0
 		return eof
 	}
@@ -148,10 +235,18 @@ func (l *lexer) acceptRun(valid string) {
 
 // lineColumn returns line and column number of current start position.
 func (l *lexer) lineColumn() (line, column int) {
-	// Doing it this way means we don't have to worry about peek double counting
-	line = 1 + strings.Count(l.input[:l.start], "\n")
-	lineStart := 1 + strings.LastIndex(l.input[:l.start], "\n")
-	column = 1 + utf8.RuneCountInString(l.input[lineStart:l.start])
+	// Doing it this way means we don't have to worry about peek double counting.
+	// The lines are counted incrementally from the last counted position,
+	// since the start position only moves forward.
+	if i := strings.LastIndex(l.input[l.linePos:l.start], "\n"); i >= 0 {
+		l.lineCount += strings.Count(l.input[l.linePos:l.start], "\n")
+		l.lineCol = utf8.RuneCountInString(l.input[l.linePos+i+1 : l.start])
+	} else {
+		l.lineCol += utf8.RuneCountInString(l.input[l.linePos:l.start])
+	}
+	l.linePos = l.start
+	line = l.lineOffset + 1 + l.lineCount
+	column = 1 + l.lineCol
 	return line, column
 }
 
@@ -179,6 +274,18 @@ func (l *lexer) nextToken() token {
 	// should not reach here
 }
 
+// Regular expressions to match the tokens at the current position of the input.
+var (
+	reDirective      = regexp.MustCompile(`^#(include|define)\b`)
+	reReference      = regexp.MustCompile(`^\$[A-Za-z_]\w*`)
+	reStreamFunction = regexp.MustCompile(`^[Ss]\d+[Ff]\d+`)
+	reWaitBit        = regexp.MustCompile(`^([Ww]|\[[Ww]\])`)
+	reDirection      = regexp.MustCompile(`^[Hh](->|<->|<-)[Ee]`)
+	reEllipsis       = regexp.MustCompile(`^\.\.\.(\[\d+\])?`)
+	reIdentifier     = regexp.MustCompile(`^[A-Za-z_]\w*`)
+	reArrayNotation  = regexp.MustCompile(`^(\[\d+\])+`)
+)
+
 // stateFn represents the state of the lexer as a function that returns the next state
 type stateFn func(*lexer) stateFn
 
@@ -192,13 +299,22 @@ func (l *lexer) terminate() stateFn {
 // lexMessageHeader scans the elements that can appear in the message header.
 func lexMessageHeader(l *lexer) stateFn {
 	for {
+		if l.pos >= len(l.input) {
+			l.fill()
+		}
+
 		// Handle a line comment
 		if strings.HasPrefix(l.input[l.pos:], "//") {
 			return lexComment
 		}
 
+		// Handle directive
+		if reDirective.MatchString(l.input[l.pos:]) {
+			return lexDirective
+		}
+
 		// Handle stream function code
-		re := regexp.MustCompile(`^[Ss]\d+[Ff]\d+`)
+		re := reStreamFunction
 		if loc := re.FindStringIndex(l.input[l.pos:]); loc != nil {
 			l.pos += loc[1]
 			l.emitUppercase(tokenTypeStreamFunction)
@@ -206,21 +322,36 @@ func lexMessageHeader(l *lexer) stateFn {
 		}
 
 		// Handle wait bit
-		re = regexp.MustCompile(`^([Ww]|\[[Ww]\])`)
+		re = reWaitBit
 		if loc := re.FindStringIndex(l.input[l.pos:]); loc != nil {
 			l.pos += loc[1]
 			l.emitUppercase(tokenTypeWaitBit)
 			return lexMessageHeader
 		}
 
+		// Handle wait bit in a dialect
+		if l.dialect.StarWaitBit && strings.HasPrefix(l.input[l.pos:], "*") {
+			l.pos += 1
+			l.emit(tokenTypeWaitBit)
+			return lexMessageHeader
+		}
+
 		// Handle message direction
-		re = regexp.MustCompile(`^[Hh](->|<->|<-)[Ee]`)
+		re = reDirection
 		if loc := re.FindStringIndex(l.input[l.pos:]); loc != nil {
 			l.pos += loc[1]
 			l.emitUppercase(tokenTypeDirection)
 			return lexMessageHeader
 		}
 
+		// Handle reference to a definition, which can be used as the message text
+		re = reReference
+		if loc := re.FindStringIndex(l.input[l.pos:]); loc != nil {
+			l.pos += loc[1]
+			l.emit(tokenTypeReference)
+			return lexMessageHeader
+		}
+
 		switch r := l.next(); r {
 		case eof:
 			return lexEOF
@@ -228,8 +359,10 @@ func lexMessageHeader(l *lexer) stateFn {
 			l.ignore()
 		case '.':
 			l.emit(tokenTypeMessageEnd)
+			l.discard()
 			return lexMessageHeader
 		case '<':
+			l.depth = 1
 			l.emit(tokenTypeLeftAngleBracket)
 			return lexMessageText
 		default:
@@ -250,40 +383,66 @@ func lexMessageHeader(l *lexer) stateFn {
 // lexMessageText scans the elements inside the message text.
 func lexMessageText(l *lexer) stateFn {
 	for {
+		if l.pos >= len(l.input) {
+			l.fill()
+		}
+
 		// Handle a line comment
 		if strings.HasPrefix(l.input[l.pos:], "//") {
 			return lexComment
 		}
 
-		re := regexp.MustCompile(`^\.\.\.(\[\d+\])?`)
+		re := reEllipsis
 		if loc := re.FindStringIndex(l.input[l.pos:]); loc != nil {
 			l.pos += loc[1]
 			l.emit(tokenTypeEllipsis)
-			return lexMessageText
+			return l.afterToken()
+		}
+
+		// Handle reference to a definition
+		re = reReference
+		if loc := re.FindStringIndex(l.input[l.pos:]); loc != nil {
+			l.pos += loc[1]
+			l.emit(tokenTypeReference)
+			return l.afterToken()
 		}
 
 		// Handle data types or variables
-		re = regexp.MustCompile(`^[A-Za-z_]\w*`)
+		re = reIdentifier
 		if loc := re.FindStringIndex(l.input[l.pos:]); loc != nil {
-			switch strings.ToUpper(l.input[l.pos : l.pos+loc[1]]) {
+			typ := strings.ToUpper(l.input[l.pos : l.pos+loc[1]])
+			if (typ == "BOOL" && l.dialect.BoolType) || ((typ == "J" || typ == "V") && l.dialect.StringTypes) {
+				l.pos += loc[1]
+				l.emitUppercase(tokenTypeDataItemType)
+				return l.afterToken()
+			}
+
+			switch typ {
 			case "L", "A", "B", "BOOLEAN", "F4", "F8",
 				"I1", "I2", "I4", "I8", "U1", "U2", "U4", "U8":
 				l.pos += loc[1]
 				l.emitUppercase(tokenTypeDataItemType)
-				return lexMessageText
+				return l.afterToken()
 			case "T", "F":
 				l.pos += loc[1]
 				l.emitUppercase(tokenTypeBool)
-				return lexMessageText
+				return l.afterToken()
 			default:
 				l.pos += loc[1]
 				// Handle optional array-like notation
-				re = regexp.MustCompile(`^(\[\d+\])+`)
+				re = reArrayNotation
 				if loc = re.FindStringIndex(l.input[l.pos:]); loc != nil {
 					l.pos += loc[1]
 				}
 				l.emit(tokenTypeVariable)
-				return lexMessageText
+				// Handle optional value constraint and default value
+				switch l.peek() {
+				case '{':
+					return lexValueConstraint
+				case '=':
+					return lexEqualSign
+				}
+				return l.afterToken()
 			}
 		}
 
@@ -298,18 +457,22 @@ func lexMessageText(l *lexer) stateFn {
 		case eof:
 			return lexEOF
 		case '<':
+			l.depth += 1
 			l.emit(tokenTypeLeftAngleBracket)
 			return lexMessageText
 		case '>':
+			l.depth -= 1
 			l.emit(tokenTypeRightAngleBracket)
-			return lexMessageText
+			return l.afterToken()
 		case '.':
+			l.depth, l.definition = 0, false
 			l.emit(tokenTypeMessageEnd)
+			l.discard()
 			return lexMessageHeader
 		case '[':
 			l.backup()
 			return lexDataItemSize
-		case '"':
+		case '"', '\'':
 			l.backup()
 			return lexQuotedString
 		case ' ', '\t', '\r', '\n':
@@ -321,6 +484,23 @@ func lexMessageText(l *lexer) stateFn {
 	// should not reach here
 }
 
+// afterToken returns the next state function after a token in the message text,
+// except '<', is scanned.
+// The body of a #define directive is either a data item or a single token,
+// so that the lexer returns to the message header state at the end of it.
+// Likewise, when the message end character is optional in the dialect,
+// the lexer returns to the message header state at the end of the message text.
+func (l *lexer) afterToken() stateFn {
+	if l.depth > 0 || !(l.definition || l.dialect.OptionalMessageEnd) {
+		return lexMessageText
+	}
+	if !l.definition {
+		l.discard()
+	}
+	l.depth, l.definition = 0, false
+	return lexMessageHeader
+}
+
 // lexEOF scans a EOF which is known to be present, and terminates the running lexer.
 func lexEOF(l *lexer) stateFn {
 	l.emitEOF()
@@ -346,7 +526,7 @@ func lexComment(l *lexer) stateFn {
 	return l.lastState
 }
 
-// lexDataItemSize scans a data item's size, e.g. [2] or [2..7].
+// lexDataItemSize scans a data item's size, e.g. [2], [2..7], or [..] which means no limit.
 // The left square bracket is known to be present.
 func lexDataItemSize(l *lexer) stateFn {
 	numberFound := false
@@ -357,7 +537,9 @@ func lexDataItemSize(l *lexer) stateFn {
 		l.acceptRun("0123456789")
 		l.acceptRun(" \t\r\n")
 	}
+	rangeFound := false
 	if strings.HasPrefix(l.input[l.pos:], "..") {
+		rangeFound = true
 		l.pos += 2
 		l.acceptRun(" \t\r\n")
 		if l.accept("0123456789") {
@@ -366,25 +548,107 @@ func lexDataItemSize(l *lexer) stateFn {
 			l.acceptRun(" \t\r\n")
 		}
 	}
-	if !(l.accept("]") && numberFound) {
+	if !(l.accept("]") && (numberFound || rangeFound)) {
 		return l.errorf("invalid data item size")
 	}
 	l.emitSpaceRemoved(tokenTypeDataItemSize)
+	return l.afterToken()
+}
+
+// lexValueConstraint scans a value constraint of a variable, e.g. {1..100} or {0 1 2}.
+// The left curly bracket is known to be present.
+// The constraint should be closed in the same line.
+func lexValueConstraint(l *lexer) stateFn {
+	l.accept("{")
+	for {
+		switch l.next() {
+		case '}':
+			l.emit(tokenTypeValueConstraint)
+			if l.peek() == '=' {
+				return lexEqualSign
+			}
+			return l.afterToken()
+		case '{', '<', '>', '\r', '\n', eof:
+			return l.errorf("unclosed value constraint")
+		}
+	}
+}
+
+// lexEqualSign scans a equal sign between a variable and its default value, e.g. DATAID=0.
+// The equal sign is known to be present. The default value is scanned in the message text.
+func lexEqualSign(l *lexer) stateFn {
+	l.accept("=")
+	l.emit(tokenTypeEqualSign)
 	return lexMessageText
 }
 
-// lexQuotedString scans a string inside double quotes.
-// The left double quote is known to be present.
+// lexDirective scans a directive and its argument,
+// e.g. #include "file.sml", or #define NAME <L <U4 RPTID>>.
+// The directive is known to be present.
+func lexDirective(l *lexer) stateFn {
+	loc := reDirective.FindStringIndex(l.input[l.pos:])
+	l.pos += loc[1]
+	name := l.input[l.start:l.pos]
+	l.emit(tokenTypeDirective)
+
+	l.acceptRun(" \t")
+	l.ignore()
+	if name == "#define" {
+		loc := reIdentifier.FindStringIndex(l.input[l.pos:])
+		if loc == nil {
+			return l.errorf("expected name after %s", name)
+		}
+		l.pos += loc[1]
+		l.emit(tokenTypeDefinitionName)
+		l.acceptRun(" \t\r\n")
+		l.ignore()
+		l.depth, l.definition = 0, true
+		return lexMessageText
+	}
+
+	if l.peek() != '"' {
+		return l.errorf("expected quoted string after %s", name)
+	}
+	if !l.scanQuotedString() {
+		return l.errorf("unclosed quoted string")
+	}
+	l.emit(tokenTypeQuotedString)
+	return lexMessageHeader
+}
+
+// lexQuotedString scans a string inside double or single quotes.
+// The left quote is known to be present.
 func lexQuotedString(l *lexer) stateFn {
-	l.accept(`"`)
-	i := strings.Index(l.input[l.pos:], `"`)
-	j := strings.IndexAny(l.input[l.pos:], "\r\n")
-	if i < 0 || (j > 0 && j < i) {
+	if !l.scanQuotedString() {
 		return l.errorf("unclosed quoted string")
 	}
-	l.pos += i + 1 // Include the double quote
 	l.emit(tokenTypeQuotedString)
-	return lexMessageText
+	return l.afterToken()
+}
+
+// scanQuotedString consumes a string inside double or single quotes, including the quotes.
+// The left quote is known to be present.
+//
+// A backslash escapes the next character, including the quote and the line break;
+// the escape sequences are interpreted by the parser.
+// Returns false if the string is not closed before an unescaped line break or EOF.
+func (l *lexer) scanQuotedString() bool {
+	quote := l.next()
+	for {
+		switch l.next() {
+		case quote:
+			return true
+		case '\\':
+			switch l.next() {
+			case eof:
+				return false
+			case '\r':
+				l.accept("\n")
+			}
+		case '\r', '\n', eof:
+			return false
+		}
+	}
 }
 
 // lexNumber scans a number, which is known to be present.
@@ -423,7 +687,7 @@ func lexNumber(l *lexer) stateFn {
 	}
 
 	l.emit(tokenTypeNumber)
-	return lexMessageText
+	return l.afterToken()
 }
 
 // Helper functions
@@ -19,6 +19,7 @@ import (
 // - Case insensitivity for tokenTypeStreamFunction, tokenTypeWaitBit, tokenTypeDataItemType, tokenTypeNumber
 // - Optional strings in input, like dot in the tokenTypeNumber
 // - Comments; it can be anywhere except inside quoted string
+// - Value constraint and default value following a variable
 // - Errors
 
 // Helper functions and variables
@@ -202,6 +203,14 @@ func TestLexer_DataItemSize(t *testing.T) {
 			input:    "[..42]",
 			expected: []token{{tokenTypeDataItemSize, "[..42]", 1, 1}},
 		},
+		{
+			input:    "[ .. ]",
+			expected: []token{{tokenTypeDataItemSize, "[..]", 1, 1}},
+		},
+		{ // Wrong syntax
+			input:    "[]",
+			expected: []token{tokenError},
+		},
 		{ // Wrong syntax
 			input:    "[0 ... 42]",
 			expected: []token{tokenError},
@@ -358,6 +367,70 @@ func TestLexer_Variable(t *testing.T) {
 	}
 }
 
+func TestLexer_ValueConstraint(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected []token
+	}{
+		{
+			input:    "SVID{1..100}",
+			expected: []token{{tokenTypeVariable, "SVID", 1, 1}, {tokenTypeValueConstraint, "{1..100}", 1, 5}},
+		},
+		{
+			input:    "ACK[0]{ 0 1  2 } 3",
+			expected: []token{{tokenTypeVariable, "ACK[0]", 1, 1}, {tokenTypeValueConstraint, "{ 0 1  2 }", 1, 7}, {tokenTypeNumber, "3", 1, 18}},
+		},
+		{
+			input:    "TEMP{..-40 -0.5..1e3}>",
+			expected: []token{{tokenTypeVariable, "TEMP", 1, 1}, {tokenTypeValueConstraint, "{..-40 -0.5..1e3}", 1, 5}, tokenRAB},
+		},
+		{
+			input:    "V {1}",
+			expected: []token{{tokenTypeVariable, "V", 1, 1}, tokenError},
+		},
+		{
+			input:    "V{1..2>",
+			expected: []token{{tokenTypeVariable, "V", 1, 1}, tokenError},
+		},
+		{
+			input:    "V{1\n}",
+			expected: []token{{tokenTypeVariable, "V", 1, 1}, tokenError},
+		},
+	}
+	for _, test := range tests {
+		tokens := doLex(test.input, lexMessageText)
+		assert.Equal(t, test.expected, tokens)
+	}
+}
+
+func TestLexer_DefaultValue(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected []token
+	}{
+		{
+			input:    "DATAID=0",
+			expected: []token{{tokenTypeVariable, "DATAID", 1, 1}, {tokenTypeEqualSign, "=", 1, 7}, {tokenTypeNumber, "0", 1, 8}},
+		},
+		{
+			input:    "SVID{1..100}= 1",
+			expected: []token{{tokenTypeVariable, "SVID", 1, 1}, {tokenTypeValueConstraint, "{1..100}", 1, 5}, {tokenTypeEqualSign, "=", 1, 13}, {tokenTypeNumber, "1", 1, 15}},
+		},
+		{
+			input:    `MDLN="model" FLAG=T`,
+			expected: []token{{tokenTypeVariable, "MDLN", 1, 1}, {tokenTypeEqualSign, "=", 1, 5}, {tokenTypeQuotedString, `"model"`, 1, 6}, {tokenTypeVariable, "FLAG", 1, 14}, {tokenTypeEqualSign, "=", 1, 18}, {tokenTypeBool, "T", 1, 19}},
+		},
+		{
+			input:    "V =0",
+			expected: []token{{tokenTypeVariable, "V", 1, 1}, tokenError},
+		},
+	}
+	for _, test := range tests {
+		tokens := doLex(test.input, lexMessageText)
+		assert.Equal(t, test.expected, tokens)
+	}
+}
+
 func TestLexer_QuotedString(t *testing.T) {
 	var tests = []struct {
 		input    string
@@ -387,7 +460,19 @@ func TestLexer_QuotedString(t *testing.T) {
 			input:    "\"\twith\t\ttabs\t\"",
 			expected: []token{{tokenTypeQuotedString, "\"\twith\t\ttabs\t\"", 1, 1}},
 		},
-		// Wrong syntax; quoted strings must finished in one line
+		{
+			input:    `"escaped \"quote\" \\"`,
+			expected: []token{{tokenTypeQuotedString, `"escaped \"quote\" \\"`, 1, 1}},
+		},
+		{
+			input:    `'single "quoted" \'string\'' 'a'`,
+			expected: []token{{tokenTypeQuotedString, `'single "quoted" \'string\''`, 1, 1}, {tokenTypeQuotedString, `'a'`, 1, 30}},
+		},
+		{
+			input:    "\"continued \\\n  line\\\r\n\" \"next\"",
+			expected: []token{{tokenTypeQuotedString, "\"continued \\\n  line\\\r\n\"", 1, 1}, {tokenTypeQuotedString, `"next"`, 3, 3}},
+		},
+		// Wrong syntax; quoted strings must finished in one line, unless the line break is escaped
 		{
 			input:    "\"line feed\n\"",
 			expected: []token{tokenError},
@@ -396,6 +481,14 @@ func TestLexer_QuotedString(t *testing.T) {
 			input:    "\"carriage return\r\"",
 			expected: []token{tokenError},
 		},
+		{
+			input:    `'mismatched quotes"`,
+			expected: []token{tokenError},
+		},
+		{
+			input:    `"escaped EOF\`,
+			expected: []token{tokenError},
+		},
 	}
 	for _, test := range tests {
 		tokens := doLex(test.input, lexMessageText)
@@ -435,6 +528,91 @@ func TestLexer_Ellipsis_Bool_Error(t *testing.T) {
 	}
 }
 
+func TestLexer_Directive(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected []token
+	}{
+		{
+			input:    `#include "file.sml"`,
+			expected: []token{{tokenTypeDirective, "#include", 1, 1}, {tokenTypeQuotedString, `"file.sml"`, 1, 10}},
+		},
+		{
+			input: "#include\t\"dir/file.sml\" // comment\nS1F1",
+			expected: []token{
+				{tokenTypeDirective, "#include", 1, 1},
+				{tokenTypeQuotedString, `"dir/file.sml"`, 1, 10},
+				{tokenTypeComment, "// comment", 1, 25},
+				{tokenTypeStreamFunction, "S1F1", 2, 1},
+			},
+		},
+		{ // Not a directive
+			input:    "#includes",
+			expected: []token{{tokenTypeMessageName, "#includes", 1, 1}},
+		},
+		{ // Missing file path
+			input:    "#include file.sml",
+			expected: []token{{tokenTypeDirective, "#include", 1, 1}, tokenError},
+		},
+		{ // Unclosed file path
+			input:    "#include \"file.sml\n\"",
+			expected: []token{{tokenTypeDirective, "#include", 1, 1}, tokenError},
+		},
+		{
+			input: "#define REPORT <L <U4 RPTID> $VIDS>\nS1F1",
+			expected: []token{
+				{tokenTypeDirective, "#define", 1, 1},
+				{tokenTypeDefinitionName, "REPORT", 1, 9},
+				tokenLAB,
+				{tokenTypeDataItemType, "L", 1, 17},
+				tokenLAB,
+				{tokenTypeDataItemType, "U4", 1, 20},
+				{tokenTypeVariable, "RPTID", 1, 23},
+				tokenRAB,
+				{tokenTypeReference, "$VIDS", 1, 30},
+				tokenRAB,
+				{tokenTypeStreamFunction, "S1F1", 2, 1},
+			},
+		},
+		{ // Single value bodies
+			input: "#define ACK 0x00 #define\tMDLN\n\"model\" #define ON T #define C $MDLN S1F1",
+			expected: []token{
+				{tokenTypeDirective, "#define", 1, 1},
+				{tokenTypeDefinitionName, "ACK", 1, 9},
+				{tokenTypeNumber, "0x00", 1, 13},
+				{tokenTypeDirective, "#define", 1, 18},
+				{tokenTypeDefinitionName, "MDLN", 1, 26},
+				{tokenTypeQuotedString, `"model"`, 2, 1},
+				{tokenTypeDirective, "#define", 2, 9},
+				{tokenTypeDefinitionName, "ON", 2, 17},
+				{tokenTypeBool, "T", 2, 20},
+				{tokenTypeDirective, "#define", 2, 22},
+				{tokenTypeDefinitionName, "C", 2, 30},
+				{tokenTypeReference, "$MDLN", 2, 32},
+				{tokenTypeStreamFunction, "S1F1", 2, 38},
+			},
+		},
+		{ // Reference as the message text
+			input: "S1F1 W H->E $BODY .",
+			expected: []token{
+				{tokenTypeStreamFunction, "S1F1", 1, 1},
+				{tokenTypeWaitBit, "W", 1, 6},
+				{tokenTypeDirection, "H->E", 1, 8},
+				{tokenTypeReference, "$BODY", 1, 13},
+				tokenMessageEnd,
+			},
+		},
+		{ // Missing definition name
+			input:    "#define 1",
+			expected: []token{{tokenTypeDirective, "#define", 1, 1}, tokenError},
+		},
+	}
+	for _, test := range tests {
+		tokens := doLex(test.input, lexMessageHeader)
+		assert.Equal(t, test.expected, tokens)
+	}
+}
+
 func TestLexer_FullMessage_NestedList_Comments(t *testing.T) {
 	var tests = []struct {
 		input    string
@@ -0,0 +1,150 @@
+package sml
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Load parses the SML file with the name in the file system fsys, resolving
+// the include directives, and returns the combined messages and parsing errors/warnings.
+//
+// An include directive, e.g. #include "stream1/messages.sml", can appear between messages.
+// The path of the included file is relative to the directory of the including file,
+// and it is resolved within fsys. The messages of the included file are placed
+// at the position of the include directive.
+// A file is loaded only once; the include directives of the already loaded files are ignored.
+// Cyclic includes are reported as errors.
+//
+// The options, e.g. WithDialect, apply to all files.
+// Definitions by define directives are shared by all files, e.g. definitions in a
+// common file can be referenced after the include directive that includes the file.
+//
+// No messages is returned if error exist in any of the files.
+// errors and warnings have format of "file: Ln x, Col y: error text",
+// where file is the name of the file in fsys.
+func Load(fsys fs.FS, name string, opts ...Option) (messages []*ast.DataMessage, errors, warnings []string) {
+	ld := &loader{
+		fsys:        fsys,
+		opts:        opts,
+		definitions: map[string]definition{},
+		loaded:      map[string]bool{},
+		stack:       []string{},
+		messages:    []*ast.DataMessage{},
+		errors:      []string{},
+		warnings:    []string{},
+	}
+
+	if !fs.ValidPath(name) {
+		ld.errors = append(ld.errors, fmt.Sprintf("%s: invalid file name", name))
+	} else {
+		ld.load(name)
+	}
+
+	if len(ld.errors) > 0 {
+		return []*ast.DataMessage{}, ld.errors, ld.warnings
+	}
+	return ld.messages, ld.errors, ld.warnings
+}
+
+// LoadFile parses the SML file with the name in the directory dir of the
+// operating system's file system, resolving the include directives.
+// The included files should be in the directory dir, or its subdirectories.
+//
+// Refer to Load for the details.
+func LoadFile(dir, name string, opts ...Option) (messages []*ast.DataMessage, errors, warnings []string) {
+	return Load(os.DirFS(dir), name, opts...)
+}
+
+// loader is a mutable data type that loads SML files and resolves the include directives.
+type loader struct {
+	fsys        fs.FS                 // file system to load the files from
+	opts        []Option              // options to configure the parser of each file
+	definitions map[string]definition // definitions shared by the files
+	loaded      map[string]bool       // names of the files that are loaded or being loaded
+	stack       []string              // names of the files being loaded, in include order
+	messages    []*ast.DataMessage    // loaded messages
+	errors      []string              // parsing errors
+	warnings    []string              // parsing warnings
+}
+
+// load parses the file with the name, and the files that it includes recursively.
+// The name should be a valid path as specified in fs.ValidPath.
+func (ld *loader) load(name string) {
+	data, err := fs.ReadFile(ld.fsys, name)
+	if err != nil {
+		ld.errors = append(ld.errors, fmt.Sprintf("%s: %v", name, unwrapPathError(err)))
+		return
+	}
+
+	ld.loaded[name] = true
+	ld.stack = append(ld.stack, name)
+	defer func() {
+		ld.stack = ld.stack[:len(ld.stack)-1]
+	}()
+
+	p := newParser(lex(string(data)), ld.opts...)
+	p.definitions = ld.definitions
+
+	// flush moves the messages, errors and warnings parsed so far to the loader,
+	// so that they are placed before the ones of the included files.
+	flush := func() {
+		ld.messages = append(ld.messages, p.messages...)
+		for _, err := range p.errors {
+			ld.errors = append(ld.errors, fmt.Sprintf("%s: %s", name, err.string()))
+		}
+		for _, warning := range p.warnings {
+			ld.warnings = append(ld.warnings, fmt.Sprintf("%s: %s", name, warning.string()))
+		}
+		p.messages, p.errors, p.warnings = p.messages[:0], p.errors[:0], p.warnings[:0]
+	}
+	p.onInclude = func(inc include) {
+		flush()
+		ld.include(name, inc)
+	}
+	p.parseAll()
+	flush()
+}
+
+// include loads the file of the include directive in the file with the name.
+func (ld *loader) include(name string, inc include) {
+	target := path.Join(path.Dir(name), inc.path)
+	if path.IsAbs(inc.path) || !fs.ValidPath(target) {
+		ld.errors = append(ld.errors, fmt.Sprintf("%s: Ln %d, Col %d: invalid include path %q",
+			name, inc.token.line, inc.token.col, inc.path))
+		return
+	}
+
+	for i, loading := range ld.stack {
+		if loading == target {
+			cycle := append(append([]string{}, ld.stack[i:]...), target)
+			ld.errors = append(ld.errors, fmt.Sprintf("%s: Ln %d, Col %d: include cycle detected: %s",
+				name, inc.token.line, inc.token.col, strings.Join(cycle, " -> ")))
+			return
+		}
+	}
+
+	if ld.loaded[target] {
+		return
+	}
+
+	if _, err := fs.Stat(ld.fsys, target); err != nil {
+		ld.errors = append(ld.errors, fmt.Sprintf("%s: Ln %d, Col %d: cannot include %q: %v",
+			name, inc.token.line, inc.token.col, inc.path, unwrapPathError(err)))
+		return
+	}
+	ld.load(target)
+}
+
+// unwrapPathError returns the underlying error of the *fs.PathError,
+// since the file name is reported separately.
+func unwrapPathError(err error) error {
+	if pathErr, ok := err.(*fs.PathError); ok {
+		return pathErr.Err
+	}
+	return err
+}
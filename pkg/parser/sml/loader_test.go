@@ -0,0 +1,178 @@
+package sml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests SML loader
+//
+// Testing Strategy:
+//
+// Load SML files from an in-memory file system, and test the loaded messages
+// by their names in order, and the errors/warnings by their texts.
+//
+// Partitions:
+//
+// - Include directives: none, one, nested, in subdirectory, relative to parent directory
+// - Included file: loaded once, included multiple times, cyclic, missing, invalid path
+// - Errors and warnings: in the root file, in the included file
+// - Definitions: in the root file, in the included file
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.sml": {Data: []byte(`
+S1F1 W H->E AreYouThere
+.
+#include "stream1/s1.sml"
+#include "stream6/s6.sml"
+S2F17 W H->E DateTimeRequest
+.
+`)},
+		"stream1/s1.sml": {Data: []byte(`
+#include "../common.sml"
+S1F13 W H<->E EstablishCommunicationsRequest
+<L <A MDLN> <A SOFTREV>>
+.
+`)},
+		"stream6/s6.sml": {Data: []byte(`
+#include "../common.sml" // already loaded
+S6F11 W H<-E EventReport
+<L <U4 DATAID> <U4 CEID> <L <L <U4 RPTID> <L <U4 V> ...>> ...>>
+.
+`)},
+		"common.sml":  {Data: []byte(`S9F1 H<-E UnrecognizedDeviceID <B MHEAD>.`)},
+		"cycle/a.sml": {Data: []byte(`S1F1 W H->E A . #include "b.sml"`)},
+		"cycle/b.sml": {Data: []byte(`S1F3 W H->E B . #include "a.sml"`)},
+		"errors.sml": {Data: []byte(`
+#include "missing.sml"
+#include "/abs.sml"
+#include "../outside.sml"
+#include "warning.sml"
+S1F1 W H->E .
+`)},
+		"warning.sml": {Data: []byte("S1F1 W\n.")},
+		"define/main.sml": {Data: []byte(`
+#include "common.sml"
+S1F13 W H->E EstablishCommunicationsRequest $MDLN_SOFTREV .
+S1F3 W H->E $UNDEFINED .
+`)},
+		"define/common.sml": {Data: []byte(`#define MDLN_SOFTREV <L <A MDLN> <A SOFTREV>>`)},
+		"syntax.sml":        {Data: []byte(`#include 'single.sml'`)},
+	}
+
+	var tests = []struct {
+		description      string
+		name             string
+		expectedMessages []string
+		expectedErrors   []string
+		expectedWarnings []string
+	}{
+		{
+			description:      "nested includes, loaded once",
+			name:             "main.sml",
+			expectedMessages: []string{"AreYouThere", "UnrecognizedDeviceID", "EstablishCommunicationsRequest", "EventReport", "DateTimeRequest"},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "included file only",
+			name:             "stream6/s6.sml",
+			expectedMessages: []string{"UnrecognizedDeviceID", "EventReport"},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "include cycle",
+			name:             "cycle/a.sml",
+			expectedMessages: []string{},
+			expectedErrors:   []string{"cycle/b.sml: Ln 1, Col 17: include cycle detected: cycle/a.sml -> cycle/b.sml -> cycle/a.sml"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "include errors, warning in included file",
+			name:             "errors.sml",
+			expectedMessages: []string{},
+			expectedErrors: []string{
+				`errors.sml: Ln 2, Col 1: cannot include "missing.sml": file does not exist`,
+				`errors.sml: Ln 3, Col 1: invalid include path "/abs.sml"`,
+				`errors.sml: Ln 4, Col 1: invalid include path "../outside.sml"`,
+			},
+			expectedWarnings: []string{
+				`warning.sml: Ln 2, Col 1: missing message direction, "H<->E" will be used`,
+			},
+		},
+		{
+			description:      "definitions in included file",
+			name:             "define/main.sml",
+			expectedMessages: []string{},
+			expectedErrors:   []string{`define/main.sml: Ln 4, Col 13: undefined reference "$UNDEFINED"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "syntax error",
+			name:             "syntax.sml",
+			expectedMessages: []string{},
+			expectedErrors:   []string{"syntax.sml: Ln 1, Col 10: syntax error: expected quoted string after #include"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "missing root file",
+			name:             "missing.sml",
+			expectedMessages: []string{},
+			expectedErrors:   []string{"missing.sml: file does not exist"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "invalid root file name",
+			name:             "../main.sml",
+			expectedMessages: []string{},
+			expectedErrors:   []string{"../main.sml: invalid file name"},
+			expectedWarnings: []string{},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		msgs, errs, warnings := Load(fsys, test.name)
+		names := []string{}
+		for _, msg := range msgs {
+			names = append(names, msg.Name())
+		}
+		assert.Equal(t, test.expectedMessages, names)
+		assert.Equal(t, test.expectedErrors, errs)
+		assert.Equal(t, test.expectedWarnings, warnings)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	fsys := fstest.MapFS{
+		"main.sml":   {Data: []byte(`#include "sub/s1.sml" S1F3 W H->E .`)},
+		"sub/s1.sml": {Data: []byte(`S1F1 W H->E .`)},
+	}
+	for name, file := range fsys {
+		assert.NoError(t, writeFile(dir, name, file.Data))
+	}
+
+	msgs, errs, warnings := LoadFile(dir, "main.sml")
+	assert.Empty(t, errs)
+	assert.Empty(t, warnings)
+	if assert.Len(t, msgs, 2) {
+		assert.Equal(t, "S1F1 W H->E\n.", fmt.Sprint(msgs[0]))
+		assert.Equal(t, "S1F3 W H->E\n.", fmt.Sprint(msgs[1]))
+	}
+}
+
+// writeFile writes data to the file with the slash-separated name in the directory dir.
+func writeFile(dir, name string, data []byte) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
 )
@@ -15,21 +16,22 @@ import (
 //
 // No messages is returned if error exist in the input.
 // errors and warnings have format of "Ln x, Col y: error text".
-func Parse(input string) (messages []*ast.DataMessage, errors, warnings []string) {
-	p := &parser{
-		input:      input,
-		lexer:      lex(input),
-		tokenQueue: []token{},
-		messages:   []*ast.DataMessage{},
-		errors:     []parseError{},
-		warnings:   []parseError{},
-	}
-
-	for p.peek().typ != tokenTypeEOF {
-		if ok := p.parseMessage(); !ok {
-			break
-		}
-	}
+//
+// Include directives in the input are not resolved, and a warning is reported
+// for each of them. Use Load to parse input with include directives.
+//
+// Define directives, e.g. #define REPORT <L <U4 RPTID> <L <U4 VID> ...>>, give names
+// to data items or single values, such as numbers, booleans and quoted strings.
+// A reference to a definition, e.g. $REPORT, is expanded into the definition body
+// wherever a data item or value can appear, or as the message text.
+// References in a definition body are expanded when the definition is referenced,
+// therefore, a definition should be defined before it is referenced in a message.
+// Undefined or recursive references are reported as errors.
+//
+// The SML dialects of other SECS tools can be parsed with the WithDialect option.
+func Parse(input string, opts ...Option) (messages []*ast.DataMessage, errors, warnings []string) {
+	p := newParser(lex(input), opts...)
+	p.parseAll()
 
 	errors = make([]string, 0, len(p.errors))
 	warnings = make([]string, 0, len(p.warnings))
@@ -47,14 +49,65 @@ func Parse(input string) (messages []*ast.DataMessage, errors, warnings []string
 }
 
 type parser struct {
-	input         string             // input string to parse
-	lexer         *lexer             // lexer to tokenize the input string
-	tokenQueue    []token            // token queue that the lexer tokenized
-	variableNames map[string]bool    // variable names in a message to check duplicates
-	ellipsisCount int                // ellipsis count in a message
-	messages      []*ast.DataMessage // parsed messages
-	errors        []parseError       // parsing errors
-	warnings      []parseError       // parsing warnings
+	lexer         *lexer                // lexer to tokenize the input string
+	tokenQueue    []token               // token queue that the lexer tokenized
+	variableNames map[string]bool       // variable names in a message to check duplicates
+	ellipsisCount int                   // ellipsis count in a message
+	definitions   map[string]definition // definitions by the define directives, by name
+	expanding     []string              // names of the definitions being expanded, outermost first
+	onInclude     func(include)         // called on each include directive; nil if includes are not resolved
+	dialect       Dialect               // SML dialect of the input
+	messages      []*ast.DataMessage    // parsed messages
+	errors        []parseError          // parsing errors
+	warnings      []parseError          // parsing warnings
+}
+
+// include represents an include directive.
+type include struct {
+	path  string // path of the file to include
+	token token  // token of the include directive
+}
+
+// definition represents a define directive.
+type definition struct {
+	name token   // token of the definition name
+	body []token // tokens of the definition body, a data item or a single value
+}
+
+// newParser creates a new parser that parses the tokens from the lexer,
+// configured by the options.
+func newParser(l *lexer, opts ...Option) *parser {
+	p := &parser{
+		lexer:       l,
+		tokenQueue:  []token{},
+		definitions: map[string]definition{},
+		expanding:   []string{},
+		messages:    []*ast.DataMessage{},
+		errors:      []parseError{},
+		warnings:    []parseError{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// parseAll parses messages and directives until the EOF, or a critical error.
+func (p *parser) parseAll() {
+	for {
+		switch p.peek().typ {
+		case tokenTypeEOF:
+			return
+		case tokenTypeDirective:
+			if ok := p.parseDirective(); !ok {
+				return
+			}
+		default:
+			if ok := p.parseMessage(); !ok {
+				return
+			}
+		}
+	}
 }
 
 type parseError struct {
@@ -68,7 +121,23 @@ func (pe *parseError) string() string {
 }
 
 // peek returns the next token.
+// References to definitions are expanded into the tokens of the definition body.
 func (p *parser) peek() token {
+	for {
+		switch t := p.peekRaw(); t.typ {
+		case tokenTypeReference:
+			p.expand()
+		case tokenTypeExpansionEnd:
+			p.tokenQueue = p.tokenQueue[1:]
+			p.expanding = p.expanding[:len(p.expanding)-1]
+		default:
+			return t
+		}
+	}
+}
+
+// peekRaw returns the next token, without expanding references.
+func (p *parser) peekRaw() token {
 	if len(p.tokenQueue) == 0 {
 		var t token
 		for {
@@ -89,6 +158,43 @@ func (p *parser) acceptAny() token {
 	return t
 }
 
+// acceptRaw returns the next token without expanding references, and removes
+// it from the token queue.
+func (p *parser) acceptRaw() token {
+	t := p.peekRaw()
+	p.tokenQueue = p.tokenQueue[1:]
+	return t
+}
+
+// expand replaces the reference token at the front of the token queue with the
+// tokens of the referenced definition's body. The expanded tokens have the
+// position of the reference token, so that errors are reported at the reference.
+// Undefined and recursive references are reported as errors, and removed.
+func (p *parser) expand() {
+	ref := p.acceptRaw()
+	name := ref.val[1:]
+	def, ok := p.definitions[name]
+	if !ok {
+		p.errorf(ref, "undefined reference %q", ref.val)
+		return
+	}
+	for _, expanding := range p.expanding {
+		if expanding == name {
+			p.errorf(ref, "recursive reference %q: %s -> %s", ref.val, strings.Join(p.expanding, " -> "), name)
+			return
+		}
+	}
+
+	tokens := make([]token, 0, len(def.body)+1+len(p.tokenQueue))
+	for _, t := range def.body {
+		t.line, t.col = ref.line, ref.col
+		tokens = append(tokens, t)
+	}
+	tokens = append(tokens, token{typ: tokenTypeExpansionEnd, line: ref.line, col: ref.col})
+	p.tokenQueue = append(tokens, p.tokenQueue...)
+	p.expanding = append(p.expanding, name)
+}
+
 // accept returns the next token, and if the token type matches, removes the
 // token from the token queue. The second return value ok is true if and only
 // if the token type matches.
@@ -126,6 +232,11 @@ func (p *parser) parseMessage() (ok bool) {
 		msgName   string
 		dataItem  ast.ItemNode
 	)
+	if t := p.peek(); p.dialect.NamedHeader && isHeaderName(t) {
+		p.acceptAny()
+		msgName = strings.TrimSuffix(t.val, ":")
+	}
+
 	stream, function, ok = p.parseStreamFunctionCode()
 	if !ok {
 		return false
@@ -140,6 +251,12 @@ func (p *parser) parseMessage() (ok bool) {
 			}
 		} else if t.val == "[W]" {
 			waitBit = 2
+		} else if t.val == "*" {
+			waitBit = 1
+			if function%2 == 0 {
+				waitBit = 0
+				p.errorf(t, "wait bit cannot be true on reply message (function code is even)")
+			}
 		}
 	}
 
@@ -150,8 +267,8 @@ func (p *parser) parseMessage() (ok bool) {
 		direction = "H<->E"
 	}
 
-	if t, ok := p.accept(tokenTypeMessageName); ok {
-		msgName = t.val
+	if t := p.peek(); t.typ == tokenTypeMessageName && msgName == "" && !(p.dialect.NamedHeader && isHeaderName(t)) {
+		msgName = p.acceptAny().val
 	}
 
 	dataItem, ok = p.parseMessageText()
@@ -159,7 +276,7 @@ func (p *parser) parseMessage() (ok bool) {
 		return false
 	}
 
-	if t, ok := p.accept(tokenTypeMessageEnd); !ok {
+	if t, ok := p.accept(tokenTypeMessageEnd); !ok && !(p.dialect.OptionalMessageEnd && isMessageStart(t)) {
 		p.errorf(t, "expected message end character '.', found %q", t.val)
 		return false
 	}
@@ -169,6 +286,109 @@ func (p *parser) parseMessage() (ok bool) {
 	return true
 }
 
+// parseDirective parses a directive.
+// Returns ok == false when parsing failed to stop the parser.
+func (p *parser) parseDirective() (ok bool) {
+	t := p.acceptAny()
+	if t.val == "#define" {
+		return p.parseDefine(t)
+	}
+	return p.parseInclude(t)
+}
+
+// parseInclude parses the argument of the include directive token t.
+// Returns ok == false when parsing failed to stop the parser.
+func (p *parser) parseInclude(t token) (ok bool) {
+	arg, ok := p.accept(tokenTypeQuotedString)
+	if !ok {
+		if arg.typ == tokenTypeError {
+			p.errorf(arg, "syntax error: %s", arg.val)
+		} else {
+			p.errorf(arg, "expected quoted string, found %q", arg.val)
+		}
+		return false
+	}
+
+	path, err := unquote(arg.val)
+	if err != nil || path == "" {
+		p.errorf(arg, "invalid file path %s", arg.val)
+		return true
+	}
+
+	if p.onInclude == nil {
+		p.warningf(t, "include directive is not resolved, use Load to resolve includes")
+		return true
+	}
+	p.onInclude(include{path, t})
+	return true
+}
+
+// parseDefine parses the name and the body of the define directive token t.
+// The body tokens are stored without expanding references.
+// Returns ok == false when parsing failed to stop the parser.
+func (p *parser) parseDefine(t token) (ok bool) {
+	name := p.acceptRaw()
+	if name.typ != tokenTypeDefinitionName {
+		if name.typ == tokenTypeError {
+			p.errorf(name, "syntax error: %s", name.val)
+		} else {
+			p.errorf(name, "expected definition name, found %q", name.val)
+		}
+		return false
+	}
+
+	body := []token{}
+	depth := 0
+	for {
+		b := p.acceptRaw()
+		switch b.typ {
+		case tokenTypeError:
+			p.errorf(b, "syntax error: %s", b.val)
+			return false
+		case tokenTypeEOF, tokenTypeMessageEnd:
+			p.errorf(b, "unexpected %q in definition %q", b.val, name.val)
+			return false
+		case tokenTypeLeftAngleBracket:
+			depth += 1
+		case tokenTypeRightAngleBracket:
+			depth -= 1
+		case tokenTypeNumber, tokenTypeBool, tokenTypeQuotedString, tokenTypeReference:
+		default:
+			if depth == 0 {
+				p.errorf(b, "expected data item or value in definition %q, found %q", name.val, b.val)
+				return false
+			}
+		}
+		body = append(body, b)
+		if depth <= 0 {
+			break
+		}
+	}
+
+	if _, ok := p.definitions[name.val]; ok {
+		p.errorf(name, "duplicated definition %q", name.val)
+		return true
+	}
+	p.definitions[name.val] = definition{name, body}
+	return true
+}
+
+// skipMessage skips the tokens until the message end token or the EOF, to
+// recover from a parsing error and continue parsing the next message.
+// If the message end character is optional in the dialect, it also stops before
+// the token that can start the next message, after skipping at least one token.
+func (p *parser) skipMessage() {
+	for {
+		switch p.acceptAny().typ {
+		case tokenTypeMessageEnd, tokenTypeEOF:
+			return
+		}
+		if p.dialect.OptionalMessageEnd && isMessageStart(p.peek()) {
+			return
+		}
+	}
+}
+
 // parseStreamFunctionCode parses the stream function token.
 // Returns ok == false when stream function token isn't found, to stop parsing the message.
 // When some non-critical errors occurred, parsed values might be changed to
@@ -204,6 +424,9 @@ func (p *parser) parseMessageText() (item ast.ItemNode, ok bool) {
 	case tokenTypeLeftAngleBracket:
 		return p.parseDataItem()
 	default:
+		if p.dialect.OptionalMessageEnd && isMessageStart(t) {
+			return ast.NewEmptyItemNode(), true
+		}
 		p.errorf(t, "expected '<' or '.', found %q", t.val)
 		return ast.NewEmptyItemNode(), false
 	}
@@ -234,6 +457,13 @@ func (p *parser) parseDataItem() (item ast.ItemNode, ok bool) {
 	var dataItemType string
 	if t, ok := p.accept(tokenTypeDataItemType); ok {
 		dataItemType = t.val
+		switch dataItemType {
+		case "BOOL":
+			dataItemType = "BOOLEAN"
+		case "J", "V":
+			p.warningf(t, "%s data item is not supported, it will be parsed as A", t.val)
+			dataItemType = "A"
+		}
 	} else {
 		p.errorf(t, "invalid data item type: %q", t.val)
 		return ast.NewEmptyItemNode(), false
@@ -243,6 +473,10 @@ func (p *parser) parseDataItem() (item ast.ItemNode, ok bool) {
 	var sizeStart, sizeEnd int = 0, -1
 	if t := p.peek(); t.typ == tokenTypeDataItemSize {
 		tokenDataItemSize, sizeStart, sizeEnd = p.parseDataItemSize()
+		if sizeEnd != -1 && sizeStart > sizeEnd {
+			p.errorf(tokenDataItemSize, "invalid data item size range %s", tokenDataItemSize.val)
+			sizeStart, sizeEnd = 0, -1
+		}
 	} else if t.typ == tokenTypeError {
 		p.errorf(t, "syntax error: %s", t.val)
 		return ast.NewEmptyItemNode(), false
@@ -250,7 +484,7 @@ func (p *parser) parseDataItem() (item ast.ItemNode, ok bool) {
 
 	switch dataItemType {
 	case "L":
-		item, ok = p.parseList()
+		item, ok = p.parseList(sizeStart, sizeEnd, tokenDataItemSize)
 	case "A":
 		item, ok = p.parseASCII(sizeStart, sizeEnd)
 	case "B":
@@ -282,7 +516,12 @@ func (p *parser) parseDataItem() (item ast.ItemNode, ok bool) {
 		return ast.NewEmptyItemNode(), false
 	}
 
-	if item.Size() >= 0 {
+	switch {
+	case dataItemType == "L":
+		// the size of ListNode is checked in parseList
+	case isArrayVariable(item, tokenDataItemSize, sizeStart, sizeEnd):
+		item = p.withArraySize(item, sizeStart, sizeEnd, tokenDataItemSize)
+	case item.Size() >= 0:
 		// (ASCIINode with variable).Size() == -1
 		p.checkDataItemSizeError(item.Size(), sizeStart, sizeEnd, tokenDataItemSize)
 	}
@@ -330,15 +569,43 @@ func (p *parser) checkDataItemSizeError(size, lowerLimit, upperLimit int, t toke
 	}
 }
 
+// isArrayVariable reports whether the item, other than ASCII and list, is a array variable,
+// which consists of a single variable with the data item size other than [1], e.g. <U4[..] VIDS>.
+func isArrayVariable(item ast.ItemNode, sizeToken token, minSize, maxSize int) bool {
+	if _, ok := item.(*ast.ASCIINode); ok || sizeToken.typ != tokenTypeDataItemSize {
+		return false
+	}
+	return item.Size() == 1 && len(item.Variables()) == 1 && !(minSize == 1 && maxSize == 1)
+}
+
+// withArraySize returns the item with its variable as a array variable with the size range.
+// If the variable has a default value, an error is submitted on the size token and the item is returned as is.
+func (p *parser) withArraySize(item ast.ItemNode, minSize, maxSize int, sizeToken token) ast.ItemNode {
+	name := item.Variables()[0]
+	if _, ok := item.(interface {
+		Default(name string) (interface{}, bool)
+	}).Default(name); ok {
+		p.errorf(sizeToken, "array variable %q cannot have a default value", name)
+		return item
+	}
+	return item.(interface {
+		WithArraySize(min, max int) ast.ItemNode
+	}).WithArraySize(minSize, maxSize)
+}
+
 // parseList parses a list data item.
 // Returns ok == false when unexpected token is found, to stop parsing the message.
 // When some non-critical errors occurred, parsed values might be changed to
 // correct the error and continue parsing. The non-critical error will be
 // handled at the end of the parsing operation.
-func (p *parser) parseList() (item ast.ItemNode, ok bool) {
+//
+// If the list has ellipsis, the size range becomes the size range of the list,
+// which is checked when the ellipsis is filled in.
+func (p *parser) parseList(minSize, maxSize int, sizeToken token) (item ast.ItemNode, ok bool) {
 	values := []interface{}{}
 
 	count := 0
+	ellipsisFound := false
 	for {
 		switch t := p.peek(); t.typ {
 		case tokenTypeLeftAngleBracket:
@@ -370,9 +637,15 @@ func (p *parser) parseList() (item ast.ItemNode, ok bool) {
 				p.warningf(t, "wrong ellipsis count, %q will be used", val)
 			}
 			values = append(values, val)
+			ellipsisFound = true
 
 		case tokenTypeRightAngleBracket:
-			return ast.NewListNode(values...), true
+			item := ast.NewListNode(values...)
+			if ellipsisFound {
+				return item.(*ast.ListNode).WithSizeRange(minSize, maxSize), true
+			}
+			p.checkDataItemSizeError(item.Size(), minSize, maxSize, sizeToken)
+			return item, true
 
 		case tokenTypeError:
 			p.errorf(t, "syntax error: %s", t.val)
@@ -392,7 +665,8 @@ func (p *parser) getDataItemValueTokens() []token {
 	tokens := []token{}
 	for {
 		switch p.peek().typ {
-		case tokenTypeNumber, tokenTypeBool, tokenTypeQuotedString, tokenTypeVariable:
+		case tokenTypeNumber, tokenTypeBool, tokenTypeQuotedString, tokenTypeVariable,
+			tokenTypeValueConstraint, tokenTypeEqualSign:
 			tokens = append(tokens, p.acceptAny())
 		case tokenTypeRightAngleBracket:
 			return tokens
@@ -410,21 +684,52 @@ func (p *parser) getDataItemValueTokens() []token {
 // correct the error and continue parsing. The non-critical error will be
 // handled at the end of the parsing operation.
 func (p *parser) parseASCII(minLength, maxLength int) (item ast.ItemNode, ok bool) {
-	var literal string
-
 	tokens := p.getDataItemValueTokens()
+	if len(tokens) == 0 || tokens[0].typ != tokenTypeVariable {
+		literal, ok := p.parseASCIILiteral(tokens)
+		if !ok {
+			return ast.NewEmptyItemNode(), false
+		}
+		return ast.NewASCIINode(literal), true
+	}
+
+	t := tokens[0]
+	hasDefault := len(tokens) > 2 && tokens[1].typ == tokenTypeEqualSign
+	if len(tokens) != 1 && !hasDefault {
+		p.errorf(t, "variable cannot co-exist with other literals in ASCII data item")
+		return ast.NewEmptyItemNode(), false
+	}
+
+	if _, ok := p.variableNames[t.val]; ok {
+		p.errorf(t, "duplicated variable name %q", t.val)
+		return ast.NewASCIINode(strings.Repeat("*", minLength)), true
+	}
+	p.variableNames[t.val] = true
+	item = ast.NewASCIINodeVariable(t.val, minLength, maxLength)
+	if hasDefault {
+		for _, dt := range tokens[2:] {
+			if dt.typ == tokenTypeVariable {
+				p.errorf(dt, "expected quoted string or ASCII number code after '=', found %q", dt.val)
+				return ast.NewEmptyItemNode(), false
+			}
+		}
+		literal, ok := p.parseASCIILiteral(tokens[2:])
+		if !ok {
+			return ast.NewEmptyItemNode(), false
+		}
+		item = p.withDefaults(item, []defaultValue{{t.val, tokens[1], literal}})
+	}
+	return item, true
+}
+
+// parseASCIILiteral parses the tokens of a ASCII literal, which is a sequence of
+// quoted strings and ASCII number codes, e.g. "abc" 0x0A "def".
+// Returns ok == false when unexpected token is found, to stop parsing the message.
+func (p *parser) parseASCIILiteral(tokens []token) (literal string, ok bool) {
 	for _, t := range tokens {
 		switch t.typ {
 		case tokenTypeQuotedString:
-			val, _ := strconv.Unquote(t.val)
-			for _, r := range val {
-				if r > unicode.MaxASCII {
-					val = ""
-					p.errorf(t, "expected ASCII characters, found %q", r)
-					break
-				}
-			}
-			literal += val
+			literal += p.parseASCIIString(t)
 
 		case tokenTypeNumber:
 			val, err := strconv.ParseUint(t.val, 0, 0)
@@ -440,30 +745,36 @@ func (p *parser) parseASCII(minLength, maxLength int) (item ast.ItemNode, ok boo
 			literal += string(byte(val))
 
 		case tokenTypeVariable:
-			if len(tokens) != 1 {
-				p.errorf(t, "variable cannot co-exist with other literals in ASCII data item")
-				return ast.NewEmptyItemNode(), false
-			}
-
-			if _, ok := p.variableNames[t.val]; ok {
-				p.errorf(t, "duplicated variable name %q", t.val)
-				return ast.NewASCIINode(strings.Repeat("*", minLength)), true
-			} else {
-				p.variableNames[t.val] = true
-				return ast.NewASCIINodeVariable(t.val, minLength, maxLength), true
-			}
+			p.errorf(t, "variable cannot co-exist with other literals in ASCII data item")
+			return "", false
 
 		case tokenTypeError:
 			p.errorf(t, "syntax error: %s", t.val)
-			return ast.NewEmptyItemNode(), false
+			return "", false
 
 		default:
 			p.errorf(t, "expected quoted string, ASCII number code or variable, found %q", t.val)
-			return ast.NewEmptyItemNode(), false
+			return "", false
 		}
 	}
 
-	return ast.NewASCIINode(literal), true
+	return literal, true
+}
+
+// parseASCIIString parses a quoted string token in a ASCII data item.
+// When an error occurred, the error is submitted and the result might be changed to continue parsing.
+func (p *parser) parseASCIIString(t token) string {
+	val, err := unquote(t.val)
+	if err != nil {
+		p.errorf(t, "%v", err)
+	}
+	for _, r := range val {
+		if r > unicode.MaxASCII {
+			p.errorf(t, "expected ASCII characters, found %q", r)
+			return ""
+		}
+	}
+	return val
 }
 
 // parseBinary parses a binary data item.
@@ -473,24 +784,31 @@ func (p *parser) parseASCII(minLength, maxLength int) (item ast.ItemNode, ok boo
 // handled at the end of the parsing operation.
 func (p *parser) parseBinary() (item ast.ItemNode, ok bool) {
 	values := []interface{}{}
+	defaults := []defaultValue{}
+	variable := "" // the last variable, which a default value follows
 
-	for _, t := range p.getDataItemValueTokens() {
-		switch t.typ {
+	tokens := p.getDataItemValueTokens()
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i]; t.typ {
 		case tokenTypeNumber:
-			val, _ := strconv.ParseInt(t.val, 0, 0)
-			if !(0 <= val && val < 256) {
-				val = 0
-				p.errorf(t, "binary value overflow, should be in range of [0, 256)")
-			}
-			values = append(values, int(val))
+			values = append(values, p.parseBinaryValue(t))
 
 		case tokenTypeVariable:
-			if _, ok := p.variableNames[t.val]; ok {
-				p.errorf(t, "duplicated variable name %q", t.val)
+			variable = p.acceptVariable(t)
+			if variable == "" {
 				values = append(values, 0)
 			} else {
-				p.variableNames[t.val] = true
-				values = append(values, t.val)
+				values = append(values, variable)
+			}
+
+		case tokenTypeEqualSign:
+			i += 1
+			if i == len(tokens) || tokens[i].typ != tokenTypeNumber {
+				p.errorf(t, "expected number after '='")
+				return ast.NewEmptyItemNode(), false
+			}
+			if val := p.parseBinaryValue(tokens[i]); variable != "" {
+				defaults = append(defaults, defaultValue{variable, t, val})
 			}
 
 		case tokenTypeError:
@@ -503,7 +821,18 @@ func (p *parser) parseBinary() (item ast.ItemNode, ok bool) {
 		}
 	}
 
-	return ast.NewBinaryNode(values...), true
+	return p.withDefaults(ast.NewBinaryNode(values...), defaults), true
+}
+
+// parseBinaryValue parses a number token in a binary data item.
+// When an error occurred, the error is submitted and the result might be changed to continue parsing.
+func (p *parser) parseBinaryValue(t token) int {
+	val, _ := strconv.ParseInt(t.val, 0, 0)
+	if !(0 <= val && val < 256) {
+		val = 0
+		p.errorf(t, "binary value overflow, should be in range of [0, 256)")
+	}
+	return int(val)
 }
 
 // parseBoolean parses a boolean data item.
@@ -513,23 +842,31 @@ func (p *parser) parseBinary() (item ast.ItemNode, ok bool) {
 // handled at the end of the parsing operation.
 func (p *parser) parseBoolean() (item ast.ItemNode, ok bool) {
 	values := []interface{}{}
+	defaults := []defaultValue{}
+	variable := "" // the last variable, which a default value follows
 
-	for _, t := range p.getDataItemValueTokens() {
-		switch t.typ {
+	tokens := p.getDataItemValueTokens()
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i]; t.typ {
 		case tokenTypeBool:
-			if t.val == "T" {
-				values = append(values, true)
-			} else {
-				values = append(values, false)
-			}
+			values = append(values, t.val == "T")
 
 		case tokenTypeVariable:
-			if _, ok := p.variableNames[t.val]; ok {
-				p.errorf(t, "duplicated variable name %q", t.val)
+			variable = p.acceptVariable(t)
+			if variable == "" {
 				values = append(values, false)
 			} else {
-				p.variableNames[t.val] = true
-				values = append(values, t.val)
+				values = append(values, variable)
+			}
+
+		case tokenTypeEqualSign:
+			i += 1
+			if i == len(tokens) || tokens[i].typ != tokenTypeBool {
+				p.errorf(t, "expected boolean value after '='")
+				return ast.NewEmptyItemNode(), false
+			}
+			if variable != "" {
+				defaults = append(defaults, defaultValue{variable, t, tokens[i].val == "T"})
 			}
 
 		case tokenTypeError:
@@ -542,7 +879,7 @@ func (p *parser) parseBoolean() (item ast.ItemNode, ok bool) {
 		}
 	}
 
-	return ast.NewBooleanNode(values...), true
+	return p.withDefaults(ast.NewBooleanNode(values...), defaults), true
 }
 
 // parseFloat4 parses a F4 data item.
@@ -564,28 +901,38 @@ func (p *parser) parseFloat8() (item ast.ItemNode, ok bool) {
 // handled at the end of the parsing operation.
 func (p *parser) parseFloat(byteSize int) (item ast.ItemNode, ok bool) {
 	values := []interface{}{}
+	constraints := map[string]*ast.Constraint{}
+	defaults := []defaultValue{}
+	variable := "" // the last variable, which a value constraint and a default value follow
+	parseNumber := func(s string) (interface{}, error) { return strconv.ParseFloat(s, 64) }
 
-	for _, t := range p.getDataItemValueTokens() {
-		switch t.typ {
+	tokens := p.getDataItemValueTokens()
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i]; t.typ {
 		case tokenTypeNumber:
-			val, err := strconv.ParseFloat(t.val, byteSize*8)
-			if err != nil {
-				val = 0
-				if err.(*strconv.NumError).Err == strconv.ErrRange {
-					p.errorf(t, "F%d range overflow", byteSize)
-				} else {
-					p.errorf(t, "expected float, found %q", t.val)
-				}
-			}
-			values = append(values, val)
+			values = append(values, p.parseFloatValue(t, byteSize))
 
 		case tokenTypeVariable:
-			if _, ok := p.variableNames[t.val]; ok {
-				p.errorf(t, "duplicated variable name %q", t.val)
+			variable = p.acceptVariable(t)
+			if variable == "" {
 				values = append(values, 0)
 			} else {
-				p.variableNames[t.val] = true
-				values = append(values, t.val)
+				values = append(values, variable)
+			}
+
+		case tokenTypeValueConstraint:
+			if c := p.parseValueConstraint(t, parseNumber); c != nil && variable != "" {
+				constraints[variable] = c
+			}
+
+		case tokenTypeEqualSign:
+			i += 1
+			if i == len(tokens) || tokens[i].typ != tokenTypeNumber {
+				p.errorf(t, "expected float after '='")
+				return ast.NewEmptyItemNode(), false
+			}
+			if val := p.parseFloatValue(tokens[i], byteSize); variable != "" {
+				defaults = append(defaults, defaultValue{variable, t, val})
 			}
 
 		case tokenTypeError:
@@ -598,7 +945,23 @@ func (p *parser) parseFloat(byteSize int) (item ast.ItemNode, ok bool) {
 		}
 	}
 
-	return ast.NewFloatNode(byteSize, values...), true
+	item = withConstraints(ast.NewFloatNode(byteSize, values...), constraints)
+	return p.withDefaults(item, defaults), true
+}
+
+// parseFloatValue parses a number token in F4 and F8 data items.
+// When an error occurred, the error is submitted and the result might be changed to continue parsing.
+func (p *parser) parseFloatValue(t token, byteSize int) float64 {
+	val, err := strconv.ParseFloat(t.val, byteSize*8)
+	if err != nil {
+		val = 0
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			p.errorf(t, "F%d range overflow", byteSize)
+		} else {
+			p.errorf(t, "expected float, found %q", t.val)
+		}
+	}
+	return val
 }
 
 // parseInt1 parses a I1 data item.
@@ -632,27 +995,38 @@ func (p *parser) parseInt8() (item ast.ItemNode, ok bool) {
 // handled at the end of the parsing operation.
 func (p *parser) parseInt(byteSize int) (item ast.ItemNode, ok bool) {
 	values := []interface{}{}
+	constraints := map[string]*ast.Constraint{}
+	defaults := []defaultValue{}
+	variable := "" // the last variable, which a value constraint and a default value follow
+	parseNumber := func(s string) (interface{}, error) { return strconv.ParseInt(s, 0, byteSize*8) }
 
-	for _, t := range p.getDataItemValueTokens() {
-		switch t.typ {
+	tokens := p.getDataItemValueTokens()
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i]; t.typ {
 		case tokenTypeNumber:
-			val, err := strconv.ParseInt(t.val, 0, byteSize*8)
-			if err != nil {
-				if err.(*strconv.NumError).Err == strconv.ErrRange {
-					p.errorf(t, "I%d range overflow", byteSize)
-				} else {
-					p.errorf(t, "expected integer, found %q", t.val)
-				}
-			}
-			values = append(values, val)
+			values = append(values, p.parseIntValue(t, byteSize))
 
 		case tokenTypeVariable:
-			if _, ok := p.variableNames[t.val]; ok {
-				p.errorf(t, "duplicated variable name %q", t.val)
+			variable = p.acceptVariable(t)
+			if variable == "" {
 				values = append(values, 0)
 			} else {
-				p.variableNames[t.val] = true
-				values = append(values, t.val)
+				values = append(values, variable)
+			}
+
+		case tokenTypeValueConstraint:
+			if c := p.parseValueConstraint(t, parseNumber); c != nil && variable != "" {
+				constraints[variable] = c
+			}
+
+		case tokenTypeEqualSign:
+			i += 1
+			if i == len(tokens) || tokens[i].typ != tokenTypeNumber {
+				p.errorf(t, "expected integer after '='")
+				return ast.NewEmptyItemNode(), false
+			}
+			if val := p.parseIntValue(tokens[i], byteSize); variable != "" {
+				defaults = append(defaults, defaultValue{variable, t, val})
 			}
 
 		case tokenTypeError:
@@ -665,7 +1039,22 @@ func (p *parser) parseInt(byteSize int) (item ast.ItemNode, ok bool) {
 		}
 	}
 
-	return ast.NewIntNode(byteSize, values...), true
+	item = withConstraints(ast.NewIntNode(byteSize, values...), constraints)
+	return p.withDefaults(item, defaults), true
+}
+
+// parseIntValue parses a number token in integer data items.
+// When an error occurred, the error is submitted and the result might be changed to continue parsing.
+func (p *parser) parseIntValue(t token, byteSize int) int64 {
+	val, err := strconv.ParseInt(t.val, 0, byteSize*8)
+	if err != nil {
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			p.errorf(t, "I%d range overflow", byteSize)
+		} else {
+			p.errorf(t, "expected integer, found %q", t.val)
+		}
+	}
+	return val
 }
 
 // parseUint1 parses a U1 data item.
@@ -699,27 +1088,38 @@ func (p *parser) parseUint8() (item ast.ItemNode, ok bool) {
 // handled at the end of the parsing operation.
 func (p *parser) parseUint(byteSize int) (item ast.ItemNode, ok bool) {
 	values := []interface{}{}
+	constraints := map[string]*ast.Constraint{}
+	defaults := []defaultValue{}
+	variable := "" // the last variable, which a value constraint and a default value follow
+	parseNumber := func(s string) (interface{}, error) { return strconv.ParseUint(s, 0, byteSize*8) }
 
-	for _, t := range p.getDataItemValueTokens() {
-		switch t.typ {
+	tokens := p.getDataItemValueTokens()
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i]; t.typ {
 		case tokenTypeNumber:
-			val, err := strconv.ParseUint(t.val, 0, byteSize*8)
-			if err != nil {
-				if err.(*strconv.NumError).Err == strconv.ErrRange {
-					p.errorf(t, "U%d range overflow", byteSize)
-				} else {
-					p.errorf(t, "expected unsigned integer, found %q", t.val)
-				}
-			}
-			values = append(values, val)
+			values = append(values, p.parseUintValue(t, byteSize))
 
 		case tokenTypeVariable:
-			if _, ok := p.variableNames[t.val]; ok {
-				p.errorf(t, "duplicated variable name %q", t.val)
+			variable = p.acceptVariable(t)
+			if variable == "" {
 				values = append(values, 0)
 			} else {
-				p.variableNames[t.val] = true
-				values = append(values, t.val)
+				values = append(values, variable)
+			}
+
+		case tokenTypeValueConstraint:
+			if c := p.parseValueConstraint(t, parseNumber); c != nil && variable != "" {
+				constraints[variable] = c
+			}
+
+		case tokenTypeEqualSign:
+			i += 1
+			if i == len(tokens) || tokens[i].typ != tokenTypeNumber {
+				p.errorf(t, "expected unsigned integer after '='")
+				return ast.NewEmptyItemNode(), false
+			}
+			if val := p.parseUintValue(tokens[i], byteSize); variable != "" {
+				defaults = append(defaults, defaultValue{variable, t, val})
 			}
 
 		case tokenTypeError:
@@ -732,5 +1132,167 @@ func (p *parser) parseUint(byteSize int) (item ast.ItemNode, ok bool) {
 		}
 	}
 
-	return ast.NewUintNode(byteSize, values...), true
+	item = withConstraints(ast.NewUintNode(byteSize, values...), constraints)
+	return p.withDefaults(item, defaults), true
+}
+
+// parseUintValue parses a number token in unsigned integer data items.
+// When an error occurred, the error is submitted and the result might be changed to continue parsing.
+func (p *parser) parseUintValue(t token, byteSize int) uint64 {
+	val, err := strconv.ParseUint(t.val, 0, byteSize*8)
+	if err != nil {
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			p.errorf(t, "U%d range overflow", byteSize)
+		} else {
+			p.errorf(t, "expected unsigned integer, found %q", t.val)
+		}
+	}
+	return val
+}
+
+// acceptVariable registers the name of the variable token, and returns the name.
+// If the variable name is duplicated, an error is submitted and empty string is returned.
+func (p *parser) acceptVariable(t token) string {
+	if _, ok := p.variableNames[t.val]; ok {
+		p.errorf(t, "duplicated variable name %q", t.val)
+		return ""
+	}
+	p.variableNames[t.val] = true
+	return t.val
+}
+
+// defaultValue is a default value of a variable in a data item, with the equal sign token before it.
+type defaultValue struct {
+	name      string
+	equalSign token
+	value     interface{}
+}
+
+// withDefaults returns the item with the default values set to its variables.
+// If a default value cannot be set, e.g. it's not allowed by the value constraint,
+// an error is submitted on the equal sign token.
+func (p *parser) withDefaults(item ast.ItemNode, defaults []defaultValue) ast.ItemNode {
+	for _, d := range defaults {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					p.errorf(d.equalSign, "invalid default value of %q: %v", d.name, r)
+				}
+			}()
+			item = item.(interface {
+				WithDefault(name string, value interface{}) ast.ItemNode
+			}).WithDefault(d.name, d.value)
+		}()
+	}
+	return item
+}
+
+// parseValueConstraint parses a value constraint token, e.g. {1..100}, {0 1 2}, or {..-1 10..},
+// using parseNumber to parse each value in the constraint.
+// Returns nil when the constraint is invalid, after submitting an error on the token.
+func (p *parser) parseValueConstraint(t token, parseNumber func(s string) (interface{}, error)) (c *ast.Constraint) {
+	ranges := []ast.ValueRange{}
+	for _, field := range strings.Fields(t.val[1 : len(t.val)-1]) {
+		min, max := field, field
+		if i := strings.Index(field, ".."); i != -1 {
+			min, max = field[:i], field[i+2:]
+		}
+		if min == "" && max == "" {
+			p.errorf(t, "invalid value constraint %s, found %q", t.val, field)
+			return nil
+		}
+
+		var r ast.ValueRange
+		var err error
+		if min != "" {
+			r.Min, err = parseNumber(min)
+		}
+		if max != "" && err == nil {
+			r.Max, err = parseNumber(max)
+		}
+		if err != nil {
+			p.errorf(t, "invalid value constraint %s, found %q", t.val, field)
+			return nil
+		}
+		ranges = append(ranges, r)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.errorf(t, "invalid value constraint %s, %v", t.val, r)
+			c = nil
+		}
+	}()
+	return ast.NewConstraint(ranges...)
+}
+
+// Helper functions
+
+// withConstraints returns the item with the value constraints set to its variables.
+// The item should be a UintNode, IntNode, or FloatNode.
+func withConstraints(item ast.ItemNode, constraints map[string]*ast.Constraint) ast.ItemNode {
+	for name, c := range constraints {
+		item = item.(interface {
+			WithConstraint(name string, c *ast.Constraint) ast.ItemNode
+		}).WithConstraint(name, c)
+	}
+	return item
+}
+
+// unquote interprets the quoted string token value s, enclosed in double or
+// single quotes, and returns the string that it represents.
+//
+// Supported escape sequences are \", \', \\, \t, \n, \r and \xNN, where NN is
+// a two-digit hexadecimal number. A backslash at the end of a line continues
+// the string on the next line; the line break and the leading spaces and tabs
+// of the next line are removed.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != s[len(s)-1] || (s[0] != '"' && s[0] != '\'') {
+		return "", fmt.Errorf("invalid quoted string %s", s)
+	}
+	s = s[1 : len(s)-1]
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i += 1
+		if i == len(s) {
+			return "", fmt.Errorf("invalid escape sequence at the end of the string")
+		}
+		switch s[i] {
+		case '"', '\'', '\\':
+			sb.WriteByte(s[i])
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'x':
+			if i+3 > len(s) {
+				return "", fmt.Errorf(`invalid escape sequence "\%s"`, s[i:])
+			}
+			val, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf(`invalid escape sequence "\%s"`, s[i:i+3])
+			}
+			sb.WriteByte(byte(val))
+			i += 2
+		case '\r', '\n':
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i += 1
+			}
+			for i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\t') {
+				i += 1
+			}
+		default:
+			r, _ := utf8.DecodeRuneInString(s[i:])
+			return "", fmt.Errorf(`invalid escape sequence "\%c"`, r)
+		}
+	}
+	return sb.String(), nil
 }
@@ -54,6 +54,14 @@ import (
 //                       error when range overflow, error when number cannot be parsed
 //     - U1, U2, U4, U8: decimal, binary, octal, hexadecimal unsigned integer
 //                       error when range overflow, error when number cannot be parsed
+//   - Value constraint of a variable in F4, F8, I1, I2, I4, I8, U1, U2, U4, U8:
+//     values, ranges, open ranges, error when a value cannot be parsed or a range is invalid
+//   - Size range of a list with ellipsis: checked when the ellipsis is filled in
+//   - Array variable in B, BOOLEAN, F4, F8, I1, I2, I4, I8, U1, U2, U4, U8:
+//     single variable with data item size other than [1], e.g. [..], [2..5], [3],
+//     error when the variable has a default value
+//   - Default value of a variable in all data items except L:
+//     error when the value is missing, has a wrong type, or is not allowed by the value constraint
 //
 // Input space is huge; Some important cases to check:
 // - Nested data items and ellipsis
@@ -226,6 +234,22 @@ S0F0 H->E TestMessage2
 .`,
 			},
 		},
+		{
+			description:              "1 message, ASCII node with escape sequences, single quotes and line continuation",
+			input:                    "S1F1 W H->E <A \"say \\\"hi\\\" \\\\\\t\\x41\" 'it\\'s \"ok\"' \"con\\\n    tinued\">.",
+			expectedNumberOfMessages: 1,
+			expectedNumberOfErrors:   0,
+			expectedNumberOfWarnings: 0,
+			expectedString:           []string{"S1F1 W H->E\n<A \"say \\\"hi\\\" \\\\\" 0x09 \"Ait's \\\"ok\\\"continued\">\n."},
+		},
+		{
+			description:              "include directive is not resolved",
+			input:                    "#include \"common.sml\"\nS1F1 W H->E .",
+			expectedNumberOfMessages: 1,
+			expectedNumberOfErrors:   0,
+			expectedNumberOfWarnings: 1,
+			expectedString:           []string{"S1F1 W H->E\n."},
+		},
 	}
 	for i, test := range tests {
 		t.Logf("Test #%d: %s", i, test.description)
@@ -526,6 +550,15 @@ func TestParser_ASCII_ErrorCases(t *testing.T) {
 			expectedErrorString:      []string{"2:4:number code"},
 			expectedWarningString:    []string{},
 		},
+		{
+			description:              "invalid escape sequences",
+			input:                    "S0F0 H->E TestMessage\n<A \"\\q\" '\\x4' \"\\xZZ\" '\\xFF'> .",
+			expectedNumberOfMessages: 0,
+			expectedNumberOfErrors:   4,
+			expectedNumberOfWarnings: 0,
+			expectedErrorString:      []string{`2:4:invalid escape sequence "\q"`, `2:9:invalid escape sequence "\x4"`, `2:15:invalid escape sequence "\xZZ"`, "2:22:expected ASCII"},
+			expectedWarningString:    []string{},
+		},
 		{
 			description:              "non-ascii number code",
 			input:                    "S0F0 H->E TestMessage\n<A 128> .",
@@ -974,3 +1007,345 @@ func TestParser_Uint_ErrorCases(t *testing.T) {
 		}
 	}
 }
+
+func TestParser_Definitions(t *testing.T) {
+	var tests = []struct {
+		description      string   // Test case description
+		input            string   // Input to the parser
+		expectedString   []string // expected string representation of messages
+		expectedErrors   []string // expected error strings
+		expectedWarnings []string // expected warning strings
+	}{
+		{
+			description: "data item and value definitions",
+			input: `
+#define ACK 0
+#define MDLN "model" // comment
+#define SOFTREV $VERSION
+#define VERSION "1.0.0"
+#define REPORT <L <U4 RPTID> <L <U4 VID> ...>>
+#define ONLINE_DATA <L <A $MDLN> <A $SOFTREV>>
+S1F2 H<-E $ONLINE_DATA .
+S6F19 W H->E <L $REPORT ...> .
+S2F34 H<-E <B $ACK 1 $ACK> .`,
+			expectedString: []string{
+				"S1F2 H<-E\n<L[2]\n  <A \"model\">\n  <A \"1.0.0\">\n>\n.",
+				"S6F19 W H->E\n<L\n  <L[2]\n    <U4[1] RPTID>\n    <L\n      <U4[1] VID>\n      ...\n    >\n  >\n  ...\n>\n.",
+				"S2F34 H<-E\n<B[3] 0b0 0b1 0b0>\n.",
+			},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "undefined reference",
+			input:            "S1F1 W H->E <L <A $MDLN>> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 19: undefined reference "$MDLN"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:    "recursive reference",
+			input:          "#define A <L $B> #define B <L $A>\nS1F1 W H->E $A .",
+			expectedString: []string{},
+			expectedErrors: []string{
+				`Ln 2, Col 13: recursive reference "$A": A -> B -> A`,
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			description: "duplicated definition, duplicated variable by reference",
+			input: `#define V <U4 VID>
+#define V <U4 VID2>
+S1F3 W H->E <L $V $V> .`,
+			expectedString: []string{},
+			expectedErrors: []string{
+				`Ln 2, Col 9: duplicated definition "V"`,
+				`Ln 3, Col 19: duplicated variable name "VID"`,
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "value definition used as data item",
+			input:            "#define ACK 0\nS2F34 H<-E <L $ACK> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 2, Col 15: expected child data item, variable, ellipsis, or '>', found "0"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "invalid definition body",
+			input:            "#define V VID\nS1F1 W H->E .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 11: expected data item or value in definition "V", found "VID"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "unclosed definition body",
+			input:            "#define V <L <U4 VID> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 23: unexpected "." in definition "V"`},
+			expectedWarnings: []string{},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		msgs, errs, warnings := Parse(test.input)
+		strs := []string{}
+		for _, msg := range msgs {
+			strs = append(strs, fmt.Sprint(msg))
+		}
+		assert.Equal(t, test.expectedString, strs)
+		assert.Equal(t, test.expectedErrors, errs)
+		assert.Equal(t, test.expectedWarnings, warnings)
+	}
+}
+
+func TestParser_Constraints(t *testing.T) {
+	var tests = []struct {
+		description      string   // Test case description
+		input            string   // Input to the parser
+		expectedString   []string // expected string representation of the parsed messages
+		expectedErrors   []string // expected error strings
+		expectedWarnings []string // expected warning strings
+	}{
+		{
+			description: "value constraints and list size range",
+			input: `S1F3 W H->E
+<L[1..10]
+  <U4 SVID{1..100}>
+  ...
+>
+.
+S2F34 H<-E <U1 DRACK{0 1 2 0x04}> .
+S6F11 W H->E <L <I2 TEMP{..-40 125..} 0 DELTA{-5..5}> <F4 RATIO{0..0.5 1}>> .`,
+			expectedString: []string{
+				"S1F3 W H->E\n<L[1..10]\n  <U4[1] SVID{1..100}>\n  ...\n>\n.",
+				"S2F34 H<-E\n<U1[1] DRACK{0 1 2 4}>\n.",
+				"S6F11 W H->E\n<L[2]\n  <I2[3] TEMP{..-40 125..} 0 DELTA{-5..5}>\n  <F4[1] RATIO{0..0.5 1}>\n>\n.",
+			},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "list size range overflow, without ellipsis",
+			input:            "S1F3 W H->E <L[1..2] <U4 V1> <U4 V2> <U4 V3>> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{"Ln 1, Col 15: data item size overflow, got size of 3"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "invalid size range",
+			input:            "S1F3 W H->E <L[2..1] <U4 V> ...> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{"Ln 1, Col 15: invalid data item size range [2..1]"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:    "invalid value constraints",
+			input:          "S1F3 W H->E <L <U1 V1{256}> <I1 V2{1..-1}> <F4 V3{..}> <U4 V4{}>> .",
+			expectedString: []string{},
+			expectedErrors: []string{
+				`Ln 1, Col 22: invalid value constraint {256}, found "256"`,
+				"Ln 1, Col 35: invalid value constraint {1..-1}, invalid value range",
+				`Ln 1, Col 50: invalid value constraint {..}, found ".."`,
+				"Ln 1, Col 62: invalid value constraint {}, empty constraint",
+			},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "value constraint in unsupported data item",
+			input:            "S1F3 W H->E <B V{0..1}> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 17: expected number or variable, found "{0..1}"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "value constraint of duplicated variable",
+			input:            "S1F3 W H->E <U4 V V{1}> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 19: duplicated variable name "V"`},
+			expectedWarnings: []string{},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		msgs, errs, warnings := Parse(test.input)
+		strs := []string{}
+		for _, msg := range msgs {
+			strs = append(strs, fmt.Sprint(msg))
+		}
+		assert.Equal(t, test.expectedString, strs)
+		assert.Equal(t, test.expectedErrors, errs)
+		assert.Equal(t, test.expectedWarnings, warnings)
+	}
+}
+
+func TestParser_Defaults(t *testing.T) {
+	var tests = []struct {
+		description      string   // Test case description
+		input            string   // Input to the parser
+		expectedString   []string // expected string representation of the parsed messages
+		expectedErrors   []string // expected error strings
+		expectedWarnings []string // expected warning strings
+	}{
+		{
+			description: "default values of each data item type",
+			input: `S6F11 W H<-E
+<L
+  <U4 DATAID=0 CEID>
+  <U1 ACK{0 1}=1>
+  <I2 TEMP= -40>
+  <F8 RATIO=0.5>
+  <B CODE=0x0F>
+  <BOOLEAN FLAG=T>
+  <A[..20] MDLN='model "A"'>
+>
+.`,
+			expectedString: []string{
+				"S6F11 W H<-E\n<L[7]\n  <U4[2] DATAID=0 CEID>\n  <U1[1] ACK{0 1}=1>\n  <I2[1] TEMP=-40>\n  <F8[1] RATIO=0.5>\n" +
+					"  <B[1] CODE=0b1111>\n  <BOOLEAN[1] FLAG=T>\n  <A[0..20] MDLN=\"model \\\"A\\\"\">\n>\n.",
+			},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "missing default value",
+			input:            "S1F3 W H->E <L <U4 V1=> > .",
+			expectedString:   []string{},
+			expectedErrors:   []string{"Ln 1, Col 22: expected unsigned integer after '='"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "default value type mismatch",
+			input:            "S1F3 W H->E <BOOLEAN V1=1> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{"Ln 1, Col 24: expected boolean value after '='"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "variable as default value",
+			input:            "S1F3 W H->E <A V1=V2> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 19: expected quoted string or ASCII number code after '=', found "V2"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "ASCII default value with number codes",
+			input:            "S1F3 W H->E <A V1=\"a\" \"b\" 0x0A \"c\"> .",
+			expectedString:   []string{"S1F3 W H->E\n<A V1=\"ab\" 0x0A \"c\">\n."},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "ASCII default value with variable",
+			input:            "S1F3 W H->E <A V1=\"a\" V2> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 23: expected quoted string or ASCII number code after '=', found "V2"`},
+			expectedWarnings: []string{},
+		},
+		{
+			description:    "invalid default value",
+			input:          "S1F3 W H->E <L <U4 V1{1..10}=0> <A[..2] V2=\"abc\"> <I1 V3=128>> .",
+			expectedString: []string{},
+			expectedErrors: []string{
+				`Ln 1, Col 29: invalid default value of "V1": default value 0 not allowed, expected {1..10}`,
+				`Ln 1, Col 43: invalid default value of "V2": default value length overflow`,
+				"Ln 1, Col 58: I1 range overflow",
+			},
+			expectedWarnings: []string{},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		msgs, errs, warnings := Parse(test.input)
+		strs := []string{}
+		for _, msg := range msgs {
+			strs = append(strs, fmt.Sprint(msg))
+		}
+		assert.Equal(t, test.expectedString, strs)
+		assert.Equal(t, test.expectedErrors, errs)
+		assert.Equal(t, test.expectedWarnings, warnings)
+	}
+}
+
+func TestParser_ArrayVariables(t *testing.T) {
+	var tests = []struct {
+		description      string   // Test case description
+		input            string   // Input to the parser
+		expectedString   []string // expected string representation of the parsed messages
+		expectedErrors   []string // expected error strings
+		expectedWarnings []string // expected warning strings
+	}{
+		{
+			description: "array variables of each data item type",
+			input: `S2F23 W H->E
+<L
+  <U4[..] SVIDS>
+  <U1[2..5] CODES{0 1}>
+  <I2[3] TEMPS>
+  <F8[..10] RATIOS>
+  <B[16] DATA>
+  <BOOLEAN[1..] FLAGS>
+>
+.`,
+			expectedString: []string{
+				"S2F23 W H->E\n<L[6]\n  <U4[..] SVIDS>\n  <U1[2..5] CODES{0 1}>\n  <I2[3] TEMPS>\n  <F8[0..10] RATIOS>\n" +
+					"  <B[16] DATA>\n  <BOOLEAN[1..] FLAGS>\n>\n.",
+			},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "scalar variables",
+			input:            "S1F3 W H->E <L <U4[1] V1> <U4[2] V2 V3> <A[..] V4>> .",
+			expectedString:   []string{"S1F3 W H->E\n<L[3]\n  <U4[1] V1>\n  <U4[2] V2 V3>\n  <A V4>\n>\n."},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "variable with other values in ranged size",
+			input:            "S1F3 W H->E <U4[..3] V1 1> .",
+			expectedString:   []string{"S1F3 W H->E\n<U4[2] V1 1>\n."},
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "variables out of size",
+			input:            "S1F3 W H->E <U4[3] V1 V2> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{"Ln 1, Col 16: data item size overflow, got size of 2"},
+			expectedWarnings: []string{},
+		},
+		{
+			description:      "array variable with default value",
+			input:            "S1F3 W H->E <U4[..3] V1=1> .",
+			expectedString:   []string{},
+			expectedErrors:   []string{`Ln 1, Col 16: array variable "V1" cannot have a default value`},
+			expectedWarnings: []string{},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		msgs, errs, warnings := Parse(test.input)
+		strs := []string{}
+		for _, msg := range msgs {
+			strs = append(strs, fmt.Sprint(msg))
+		}
+		assert.Equal(t, test.expectedString, strs)
+		assert.Equal(t, test.expectedErrors, errs)
+		assert.Equal(t, test.expectedWarnings, warnings)
+
+		// Round trip
+		if len(errs) == 0 {
+			msgs2, errs, _ := Parse(strings.Join(strs, "\n"))
+			assert.Empty(t, errs)
+			assert.Equal(t, msgs, msgs2)
+		}
+	}
+
+	msgs, _, _ := Parse("S2F23 W H->E <U4[1..3] SVIDS{1..100}> .")
+	_, err := msgs[0].FillVariables(map[string]interface{}{"SVIDS": []int{1, 2, 3, 4}})
+	assert.EqualError(t, err, `variable "SVIDS": array size 4 out of range [1..3]`)
+	msg, err := msgs[0].FillVariables(map[string]interface{}{"SVIDS": []int{1, 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, "S2F23 W H->E\n<U4[2] 1 2>\n.", fmt.Sprint(msg))
+}
@@ -0,0 +1,107 @@
+package sml
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Format returns the SML representation of the message in the dialect.
+//
+// Format(msg, DialectDefault) is equal to msg.String().
+// The result can be parsed by Parse with WithDialect(dialect).
+func Format(msg *ast.DataMessage, dialect Dialect) string {
+	text := msg.String()
+	i := strings.Index(text, "\n")
+	body := text[i+1 : len(text)-1] // Without the message end character
+
+	var sb strings.Builder
+	sb.WriteString(formatHeader(msg, dialect))
+	sb.WriteString("\n")
+	if body != "" {
+		sb.WriteString(formatBody(body, dialect))
+	}
+	if !dialect.OptionalMessageEnd {
+		sb.WriteString(".")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// formatHeader returns the message header of msg in the dialect.
+func formatHeader(msg *ast.DataMessage, dialect Dialect) string {
+	var sb strings.Builder
+	if dialect.NamedHeader && msg.Name() != "" {
+		fmt.Fprintf(&sb, "%s: ", msg.Name())
+	}
+
+	fmt.Fprintf(&sb, "S%dF%d", msg.StreamCode(), msg.FunctionCode())
+	switch msg.WaitBit() {
+	case "true":
+		if dialect.StarWaitBit {
+			sb.WriteString(" *")
+		} else {
+			sb.WriteString(" W")
+		}
+	case "optional":
+		sb.WriteString(" [W]")
+	}
+
+	sb.WriteString(" " + msg.Direction())
+	if !dialect.NamedHeader && msg.Name() != "" {
+		sb.WriteString(" " + msg.Name())
+	}
+	return sb.String()
+}
+
+// formatBody converts the message text in the SML syntax of this library
+// to the dialect. The message text is a result of ItemNode.String().
+func formatBody(body string, dialect Dialect) string {
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '"':
+			j := i + 1
+			for body[j] != '"' {
+				if body[j] == '\\' {
+					j += 1
+				}
+				j += 1
+			}
+			if dialect.SingleQuote {
+				sb.WriteString(toSingleQuoted(body[i : j+1]))
+			} else {
+				sb.WriteString(body[i : j+1])
+			}
+			i = j
+		case dialect.BoolType && strings.HasPrefix(body[i:], "<BOOLEAN"):
+			sb.WriteString("<BOOL")
+			i += len("<BOOLEAN") - 1
+		default:
+			sb.WriteByte(body[i])
+		}
+	}
+	return sb.String()
+}
+
+// toSingleQuoted converts the double-quoted string s to a single-quoted string.
+func toSingleQuoted(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('\'')
+	for i := 1; i < len(s)-1; i++ {
+		switch {
+		case s[i] == '\\' && s[i+1] == '"':
+			sb.WriteByte('"')
+			i += 1
+		case s[i] == '\\':
+			sb.WriteString(s[i : i+2])
+			i += 1
+		case s[i] == '\'':
+			sb.WriteString(`\'`)
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
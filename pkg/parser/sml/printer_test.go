@@ -0,0 +1,78 @@
+package sml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests SML printer
+//
+// Testing Strategy:
+//
+// Format the parsed messages in the dialects, and test the result strings.
+// Parse the result strings in the same dialects, and test that the messages are equal.
+//
+// Partitions:
+//
+// - Dialect: default, each printing variation
+// - Message name: empty, non-empty
+// - Wait bit: false, true, optional
+// - Data item: none, boolean, string with quotes and backslashes, nested list
+
+func TestFormat(t *testing.T) {
+	input := `S1F1 W H->E AreYouThere .
+S1F2 H<-E <L <BOOLEAN T> <A "it's \"quoted\" \\"> <L <BOOLEAN F>>> .
+S1F3 [W] H->E .`
+
+	var tests = []struct {
+		description string   // Test case description
+		dialect     Dialect  // dialect to format the messages in
+		expected    []string // expected results of Format
+	}{
+		{
+			description: "default dialect",
+			dialect:     DialectDefault,
+			expected: []string{
+				"S1F1 W H->E AreYouThere\n.",
+				"S1F2 H<-E\n<L[3]\n  <BOOLEAN[1] T>\n  <A \"it's \\\"quoted\\\" \\\\\">\n  <L[1]\n    <BOOLEAN[1] F>\n  >\n>\n.",
+				"S1F3 [W] H->E\n.",
+			},
+		},
+		{
+			description: "BOOL, single quote",
+			dialect:     Dialect{BoolType: true, SingleQuote: true},
+			expected: []string{
+				"S1F1 W H->E AreYouThere\n.",
+				"S1F2 H<-E\n<L[3]\n  <BOOL[1] T>\n  <A 'it\\'s \"quoted\" \\\\'>\n  <L[1]\n    <BOOL[1] F>\n  >\n>\n.",
+				"S1F3 [W] H->E\n.",
+			},
+		},
+		{
+			description: "star wait bit, named header, optional message end",
+			dialect:     Dialect{StarWaitBit: true, NamedHeader: true, OptionalMessageEnd: true},
+			expected: []string{
+				"AreYouThere: S1F1 * H->E",
+				"S1F2 H<-E\n<L[3]\n  <BOOLEAN[1] T>\n  <A \"it's \\\"quoted\\\" \\\\\">\n  <L[1]\n    <BOOLEAN[1] F>\n  >\n>",
+				"S1F3 [W] H->E",
+			},
+		},
+	}
+
+	msgs, errs, _ := Parse(input)
+	if !assert.Empty(t, errs) {
+		return
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		for j, msg := range msgs {
+			str := Format(msg, test.dialect)
+			assert.Equal(t, test.expected[j], str)
+			reparsedMsgs, reparsedErrs, _ := Parse(str, WithDialect(test.dialect))
+			assert.Empty(t, reparsedErrs)
+			if assert.Len(t, reparsedMsgs, 1) {
+				assert.Equal(t, msg, reparsedMsgs[0])
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package sml
+
+import (
+	"io"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Scanner provides an interface for parsing SML input read from an io.Reader,
+// one message at a time.
+//
+// Unlike Parse, the whole input doesn't need to be kept in memory; only the
+// message being parsed is kept, so that large inputs such as SML trace logs
+// can be parsed with bounded memory.
+//
+// Successive calls to the Scan method will step through the messages of the input.
+// Scanning stops at the end of the input, at the first I/O error, or at the
+// first lexical error, e.g. unclosed quoted string, after which the rest of the
+// input cannot be tokenized. Other parsing errors only affect the message that
+// contains the error; the scanner skips to the next message end character '.'
+// and continues scanning.
+//
+// Example:
+//
+//	scanner := sml.NewScanner(r)
+//	for scanner.Scan() {
+//		if msg := scanner.Message(); msg != nil {
+//			// ...
+//		}
+//	}
+//	if err := scanner.Err(); err != nil {
+//		// ...
+//	}
+type Scanner struct {
+	p        *parser          // parser that parses the input read from the reader
+	message  *ast.DataMessage // last scanned message; nil if it had errors
+	errors   []string         // parsing errors of the last scanned message
+	warnings []string         // parsing warnings of the last scanned message
+	done     bool             // true if the scanning is finished
+}
+
+// NewScanner returns a new Scanner to parse the SML input read from r,
+// configured by the options.
+//
+// The input should have UTF-8 encoding.
+func NewScanner(r io.Reader, opts ...Option) *Scanner {
+	return &Scanner{
+		p: newParser(lexReader(r), opts...),
+	}
+}
+
+// Scan parses the next message in the input, which will then be available
+// through the Message, Errors, and Warnings methods.
+//
+// It returns false when the scan stops, either by reaching the end of the
+// input or an error. After Scan returns false, the Err method will return
+// any I/O error that occurred during scanning.
+func (s *Scanner) Scan() bool {
+	s.message, s.errors, s.warnings = nil, []string{}, []string{}
+	if s.done {
+		return false
+	}
+
+	p := s.p
+	p.messages, p.errors, p.warnings = p.messages[:0], p.errors[:0], p.warnings[:0]
+
+	if p.peek().typ == tokenTypeEOF {
+		s.done = true
+		return false
+	}
+
+	if p.peek().typ == tokenTypeDirective {
+		if ok := p.parseDirective(); !ok {
+			p.skipMessage()
+		}
+	} else if ok := p.parseMessage(); !ok {
+		p.skipMessage()
+	}
+
+	for _, err := range p.errors {
+		s.errors = append(s.errors, err.string())
+	}
+	for _, warning := range p.warnings {
+		s.warnings = append(s.warnings, warning.string())
+	}
+	if len(p.errors) == 0 && len(p.messages) == 1 {
+		s.message = p.messages[0]
+	}
+	return true
+}
+
+// Message returns the message parsed by the last call to Scan.
+// It returns nil if the message had parsing errors, or a directive was
+// scanned instead of a message. Include directives are not resolved by Scanner,
+// but definitions by define directives are kept for the following messages.
+func (s *Scanner) Message() *ast.DataMessage {
+	return s.message
+}
+
+// Errors returns the parsing errors found by the last call to Scan.
+// Errors have format of "Ln x, Col y: error text".
+func (s *Scanner) Errors() []string {
+	return s.errors
+}
+
+// Warnings returns the parsing warnings found by the last call to Scan.
+// Warnings have format of "Ln x, Col y: warning text".
+func (s *Scanner) Warnings() []string {
+	return s.warnings
+}
+
+// Err returns the first non-EOF I/O error that was encountered by the Scanner.
+func (s *Scanner) Err() error {
+	return s.p.lexer.readErr
+}
@@ -0,0 +1,232 @@
+package sml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+)
+
+// Tests SML scanner
+//
+// Testing Strategy:
+//
+// Scan input read from a reader, and test the scanned messages, errors and warnings
+// of each Scan call. Compare the results with the results of Parse on the same input,
+// when the input has no errors.
+//
+// Partitions:
+//
+// - Number of messages: 0, 1, ...
+// - Errors: none, parsing error, lexical error, I/O error
+// - Directives: none, define directive
+// - Input size: smaller than the read chunk size, larger than the read chunk size,
+//   a message or a line larger than the read chunk size
+// - Reader: reads whole input at once, reads one byte at a time
+
+// scanResult is a result of a Scan call.
+type scanResult struct {
+	message  string   // string representation of the message; empty if nil
+	errors   []string // expected error strings in form of "line:col:subset of error text"
+	warnings int      // number of warnings
+}
+
+// doScan runs the scanner and returns the result of each Scan call.
+func doScan(r io.Reader) ([]scanResult, error) {
+	results := []scanResult{}
+	scanner := NewScanner(r)
+	for scanner.Scan() {
+		result := scanResult{warnings: len(scanner.Warnings())}
+		if msg := scanner.Message(); msg != nil {
+			result.message = fmt.Sprint(msg)
+		}
+		result.errors = scanner.Errors()
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+func TestScanner(t *testing.T) {
+	var tests = []struct {
+		description string       // Test case description
+		input       string       // Input to the scanner
+		expected    []scanResult // expected results of Scan calls
+	}{
+		{
+			description: "empty input",
+			input:       "",
+			expected:    []scanResult{},
+		},
+		{
+			description: "comments only",
+			input:       "// comment\n// comment",
+			expected:    []scanResult{},
+		},
+		{
+			description: "1 message",
+			input:       "S1F1 W H->E\n.",
+			expected:    []scanResult{{"S1F1 W H->E\n.", []string{}, 0}},
+		},
+		{
+			description: "2 messages, no newline between messages",
+			input:       `S1F1 W H->E . S1F2 H<-E <L <A "MDLN"> <A "1.0.0">>.`,
+			expected: []scanResult{
+				{"S1F1 W H->E\n.", []string{}, 0},
+				{"S1F2 H<-E\n<L[2]\n  <A \"MDLN\">\n  <A \"1.0.0\">\n>\n.", []string{}, 0},
+			},
+		},
+		{
+			description: "warnings",
+			input:       "S1F1 W\n.\nS1F2 H<-E\n.",
+			expected: []scanResult{
+				{"S1F1 W H<->E\n.", []string{}, 1},
+				{"S1F2 H<-E\n.", []string{}, 0},
+			},
+		},
+		{
+			description: "non-critical parsing error",
+			input:       "S1F1 H->E <U1 256>.\nS1F2 H<-E .",
+			expected: []scanResult{
+				{"", []string{"1:15:overflow"}, 0},
+				{"S1F2 H<-E\n.", []string{}, 0},
+			},
+		},
+		{
+			description: "critical parsing error, recovered at the next message",
+			input:       "S1F1 H->E\n<L <X>>.\nS1F2 H<-E .\nS1F3 H->E\n<U1 1 .",
+			expected: []scanResult{
+				{"", []string{"2:5:invalid data item type"}, 0},
+				{"S1F2 H<-E\n.", []string{}, 0},
+				{"", []string{"5:7:expected unsigned integer or variable"}, 0},
+			},
+		},
+		{
+			description: "definitions are kept for the following messages",
+			input:       "#define MDLN <A \"model\">\nS1F2 H<-E <L $MDLN> .\nS1F14 H<-E <L <B 0> <L $MDLN>> .",
+			expected: []scanResult{
+				{"", []string{}, 0},
+				{"S1F2 H<-E\n<L[1]\n  <A \"model\">\n>\n.", []string{}, 0},
+				{"S1F14 H<-E\n<L[2]\n  <B[1] 0b0>\n  <L[1]\n    <A \"model\">\n  >\n>\n.", []string{}, 0},
+			},
+		},
+		{
+			description: "lexical error, stops scanning",
+			input:       "S1F1 H->E .\nS1F2 H<-E <A \"unclosed\n>.\nS1F3 H->E .",
+			expected: []scanResult{
+				{"S1F1 H->E\n.", []string{}, 0},
+				{"", []string{"2:14:unclosed"}, 0},
+			},
+		},
+	}
+	for i, test := range tests {
+		t.Logf("Test #%d: %s", i, test.description)
+		for _, r := range []io.Reader{strings.NewReader(test.input), iotest.OneByteReader(strings.NewReader(test.input))} {
+			results, err := doScan(r)
+			assert.NoError(t, err)
+			if !assert.Len(t, results, len(test.expected)) {
+				continue
+			}
+			for j, result := range results {
+				assert.Equal(t, test.expected[j].message, result.message)
+				assert.Equal(t, test.expected[j].warnings, result.warnings)
+				if !assert.Len(t, result.errors, len(test.expected[j].errors)) {
+					continue
+				}
+				for k, err := range result.errors {
+					s := strings.SplitN(test.expected[j].errors[k], ":", 3)
+					lineCol := fmt.Sprintf("Ln %s, Col %s", s[0], s[1])
+					assert.Truef(t, strings.HasPrefix(err, lineCol), "Wrong error position, expected %s, got %s", lineCol, err)
+					assert.Contains(t, err, s[2])
+				}
+			}
+		}
+	}
+}
+
+func TestScanner_LargeInput(t *testing.T) {
+	var sb strings.Builder
+	count := 0
+	for sb.Len() < 3*readChunkSize {
+		fmt.Fprintf(&sb, "// message #%d\nS6F11 W H<-E EventReport\n<L[3]\n  <U4 %d>\n  <F4 1.5>\n  <L <A \"...\"> ...>\n>\n.\n", count, count)
+		count += 1
+	}
+	// Last message has an error, to check the line number
+	sb.WriteString("S6F12 H->E\n<B 256>\n.")
+
+	input := sb.String()
+	parsed, _, _ := Parse(strings.TrimSuffix(input, "S6F12 H->E\n<B 256>\n."))
+	assert.Len(t, parsed, count)
+
+	scanner := NewScanner(strings.NewReader(input))
+	for i := 0; i < count; i++ {
+		assert.True(t, scanner.Scan())
+		assert.Empty(t, scanner.Errors())
+		assert.Equal(t, parsed[i], scanner.Message())
+	}
+	assert.True(t, scanner.Scan())
+	assert.Nil(t, scanner.Message())
+	if assert.Len(t, scanner.Errors(), 1) {
+		line := strings.Count(input, "\n")
+		assert.True(t, strings.HasPrefix(scanner.Errors()[0], fmt.Sprintf("Ln %d, Col 4:", line)), scanner.Errors()[0])
+	}
+	assert.False(t, scanner.Scan())
+	assert.NoError(t, scanner.Err())
+}
+
+func TestScanner_LargeMessage(t *testing.T) {
+	// a process program of several MB in a message, and a line larger than the read chunk size
+	var sb strings.Builder
+	sb.WriteString("S7F3 W H->E\n<L\n  <A \"PPID\">\n  <B")
+	for i := 0; i < 1<<19; i++ {
+		if i%16 == 0 {
+			sb.WriteString("\n   ")
+		}
+		sb.WriteString(" 0x1F")
+	}
+	sb.WriteString(">\n>\n.\nS7F4 H<-E\n<L")
+	for i := 0; i < readChunkSize/4; i++ {
+		sb.WriteString(" <U1 1>")
+	}
+	sb.WriteString(" ?>\n.")
+	input := sb.String()
+
+	// the kept input is bounded by the read chunk size and the line length
+	l := lexReader(strings.NewReader(input))
+	maxInput := 0
+	for tok := l.nextToken(); tok.typ != tokenTypeEOF && tok.typ != tokenTypeError; tok = l.nextToken() {
+		if len(l.input) > maxInput {
+			maxInput = len(l.input)
+		}
+	}
+	assert.Less(t, maxInput, 4*readChunkSize+len(" <U1 1>")*readChunkSize/4)
+
+	scanner := NewScanner(strings.NewReader(input))
+	assert.True(t, scanner.Scan())
+	assert.Empty(t, scanner.Errors())
+	msg := scanner.Message()
+	if assert.NotNil(t, msg) {
+		assert.Equal(t, 1<<19, msg.Item().(*ast.ListNode).Values()[1].(*ast.BinaryNode).Size())
+	}
+	assert.True(t, scanner.Scan())
+	if assert.Len(t, scanner.Errors(), 1) {
+		line := strings.Count(input, "\n")
+		col := 2 + len(" <U1 1>")*readChunkSize/4 + 2
+		assert.True(t, strings.HasPrefix(scanner.Errors()[0], fmt.Sprintf("Ln %d, Col %d:", line, col)), scanner.Errors()[0])
+	}
+}
+
+func TestScanner_ReadError(t *testing.T) {
+	readErr := errors.New("read error")
+	r := io.MultiReader(strings.NewReader("S1F1 W H->E\n.\n"), iotest.ErrReader(readErr))
+
+	scanner := NewScanner(r)
+	assert.True(t, scanner.Scan())
+	assert.Equal(t, "S1F1 W H->E\n.", fmt.Sprint(scanner.Message()))
+	assert.False(t, scanner.Scan())
+	assert.Equal(t, readErr, scanner.Err())
+}
@@ -0,0 +1,173 @@
+package smllog
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout describes how a record header line is written in a SML communication log.
+//
+// Each record in a log starts with a header line that contains information
+// about the message, such as timestamp, direction, session id and system bytes,
+// followed by the SML text of the message. The SML text may start on the header
+// line itself, after the text matched by the Pattern.
+type Layout struct {
+	// Name is a identifier of the layout.
+	Name string
+
+	// Pattern matches a record header line.
+	// It can contain following named capturing groups, which are all optional.
+	//
+	// "time": timestamp of the record, parsed using TimeFormat.
+	//
+	// "direction": direction marker of the record, compared with SendMarkers and
+	// ReceiveMarkers case-insensitively.
+	//
+	// "session": session id (device id) of the message, parsed as a decimal number,
+	// or a hexadecimal number with 0x prefix.
+	//
+	// "systembytes": system bytes of the message, parsed as a number with the
+	// base of SystemBytesBase.
+	Pattern *regexp.Regexp
+
+	// TimeFormat is the layout of the timestamp, as defined in the time package.
+	TimeFormat string
+
+	// SendMarkers and ReceiveMarkers are the direction markers of sent and
+	// received messages respectively.
+	SendMarkers    []string
+	ReceiveMarkers []string
+
+	// SystemBytesBase is the base of the system bytes number, e.g. 10 or 16.
+	// 0 means that the base is implied by the prefix, as in strconv.ParseUint.
+	SystemBytesBase int
+}
+
+// Predefined layouts
+var (
+	// LayoutBracket matches header lines such as
+	// "2024-01-02 10:00:00.123 [SEND] Session=1 SysBytes=00000001".
+	// Session and system bytes are optional, and the system bytes are hexadecimal.
+	LayoutBracket = Layout{
+		Name: "bracket",
+		Pattern: regexp.MustCompile(
+			`^(?P<time>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?)\s+` +
+				`\[(?P<direction>[A-Za-z]+)\]` +
+				`(?:\s+(?:Session|SessionID|DeviceID)=(?P<session>0x[0-9A-Fa-f]+|\d+))?` +
+				`(?:\s+(?:SysBytes|SystemBytes)=(?P<systembytes>[0-9A-Fa-f]+))?`,
+		),
+		TimeFormat:      "2006-01-02 15:04:05.999999999",
+		SendMarkers:     []string{"SEND", "SENT", "TX", "OUT"},
+		ReceiveMarkers:  []string{"RECV", "RECEIVE", "RECEIVED", "RX", "IN"},
+		SystemBytesBase: 16,
+	}
+
+	// LayoutArrow matches header lines such as
+	// "2024/01/02 10:00:00.123 --> [0x00000001] S1F1 W", where "-->" means sent
+	// and "<--" means received. The system bytes are optional, and the SML text
+	// starts on the header line.
+	LayoutArrow = Layout{
+		Name: "arrow",
+		Pattern: regexp.MustCompile(
+			`^(?P<time>\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?)\s+` +
+				`(?P<direction>-->|<--)` +
+				`(?:\s+\[(?P<systembytes>0x[0-9A-Fa-f]+)\])?`,
+		),
+		TimeFormat:      "2006/01/02 15:04:05.999999999",
+		SendMarkers:     []string{"-->"},
+		ReceiveMarkers:  []string{"<--"},
+		SystemBytesBase: 0,
+	}
+
+	// LayoutTabbed matches tab separated header lines such as
+	// "2024-01-02T10:00:00.123Z<TAB>OUT<TAB>device=0<TAB>system=1", where
+	// the timestamp is in RFC 3339 format, and the system bytes are decimal.
+	LayoutTabbed = Layout{
+		Name: "tabbed",
+		Pattern: regexp.MustCompile(
+			`^(?P<time>\d{4}-\d{2}-\d{2}T[^\t]+)\t` +
+				`(?P<direction>IN|OUT)\t` +
+				`device=(?P<session>\d+)\t` +
+				`system=(?P<systembytes>\d+)`,
+		),
+		TimeFormat:      time.RFC3339Nano,
+		SendMarkers:     []string{"OUT"},
+		ReceiveMarkers:  []string{"IN"},
+		SystemBytesBase: 10,
+	}
+)
+
+// header is the information parsed from a record header line.
+type header struct {
+	time        time.Time
+	direction   string
+	sessionID   int    // -1 if not specified
+	systemBytes []byte // nil if not specified
+	end         int    // end position of the header in the line
+}
+
+// match parses the line as a record header line of the layout.
+// The second return value is false if the line doesn't match the layout.
+// The third return value describes the error if the matched line has invalid values.
+func (layout *Layout) match(line string) (h header, ok bool, err string) {
+	loc := layout.Pattern.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return header{}, false, ""
+	}
+
+	h = header{sessionID: -1, end: loc[1]}
+	for i, name := range layout.Pattern.SubexpNames() {
+		if name == "" || loc[2*i] < 0 {
+			continue
+		}
+		value := line[loc[2*i]:loc[2*i+1]]
+
+		switch name {
+		case "time":
+			t, e := time.Parse(layout.TimeFormat, value)
+			if e != nil {
+				return h, true, "invalid timestamp " + strconv.Quote(value)
+			}
+			h.time = t
+
+		case "direction":
+			h.direction = layout.direction(value)
+			if h.direction == "" {
+				return h, true, "unknown direction marker " + strconv.Quote(value)
+			}
+
+		case "session":
+			v, e := strconv.ParseUint(value, 0, 16)
+			if e != nil {
+				return h, true, "invalid session id " + strconv.Quote(value)
+			}
+			h.sessionID = int(v)
+
+		case "systembytes":
+			v, e := strconv.ParseUint(value, layout.SystemBytesBase, 32)
+			if e != nil {
+				return h, true, "invalid system bytes " + strconv.Quote(value)
+			}
+			h.systemBytes = []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		}
+	}
+	return h, true, ""
+}
+
+// direction returns DirectionSend or DirectionReceive for the direction marker,
+// or empty string if the marker is unknown.
+func (layout *Layout) direction(marker string) string {
+	for _, m := range layout.SendMarkers {
+		if strings.EqualFold(m, marker) {
+			return DirectionSend
+		}
+	}
+	for _, m := range layout.ReceiveMarkers {
+		if strings.EqualFold(m, marker) {
+			return DirectionReceive
+		}
+	}
+	return ""
+}
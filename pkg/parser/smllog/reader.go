@@ -0,0 +1,198 @@
+// Package smllog contains a reader of SML communication logs, which consist of
+// SML messages interleaved with record header lines, such as timestamps,
+// direction markers, session ids and system bytes.
+package smllog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+// Directions of the records, relative to the side that wrote the log.
+const (
+	DirectionSend    = "send"
+	DirectionReceive = "receive"
+)
+
+// Record is a message record in a SML communication log.
+type Record struct {
+	Line      int              // line number of the record header line
+	Layout    string           // name of the layout that matched the record header line
+	Time      time.Time        // timestamp of the record; zero value if not specified
+	Direction string           // DirectionSend, DirectionReceive, or empty string if not specified
+	Message   *ast.DataMessage // parsed message; nil if the record has errors
+	Errors    []string         // errors in the record, in format of "Ln x, Col y: error text"
+	Warnings  []string         // warnings in the record, in format of "Ln x, Col y: warning text"
+}
+
+// Reader reads records from a SML communication log.
+//
+// A record starts with a header line that matches one of the layouts of the
+// reader, followed by the SML text of a message, which may start on the header
+// line after the matched text. Any text after the end of the message,
+// until the next header line, is ignored. Lines before the first header line
+// are ignored as well.
+//
+// When the header line contains the session id or the system bytes, they are
+// applied to the parsed message using ast.DataMessage.SetSessionIDAndSystemBytes.
+type Reader struct {
+	r       *bufio.Reader // reader to read the log from
+	layouts []Layout      // layouts to match the record header lines
+	line    int           // number of lines read
+	next    *pendingLine  // header line of the next record, read ahead; nil if not read
+	err     error         // error occurred while reading
+}
+
+// pendingLine is a record header line, read ahead of the record text.
+type pendingLine struct {
+	text   string // header line
+	line   int    // line number of the header line
+	layout Layout // matched layout
+	header header // parsed header
+	err    string // error text on parsing header
+}
+
+// NewReader returns a new Reader that reads records from r.
+//
+// Each line is matched against the layouts in order, and the first matched
+// layout is used. If no layout is given, all predefined layouts are used.
+func NewReader(r io.Reader, layouts ...Layout) *Reader {
+	if len(layouts) == 0 {
+		layouts = []Layout{LayoutBracket, LayoutArrow, LayoutTabbed}
+	}
+	return &Reader{
+		r:       bufio.NewReader(r),
+		layouts: layouts,
+	}
+}
+
+// Read reads the next record from the log.
+//
+// It returns io.EOF when no more record is available, or other error if
+// reading from the underlying reader failed.
+// Problems in a record, e.g. syntax errors in SML text, are not returned as
+// an error, but reported in the Errors field of the record.
+func (r *Reader) Read() (*Record, error) {
+	if r.next == nil {
+		// Skip until the first record header line
+		for r.next == nil {
+			text, ok := r.readLine()
+			if !ok {
+				return nil, r.err
+			}
+			r.next = r.matchHeader(text)
+		}
+	}
+
+	current := r.next
+	r.next = nil
+
+	// Replace the matched header text with spaces, to keep the column numbers
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat(" ", utf8.RuneCountInString(current.text[:current.header.end])))
+	sb.WriteString(current.text[current.header.end:])
+	for r.next == nil {
+		text, ok := r.readLine()
+		if !ok {
+			break
+		}
+		if r.next = r.matchHeader(text); r.next == nil {
+			sb.WriteString(text)
+		}
+	}
+	if r.err != nil && r.err != io.EOF {
+		return nil, r.err
+	}
+
+	return current.record(sb.String()), nil
+}
+
+// readLine reads a line including the line break.
+// Returns ok == false if no more line is available.
+func (r *Reader) readLine() (text string, ok bool) {
+	if r.err != nil {
+		return "", false
+	}
+
+	text, r.err = r.r.ReadString('\n')
+	if text == "" {
+		if r.err == nil {
+			r.err = io.EOF
+		}
+		return "", false
+	}
+	r.line += 1
+	return text, true
+}
+
+// matchHeader matches the line against the layouts. Returns nil if no layout matches.
+func (r *Reader) matchHeader(text string) *pendingLine {
+	trimmed := strings.TrimRight(text, "\r\n")
+	for _, layout := range r.layouts {
+		if h, ok, err := layout.match(trimmed); ok {
+			return &pendingLine{text, r.line, layout, h, err}
+		}
+	}
+	return nil
+}
+
+// record parses the SML text of the record, and creates a record.
+func (pl *pendingLine) record(text string) *Record {
+	record := &Record{
+		Line:      pl.line,
+		Layout:    pl.layout.Name,
+		Time:      pl.header.time,
+		Direction: pl.header.direction,
+		Errors:    []string{},
+		Warnings:  []string{},
+	}
+
+	if pl.err != "" {
+		record.Errors = append(record.Errors, fmt.Sprintf("Ln %d, Col 1: %s", pl.line, pl.err))
+	}
+
+	scanner := sml.NewScanner(strings.NewReader(text))
+	if !scanner.Scan() {
+		record.Errors = append(record.Errors, fmt.Sprintf("Ln %d, Col 1: SML message not found", pl.line))
+		return record
+	}
+	for _, err := range scanner.Errors() {
+		record.Errors = append(record.Errors, offsetLine(err, pl.line-1))
+	}
+	for _, warning := range scanner.Warnings() {
+		record.Warnings = append(record.Warnings, offsetLine(warning, pl.line-1))
+	}
+
+	msg := scanner.Message()
+	if msg == nil || len(record.Errors) > 0 {
+		return record
+	}
+
+	sessionID, systemBytes := msg.SessionID(), msg.SystemBytes()
+	if pl.header.sessionID != -1 {
+		sessionID = pl.header.sessionID
+	}
+	if pl.header.systemBytes != nil {
+		systemBytes = pl.header.systemBytes
+	}
+	record.Message = msg.SetSessionIDAndSystemBytes(sessionID, systemBytes)
+	return record
+}
+
+// offsetLine adds offset to the line number of the error or warning text,
+// which has format of "Ln x, Col y: text".
+func offsetLine(text string, offset int) string {
+	var line, col int
+	if n, _ := fmt.Sscanf(text, "Ln %d, Col %d:", &line, &col); n != 2 {
+		return text
+	}
+	i := strings.Index(text, ":")
+	return fmt.Sprintf("Ln %d, Col %d%s", line+offset, col, text[i:])
+}
@@ -0,0 +1,192 @@
+package smllog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests SML communication log reader
+//
+// Testing Strategy:
+//
+// Read records from the input log, and test the fields of each record,
+// including the message's string representation, session id and system bytes.
+//
+// Partitions:
+//
+// - Layout: predefined layouts, custom layout, multiple layouts in a log
+// - Number of records: 0, 1, ...
+// - Lines before the first record: none, some
+// - SML text: on the header line, on the following lines,
+//             with text after the message end, with errors
+// - Header: with/without session id and system bytes, invalid values
+// - I/O error
+
+// readAll reads all records from the reader.
+func readAll(r *Reader) ([]*Record, error) {
+	records := []*Record{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+func TestReader_PredefinedLayouts(t *testing.T) {
+	input := `Log started
+2024-01-02 10:00:00.123 [SEND] SysBytes=0000000A
+S1F1 W H->E
+.
+2024-01-02 10:00:00.456 [RECV] Session=1 SysBytes=0000000A
+S1F2 H<-E
+<L[2]
+  <A "MDLN">
+  <A "1.0.0">
+>
+.
+----------------------------------------
+2024/01/02 10:00:01.000 --> [0x0000000B] S1F13 W H->E
+<L[0]>
+.
+2024/01/02 10:00:01.500 <-- S1F14 H<-E <L <B 0> <L>> .
+2024-01-02T10:00:02.000Z	OUT	device=2	system=12	S2F17 W H->E .
+`
+	records, err := readAll(NewReader(strings.NewReader(input)))
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 5) {
+		return
+	}
+
+	var tests = []struct {
+		line        int
+		layout      string
+		time        time.Time
+		direction   string
+		message     string
+		sessionID   int
+		systemBytes []byte
+	}{
+		{2, "bracket", time.Date(2024, 1, 2, 10, 0, 0, 123000000, time.UTC), DirectionSend,
+			"S1F1 W H->E\n.", -1, []byte{0, 0, 0, 10}},
+		{5, "bracket", time.Date(2024, 1, 2, 10, 0, 0, 456000000, time.UTC), DirectionReceive,
+			"S1F2 H<-E\n<L[2]\n  <A \"MDLN\">\n  <A \"1.0.0\">\n>\n.", 1, []byte{0, 0, 0, 10}},
+		{13, "arrow", time.Date(2024, 1, 2, 10, 0, 1, 0, time.UTC), DirectionSend,
+			"S1F13 W H->E\n<L[0]>\n.", -1, []byte{0, 0, 0, 11}},
+		{16, "arrow", time.Date(2024, 1, 2, 10, 0, 1, 500000000, time.UTC), DirectionReceive,
+			"S1F14 H<-E\n<L[2]\n  <B[1] 0b0>\n  <L[0]>\n>\n.", -1, []byte{0, 0, 0, 0}},
+		{17, "tabbed", time.Date(2024, 1, 2, 10, 0, 2, 0, time.UTC), DirectionSend,
+			"S2F17 W H->E\n.", 2, []byte{0, 0, 0, 12}},
+	}
+	for i, test := range tests {
+		t.Logf("Record #%d", i)
+		record := records[i]
+		assert.Equal(t, test.line, record.Line)
+		assert.Equal(t, test.layout, record.Layout)
+		assert.True(t, test.time.Equal(record.Time), "expected %v, got %v", test.time, record.Time)
+		assert.Equal(t, test.direction, record.Direction)
+		assert.Empty(t, record.Errors)
+		assert.Empty(t, record.Warnings)
+		if assert.NotNil(t, record.Message) {
+			assert.Equal(t, test.message, fmt.Sprint(record.Message))
+			assert.Equal(t, test.sessionID, record.Message.SessionID())
+			assert.Equal(t, test.systemBytes, record.Message.SystemBytes())
+		}
+	}
+}
+
+func TestReader_CustomLayout(t *testing.T) {
+	layout := Layout{
+		Name:            "custom",
+		Pattern:         regexp.MustCompile(`^(?P<time>\d{2}:\d{2}:\d{2}) (?P<direction>H->E|E->H) #(?P<systembytes>\d+)$`),
+		TimeFormat:      "15:04:05",
+		SendMarkers:     []string{"H->E"},
+		ReceiveMarkers:  []string{"E->H"},
+		SystemBytesBase: 10,
+	}
+	input := "10:00:00 H->E #1\nS1F1 W\n.\n10:00:01 E->H #1\nS1F2\n.\n"
+
+	records, err := readAll(NewReader(strings.NewReader(input), layout))
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, DirectionSend, records[0].Direction)
+		assert.Equal(t, 10, records[0].Time.Hour())
+		assert.Equal(t, []byte{0, 0, 0, 1}, records[0].Message.SystemBytes())
+		assert.Equal(t, []string{"Ln 3, Col 1: missing message direction, \"H<->E\" will be used"}, records[0].Warnings)
+		assert.Equal(t, DirectionReceive, records[1].Direction)
+		assert.Equal(t, 1, records[1].Time.Second())
+		assert.Equal(t, "S1F2 H<->E\n.", fmt.Sprint(records[1].Message))
+	}
+}
+
+func TestReader_Errors(t *testing.T) {
+	input := `2024-01-02 10:00:00.000 [SEND]
+S1F1 W H->E
+<U1 256>
+.
+2024-01-02 10:00:01.000 [WRITE]
+S1F1 W H->E
+.
+2024-01-02 10:00:02.000 [SEND]
+linktest.req
+2024-01-02 10:00:03.000 [SEND]
+2024-01-02 10:00:04.000 [SEND] S1F3 W H->E . trailing text
+`
+	records, err := readAll(NewReader(strings.NewReader(input)))
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 5) {
+		return
+	}
+
+	assert.Nil(t, records[0].Message)
+	assert.Equal(t, []string{"Ln 3, Col 5: U1 range overflow"}, records[0].Errors)
+
+	assert.Nil(t, records[1].Message)
+	assert.Equal(t, []string{`Ln 5, Col 1: unknown direction marker "WRITE"`}, records[1].Errors)
+
+	assert.Nil(t, records[2].Message)
+	if assert.Len(t, records[2].Errors, 1) {
+		assert.Contains(t, records[2].Errors[0], "Ln 9, Col 1: expected stream function")
+	}
+
+	assert.Nil(t, records[3].Message)
+	assert.Equal(t, []string{"Ln 10, Col 1: SML message not found"}, records[3].Errors)
+
+	assert.Empty(t, records[4].Errors)
+	assert.Equal(t, "S1F3 W H->E\n.", fmt.Sprint(records[4].Message))
+}
+
+func TestReader_EmptyInput(t *testing.T) {
+	records, err := readAll(NewReader(strings.NewReader("")))
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	records, err = readAll(NewReader(strings.NewReader("no records\nin this log\n")))
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestReader_ReadError(t *testing.T) {
+	readErr := errors.New("read error")
+	input := "2024-01-02 10:00:00.000 [SEND]\nS1F1 W H->E\n.\n2024-01-02 10:00:01.000 [SEND]\n"
+	r := io.MultiReader(strings.NewReader(input), iotest.ErrReader(readErr))
+
+	reader := NewReader(r)
+	record, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "S1F1 W H->E\n.", fmt.Sprint(record.Message))
+	_, err = reader.Read()
+	assert.Equal(t, readErr, err)
+}
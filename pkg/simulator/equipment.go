@@ -0,0 +1,257 @@
+package simulator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/library"
+)
+
+// Equipment is a simulated equipment, which replies to the primary messages and sends
+// the events of a equipment scenario. It is a connection.Handler, which should be set as
+// the handler of the connections in the passive mode, e.g.
+//
+//	eq, err := simulator.NewEquipment(lib, scenario)
+//	l, err := connection.Listen(":5000", connection.WithHandler(eq))
+//	err = eq.Serve(l)
+//
+// The values bound to the equipment are shared by all connections.
+type Equipment struct {
+	lib      *library.MessageLibrary
+	scenario *EquipmentScenario
+	events   map[string]Event // events of the scenario by their names
+	opts     *options
+
+	mu     sync.Mutex             // guards values
+	values map[string]interface{} // values of the variables bound to the equipment
+}
+
+// sender sends a message, e.g. *connection.Conn and connection.ReplyWriter.
+type sender interface {
+	Send(msg *ast.DataMessage) (*ast.DataMessage, error)
+}
+
+// NewEquipment creates a simulated equipment with the message library and the scenario.
+// An error is returned if the scenario refers to a message or a event that doesn't exist,
+// or a rule without the reply has a request without the secondary message in the library.
+func NewEquipment(lib *library.MessageLibrary, scenario *EquipmentScenario, opts ...Option) (*Equipment, error) {
+	e := &Equipment{
+		lib:      lib,
+		scenario: scenario,
+		events:   map[string]Event{},
+		opts:     newOptions(opts),
+		values:   merge(scenario.Values),
+	}
+
+	for i, event := range scenario.Events {
+		if _, ok := e.events[event.Name]; ok || event.Name == "" {
+			return nil, fmt.Errorf("event %d: invalid or duplicated name %q", i+1, event.Name)
+		}
+		if _, ok := lib.Message(event.Message); !ok {
+			return nil, fmt.Errorf("event %q: message %q not found", event.Name, event.Message)
+		}
+		e.events[event.Name] = event
+	}
+	for i, rule := range scenario.Rules {
+		if _, ok := lib.Message(rule.Request); !ok {
+			return nil, fmt.Errorf("rule %d: message %q not found", i+1, rule.Request)
+		}
+		if _, err := e.replyName(rule); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		for _, name := range rule.Trigger {
+			if _, ok := e.events[name]; !ok {
+				return nil, fmt.Errorf("rule %d: event %q not found", i+1, name)
+			}
+		}
+	}
+	return e, nil
+}
+
+// Values returns a copy of the values bound to the equipment.
+func (e *Equipment) Values() map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return merge(e.values)
+}
+
+// ServeSECS implements connection.Handler.ServeSECS().
+//
+// The message is handled by the first rule whose request matches the message; the values of
+// the variables in the request are bound to the equipment, the reply is sent if the message has
+// the wait bit, and then the triggered events are sent. SxF0 is replied to the messages with
+// the wait bit that match no rule, or whose reply cannot be filled.
+func (e *Equipment) ServeSECS(w connection.ReplyWriter, msg *ast.DataMessage) {
+	for _, rule := range e.scenario.Rules {
+		template, _ := e.lib.Message(rule.Request)
+		values, err := match(template, msg)
+		if err != nil {
+			continue
+		}
+		e.opts.logger.Printf("S%dF%d matched %s", msg.StreamCode(), msg.FunctionCode(), rule.Request)
+
+		e.mu.Lock()
+		for name, value := range values {
+			e.values[name] = value
+		}
+		values = merge(e.values, rule.Values)
+		e.mu.Unlock()
+
+		if msg.WaitBit() == "true" {
+			if err := e.reply(w, msg, rule, values); err != nil {
+				e.opts.logger.Printf("S%dF%d: %v", msg.StreamCode(), msg.FunctionCode(), err)
+				abort(w, msg)
+				return
+			}
+		}
+		for _, name := range rule.Trigger {
+			e.send(w, e.events[name])
+		}
+		return
+	}
+
+	e.opts.logger.Printf("S%dF%d matched no rule", msg.StreamCode(), msg.FunctionCode())
+	if msg.WaitBit() == "true" {
+		abort(w, msg)
+	}
+}
+
+// Trigger sends the event with the name on the connection.
+// An error is returned if the event is not found, or the message cannot be sent.
+func (e *Equipment) Trigger(conn *connection.Conn, name string) error {
+	event, ok := e.events[name]
+	if !ok {
+		return fmt.Errorf("event %q not found", name)
+	}
+	return e.send(conn, event)
+}
+
+// Run sends the periodic events on the connection, until the connection is closed.
+// Each event is sent first after its delay, and then every its interval up to its count.
+func (e *Equipment) Run(conn *connection.Conn) {
+	var wg sync.WaitGroup
+	for _, event := range e.scenario.Events {
+		if !event.timed() {
+			continue
+		}
+		wg.Add(1)
+		go func(event Event) {
+			defer wg.Done()
+			e.runEvent(conn, event)
+		}(event)
+	}
+	wg.Wait()
+}
+
+// Serve accepts the connections from the listener, and runs the periodic events on each connection.
+// It returns the error of the listener, e.g. when the listener is closed.
+func (e *Equipment) Serve(l *connection.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		e.opts.logger.Printf("accepted %s", conn.RemoteAddr())
+		go e.Run(conn)
+	}
+}
+
+// runEvent sends the periodic event on the connection, until the connection is closed.
+func (e *Equipment) runEvent(conn *connection.Conn, event Event) {
+	d := time.Duration(event.Delay)
+	for n := 0; event.Count == 0 || n < event.Count; n++ {
+		if n > 0 {
+			if event.Interval <= 0 {
+				return
+			}
+			d = time.Duration(event.Interval)
+		}
+		timer := e.opts.clock.NewTimer(d)
+		select {
+		case <-timer.C():
+		case <-conn.Done():
+			timer.Stop()
+			return
+		}
+		e.send(conn, event)
+	}
+}
+
+// send sends the message of the event with the sender, and logs the error.
+func (e *Equipment) send(s sender, event Event) error {
+	e.mu.Lock()
+	values := merge(e.values, event.Values)
+	e.mu.Unlock()
+
+	msg, err := e.lib.Instantiate(event.Message, values)
+	if err == nil {
+		_, err = s.Send(msg)
+	}
+	if err != nil {
+		e.opts.logger.Printf("event %s: %v", event.Name, err)
+		return err
+	}
+	e.opts.logger.Printf("event %s: sent %s", event.Name, msg.Header())
+	return nil
+}
+
+// reply replies the secondary message of the rule to the message, filled with the values.
+func (e *Equipment) reply(w connection.ReplyWriter, msg *ast.DataMessage, rule Rule, values map[string]interface{}) error {
+	name, err := e.replyName(rule)
+	if err != nil {
+		return err
+	}
+	reply, err := e.lib.Instantiate(name, values)
+	if err != nil {
+		return err
+	}
+	return w.Reply(reply)
+}
+
+// replyName returns the name of the secondary message of the rule.
+func (e *Equipment) replyName(rule Rule) (string, error) {
+	if rule.Reply != "" {
+		if _, ok := e.lib.Message(rule.Reply); !ok {
+			return "", fmt.Errorf("message %q not found", rule.Reply)
+		}
+		return rule.Reply, nil
+	}
+	if reply, ok := e.lib.Secondary(rule.Request); ok {
+		return reply.Name(), nil
+	}
+	template, _ := e.lib.Message(rule.Request)
+	if template.WaitBit() == "false" {
+		return "", nil
+	}
+	return "", fmt.Errorf("secondary message of %q not found", rule.Request)
+}
+
+// match matches the message against the template, which has the same stream and function code,
+// the same wait bit unless it is optional, and the data item that matches as in ast.Match.
+// It returns the values of the variables in the template, or a error if the message doesn't match.
+func match(template, msg *ast.DataMessage) (map[string]interface{}, error) {
+	if template.StreamCode() != msg.StreamCode() || template.FunctionCode() != msg.FunctionCode() {
+		return nil, fmt.Errorf("expected S%dF%d, found S%dF%d",
+			template.StreamCode(), template.FunctionCode(), msg.StreamCode(), msg.FunctionCode())
+	}
+	if template.WaitBit() != "optional" && msg.WaitBit() != "optional" && template.WaitBit() != msg.WaitBit() {
+		return nil, fmt.Errorf("expected wait bit %s, found %s", template.WaitBit(), msg.WaitBit())
+	}
+	switch {
+	case template.Item() == nil && msg.Item() == nil:
+		return map[string]interface{}{}, nil
+	case template.Item() == nil:
+		return nil, fmt.Errorf("unexpected data item")
+	case msg.Item() == nil:
+		return nil, fmt.Errorf("missing data item")
+	}
+	return ast.Match(template.Item(), msg.Item())
+}
+
+// abort replies SxF0 to the message.
+func abort(w connection.ReplyWriter, msg *ast.DataMessage) {
+	w.Reply(ast.NewDataMessage("", msg.StreamCode(), 0, 0, "H<->E", ast.NewEmptyItemNode()))
+}
@@ -0,0 +1,193 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection/connectiontest"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/library"
+)
+
+// Tests the simulated equipment
+//
+// Testing Strategy:
+//
+// Connect a host to the simulated equipment with in-memory connections, send primary messages,
+// and test the replies, the bound values and the events received by the host.
+// The periodic events are expired by advancing a fake clock.
+//
+// Partitions:
+//
+// - Primary message: matches a rule, matches no rule, reply cannot be filled, without wait bit
+// - Rule: default reply, explicit reply, with values, with triggers
+// - Event: triggered by a rule, triggered by Trigger, periodic with count, unknown
+// - Scenario: valid, unknown message, unknown event, duplicated event, missing secondary message
+
+const testLibrary = `
+S1F1 W H->E AreYouThere
+.
+S1F2 H<-E OnLineData
+<L <A MDLN> <A SOFTREV>>
+.
+S1F3 W H->E StatusRequest
+<L <U4 SVID>>
+.
+S1F4 H<-E StatusData
+<L <U4 SV>>
+.
+S2F41 W H->E RemoteCommand
+<L <A RCMD> <L>>
+.
+S2F42 H<-E RemoteCommandAck
+<L <B[1] HCACK> <L>>
+.
+S5F1 W H<-E AlarmReport
+<L <B[1] ALCD> <U4 ALID> <A ALTX>>
+.
+S6F11 W H<-E EventReport
+<L <U4 DATAID> <U4 CEID> <L>>
+.
+S6F12 H->E EventReportAck
+<B[1] ACKC6>
+.
+S10F1 H->E TerminalMessage
+<L <B[1] TID> <A ALTX>>
+.
+`
+
+const testScenario = `{
+	"values": {"MDLN": "SIM", "SOFTREV": "1.0.0"},
+	"rules": [
+		{"request": "AreYouThere"},
+		{"request": "StatusRequest", "reply": "StatusData"},
+		{"request": "RemoteCommand", "values": {"HCACK": 0}, "trigger": ["Started"]},
+		{"request": "TerminalMessage", "trigger": ["Alarm"]}
+	],
+	"events": [
+		{"name": "Started", "message": "EventReport", "values": {"DATAID": 1, "CEID": 1001}},
+		{"name": "Alarm", "message": "AlarmReport", "values": {"ALCD": 1, "ALID": 7}, "delay": "30s", "interval": "1m", "count": 2}
+	]
+}`
+
+// newTestEquipment creates a equipment of the test library and the scenario.
+func newTestEquipment(t *testing.T, scenario string, opts ...Option) (*Equipment, error) {
+	lib, errs, _ := library.ParseMessageLibrary(testLibrary)
+	assert.Empty(t, errs)
+	s, err := ParseEquipmentScenario([]byte(scenario))
+	assert.NoError(t, err)
+	return NewEquipment(lib, s, opts...)
+}
+
+// connectHost connects a host to the equipment, which sends the received primary messages to the channel.
+func connectHost(t *testing.T, eq *Equipment) (host, equipment *connection.Conn, received chan *ast.DataMessage) {
+	received = make(chan *ast.DataMessage, 16)
+	handler := connection.HandlerFunc(func(w connection.ReplyWriter, msg *ast.DataMessage) {
+		received <- msg
+		w.Reply(ast.NewDataMessage("", msg.StreamCode(), msg.FunctionCode()+1, 0, "H->E", ast.NewBinaryNode(0)))
+	})
+	host, equipment, err := connectiontest.Connect(
+		[]connection.Option{connection.WithHandler(handler)},
+		[]connection.Option{connection.WithHandler(eq)},
+	)
+	assert.NoError(t, err)
+	return host, equipment, received
+}
+
+func TestEquipment_ServeSECS(t *testing.T) {
+	eq, err := newTestEquipment(t, testScenario)
+	if !assert.NoError(t, err) {
+		return
+	}
+	host, _, received := connectHost(t, eq)
+	defer host.Close()
+
+	// default reply
+	reply, err := host.Send(ast.NewDataMessage("", 1, 1, 1, "H->E", ast.NewEmptyItemNode()))
+	assert.NoError(t, err)
+	assert.Equal(t, ast.NewListNode(ast.NewASCIINode("SIM"), ast.NewASCIINode("1.0.0")), reply.Item())
+
+	// reply cannot be filled, SV is not bound
+	_, err = host.Send(ast.NewDataMessage("", 1, 3, 1, "H->E", ast.NewListNode(ast.NewUintNode(4, 1))))
+	assert.ErrorIs(t, err, connection.ErrAborted)
+
+	// no rule matched
+	_, err = host.Send(ast.NewDataMessage("", 1, 3, 1, "H->E", ast.NewASCIINode("SVID")))
+	assert.ErrorIs(t, err, connection.ErrAborted)
+
+	// values bound, and event triggered
+	reply, err = host.Send(ast.NewDataMessage("", 2, 41, 1, "H->E",
+		ast.NewListNode(ast.NewASCIINode("START"), ast.NewListNode())))
+	assert.NoError(t, err)
+	assert.Equal(t, ast.NewListNode(ast.NewBinaryNode(0), ast.NewListNode()), reply.Item())
+	assert.Equal(t, "START", eq.Values()["RCMD"])
+	assert.Equal(t, uint64(1), eq.Values()["SVID"])
+	event := <-received
+	assert.Equal(t, "S6F11 W H<->E", event.Header())
+	assert.Equal(t, ast.NewListNode(ast.NewUintNode(4, 1), ast.NewUintNode(4, 1001), ast.NewListNode()), event.Item())
+
+	// without wait bit, event triggered
+	_, err = host.Send(ast.NewDataMessage("", 10, 1, 0, "H->E",
+		ast.NewListNode(ast.NewBinaryNode(0), ast.NewASCIINode("hello"))))
+	assert.NoError(t, err)
+	event = <-received
+	assert.Equal(t, ast.NewListNode(ast.NewBinaryNode(1), ast.NewUintNode(4, 7), ast.NewASCIINode("hello")), event.Item())
+}
+
+func TestEquipment_Run(t *testing.T) {
+	clock := connectiontest.NewFakeClock(time.Now())
+	eq, err := newTestEquipment(t, testScenario, WithClock(clock))
+	if !assert.NoError(t, err) {
+		return
+	}
+	host, equipment, received := connectHost(t, eq)
+	defer host.Close()
+	assert.Error(t, eq.Trigger(equipment, "Unknown"))
+	assert.Error(t, eq.Trigger(equipment, "Alarm"), "ALTX is not bound")
+	assert.NoError(t, eq.Trigger(equipment, "Started"))
+	assert.Equal(t, 6, (<-received).StreamCode())
+
+	done := make(chan struct{})
+	go func() {
+		eq.Run(equipment)
+		close(done)
+	}()
+	eq.mu.Lock()
+	eq.values["ALTX"] = "overheat"
+	eq.mu.Unlock()
+
+	clock.BlockUntil(1)
+	clock.Advance(29 * time.Second)
+	assert.Empty(t, received)
+	clock.Advance(time.Second)
+	assert.Equal(t, ast.NewListNode(ast.NewBinaryNode(1), ast.NewUintNode(4, 7), ast.NewASCIINode("overheat")), (<-received).Item())
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	assert.Equal(t, 5, (<-received).StreamCode())
+
+	// count reached
+	<-done
+	assert.Equal(t, 0, clock.Timers())
+	equipment.Close()
+}
+
+func TestNewEquipment(t *testing.T) {
+	for _, scenario := range []string{
+		`{"rules": [{"request": "Unknown"}]}`,
+		`{"rules": [{"request": "AreYouThere", "reply": "Unknown"}]}`,
+		`{"rules": [{"request": "AreYouThere", "trigger": ["Unknown"]}]}`,
+		`{"rules": [{"request": "AlarmReport"}]}`,
+		`{"events": [{"name": "Alarm", "message": "Unknown"}]}`,
+		`{"events": [{"name": "Alarm", "message": "AlarmReport"}, {"name": "Alarm", "message": "AlarmReport"}]}`,
+		`{"events": [{"message": "AlarmReport"}]}`,
+	} {
+		_, err := newTestEquipment(t, scenario)
+		assert.Error(t, err, scenario)
+	}
+
+	_, err := newTestEquipment(t, `{"rules": [{"request": "TerminalMessage"}]}`)
+	assert.NoError(t, err)
+}
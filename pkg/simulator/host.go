@@ -0,0 +1,303 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/library"
+)
+
+// defaultWaitTimeout is the default timeout of the steps waiting for a message.
+const defaultWaitTimeout = 30 * time.Second
+
+// ErrStepTimeout is the cause of a failed step, whose reply or message is not received until the timeout.
+var ErrStepTimeout = errors.New("step timeout")
+
+// Host is a simulated host, which runs the steps of a host scenario on a connection to a equipment.
+// It is a connection.Handler, which should be set as the handler of the connection, e.g.
+//
+//	h, err := simulator.NewHost(lib, scenario)
+//	conn, err := connection.Dial("equipment:5000", connection.WithHandler(h))
+//	report := h.Run(conn)
+//
+// The received primary messages are replied, and kept for the steps waiting for them.
+type Host struct {
+	lib      *library.MessageLibrary
+	scenario *HostScenario
+	opts     *options
+
+	mu       sync.Mutex             // guards the fields below
+	values   map[string]interface{} // values of the variables bound to the host
+	received []*ast.DataMessage     // received primary messages not matched by a step yet
+	notify   chan struct{}          // closed when a primary message is received
+}
+
+// NewHost creates a simulated host with the message library and the scenario.
+// An error is returned if a step refers to a message that doesn't exist, sends a message expecting
+// a reply without the secondary message in the library, or neither sends, waits nor asserts.
+func NewHost(lib *library.MessageLibrary, scenario *HostScenario, opts ...Option) (*Host, error) {
+	for i, step := range scenario.Steps {
+		if err := validateStep(lib, step); err != nil {
+			return nil, fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+	return &Host{
+		lib:      lib,
+		scenario: scenario,
+		opts:     newOptions(opts),
+		values:   merge(scenario.Values),
+		notify:   make(chan struct{}),
+	}, nil
+}
+
+// validateStep checks the messages of the step exist in the library.
+func validateStep(lib *library.MessageLibrary, step Step) error {
+	switch {
+	case step.Send != "" && step.Wait != "":
+		return fmt.Errorf("both send and wait")
+	case step.Send == "" && step.Wait == "" && len(step.Assert) == 0:
+		return fmt.Errorf("neither send, wait nor assert")
+	case step.Send == "" && step.Expect != "":
+		return fmt.Errorf("expect without send")
+	}
+	for _, name := range []string{step.Send, step.Expect, step.Wait} {
+		if _, ok := lib.Message(name); name != "" && !ok {
+			return fmt.Errorf("message %q not found", name)
+		}
+	}
+	if step.Send != "" && step.Expect == "" {
+		msg, _ := lib.Message(step.Send)
+		if _, ok := lib.Secondary(step.Send); !ok && msg.WaitBit() == "true" {
+			return fmt.Errorf("secondary message of %q not found", step.Send)
+		}
+	}
+	return nil
+}
+
+// Values returns a copy of the values bound to the host.
+func (h *Host) Values() map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return merge(h.values)
+}
+
+// ServeSECS implements connection.Handler.ServeSECS().
+//
+// The message is kept for the steps waiting for a message. If the message has the wait bit,
+// the secondary message of the first message in the library that the message matches is replied,
+// filled with the values bound to the host and the values of the matched message, e.g. S6F12 to
+// S6F11; SxF0 is replied if no secondary message is found, or it cannot be filled.
+func (h *Host) ServeSECS(w connection.ReplyWriter, msg *ast.DataMessage) {
+	h.mu.Lock()
+	h.received = append(h.received, msg)
+	close(h.notify)
+	h.notify = make(chan struct{})
+	values := merge(h.values)
+	h.mu.Unlock()
+
+	if msg.WaitBit() != "true" {
+		return
+	}
+	for _, template := range h.lib.Lookup(msg.StreamCode(), msg.FunctionCode(), "H<-E") {
+		matched, err := match(template, msg)
+		if err != nil {
+			continue
+		}
+		secondary, ok := h.lib.Secondary(template.Name())
+		if !ok {
+			break
+		}
+		reply, err := h.lib.Instantiate(secondary.Name(), merge(values, matched))
+		if err != nil {
+			h.opts.logger.Printf("S%dF%d: %v", msg.StreamCode(), msg.FunctionCode(), err)
+			break
+		}
+		w.Reply(reply)
+		return
+	}
+	abort(w, msg)
+}
+
+// Run runs the steps of the scenario on the connection, and returns the report.
+// The steps after a failed step are skipped.
+func (h *Host) Run(conn *connection.Conn) *Report {
+	report := &Report{Name: h.scenario.Name}
+	start := h.opts.clock.Now()
+	failed := false
+	for i, step := range h.scenario.Steps {
+		result := StepResult{Name: stepName(i, step)}
+		if failed {
+			result.Skipped = true
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		stepStart := h.opts.clock.Now()
+		result.Err = h.runStep(conn, step)
+		result.Time = h.opts.clock.Now().Sub(stepStart)
+		if result.Err != nil {
+			failed = true
+			h.opts.logger.Printf("FAIL %s: %v", result.Name, result.Err)
+		} else {
+			h.opts.logger.Printf("PASS %s", result.Name)
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.Time = h.opts.clock.Now().Sub(start)
+	return report
+}
+
+// runStep runs the step on the connection.
+func (h *Host) runStep(conn *connection.Conn, step Step) error {
+	switch {
+	case step.Send != "":
+		if err := h.send(conn, step); err != nil {
+			return err
+		}
+	case step.Wait != "":
+		if err := h.wait(conn, step); err != nil {
+			return err
+		}
+	}
+	return h.assert(step.Assert)
+}
+
+// send sends the message of the step, and matches the reply against the expected template.
+func (h *Host) send(conn *connection.Conn, step Step) error {
+	h.mu.Lock()
+	values := merge(h.values, step.Values)
+	h.mu.Unlock()
+	msg, err := h.lib.Instantiate(step.Send, values)
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		reply *ast.DataMessage
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		reply, err := conn.Send(msg)
+		ch <- result{reply, err}
+	}()
+	var timeout <-chan time.Time
+	if step.Timeout > 0 {
+		timer := h.opts.clock.NewTimer(time.Duration(step.Timeout))
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+	var r result
+	select {
+	case r = <-ch:
+	case <-timeout:
+		return fmt.Errorf("%w: no reply of %s in %s", ErrStepTimeout, msg.Header(), time.Duration(step.Timeout))
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if r.reply == nil {
+		return nil
+	}
+
+	template := h.expected(step)
+	matched, err := match(template, r.reply)
+	if err != nil {
+		return fmt.Errorf("reply doesn't match %s: %v", template.Header(), err)
+	}
+	h.bind(matched)
+	return nil
+}
+
+// expected returns the template of the reply of the step.
+func (h *Host) expected(step Step) *ast.DataMessage {
+	if step.Expect != "" {
+		template, _ := h.lib.Message(step.Expect)
+		return template
+	}
+	template, _ := h.lib.Secondary(step.Send)
+	return template
+}
+
+// wait waits for a received primary message that matches the template of the step.
+func (h *Host) wait(conn *connection.Conn, step Step) error {
+	template, _ := h.lib.Message(step.Wait)
+	d := time.Duration(step.Timeout)
+	if d <= 0 {
+		d = defaultWaitTimeout
+	}
+	timer := h.opts.clock.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		h.mu.Lock()
+		notify := h.notify
+		for i, msg := range h.received {
+			if matched, err := match(template, msg); err == nil {
+				h.received = append(h.received[:i], h.received[i+1:]...)
+				h.mu.Unlock()
+				h.bind(matched)
+				return nil
+			}
+		}
+		h.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C():
+			return fmt.Errorf("%w: no message matching %s in %s", ErrStepTimeout, template.Header(), d)
+		case <-conn.Done():
+			return conn.Err()
+		}
+	}
+}
+
+// assert checks the bound values against the expected values, in order of the names.
+func (h *Host) assert(expected Values) error {
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, name := range names {
+		actual, ok := h.values[name]
+		if !ok {
+			return fmt.Errorf("%s: not bound", name)
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(expected[name]) {
+			return fmt.Errorf("%s: expected %v, found %v", name, expected[name], actual)
+		}
+	}
+	return nil
+}
+
+// bind binds the values to the host.
+func (h *Host) bind(values map[string]interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, value := range values {
+		h.values[name] = value
+	}
+}
+
+// stepName returns the name of the i-th (zero-based) step.
+func stepName(i int, step Step) string {
+	switch {
+	case step.Name != "":
+		return step.Name
+	case step.Send != "":
+		return fmt.Sprintf("step %d: send %s", i+1, step.Send)
+	case step.Wait != "":
+		return fmt.Sprintf("step %d: wait %s", i+1, step.Wait)
+	default:
+		return fmt.Sprintf("step %d: assert", i+1)
+	}
+}
@@ -0,0 +1,191 @@
+package simulator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/ast"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection/connectiontest"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/library"
+)
+
+// Tests the simulated host
+//
+// Testing Strategy:
+//
+// Connect the simulated host to the simulated equipment with in-memory connections, run the steps
+// of host scenarios, and test the reports and the bound values. The step timeouts are expired by
+// advancing a fake clock.
+//
+// Partitions:
+//
+// - Send step: default expected reply, explicit expected reply, reply doesn't match, aborted,
+//   without wait bit, timeout
+// - Wait step: message received before the step, after the step, timeout
+// - Assert: bound value equal, not equal, not bound
+// - Received primary message: replied with the secondary message, replied SxF0
+// - Scenario: valid, unknown message, invalid step, missing secondary message
+
+// newTestHost creates a host of the test library and the scenario.
+func newTestHost(t *testing.T, scenario string, opts ...Option) (*Host, error) {
+	lib, errs, _ := library.ParseMessageLibrary(testLibrary)
+	assert.Empty(t, errs)
+	s, err := ParseHostScenario([]byte(scenario))
+	assert.NoError(t, err)
+	return NewHost(lib, s, opts...)
+}
+
+// connectSimulators connects the host to the equipment, and runs the periodic events of the equipment.
+func connectSimulators(t *testing.T, h *Host, eq connection.Handler) (host, equipment *connection.Conn) {
+	host, equipment, err := connectiontest.Connect(
+		[]connection.Option{connection.WithHandler(h)},
+		[]connection.Option{connection.WithHandler(eq)},
+	)
+	assert.NoError(t, err)
+	if eq, ok := eq.(*Equipment); ok {
+		go eq.Run(equipment)
+	}
+	return host, equipment
+}
+
+func TestHost_Run(t *testing.T) {
+	eq, err := newTestEquipment(t, testScenario)
+	assert.NoError(t, err)
+	h, err := newTestHost(t, `{
+		"name": "remote start",
+		"values": {"ACKC6": 0},
+		"steps": [
+			{"send": "AreYouThere", "assert": {"MDLN": "SIM", "SOFTREV": "1.0.0"}},
+			{"name": "start", "send": "RemoteCommand", "expect": "RemoteCommandAck", "values": {"RCMD": "START"}, "timeout": "5s"},
+			{"wait": "EventReport", "assert": {"CEID": 1001, "DATAID": 1}},
+			{"send": "TerminalMessage", "values": {"TID": 0, "ALTX": "hello"}},
+			{"wait": "AlarmReport", "assert": {"ALTX": "hello", "ALID": 7}},
+			{"assert": {"HCACK": 0}}
+		]
+	}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	host, _ := connectSimulators(t, h, eq)
+	defer host.Close()
+
+	report := h.Run(host)
+	assert.Equal(t, "remote start", report.Name)
+	assert.False(t, report.Failed(), report.String())
+	names := []string{}
+	for _, result := range report.Results {
+		names = append(names, result.Name)
+	}
+	assert.Equal(t, []string{
+		"step 1: send AreYouThere", "start", "step 3: wait EventReport",
+		"step 4: send TerminalMessage", "step 5: wait AlarmReport", "step 6: assert",
+	}, names)
+	assert.Equal(t, uint64(1001), h.Values()["CEID"])
+	assert.Equal(t, 0, h.Values()["HCACK"])
+}
+
+func TestHost_RunFailed(t *testing.T) {
+	eq, err := newTestEquipment(t, testScenario)
+	assert.NoError(t, err)
+
+	for _, test := range []struct {
+		steps, err string
+	}{
+		{`{"assert": {"MDLN": "SIM"}}`, "MDLN: not bound"},
+		{`{"send": "AreYouThere", "assert": {"MDLN": "EQ"}}`, "MDLN: expected EQ, found SIM"},
+		{`{"send": "AreYouThere", "expect": "StatusData"}`, "reply doesn't match S1F4 H<-E StatusData: expected S1F4, found S1F2"},
+		{`{"send": "StatusRequest", "values": {"SVID": 1}}`, connection.ErrAborted.Error()},
+		{`{"send": "RemoteCommand"}`, `cannot fill variables of message "RemoteCommand": unbound variables: RCMD`},
+	} {
+		h, err := newTestHost(t, `{"steps": [`+test.steps+`, {"send": "AreYouThere"}]}`)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		host, _ := connectSimulators(t, h, eq)
+		report := h.Run(host)
+		host.Close()
+
+		assert.True(t, report.Failed())
+		if assert.Len(t, report.Results, 2) {
+			assert.EqualError(t, report.Results[0].Err, test.err)
+			assert.True(t, report.Results[1].Skipped)
+			assert.NoError(t, report.Results[1].Err)
+		}
+	}
+}
+
+func TestHost_Timeout(t *testing.T) {
+	clock := connectiontest.NewFakeClock(time.Now())
+	h, err := newTestHost(t, `{"steps": [
+		{"send": "AreYouThere", "timeout": "5s"},
+		{"wait": "AlarmReport", "timeout": "10s"}
+	]}`, WithClock(clock))
+	if !assert.NoError(t, err) {
+		return
+	}
+	silent := connection.HandlerFunc(func(w connection.ReplyWriter, msg *ast.DataMessage) {})
+	host, _ := connectSimulators(t, h, silent)
+	defer host.Close()
+
+	ch := make(chan *Report)
+	go func() { ch <- h.Run(host) }()
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	report := <-ch
+	assert.True(t, errors.Is(report.Results[0].Err, ErrStepTimeout))
+	assert.Equal(t, 5*time.Second, report.Results[0].Time)
+
+	h, err = newTestHost(t, `{"steps": [{"wait": "AlarmReport"}]}`, WithClock(clock))
+	assert.NoError(t, err)
+	go func() { ch <- h.Run(host) }()
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Second)
+	report = <-ch
+	assert.EqualError(t, report.Results[0].Err, "step timeout: no message matching S5F1 W H<-E AlarmReport in 30s")
+}
+
+func TestHost_ServeSECS(t *testing.T) {
+	h, err := newTestHost(t, `{"values": {"ACKC6": 0}, "steps": [{"wait": "EventReport"}]}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	host, equipment := connectSimulators(t, h, connection.HandlerFunc(abort))
+	defer host.Close()
+
+	// replied with the secondary message, and kept for the step
+	reply, err := equipment.Send(ast.NewDataMessage("", 6, 11, 1, "H<-E",
+		ast.NewListNode(ast.NewUintNode(4, 1), ast.NewUintNode(4, 1001), ast.NewListNode())))
+	assert.NoError(t, err)
+	assert.Equal(t, ast.NewBinaryNode(0), reply.Item())
+
+	// no secondary message
+	_, err = equipment.Send(ast.NewDataMessage("", 5, 1, 1, "H<-E",
+		ast.NewListNode(ast.NewBinaryNode(1), ast.NewUintNode(4, 7), ast.NewASCIINode("overheat"))))
+	assert.ErrorIs(t, err, connection.ErrAborted)
+
+	report := h.Run(host)
+	assert.False(t, report.Failed(), report.String())
+	assert.Equal(t, uint64(1001), h.Values()["CEID"])
+	assert.Nil(t, h.Values()["ALID"])
+}
+
+func TestNewHost(t *testing.T) {
+	for _, steps := range []string{
+		`{"send": "Unknown"}`,
+		`{"send": "AreYouThere", "expect": "Unknown"}`,
+		`{"wait": "Unknown"}`,
+		`{"send": "AreYouThere", "wait": "AlarmReport"}`,
+		`{"expect": "OnLineData"}`,
+		`{}`,
+		`{"send": "AlarmReport"}`,
+	} {
+		_, err := newTestHost(t, `{"steps": [`+steps+`]}`)
+		assert.Error(t, err, steps)
+	}
+
+	_, err := newTestHost(t, `{"steps": [{"send": "TerminalMessage"}, {"send": "AlarmReport", "expect": "EventReportAck"}]}`)
+	assert.NoError(t, err)
+}
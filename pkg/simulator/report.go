@@ -0,0 +1,120 @@
+package simulator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Report is the result of a host scenario.
+type Report struct {
+	Name    string        // name of the scenario
+	Time    time.Duration // total time of the steps
+	Results []StepResult  // results of the steps, in order
+}
+
+// StepResult is the result of a step of a host scenario.
+type StepResult struct {
+	Name    string        // name of the step
+	Time    time.Duration // time taken by the step
+	Err     error         // cause of the failure; nil if the step passed or skipped
+	Skipped bool          // true if the step is not run, after a failed step
+}
+
+// Failed returns true if any step failed.
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the summary of the report, with a line for each step and a line for the result,
+// e.g. "PASS step 1: send EstablishCommRequest (0.012s)", and "FAIL remote start (1 of 3 steps failed)".
+func (r *Report) String() string {
+	s := ""
+	failures := 0
+	for _, result := range r.Results {
+		switch {
+		case result.Err != nil:
+			failures++
+			s += fmt.Sprintf("FAIL %s (%ss): %v\n", result.Name, seconds(result.Time), result.Err)
+		case result.Skipped:
+			s += fmt.Sprintf("SKIP %s\n", result.Name)
+		default:
+			s += fmt.Sprintf("PASS %s (%ss)\n", result.Name, seconds(result.Time))
+		}
+	}
+	if failures != 0 {
+		return s + fmt.Sprintf("FAIL %s (%d of %d steps failed)\n", r.Name, failures, len(r.Results))
+	}
+	return s + fmt.Sprintf("PASS %s (%ss)\n", r.Name, seconds(r.Time))
+}
+
+// WriteJUnit writes the report in the JUnit XML format, which is read by CI servers,
+// as a test suite with a test case for each step.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{Name: r.Name, Tests: len(r.Results), Time: seconds(r.Time)}
+	for _, result := range r.Results {
+		c := junitTestCase{Name: result.Name, Classname: r.Name, Time: seconds(result.Time)}
+		switch {
+		case result.Err != nil:
+			suite.Failures++
+			c.Failure = &junitFailure{Message: result.Err.Error(), Text: result.Err.Error()}
+		case result.Skipped:
+			suite.Skipped++
+			c.Skipped = &struct{}{}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// seconds returns the duration in seconds with 3 decimal places, e.g. "1.500".
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// junitTestSuites is the root element of the JUnit XML format.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite is a test suite of the JUnit XML format.
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a test case of the JUnit XML format.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+// junitFailure is the failure of a test case of the JUnit XML format.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
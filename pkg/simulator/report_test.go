@@ -0,0 +1,58 @@
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the reports of the host scenarios
+//
+// Testing Strategy:
+//
+// Create reports with the step results, and test the summaries and the JUnit XML outputs.
+//
+// Partitions:
+//
+// - Step result: passed, failed, skipped
+// - Report: all steps passed, any step failed, no steps
+
+func TestReport(t *testing.T) {
+	report := &Report{Name: "remote start", Time: 1500 * time.Millisecond, Results: []StepResult{
+		{Name: "step 1: send AreYouThere", Time: 12 * time.Millisecond},
+		{Name: "process started", Time: time.Second, Err: errors.New(`CEID: expected 1001, found "<1002>"`)},
+		{Name: "step 3: assert", Skipped: true},
+	}}
+	assert.True(t, report.Failed())
+	assert.Equal(t, `PASS step 1: send AreYouThere (0.012s)
+FAIL process started (1.000s): CEID: expected 1001, found "<1002>"
+SKIP step 3: assert
+FAIL remote start (1 of 3 steps failed)
+`, report.String())
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteJUnit(&buf))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="remote start" tests="3" failures="1" skipped="1" time="1.500">
+    <testcase name="step 1: send AreYouThere" classname="remote start" time="0.012"></testcase>
+    <testcase name="process started" classname="remote start" time="1.000">
+      <failure message="CEID: expected 1001, found &#34;&lt;1002&gt;&#34;">CEID: expected 1001, found &#34;&lt;1002&gt;&#34;</failure>
+    </testcase>
+    <testcase name="step 3: assert" classname="remote start" time="0.000">
+      <skipped></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+
+	report = &Report{Name: "empty"}
+	assert.False(t, report.Failed())
+	assert.Equal(t, "PASS empty (0.000s)\n", report.String())
+	buf.Reset()
+	assert.NoError(t, report.WriteJUnit(&buf))
+	assert.Contains(t, buf.String(), `<testsuite name="empty" tests="0" failures="0" skipped="0" time="0.000"></testsuite>`)
+}
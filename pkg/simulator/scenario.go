@@ -0,0 +1,245 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/library"
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/parser/sml"
+)
+
+// Duration is a time.Duration, which is written as a string in the scenario files, e.g. "1.5s".
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler, with the format of time.ParseDuration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration should be a string, e.g. \"10s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Values is the values of the variables in SML messages, by their names.
+//
+// In the scenario files, a integer number is decoded into int64, other numbers into float64,
+// and a array into []interface{}, so that they can be filled into the variables; refer to
+// ast.DataMessage.FillVariables. Note that a string renames the variable, unless it is a
+// variable of a ASCII item.
+type Values map[string]interface{}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (v *Values) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return err
+	}
+	result := Values{}
+	for name, value := range m {
+		result[name] = convertNumbers(value)
+	}
+	*v = result
+	return nil
+}
+
+// convertNumbers converts the json.Number in the decoded JSON value into int64 or float64.
+func convertNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, elem := range value {
+			result[i] = convertNumbers(elem)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// merge returns a new map with the values in the maps, where the latter maps take precedence.
+func merge(maps ...map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, m := range maps {
+		for name, value := range m {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// EquipmentScenario is the scenario of a simulated equipment, which is decoded from JSON, e.g.
+//
+//	{
+//	  "library": "equipment.sml",
+//	  "values": {"MDLN": "SIM", "SOFTREV": "1.0.0"},
+//	  "rules": [
+//	    {"request": "EstablishCommRequest", "values": {"COMMACK": 0}},
+//	    {"request": "RemoteCommand", "reply": "RemoteCommandAck", "trigger": ["ProcessStarted"]}
+//	  ],
+//	  "events": [
+//	    {"name": "ProcessStarted", "message": "EventReport", "values": {"CEID": 1001}},
+//	    {"name": "Alarm", "message": "AlarmReport", "delay": "30s", "interval": "1m", "count": 3}
+//	  ]
+//	}
+//
+// Messages are referred to by their names in the message library of the equipment.
+type EquipmentScenario struct {
+	Library string  `json:"library"` // path of the SML message library, relative to the scenario file
+	Values  Values  `json:"values"`  // initial values of the variables
+	Rules   []Rule  `json:"rules"`   // rules of the replies to the primary messages, in order of precedence
+	Events  []Event `json:"events"`  // unsolicited messages sent by the equipment
+}
+
+// Rule is a rule of the equipment scenario, which replies to the primary messages matching its request.
+type Rule struct {
+	// Request is the name of the primary message, which is the template of the matching messages;
+	// a received message matches if it has the stream and function code, the wait bit unless
+	// it is optional, and the data item matching the data item of the template as in ast.Match.
+	// The values of the variables in the template are bound to the equipment.
+	Request string `json:"request"`
+
+	// Reply is the name of the secondary message replied to the matching messages with the wait bit,
+	// which is filled with the values bound to the equipment and the values of the rule.
+	// The default is the secondary message of the request in the message library.
+	Reply string `json:"reply"`
+
+	// Values is the values filled into the reply, which take precedence over the bound values.
+	Values Values `json:"values"`
+
+	// Trigger is the names of the events sent after the reply.
+	Trigger []string `json:"trigger"`
+}
+
+// Event is a unsolicited message of the equipment scenario, e.g. S6F11 or S5F1, which is sent
+// when triggered by a rule, or periodically while a connection is selected, if it has the delay
+// or the interval.
+type Event struct {
+	Name    string `json:"name"`    // name of the event, referred to by the rules
+	Message string `json:"message"` // name of the message, which is filled as the reply of a rule
+	Values  Values `json:"values"`  // values filled into the message
+
+	Delay    Duration `json:"delay"`    // time from the selection of a connection to the first message
+	Interval Duration `json:"interval"` // time between the messages; 0 to send once after the delay
+	Count    int      `json:"count"`    // maximum number of the periodic messages; 0 for no limit
+}
+
+// timed returns true if the event is sent periodically.
+func (e Event) timed() bool {
+	return e.Delay > 0 || e.Interval > 0
+}
+
+// ParseEquipmentScenario decodes the equipment scenario from JSON.
+// Unknown fields are reported as errors.
+func ParseEquipmentScenario(data []byte) (*EquipmentScenario, error) {
+	scenario := &EquipmentScenario{}
+	if err := decodeStrict(data, scenario); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// HostScenario is the scenario of a simulated host, which is a sequence of steps run on a connection
+// to a equipment, decoded from JSON, e.g.
+//
+//	{
+//	  "name": "remote start",
+//	  "library": "host.sml",
+//	  "steps": [
+//	    {"send": "EstablishCommRequest", "expect": "EstablishCommAck", "timeout": "5s", "assert": {"COMMACK": 0}},
+//	    {"send": "RemoteCommand", "values": {"RCMD": "START"}, "assert": {"HCACK": 0}},
+//	    {"name": "process started", "wait": "EventReport", "timeout": "1m", "assert": {"CEID": 1001}}
+//	  ]
+//	}
+//
+// Messages are referred to by their names in the message library of the host.
+type HostScenario struct {
+	Name    string `json:"name"`    // name of the scenario, e.g. the test suite name of the report
+	Library string `json:"library"` // path of the SML message library, relative to the scenario file
+	Values  Values `json:"values"`  // initial values of the variables
+	Steps   []Step `json:"steps"`   // steps of the scenario, run in order
+}
+
+// Step is a step of the host scenario, which sends a message or waits for a message, and then
+// asserts the bound values. The values of the variables in the replies and the received messages
+// are bound to the host, and filled into the messages of the later steps.
+type Step struct {
+	// Name is the name of the step in the report. The default is generated from the step,
+	// e.g. "step 1: send EstablishCommRequest".
+	Name string `json:"name"`
+
+	// Send is the name of the primary message sent to the equipment, which is filled with the
+	// values bound to the host and the values of the step.
+	Send   string `json:"send"`
+	Values Values `json:"values"`
+
+	// Expect is the name of the template that the reply of the sent message should match,
+	// as the request of a equipment rule. The default is the secondary message of the sent message
+	// in the message library.
+	Expect string `json:"expect"`
+
+	// Wait is the name of the template that a primary message received from the equipment should
+	// match, e.g. S6F11. Messages received before the step are also matched, in order of receipt.
+	Wait string `json:"wait"`
+
+	// Timeout is the maximum time to wait for the reply or the message. For a sent message,
+	// the default is T3 of the connection; for a waited message, the default is 30 seconds.
+	Timeout Duration `json:"timeout"`
+
+	// Assert is the expected values of the variables bound to the host, after the message is
+	// sent or received. A bound value equals the expected value if they have the same string
+	// representation, e.g. uint64(1001) and 1001.
+	Assert Values `json:"assert"`
+}
+
+// ParseHostScenario decodes the host scenario from JSON.
+// Unknown fields are reported as errors.
+func ParseHostScenario(data []byte) (*HostScenario, error) {
+	scenario := &HostScenario{}
+	if err := decodeStrict(data, scenario); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// decodeStrict decodes the JSON data into v, disallowing unknown fields.
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid scenario: %v", err)
+	}
+	return nil
+}
+
+// LoadLibrary loads the SML message library of a scenario file, whose path is relative to
+// the directory of the scenario file, unless it is absolute. The loading errors are returned
+// as a error, and the warnings are returned as is.
+func LoadLibrary(scenarioFile, path string, opts ...sml.Option) (lib *library.MessageLibrary, warnings []string, err error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(scenarioFile), path)
+	}
+	lib, errs, warnings := library.LoadMessageLibrary(os.DirFS(filepath.Dir(path)), filepath.Base(path), opts...)
+	if len(errs) != 0 {
+		return nil, warnings, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return lib, warnings, nil
+}
@@ -0,0 +1,99 @@
+package simulator
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests the scenario files
+//
+// Testing Strategy:
+//
+// Decode equipment scenarios from JSON, and test the decoded values and the errors.
+//
+// Partitions:
+//
+// - Values: string, integer, float, bool, array, nested array
+// - Duration: valid, invalid format, not a string
+// - Fields: known, unknown
+// - Library path: relative, absolute, invalid SML, not found
+
+func TestParseEquipmentScenario(t *testing.T) {
+	scenario, err := ParseEquipmentScenario([]byte(`{
+		"library": "equipment.sml",
+		"values": {"MDLN": "SIM", "CEID": 1001, "TEMP": 25.5, "ONLINE": true, "SVID": [1, -2, [3.5]]},
+		"rules": [{"request": "RemoteCommand", "reply": "RemoteCommandAck", "values": {"HCACK": 0}, "trigger": ["Started"]}],
+		"events": [{"name": "Alarm", "message": "AlarmReport", "delay": "30s", "interval": "1m", "count": 3}]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "equipment.sml", scenario.Library)
+	assert.Equal(t, Values{
+		"MDLN": "SIM", "CEID": int64(1001), "TEMP": 25.5, "ONLINE": true,
+		"SVID": []interface{}{int64(1), int64(-2), []interface{}{3.5}},
+	}, scenario.Values)
+	assert.Equal(t, []Rule{{"RemoteCommand", "RemoteCommandAck", Values{"HCACK": int64(0)}, []string{"Started"}}}, scenario.Rules)
+	assert.Equal(t, []Event{{"Alarm", "AlarmReport", nil, Duration(30 * time.Second), Duration(time.Minute), 3}}, scenario.Events)
+	assert.True(t, scenario.Events[0].timed())
+
+	for _, input := range []string{
+		`{"events": [{"name": "Alarm", "delay": "30"}]}`,
+		`{"events": [{"name": "Alarm", "delay": 30}]}`,
+		`{"rule": []}`,
+		`{"values": [1]}`,
+		`{`,
+	} {
+		_, err := ParseEquipmentScenario([]byte(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseHostScenario(t *testing.T) {
+	scenario, err := ParseHostScenario([]byte(`{
+		"name": "remote start",
+		"library": "host.sml",
+		"values": {"ACKC6": 0},
+		"steps": [
+			{"send": "RemoteCommand", "values": {"RCMD": "START"}, "expect": "RemoteCommandAck", "timeout": "5s"},
+			{"name": "started", "wait": "EventReport", "assert": {"CEID": 1001}}
+		]
+	}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "remote start", scenario.Name)
+	assert.Equal(t, "host.sml", scenario.Library)
+	assert.Equal(t, Values{"ACKC6": int64(0)}, scenario.Values)
+	assert.Equal(t, []Step{
+		{Send: "RemoteCommand", Values: Values{"RCMD": "START"}, Expect: "RemoteCommandAck", Timeout: Duration(5 * time.Second)},
+		{Name: "started", Wait: "EventReport", Assert: Values{"CEID": int64(1001)}},
+	}, scenario.Steps)
+
+	_, err = ParseHostScenario([]byte(`{"steps": [{"sned": "RemoteCommand"}]}`))
+	assert.Error(t, err)
+}
+
+func TestLoadLibrary(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "equipment.sml"), []byte(testLibrary), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "invalid.sml"), []byte("S1F1 W"), 0644))
+	scenarioFile := filepath.Join(dir, "scenario.json")
+
+	lib, _, err := LoadLibrary(scenarioFile, "equipment.sml")
+	assert.NoError(t, err)
+	_, ok := lib.Message("AreYouThere")
+	assert.True(t, ok)
+
+	_, _, err = LoadLibrary("other/scenario.json", filepath.Join(dir, "equipment.sml"))
+	assert.NoError(t, err)
+
+	_, _, err = LoadLibrary(scenarioFile, "invalid.sml")
+	assert.Error(t, err)
+	_, _, err = LoadLibrary(scenarioFile, "unknown.sml")
+	assert.Error(t, err)
+}
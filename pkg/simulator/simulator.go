@@ -0,0 +1,57 @@
+// Package simulator simulates a equipment and a host with HSMS connections, driven by scenario files
+// and SML message libraries, so that host software and equipment software can be tested without
+// their real counterparts.
+//
+// The equipment replies to the primary messages with the rules of the scenario, which match the
+// messages by the templates in the message library, and fill the secondary messages with the values
+// bound from the matched messages. It also sends unsolicited messages, e.g. S6F11 event reports and
+// S5F1 alarm reports, periodically or when triggered by the rules.
+//
+// The host runs the steps of the scenario, which send messages and expect their replies, wait for
+// messages from the equipment, and assert the bound values. The results are reported as pass or fail,
+// e.g. in the JUnit XML format.
+package simulator
+
+import (
+	"io"
+	"log"
+
+	"github.com/wolimst/lib-secs2-hsms-go/pkg/connection"
+)
+
+// Option is a option of the simulators.
+type Option func(*options)
+
+// options is the configuration of the simulators.
+type options struct {
+	clock  connection.Clock // clock of the timers
+	logger *log.Logger      // logger of the activities
+}
+
+// newOptions returns the options with the default values, applied with the opts.
+func newOptions(opts []Option) *options {
+	o := &options{
+		clock:  connection.NewSystemClock(),
+		logger: log.New(io.Discard, "", 0),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithClock returns a option that sets the clock of the timers, e.g. the periodic events.
+// The default is the system clock.
+func WithClock(clock connection.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithLogger returns a option that logs the activities of the simulator, e.g. the matched rules
+// and the errors, with the logger. The activities are not logged by default.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}